@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -15,6 +16,11 @@ type PauseAllAlertsResponse struct {
 
 // CreateUser creates a Grafana user.
 func (c *Client) CreateUser(user User) (int64, error) {
+	return c.CreateUserContext(context.Background(), user)
+}
+
+// CreateUserContext is like CreateUser but takes a context.
+func (c *Client) CreateUserContext(ctx context.Context, user User) (int64, error) {
 	id := int64(0)
 	data, err := json.Marshal(user)
 	if err != nil {
@@ -25,7 +31,7 @@ func (c *Client) CreateUser(user User) (int64, error) {
 		Id int64 `json:"id"`
 	}{}
 
-	err = c.request("POST", "/api/admin/users", nil, bytes.NewBuffer(data), &created)
+	err = c.request(ctx, "POST", "/api/admin/users", nil, bytes.NewBuffer(data), &created)
 	if err != nil {
 		return id, err
 	}
@@ -35,11 +41,21 @@ func (c *Client) CreateUser(user User) (int64, error) {
 
 // DeleteUser deletes a Grafana user.
 func (c *Client) DeleteUser(id int64) error {
-	return c.request("DELETE", fmt.Sprintf("/api/admin/users/%d", id), nil, nil, nil)
+	return c.DeleteUserContext(context.Background(), id)
+}
+
+// DeleteUserContext is like DeleteUser but takes a context.
+func (c *Client) DeleteUserContext(ctx context.Context, id int64) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("/api/admin/users/%d", id), nil, nil, nil)
 }
 
 // PauseAllAlerts pauses all Grafana alerts.
 func (c *Client) PauseAllAlerts() (PauseAllAlertsResponse, error) {
+	return c.PauseAllAlertsContext(context.Background())
+}
+
+// PauseAllAlertsContext is like PauseAllAlerts but takes a context.
+func (c *Client) PauseAllAlertsContext(ctx context.Context) (PauseAllAlertsResponse, error) {
 	result := PauseAllAlertsResponse{}
 	data, err := json.Marshal(PauseAlertRequest{
 		Paused: true,
@@ -48,7 +64,7 @@ func (c *Client) PauseAllAlerts() (PauseAllAlertsResponse, error) {
 		return result, err
 	}
 
-	err = c.request("POST", "/api/admin/pause-all-alerts", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/admin/pause-all-alerts", nil, bytes.NewBuffer(data), &result)
 
 	return result, err
 }
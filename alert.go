@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -36,8 +37,13 @@ type PauseAlertResponse struct {
 
 // Alerts fetches the annotations queried with the params it's passed.
 func (c *Client) Alerts(params url.Values) ([]Alert, error) {
+	return c.AlertsContext(context.Background(), params)
+}
+
+// AlertsContext is like Alerts but takes a context.
+func (c *Client) AlertsContext(ctx context.Context, params url.Values) ([]Alert, error) {
 	result := []Alert{}
-	err := c.request("GET", "/api/alerts", params, nil, &result)
+	err := c.request(ctx, "GET", "/api/alerts", params, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -47,9 +53,14 @@ func (c *Client) Alerts(params url.Values) ([]Alert, error) {
 
 // Alert fetches and returns an individual Grafana alert.
 func (c *Client) Alert(id int64) (Alert, error) {
+	return c.AlertContext(context.Background(), id)
+}
+
+// AlertContext is like Alert but takes a context.
+func (c *Client) AlertContext(ctx context.Context, id int64) (Alert, error) {
 	path := fmt.Sprintf("/api/alerts/%d", id)
 	result := Alert{}
-	err := c.request("GET", path, nil, nil, &result)
+	err := c.request(ctx, "GET", path, nil, nil, &result)
 	if err != nil {
 		return result, err
 	}
@@ -59,7 +70,12 @@ func (c *Client) Alert(id int64) (Alert, error) {
 
 // PauseAlert pauses the Grafana alert whose ID it's passed.
 func (c *Client) PauseAlert(id int64) (PauseAlertResponse, error) {
-	path := fmt.Sprintf("/api/alerts/%d", id)
+	return c.PauseAlertContext(context.Background(), id)
+}
+
+// PauseAlertContext is like PauseAlert but takes a context.
+func (c *Client) PauseAlertContext(ctx context.Context, id int64) (PauseAlertResponse, error) {
+	path := fmt.Sprintf("/api/alerts/%d/pause", id)
 	result := PauseAlertResponse{}
 	data, err := json.Marshal(PauseAlertRequest{
 		Paused: true,
@@ -68,7 +84,7 @@ func (c *Client) PauseAlert(id int64) (PauseAlertResponse, error) {
 		return result, err
 	}
 
-	err = c.request("POST", path, nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", path, nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return result, err
 	}
@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -21,9 +22,14 @@ type AlertNotification struct {
 
 // AlertNotifications fetches and returns Grafana alert notifications.
 func (c *Client) AlertNotifications() ([]AlertNotification, error) {
+	return c.AlertNotificationsContext(context.Background())
+}
+
+// AlertNotificationsContext is like AlertNotifications but takes a context.
+func (c *Client) AlertNotificationsContext(ctx context.Context) ([]AlertNotification, error) {
 	alertnotifications := make([]AlertNotification, 0)
 
-	err := c.request("GET", "/api/alert-notifications/", nil, nil, &alertnotifications)
+	err := c.request(ctx, "GET", "/api/alert-notifications/", nil, nil, &alertnotifications)
 	if err != nil {
 		return nil, err
 	}
@@ -33,9 +39,14 @@ func (c *Client) AlertNotifications() ([]AlertNotification, error) {
 
 // AlertNotification fetches and returns a Grafana alert notification.
 func (c *Client) AlertNotification(id int64) (*AlertNotification, error) {
+	return c.AlertNotificationContext(context.Background(), id)
+}
+
+// AlertNotificationContext is like AlertNotification but takes a context.
+func (c *Client) AlertNotificationContext(ctx context.Context, id int64) (*AlertNotification, error) {
 	path := fmt.Sprintf("/api/alert-notifications/%d", id)
 	result := &AlertNotification{}
-	err := c.request("GET", path, nil, nil, result)
+	err := c.request(ctx, "GET", path, nil, nil, result)
 	if err != nil {
 		return nil, err
 	}
@@ -45,6 +56,11 @@ func (c *Client) AlertNotification(id int64) (*AlertNotification, error) {
 
 // NewAlertNotification creates a new Grafana alert notification.
 func (c *Client) NewAlertNotification(a *AlertNotification) (int64, error) {
+	return c.NewAlertNotificationContext(context.Background(), a)
+}
+
+// NewAlertNotificationContext is like NewAlertNotification but takes a context.
+func (c *Client) NewAlertNotificationContext(ctx context.Context, a *AlertNotification) (int64, error) {
 	data, err := json.Marshal(a)
 	if err != nil {
 		return 0, err
@@ -53,7 +69,7 @@ func (c *Client) NewAlertNotification(a *AlertNotification) (int64, error) {
 		Id int64 `json:"id"`
 	}{}
 
-	err = c.request("POST", "/api/alert-notifications", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/alert-notifications", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return 0, err
 	}
@@ -63,19 +79,29 @@ func (c *Client) NewAlertNotification(a *AlertNotification) (int64, error) {
 
 // UpdateAlertNotification updates a Grafana alert notification.
 func (c *Client) UpdateAlertNotification(a *AlertNotification) error {
+	return c.UpdateAlertNotificationContext(context.Background(), a)
+}
+
+// UpdateAlertNotificationContext is like UpdateAlertNotification but takes a context.
+func (c *Client) UpdateAlertNotificationContext(ctx context.Context, a *AlertNotification) error {
 	path := fmt.Sprintf("/api/alert-notifications/%d", a.Id)
 	data, err := json.Marshal(a)
 	if err != nil {
 		return err
 	}
-	err = c.request("PUT", path, nil, bytes.NewBuffer(data), nil)
+	err = c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), nil)
 
 	return err
 }
 
 // DeleteAlertNotification deletes a Grafana alert notification.
 func (c *Client) DeleteAlertNotification(id int64) error {
+	return c.DeleteAlertNotificationContext(context.Background(), id)
+}
+
+// DeleteAlertNotificationContext is like DeleteAlertNotification but takes a context.
+func (c *Client) DeleteAlertNotificationContext(ctx context.Context, id int64) error {
 	path := fmt.Sprintf("/api/alert-notifications/%d", id)
 
-	return c.request("DELETE", path, nil, nil, nil)
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
 }
@@ -2,9 +2,11 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
 )
 
 // Annotation represents a Grafana API Annotation
@@ -37,8 +39,13 @@ type GraphiteAnnotation struct {
 
 // Annotations fetches the annotations queried with the params it's passed
 func (c *Client) Annotations(params url.Values) ([]Annotation, error) {
+	return c.AnnotationsContext(context.Background(), params)
+}
+
+// AnnotationsContext is like Annotations but takes a context.
+func (c *Client) AnnotationsContext(ctx context.Context, params url.Values) ([]Annotation, error) {
 	result := []Annotation{}
-	err := c.request("GET", "/api/annotation", params, nil, &result)
+	err := c.request(ctx, "GET", "/api/annotations", params, nil, &result)
 	if err != nil {
 		return nil, err
 	}
@@ -46,8 +53,71 @@ func (c *Client) Annotations(params url.Values) ([]Annotation, error) {
 	return result, err
 }
 
+// ForEachAnnotation calls fn for each annotation matching the params it's
+// passed, from the newest to the oldest. The annotations API has no pages, so
+// successive requests move the "to" bound back past the annotations already seen.
+// Return ErrStopPaging from fn to stop early.
+func (c *Client) ForEachAnnotation(params url.Values, fn func(Annotation) error) error {
+	return c.ForEachAnnotationContext(context.Background(), params, fn)
+}
+
+// ForEachAnnotationContext is like ForEachAnnotation but takes a context.
+func (c *Client) ForEachAnnotationContext(ctx context.Context, params url.Values, fn func(Annotation) error) error {
+	limit := c.perPage()
+	seen := map[int64]bool{}
+	query := url.Values{}
+	for k, v := range params {
+		query[k] = v
+	}
+
+	return paginate(ctx, func(page int) (bool, error) {
+		query.Set("limit", strconv.Itoa(limit))
+		result := []Annotation{}
+		err := c.request(ctx, "GET", "/api/annotations", query, nil, &result)
+		if err != nil {
+			return false, err
+		}
+
+		added := 0
+		oldest := int64(0)
+		for _, a := range result {
+			if oldest == 0 || a.Time < oldest {
+				oldest = a.Time
+			}
+			if seen[a.ID] {
+				continue
+			}
+			seen[a.ID] = true
+			added++
+			if err := fn(a); err != nil {
+				return false, err
+			}
+		}
+		full := len(result) >= limit
+
+		// Annotations at the oldest time are requested again, as more of them
+		// may not have fit in this page, and skipped when already seen. A full
+		// page holding nothing new only has annotations at that time, so the
+		// next one is made larger until it holds them all.
+		switch {
+		case added > 0:
+			limit = c.perPage()
+			query.Set("to", strconv.FormatInt(oldest, 10))
+		case full:
+			limit *= 2
+		}
+
+		return full, nil
+	})
+}
+
 // NewAnnotation creates a new annotation with the Annotation it is passed
 func (c *Client) NewAnnotation(a *Annotation) (int64, error) {
+	return c.NewAnnotationContext(context.Background(), a)
+}
+
+// NewAnnotationContext is like NewAnnotation but takes a context.
+func (c *Client) NewAnnotationContext(ctx context.Context, a *Annotation) (int64, error) {
 	data, err := json.Marshal(a)
 	if err != nil {
 		return 0, err
@@ -57,7 +127,7 @@ func (c *Client) NewAnnotation(a *Annotation) (int64, error) {
 		ID int64 `json:"id"`
 	}{}
 
-	err = c.request("POST", "/api/annotations", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/annotations", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return 0, err
 	}
@@ -67,6 +137,11 @@ func (c *Client) NewAnnotation(a *Annotation) (int64, error) {
 
 // NewGraphiteAnnotation creates a new annotation with the GraphiteAnnotation it is passed
 func (c *Client) NewGraphiteAnnotation(gfa *GraphiteAnnotation) (int64, error) {
+	return c.NewGraphiteAnnotationContext(context.Background(), gfa)
+}
+
+// NewGraphiteAnnotationContext is like NewGraphiteAnnotation but takes a context.
+func (c *Client) NewGraphiteAnnotationContext(ctx context.Context, gfa *GraphiteAnnotation) (int64, error) {
 	data, err := json.Marshal(gfa)
 	if err != nil {
 		return 0, err
@@ -76,7 +151,7 @@ func (c *Client) NewGraphiteAnnotation(gfa *GraphiteAnnotation) (int64, error) {
 		ID int64 `json:"id"`
 	}{}
 
-	err = c.request("POST", "/api/annotations/graphite", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/annotations/graphite", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return 0, err
 	}
@@ -86,6 +161,11 @@ func (c *Client) NewGraphiteAnnotation(gfa *GraphiteAnnotation) (int64, error) {
 
 // UpdateAnnotation updates all properties an existing annotation with the Annotation it is passed.
 func (c *Client) UpdateAnnotation(id int64, a *Annotation) (string, error) {
+	return c.UpdateAnnotationContext(context.Background(), id, a)
+}
+
+// UpdateAnnotationContext is like UpdateAnnotation but takes a context.
+func (c *Client) UpdateAnnotationContext(ctx context.Context, id int64, a *Annotation) (string, error) {
 	path := fmt.Sprintf("/api/annotations/%d", id)
 	data, err := json.Marshal(a)
 	if err != nil {
@@ -96,7 +176,7 @@ func (c *Client) UpdateAnnotation(id int64, a *Annotation) (string, error) {
 		Message string `json:"message"`
 	}{}
 
-	err = c.request("PUT", path, nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return "", err
 	}
@@ -106,6 +186,11 @@ func (c *Client) UpdateAnnotation(id int64, a *Annotation) (string, error) {
 
 // PatchAnnotation updates one or more properties of an existing annotation that matches the specified ID.
 func (c *Client) PatchAnnotation(id int64, a *Annotation) (string, error) {
+	return c.PatchAnnotationContext(context.Background(), id, a)
+}
+
+// PatchAnnotationContext is like PatchAnnotation but takes a context.
+func (c *Client) PatchAnnotationContext(ctx context.Context, id int64, a *Annotation) (string, error) {
 	path := fmt.Sprintf("/api/annotations/%d", id)
 	data, err := json.Marshal(a)
 	if err != nil {
@@ -116,7 +201,7 @@ func (c *Client) PatchAnnotation(id int64, a *Annotation) (string, error) {
 		Message string `json:"message"`
 	}{}
 
-	err = c.request("PATCH", path, nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "PATCH", path, nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return "", err
 	}
@@ -126,12 +211,17 @@ func (c *Client) PatchAnnotation(id int64, a *Annotation) (string, error) {
 
 // DeleteAnnotation deletes the annotation of the ID it is passed
 func (c *Client) DeleteAnnotation(id int64) (string, error) {
+	return c.DeleteAnnotationContext(context.Background(), id)
+}
+
+// DeleteAnnotationContext is like DeleteAnnotation but takes a context.
+func (c *Client) DeleteAnnotationContext(ctx context.Context, id int64) (string, error) {
 	path := fmt.Sprintf("/api/annotations/%d", id)
 	result := struct {
 		Message string `json:"message"`
 	}{}
 
-	err := c.request("DELETE", path, nil, bytes.NewBuffer(nil), &result)
+	err := c.request(ctx, "DELETE", path, nil, bytes.NewBuffer(nil), &result)
 	if err != nil {
 		return "", err
 	}
@@ -141,12 +231,17 @@ func (c *Client) DeleteAnnotation(id int64) (string, error) {
 
 // DeleteAnnotationByRegionID deletes the annotation corresponding to the region ID it is passed
 func (c *Client) DeleteAnnotationByRegionID(id int64) (string, error) {
+	return c.DeleteAnnotationByRegionIDContext(context.Background(), id)
+}
+
+// DeleteAnnotationByRegionIDContext is like DeleteAnnotationByRegionID but takes a context.
+func (c *Client) DeleteAnnotationByRegionIDContext(ctx context.Context, id int64) (string, error) {
 	path := fmt.Sprintf("/api/annotations/region/%d", id)
 	result := struct {
 		Message string `json:"message"`
 	}{}
 
-	err := c.request("DELETE", path, nil, bytes.NewBuffer(nil), &result)
+	err := c.request(ctx, "DELETE", path, nil, bytes.NewBuffer(nil), &result)
 	if err != nil {
 		return "", err
 	}
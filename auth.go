@@ -0,0 +1,243 @@
+package gapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+)
+
+// AuthProxyHeader is the header Grafana's auth proxy reads the username from by default.
+const AuthProxyHeader = "X-WEBAUTH-USER"
+
+// Authenticator adds credentials to the requests made by the client.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// AuthenticatorFunc adapts an ordinary function to the Authenticator interface.
+type AuthenticatorFunc func(req *http.Request) error
+
+// Authenticate calls f(req).
+func (f AuthenticatorFunc) Authenticate(req *http.Request) error {
+	return f(req)
+}
+
+// clientBinder is implemented by authenticators that make requests of their
+// own and so need to know about the client they're used by.
+type clientBinder interface {
+	bind(baseURL url.URL, httpClient *http.Client, headers http.Header) error
+}
+
+// BasicAuth authenticates requests with a username and password.
+func BasicAuth(username, password string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.SetBasicAuth(username, password)
+		return nil
+	})
+}
+
+// TokenAuth authenticates requests with an API key or service account token.
+func TokenAuth(token string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		return nil
+	})
+}
+
+// AuthProxy authenticates requests as the user whose username it's passed,
+// for Grafana instances running behind an authenticating proxy.
+// The username is sent in the X-WEBAUTH-USER header.
+func AuthProxy(username string) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) error {
+		req.Header.Set(AuthProxyHeader, username)
+		return nil
+	})
+}
+
+// OAuth2Token is an OAuth2 access token.
+type OAuth2Token struct {
+	AccessToken string
+	TokenType   string
+	// Expiry is when the token expires. A zero value means it never does.
+	Expiry time.Time
+}
+
+// TokenSource supplies OAuth2 tokens. golang.org/x/oauth2 token sources can
+// be adapted with a few lines of code.
+type TokenSource interface {
+	Token() (*OAuth2Token, error)
+}
+
+// TokenSourceFunc adapts an ordinary function to the TokenSource interface.
+type TokenSourceFunc func() (*OAuth2Token, error)
+
+// Token calls f().
+func (f TokenSourceFunc) Token() (*OAuth2Token, error) {
+	return f()
+}
+
+// oauth2ExpiryDelta is how long before its expiry a token is refreshed.
+const oauth2ExpiryDelta = 10 * time.Second
+
+type oauth2Auth struct {
+	source TokenSource
+
+	mu    sync.Mutex
+	token *OAuth2Token
+}
+
+// OAuth2Auth authenticates requests with tokens from the TokenSource it's
+// passed. Tokens are cached, and fetched again shortly before they expire.
+func OAuth2Auth(source TokenSource) Authenticator {
+	return &oauth2Auth{source: source}
+}
+
+func (a *oauth2Auth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token == nil || (!a.token.Expiry.IsZero() && time.Until(a.token.Expiry) < oauth2ExpiryDelta) {
+		token, err := a.source.Token()
+		if err != nil {
+			return err
+		}
+		if token == nil || token.AccessToken == "" {
+			return errors.New("oauth2: token source returned no access token")
+		}
+		a.token = token
+	}
+
+	tokenType := a.token.TokenType
+	if tokenType == "" {
+		tokenType = "Bearer"
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s", tokenType, a.token.AccessToken))
+	return nil
+}
+
+type sessionAuth struct {
+	username string
+	password string
+
+	mu         sync.Mutex
+	loginURL   string
+	httpClient *http.Client
+	headers    http.Header
+	cookies    []*http.Cookie
+	expires    time.Time
+}
+
+// SessionAuth authenticates requests with a session cookie, obtained by
+// logging in through /login with a username and password.
+// The login happens on the first request, and again once the session expires.
+// A SessionAuth holds the session of a single client, and can't be passed to
+// NewWithOptions twice; clients made by WithOrgID share it.
+func SessionAuth(username, password string) Authenticator {
+	return &sessionAuth{username: username, password: password}
+}
+
+func (a *sessionAuth) bind(baseURL url.URL, httpClient *http.Client, headers http.Header) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.httpClient != nil {
+		return errors.New("session auth is already used by another client")
+	}
+	baseURL.Path = path.Join(baseURL.Path, "/login")
+	baseURL.RawQuery = ""
+	a.loginURL = baseURL.String()
+	a.httpClient = httpClient
+	a.headers = headers
+	return nil
+}
+
+func (a *sessionAuth) Authenticate(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cookies == nil || (!a.expires.IsZero() && time.Now().After(a.expires)) {
+		if err := a.login(req); err != nil {
+			return err
+		}
+	}
+
+	for _, cookie := range a.cookies {
+		req.AddCookie(cookie)
+	}
+	return nil
+}
+
+// reset discards the session, so that the next request logs in again.
+func (a *sessionAuth) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.cookies = nil
+}
+
+func (a *sessionAuth) login(req *http.Request) error {
+	if a.httpClient == nil {
+		return errors.New("session auth is not bound to a client")
+	}
+
+	data, err := json.Marshal(map[string]string{
+		"user":     a.username,
+		"password": a.password,
+	})
+	if err != nil {
+		return err
+	}
+
+	loginReq, err := http.NewRequestWithContext(req.Context(), "POST", a.loginURL, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	for k, v := range a.headers {
+		loginReq.Header[k] = append([]string(nil), v...)
+	}
+	if orgID := req.Header.Get("X-Grafana-Org-Id"); orgID != "" {
+		loginReq.Header.Set("X-Grafana-Org-Id", orgID)
+	}
+	loginReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.httpClient.Do(loginReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 400 {
+		return newAPIError("POST", "/login", resp.StatusCode, body)
+	}
+
+	cookies := resp.Cookies()
+	if len(cookies) == 0 {
+		return errors.New("login response did not set a session cookie")
+	}
+
+	a.cookies = make([]*http.Cookie, 0, len(cookies))
+	a.expires = time.Time{}
+	for _, cookie := range cookies {
+		a.cookies = append(a.cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+
+		expires := cookie.Expires
+		if cookie.MaxAge > 0 {
+			expires = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+		}
+		if !expires.IsZero() && (a.expires.IsZero() || expires.Before(a.expires)) {
+			a.expires = expires
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuthenticators(t *testing.T) {
+	for name, tc := range map[string]struct {
+		auth   Authenticator
+		header string
+		value  string
+	}{
+		"basic":  {BasicAuth("admin", "pa:ss"), "Authorization", "Basic YWRtaW46cGE6c3M="},
+		"token":  {TokenAuth("glsa_abc:def"), "Authorization", "Bearer glsa_abc:def"},
+		"proxy":  {AuthProxy("admin"), AuthProxyHeader, "admin"},
+		"custom": {AuthenticatorFunc(func(r *http.Request) error { r.Header.Set("X-Key", "v"); return nil }), "X-Key", "v"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client, err := NewWithOptions("http://my-grafana.com", WithAuth(tc.auth))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, err := client.newRequest(context.Background(), "GET", "/api/org", nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Header.Get(tc.header) != tc.value {
+				t.Errorf("expected %s header: %s; got: %s", tc.header, tc.value, req.Header.Get(tc.header))
+			}
+		})
+	}
+}
+
+func TestOAuth2Auth(t *testing.T) {
+	calls := 0
+	source := TokenSourceFunc(func() (*OAuth2Token, error) {
+		calls++
+		if calls == 3 {
+			return nil, errors.New("refresh failed")
+		}
+		return &OAuth2Token{
+			AccessToken: "token",
+			Expiry:      time.Now().Add(time.Second),
+		}, nil
+	})
+	auth := OAuth2Auth(source)
+
+	req, _ := http.NewRequest("GET", "http://my-grafana.com", nil)
+	if err := auth.Authenticate(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected bearer token; got: %s", req.Header.Get("Authorization"))
+	}
+
+	// The token expires within the refresh delta, so each call refreshes it.
+	if err := auth.Authenticate(req); err != nil {
+		t.Fatal(err)
+	}
+	if err := auth.Authenticate(req); err == nil {
+		t.Error("expected refresh error")
+	}
+	if calls != 3 {
+		t.Errorf("expected 3 token fetches; got: %d", calls)
+	}
+}
+
+func TestSessionAuth(t *testing.T) {
+	logins := 0
+	var login *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/grafana/login" {
+			logins++
+			login = r
+			http.SetCookie(w, &http.Cookie{Name: "grafana_session", Value: "abc", MaxAge: 3600})
+			w.Write([]byte(`{"message":"Logged in"}`))
+			return
+		}
+		if cookie, err := r.Cookie("grafana_session"); err != nil || cookie.Value != "abc" {
+			w.WriteHeader(401)
+			w.Write([]byte(`{"message":"Unauthorized"}`))
+			return
+		}
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	auth := SessionAuth("admin", "admin")
+	client, err := NewWithOptions(server.URL+"/grafana", WithAuth(auth), WithHeader("X-Team", "sre"), WithOrgID(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Orgs(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("expected 1 login; got: %d", logins)
+	}
+	if login.Header.Get("X-Team") != "sre" || login.Header.Get("X-Grafana-Org-Id") != "2" {
+		t.Errorf("expected the login to have the client headers; got: %v", login.Header)
+	}
+
+	// The session belongs to the first client.
+	if _, err := NewWithOptions(server.URL, WithAuth(auth)); err == nil {
+		t.Error("expected an error binding the session to a second client")
+	}
+}
@@ -0,0 +1,214 @@
+package backup
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Files of a snapshot, in a directory or tar archive.
+const (
+	manifestFile           = "manifest.json"
+	preferencesFile        = "preferences.json"
+	foldersFile            = "folders.json"
+	dataSourcesFile        = "datasources.json"
+	alertNotificationsFile = "alert-notifications.json"
+	playlistsFile          = "playlists.json"
+	teamsFile              = "teams.json"
+	annotationsFile        = "annotations.json"
+	dashboardsDir          = "dashboards"
+)
+
+// files encodes the snapshot as files, by slash-separated name.
+func (s *Snapshot) files() (map[string][]byte, error) {
+	files := map[string][]byte{}
+	add := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		files[name] = append(data, '\n')
+		return nil
+	}
+
+	entries := map[string]interface{}{
+		manifestFile:           s.Manifest,
+		preferencesFile:        s.Preferences,
+		foldersFile:            s.Folders,
+		dataSourcesFile:        s.DataSources,
+		alertNotificationsFile: s.AlertNotifications,
+		playlistsFile:          s.Playlists,
+		teamsFile:              s.Teams,
+		annotationsFile:        s.Annotations,
+	}
+	for name, v := range entries {
+		if err := add(name, v); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range s.Dashboards {
+		uid, _ := d.Model["uid"].(string)
+		if uid == "" {
+			uid = fmt.Sprintf("id-%d", d.Id)
+		}
+		if err := add(path.Join(dashboardsDir, uid+".json"), d); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// fromFiles decodes a snapshot from its files, by slash-separated name.
+func fromFiles(files map[string][]byte) (*Snapshot, error) {
+	s := &Snapshot{}
+	if _, ok := files[manifestFile]; !ok {
+		return nil, errors.New("not a snapshot: missing " + manifestFile)
+	}
+
+	entries := map[string]interface{}{
+		manifestFile:           &s.Manifest,
+		preferencesFile:        &s.Preferences,
+		foldersFile:            &s.Folders,
+		dataSourcesFile:        &s.DataSources,
+		alertNotificationsFile: &s.AlertNotifications,
+		playlistsFile:          &s.Playlists,
+		teamsFile:              &s.Teams,
+		annotationsFile:        &s.Annotations,
+	}
+	for name, v := range entries {
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	var names []string
+	for name := range files {
+		if strings.HasPrefix(name, dashboardsDir+"/") && strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := Dashboard{}
+		if err := json.Unmarshal(files[name], &d); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		s.Dashboards = append(s.Dashboards, d)
+	}
+	return s, nil
+}
+
+// WriteDir writes the snapshot to a directory, created if needed.
+func (s *Snapshot) WriteDir(dir string) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	for name, data := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(p, data, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadDir reads a snapshot written by WriteDir.
+func ReadDir(dir string) (*Snapshot, error) {
+	files := map[string][]byte{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fromFiles(files)
+}
+
+// WriteTar writes the snapshot as a tar archive. Wrap w in a gzip.Writer
+// to compress it.
+func (s *Snapshot) WriteTar(w io.Writer) error {
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tar.NewWriter(w)
+	modTime := s.Manifest.Created
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: modTime,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(files[name]); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ReadTar reads a snapshot written by WriteTar.
+func ReadTar(r io.Reader) (*Snapshot, error) {
+	files := map[string][]byte{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Clean(header.Name)] = data
+	}
+	return fromFiles(files)
+}
@@ -0,0 +1,277 @@
+// Package backup snapshots a Grafana org and restores snapshots into the
+// same org or another one.
+//
+//	snapshot, err := backup.Backup(ctx, client, backup.Options{Versions: true})
+//	...
+//	err = snapshot.WriteDir("backups/2021-06-01")
+//
+//	snapshot, err := backup.ReadDir("backups/2021-06-01")
+//	...
+//	report, err := backup.Restore(ctx, client.WithOrgID(2), snapshot, backup.RestoreOptions{})
+//
+// Grafana never returns datasource secrets, so snapshots hold placeholders
+// for them, resolved on restore with RestoreOptions.Secrets.
+package backup
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// FormatVersion is the version of the snapshot format written by this package.
+const FormatVersion = 1
+
+// Snapshot is the content of a Grafana org.
+type Snapshot struct {
+	Manifest           Manifest
+	Preferences        *gapi.Preferences
+	Folders            []Folder
+	Dashboards         []Dashboard
+	DataSources        []DataSource
+	AlertNotifications []gapi.AlertNotification
+	Playlists          []gapi.Playlist
+	Teams              []Team
+	Annotations        []gapi.Annotation
+}
+
+// Manifest describes a snapshot.
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Versions is set when the snapshot holds dashboard versions.
+	Versions bool `json:"versions"`
+}
+
+// Folder is a folder and its permissions.
+type Folder struct {
+	Folder      gapi.Folder              `json:"folder"`
+	Permissions []*gapi.FolderPermission `json:"permissions"`
+}
+
+// Dashboard is a dashboard and, optionally, its previous versions.
+type Dashboard struct {
+	// Id is the ID of the dashboard in the org it was backed up from.
+	Id        int64                  `json:"id"`
+	FolderUid string                 `json:"folderUid"`
+	Model     map[string]interface{} `json:"dashboard"`
+	// Versions are the versions of the dashboard, oldest first.
+	Versions []gapi.DashboardVersion `json:"versions,omitempty"`
+}
+
+// DataSource is a datasource and placeholders for its secrets, which
+// Grafana never returns.
+type DataSource struct {
+	DataSource gapi.DataSource `json:"datasource"`
+	// SecureJSONData maps the secure fields set in the datasource, whatever
+	// its plugin, to their placeholder.
+	SecureJSONData map[string]string `json:"secureJsonData,omitempty"`
+}
+
+// Team is a team, its members and its preferences.
+type Team struct {
+	Team        gapi.Team          `json:"team"`
+	Members     []*gapi.TeamMember `json:"members"`
+	Preferences *gapi.Preferences  `json:"preferences,omitempty"`
+}
+
+// Options controls what's backed up.
+type Options struct {
+	// Versions backs up the version history of dashboards.
+	Versions bool
+}
+
+// SecretPlaceholder returns the placeholder standing for a secure field of
+// a datasource in snapshots, such as ${secret:Prometheus/basicAuthPassword}.
+func SecretPlaceholder(dataSource, field string) string {
+	return fmt.Sprintf("${secret:%s/%s}", dataSource, field)
+}
+
+// Backup reads the content of the org the client is scoped to.
+func Backup(ctx context.Context, client *gapi.Client, opts Options) (*Snapshot, error) {
+	s := &Snapshot{
+		Manifest: Manifest{
+			Version:  FormatVersion,
+			Created:  time.Now().UTC(),
+			Versions: opts.Versions,
+		},
+	}
+
+	steps := []struct {
+		name string
+		fn   func(context.Context, *gapi.Client, Options) error
+	}{
+		{"preferences", s.backupPreferences},
+		{"folders", s.backupFolders},
+		{"dashboards", s.backupDashboards},
+		{"datasources", s.backupDataSources},
+		{"alert notifications", s.backupAlertNotifications},
+		{"playlists", s.backupPlaylists},
+		{"teams", s.backupTeams},
+		{"annotations", s.backupAnnotations},
+	}
+	for _, step := range steps {
+		if err := step.fn(ctx, client, opts); err != nil {
+			return nil, fmt.Errorf("backing up %s: %w", step.name, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *Snapshot) backupPreferences(ctx context.Context, client *gapi.Client, _ Options) error {
+	preferences, err := client.OrgPreferencesContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.Preferences = preferences
+	return nil
+}
+
+func (s *Snapshot) backupFolders(ctx context.Context, client *gapi.Client, _ Options) error {
+	folders, err := client.FoldersContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.Folders = make([]Folder, 0, len(folders))
+	for _, f := range folders {
+		permissions, err := client.FolderPermissionsContext(ctx, f.Uid)
+		if err != nil {
+			return fmt.Errorf("folder %q: %w", f.Uid, err)
+		}
+		s.Folders = append(s.Folders, Folder{Folder: f, Permissions: permissions})
+	}
+	return nil
+}
+
+func (s *Snapshot) backupDashboards(ctx context.Context, client *gapi.Client, opts Options) error {
+	results, err := client.SearchDashboardsContext(ctx, gapi.SearchQuery{Type: gapi.SearchTypeDashboard})
+	if err != nil {
+		return err
+	}
+
+	s.Dashboards = make([]Dashboard, 0, len(results))
+	for _, r := range results {
+		dashboard, err := client.DashboardByUidContext(ctx, r.Uid)
+		if err != nil {
+			return fmt.Errorf("dashboard %q: %w", r.Uid, err)
+		}
+		d := Dashboard{
+			Id:        int64(r.Id),
+			FolderUid: r.FolderUid,
+			Model:     dashboard.Model,
+		}
+
+		if opts.Versions {
+			versions, err := client.DashboardVersionsContext(ctx, d.Id, nil)
+			if err != nil {
+				return fmt.Errorf("dashboard %q versions: %w", r.Uid, err)
+			}
+			for i := len(versions) - 1; i >= 0; i-- {
+				version, err := client.DashboardVersionContext(ctx, d.Id, versions[i].Id)
+				if err != nil {
+					return fmt.Errorf("dashboard %q version %d: %w", r.Uid, versions[i].Version, err)
+				}
+				d.Versions = append(d.Versions, *version)
+			}
+		}
+
+		s.Dashboards = append(s.Dashboards, d)
+	}
+	return nil
+}
+
+func (s *Snapshot) backupDataSources(ctx context.Context, client *gapi.Client, _ Options) error {
+	list, err := client.DataSourcesContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.DataSources = make([]DataSource, 0, len(list))
+	for _, item := range list {
+		// Only single datasources list their secure fields.
+		ds, err := client.DataSourceContext(ctx, item.Id)
+		if err != nil {
+			return fmt.Errorf("datasource %q: %w", item.Name, err)
+		}
+		ds.SecureJSONData = gapi.SecureJSONData{}
+		s.DataSources = append(s.DataSources, DataSource{
+			DataSource:     *ds,
+			SecureJSONData: secretPlaceholders(ds),
+		})
+	}
+	return nil
+}
+
+// secretPlaceholders returns the placeholders of the secure fields set in
+// a datasource.
+func secretPlaceholders(ds *gapi.DataSource) map[string]string {
+	placeholders := map[string]string{}
+	for field, set := range ds.SecureJSONFields {
+		if set {
+			placeholders[field] = SecretPlaceholder(ds.Name, field)
+		}
+	}
+	return placeholders
+}
+
+func (s *Snapshot) backupAlertNotifications(ctx context.Context, client *gapi.Client, _ Options) error {
+	notifications, err := client.AlertNotificationsContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.AlertNotifications = notifications
+	return nil
+}
+
+func (s *Snapshot) backupPlaylists(ctx context.Context, client *gapi.Client, _ Options) error {
+	list, err := client.PlaylistsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.Playlists = make([]gapi.Playlist, 0, len(list))
+	for _, item := range list {
+		playlist, err := client.PlaylistContext(ctx, item.Id)
+		if err != nil {
+			return fmt.Errorf("playlist %q: %w", item.Name, err)
+		}
+		s.Playlists = append(s.Playlists, *playlist)
+	}
+	return nil
+}
+
+func (s *Snapshot) backupTeams(ctx context.Context, client *gapi.Client, _ Options) error {
+	s.Teams = []Team{}
+	return client.ForEachTeamContext(ctx, "", func(team *gapi.Team) error {
+		members, err := client.TeamMembersContext(ctx, team.Id)
+		if err != nil {
+			return fmt.Errorf("team %q members: %w", team.Name, err)
+		}
+		preferences, err := client.TeamPreferencesContext(ctx, team.Id)
+		if err != nil {
+			return fmt.Errorf("team %q preferences: %w", team.Name, err)
+		}
+		s.Teams = append(s.Teams, Team{Team: *team, Members: members, Preferences: preferences})
+		return nil
+	})
+}
+
+func (s *Snapshot) backupAnnotations(ctx context.Context, client *gapi.Client, _ Options) error {
+	// Alert annotations are recorded by Grafana, so only back up the others.
+	params := url.Values{"type": {"annotation"}}
+	s.Annotations = []gapi.Annotation{}
+	return client.ForEachAnnotationContext(ctx, params, func(a gapi.Annotation) error {
+		s.Annotations = append(s.Annotations, a)
+		return nil
+	})
+}
+
+// isSecretPlaceholder reports whether v is a placeholder made by SecretPlaceholder.
+func isSecretPlaceholder(v string) bool {
+	return strings.HasPrefix(v, "${secret:") && strings.HasSuffix(v, "}")
+}
@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+	"github.com/nytm/go-grafana-api/internal/routetest"
+)
+
+func TestBackup(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"GET /api/org/preferences":             `{"theme":"dark","homeDashboardId":7,"timezone":"utc"}`,
+		"GET /api/folders":                     `[{"id":3,"uid":"ops","title":"Ops"}]`,
+		"GET /api/folders/ops/permissions":     `[{"uid":"ops","teamId":4,"team":"SRE","permission":2},{"userId":9,"userLogin":"jane","permission":4}]`,
+		"GET /api/search":                      `[{"id":7,"uid":"home","title":"Home","type":"dash-db","folderId":3,"folderUid":"ops"}]`,
+		"GET /api/dashboards/uid/home":         `{"meta":{},"dashboard":{"id":7,"uid":"home","title":"Home","version":2}}`,
+		"GET /api/dashboards/id/7/versions":    `[{"id":21,"version":2},{"id":20,"version":1}]`,
+		"GET /api/dashboards/id/7/versions/20": `{"id":20,"version":1,"message":"first","data":{"uid":"home","title":"Old home"}}`,
+		"GET /api/dashboards/id/7/versions/21": `{"id":21,"version":2,"data":{"uid":"home","title":"Home"}}`,
+		"GET /api/datasources":                 `[{"id":1,"name":"Prometheus","type":"prometheus"}]`,
+		"GET /api/datasources/1":               `{"id":1,"name":"Prometheus","type":"prometheus","basicAuth":true,"secureJsonFields":{"basicAuthPassword":true,"httpHeaderValue1":true}}`,
+		"GET /api/alert-notifications":         `[{"id":2,"uid":"slack","name":"Slack","type":"slack"}]`,
+		"GET /api/playlists":                   `[{"id":5,"name":"Wall","interval":"5m"}]`,
+		"GET /api/playlists/5":                 `{"id":5,"name":"Wall","interval":"5m","items":[{"type":"dashboard_by_id","value":"7","order":1}]}`,
+		"GET /api/teams/search":                `{"totalCount":1,"teams":[{"id":4,"name":"SRE"}],"page":1,"perPage":1000}`,
+		"GET /api/teams/4/members":             `[{"teamId":4,"userId":9,"login":"jane"}]`,
+		"GET /api/teams/4/preferences":         `{"homeDashboardId":7}`,
+		"GET /api/annotations":                 `[{"id":11,"dashboardId":7,"panelId":1,"time":1000,"text":"deploy"}]`,
+	}}
+	client, stop := routetest.NewClient(t, routes)
+	defer stop()
+
+	s, err := Backup(context.Background(), client, Options{Versions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Manifest.Version != FormatVersion || !s.Manifest.Versions {
+		t.Errorf("Unexpected manifest: %#v", s.Manifest)
+	}
+	if len(s.Folders) != 1 || len(s.Folders[0].Permissions) != 2 || s.Folders[0].Permissions[1].UserLogin != "jane" {
+		t.Errorf("Unexpected folders: %#v", s.Folders)
+	}
+	if len(s.Dashboards) != 1 {
+		t.Fatalf("Unexpected dashboards: %#v", s.Dashboards)
+	}
+	d := s.Dashboards[0]
+	if d.Id != 7 || d.FolderUid != "ops" || len(d.Versions) != 2 || d.Versions[0].Version != 1 || d.Versions[0].Data["title"] != "Old home" {
+		t.Errorf("Unexpected dashboard: %#v", d)
+	}
+	// Secure fields keep their placeholder, whatever the plugin.
+	if len(s.DataSources) != 1 || s.DataSources[0].DataSource.Name != "Prometheus" ||
+		s.DataSources[0].SecureJSONData["basicAuthPassword"] != "${secret:Prometheus/basicAuthPassword}" ||
+		s.DataSources[0].SecureJSONData["httpHeaderValue1"] != "${secret:Prometheus/httpHeaderValue1}" {
+		t.Errorf("Unexpected datasources: %#v", s.DataSources)
+	}
+	if len(s.Playlists) != 1 || len(s.Playlists[0].Items) != 1 {
+		t.Errorf("Unexpected playlists: %#v", s.Playlists)
+	}
+	if len(s.Teams) != 1 || len(s.Teams[0].Members) != 1 || s.Teams[0].Preferences.HomeDashboardId != 7 {
+		t.Errorf("Unexpected teams: %#v", s.Teams)
+	}
+	if len(s.Annotations) != 1 || len(s.AlertNotifications) != 1 || s.Preferences.HomeDashboardId != 7 {
+		t.Errorf("Unexpected snapshot: %#v", s)
+	}
+	if r := routes.Find("GET", "/api/annotations"); len(r) == 0 {
+		t.Error("Annotations not fetched")
+	}
+}
+
+func testSnapshot() *Snapshot {
+	return &Snapshot{
+		Manifest:    Manifest{Version: FormatVersion, Created: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)},
+		Preferences: &gapi.Preferences{Theme: "dark", HomeDashboardId: 7},
+		Folders: []Folder{{
+			Folder: gapi.Folder{Id: 3, Uid: "ops", Title: "Ops"},
+			Permissions: []*gapi.FolderPermission{
+				{TeamId: 4, Team: "SRE", Permission: gapi.PermissionEdit},
+				{UserId: 9, UserLogin: "jane", Permission: gapi.PermissionAdmin},
+				{UserId: 10, UserLogin: "gone", Permission: gapi.PermissionView},
+			},
+		}},
+		Dashboards: []Dashboard{{
+			Id:        7,
+			FolderUid: "ops",
+			Model:     map[string]interface{}{"id": float64(7), "uid": "home", "title": "Home", "version": float64(2)},
+			Versions: []gapi.DashboardVersion{
+				{Version: 1, Message: "first", Data: map[string]interface{}{"uid": "home", "title": "Old home"}},
+				{Version: 2, Data: map[string]interface{}{"uid": "home", "title": "Home"}},
+			},
+		}},
+		DataSources: []DataSource{{
+			DataSource: gapi.DataSource{
+				Id:               1,
+				Name:             "Prometheus",
+				Type:             "prometheus",
+				SecureJSONFields: map[string]bool{"basicAuthPassword": true},
+			},
+			SecureJSONData: map[string]string{"basicAuthPassword": SecretPlaceholder("Prometheus", "basicAuthPassword")},
+		}},
+		AlertNotifications: []gapi.AlertNotification{{Id: 2, Uid: "slack", Name: "Slack", Type: "slack"}},
+		Playlists: []gapi.Playlist{{
+			Id:       5,
+			Name:     "Wall",
+			Interval: "5m",
+			Items:    []gapi.PlaylistItem{{Type: "dashboard_by_id", Value: "7", Order: 1}},
+		}},
+		Teams: []Team{{
+			Team:        gapi.Team{Id: 4, Name: "SRE"},
+			Members:     []*gapi.TeamMember{{TeamId: 4, UserId: 9, Login: "jane"}},
+			Preferences: &gapi.Preferences{HomeDashboardId: 7},
+		}},
+		Annotations: []gapi.Annotation{{ID: 11, DashboardID: 7, PanelID: 1, Time: 1000, Text: "deploy"}},
+	}
+}
+
+func TestSnapshot_archives(t *testing.T) {
+	s := testSnapshot()
+
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := s.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	fromDir, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := s.WriteTar(buf); err != nil {
+		t.Fatal(err)
+	}
+	fromTar, err := ReadTar(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(fromDir, fromTar) {
+		t.Errorf("Directory and tar snapshots differ:\n%#v\n%#v", fromDir, fromTar)
+	}
+	if len(fromDir.Dashboards) != 1 || fromDir.Dashboards[0].Model["title"] != "Home" || len(fromDir.Dashboards[0].Versions) != 2 {
+		t.Errorf("Unexpected dashboards: %#v", fromDir.Dashboards)
+	}
+	if fromDir.DataSources[0].SecureJSONData["basicAuthPassword"] != SecretPlaceholder("Prometheus", "basicAuthPassword") {
+		t.Errorf("Unexpected datasources: %#v", fromDir.DataSources)
+	}
+
+	if _, err := ReadTar(strings.NewReader("")); err == nil {
+		t.Error("Empty archive not detected")
+	}
+}
+
+func TestRestore(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"GET /api/org/users":           `[{"userId":90,"login":"jane"}]`,
+		"GET /api/datasources":         `[]`,
+		"GET /api/alert-notifications": `[{"id":20,"uid":"slack","name":"Slack"}]`,
+		"GET /api/folders":             `[]`,
+		"POST /api/folders":            `{"id":30,"uid":"ops","title":"Ops"}`,
+		"GET /api/teams/search":        `{"totalCount":1,"teams":[{"id":40,"name":"SRE"}],"page":1,"perPage":1000}`,
+		"GET /api/teams/40/members":    `[]`,
+		"POST /api/dashboards/db":      `{"id":70,"uid":"home","status":"success"}`,
+		"GET /api/playlists":           `[]`,
+	}}
+	client, stop := routetest.NewClient(t, routes)
+	defer stop()
+
+	snapshot := testSnapshot()
+	snapshot.DataSources[0].SecureJSONData["httpHeaderValue1"] = SecretPlaceholder("Prometheus", "httpHeaderValue1")
+	report, err := Restore(context.Background(), client, snapshot, RestoreOptions{
+		Secrets: map[string]string{
+			"Prometheus/basicAuthPassword": "s3cret",
+			"Prometheus/httpHeaderValue1":  "Bearer t0ken",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.FolderIDs[3] != 30 || report.DashboardIDs[7] != 70 || report.TeamIDs[4] != 40 {
+		t.Errorf("Unexpected ID maps: %#v", report)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], `"gone"`) {
+		t.Errorf("Unexpected warnings: %v", report.Warnings)
+	}
+
+	ds := routes.Find("POST", "/api/datasources")
+	if len(ds) != 1 || ds[0].Body["secureJsonData"].(map[string]interface{})["basicAuthPassword"] != "s3cret" ||
+		ds[0].Body["secureJsonData"].(map[string]interface{})["httpHeaderValue1"] != "Bearer t0ken" {
+		t.Errorf("Unexpected datasource creation: %#v", ds)
+	}
+	if n := routes.Find("PUT", "/api/alert-notifications/20"); len(n) != 1 {
+		t.Error("Existing alert notification not updated")
+	}
+	if m := routes.Find("POST", "/api/teams/40/members"); len(m) != 1 || m[0].Body["userId"] != float64(90) {
+		t.Errorf("Unexpected team members: %#v", m)
+	}
+
+	perms := routes.Find("POST", "/api/folders/ops/permissions")
+	expected := []interface{}{
+		map[string]interface{}{"teamId": float64(40), "permission": float64(2)},
+		map[string]interface{}{"userId": float64(90), "permission": float64(4)},
+	}
+	if len(perms) != 1 || !reflect.DeepEqual(perms[0].Body["items"], expected) {
+		t.Errorf("Unexpected folder permissions: %#v", perms)
+	}
+
+	saves := routes.Find("POST", "/api/dashboards/db")
+	if len(saves) != 2 {
+		t.Fatalf("Expected the old version and the dashboard to be saved, got %#v", saves)
+	}
+	if saves[0].Body["message"] != "first" || saves[0].Body["dashboard"].(map[string]interface{})["title"] != "Old home" {
+		t.Errorf("Unexpected version replay: %#v", saves[0])
+	}
+	if saves[1].Body["folderId"] != float64(30) || saves[1].Body["dashboard"].(map[string]interface{})["id"] != nil {
+		t.Errorf("Unexpected dashboard save: %#v", saves[1])
+	}
+
+	if p := routes.Find("PUT", "/api/org/preferences"); len(p) != 1 || p[0].Body["homeDashboardId"] != float64(70) {
+		t.Errorf("Unexpected org preferences: %#v", p)
+	}
+	if p := routes.Find("PUT", "/api/teams/40/preferences"); len(p) != 1 || p[0].Body["homeDashboardId"] != float64(70) {
+		t.Errorf("Unexpected team preferences: %#v", p)
+	}
+	playlists := routes.Find("POST", "/api/playlists")
+	if len(playlists) != 1 || playlists[0].Body["items"].([]interface{})[0].(map[string]interface{})["value"] != "70" {
+		t.Errorf("Unexpected playlists: %#v", playlists)
+	}
+	annotations := routes.Find("POST", "/api/annotations")
+	if len(annotations) != 1 || annotations[0].Body["dashboardId"] != float64(70) || annotations[0].Body["id"] != nil {
+		t.Errorf("Unexpected annotations: %#v", annotations)
+	}
+}
+
+func TestRestore_missingSecret(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"GET /api/org/users":           `[]`,
+		"GET /api/datasources":         `[]`,
+		"GET /api/alert-notifications": `[]`,
+		"GET /api/folders":             `[]`,
+		"GET /api/teams/search":        `{"totalCount":0,"teams":[],"page":1,"perPage":1000}`,
+		"GET /api/playlists":           `[]`,
+	}}
+	client, stop := routetest.NewClient(t, routes)
+	defer stop()
+
+	// The InfluxDB plugin requires its token, but the datasource is restored
+	// without it.
+	snapshot := &Snapshot{
+		Manifest: Manifest{Version: FormatVersion},
+		DataSources: []DataSource{{
+			DataSource: gapi.DataSource{
+				Id:               1,
+				Name:             "InfluxDB",
+				Type:             "influxdb",
+				URL:              "http://influxdb:8086",
+				JSONData:         gapi.JSONData{Extra: map[string]interface{}{"version": "Flux", "organization": "ops", "defaultBucket": "metrics"}},
+				SecureJSONFields: map[string]bool{"token": true},
+			},
+			SecureJSONData: map[string]string{"token": SecretPlaceholder("InfluxDB", "token")},
+		}},
+	}
+	report, err := Restore(context.Background(), client, snapshot, RestoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Warnings) != 1 || report.Warnings[0] != `datasource "InfluxDB": no secret for token` {
+		t.Errorf("Unexpected warnings: %v", report.Warnings)
+	}
+	ds := routes.Find("POST", "/api/datasources")
+	if len(ds) != 1 || ds[0].Body["name"] != "InfluxDB" || len(ds[0].Body["secureJsonData"].(map[string]interface{})) != 0 {
+		t.Errorf("Unexpected datasource creation: %#v", ds)
+	}
+}
@@ -0,0 +1,468 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// RestoreOptions controls how snapshots are restored.
+type RestoreOptions struct {
+	// Secrets maps the secure fields of datasources, as "<datasource>/<field>",
+	// to their values. Secure fields without a value are left unset, with a
+	// warning, and their datasources are restored without validation, even
+	// when their plugins need those secrets.
+	Secrets map[string]string
+}
+
+// Report describes a restore. The ID maps translate the IDs of the
+// snapshot to the IDs of the org it was restored into.
+type Report struct {
+	FolderIDs    map[int64]int64
+	DashboardIDs map[int64]int64
+	TeamIDs      map[int64]int64
+	// UserIDs maps users by login to their IDs in the org.
+	UserIDs map[string]int64
+	// Warnings lists what couldn't be restored as it was backed up.
+	Warnings []string
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Restore recreates the content of a snapshot in the org the client is
+// scoped to, in dependency order. Resources that already exist are
+// updated: folders, dashboards and alert notifications are matched by
+// UID, datasources, teams and playlists by name. Annotations are always
+// added, so restoring twice duplicates them.
+//
+// Users aren't created; team members and permissions are restored for the
+// users of the org with the same login.
+func Restore(ctx context.Context, client *gapi.Client, s *Snapshot, opts RestoreOptions) (*Report, error) {
+	if s.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format version %d", s.Manifest.Version)
+	}
+
+	r := &restorer{
+		client: client,
+		s:      s,
+		opts:   opts,
+		report: &Report{
+			FolderIDs:    map[int64]int64{},
+			DashboardIDs: map[int64]int64{},
+			TeamIDs:      map[int64]int64{},
+			UserIDs:      map[string]int64{},
+		},
+		folderIDs: map[string]int64{},
+	}
+
+	steps := []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"users", r.users},
+		{"datasources", r.dataSources},
+		{"alert notifications", r.alertNotifications},
+		{"folders", r.folders},
+		{"teams", r.teams},
+		{"folder permissions", r.folderPermissions},
+		{"dashboards", r.dashboards},
+		{"preferences", r.preferences},
+		{"playlists", r.playlists},
+		{"annotations", r.annotations},
+	}
+	for _, step := range steps {
+		if err := step.fn(ctx); err != nil {
+			return r.report, fmt.Errorf("restoring %s: %w", step.name, err)
+		}
+	}
+	return r.report, nil
+}
+
+type restorer struct {
+	client *gapi.Client
+	s      *Snapshot
+	opts   RestoreOptions
+	report *Report
+	// folderIDs maps folder UIDs to their IDs in the org.
+	folderIDs map[string]int64
+}
+
+func (r *restorer) users(ctx context.Context) error {
+	users, err := r.client.OrgUsersCurrentContext(ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		r.report.UserIDs[u.Login] = u.UserId
+	}
+	return nil
+}
+
+func (r *restorer) dataSources(ctx context.Context) error {
+	existing, err := r.client.DataSourcesContext(ctx)
+	if err != nil {
+		return err
+	}
+	ids := map[string]int64{}
+	for _, ds := range existing {
+		ids[ds.Name] = ds.Id
+	}
+
+	for _, d := range r.s.DataSources {
+		ds := d.DataSource
+		if err := r.resolveSecrets(&ds, d.SecureJSONData); err != nil {
+			return fmt.Errorf("datasource %q: %w", ds.Name, err)
+		}
+		ds.OrgId = 0
+		ds.SecureJSONFields = nil
+
+		if id, ok := ids[ds.Name]; ok {
+			ds.Id = id
+			err = r.client.UpdateDataSourceContext(ctx, &ds)
+		} else {
+			ds.Id = 0
+			_, err = r.client.NewDataSourceContext(ctx, &ds)
+		}
+		if err != nil {
+			return fmt.Errorf("datasource %q: %w", ds.Name, err)
+		}
+	}
+	return nil
+}
+
+// resolveSecrets sets the secure fields of a datasource to the secrets of
+// the options their placeholders stand for, warning about those without one.
+// Values that aren't placeholders are kept.
+func (r *restorer) resolveSecrets(ds *gapi.DataSource, placeholders map[string]string) error {
+	fields := make([]string, 0, len(placeholders))
+	for field := range placeholders {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	secrets := map[string]string{}
+	for _, field := range fields {
+		value := placeholders[field]
+		if isSecretPlaceholder(value) {
+			secret, ok := r.opts.Secrets[ds.Name+"/"+field]
+			if !ok {
+				r.report.warn("datasource %q: no secret for %s", ds.Name, field)
+				continue
+			}
+			value = secret
+		}
+		secrets[field] = value
+	}
+
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	ds.SecureJSONData = gapi.SecureJSONData{}
+	if err := json.Unmarshal(data, &ds.SecureJSONData); err != nil {
+		return err
+	}
+
+	// Check that the client sends every secure field.
+	if data, err = json.Marshal(ds.SecureJSONData); err != nil {
+		return err
+	}
+	sent := map[string]interface{}{}
+	if err := json.Unmarshal(data, &sent); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if _, ok := sent[field]; !ok && secrets[field] != "" {
+			r.report.warn("datasource %q: secure field %s not restored", ds.Name, field)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) alertNotifications(ctx context.Context) error {
+	existing, err := r.client.AlertNotificationsContext(ctx)
+	if err != nil {
+		return err
+	}
+	ids := map[string]int64{}
+	for _, n := range existing {
+		ids[n.Uid] = n.Id
+	}
+
+	for _, n := range r.s.AlertNotifications {
+		if id, ok := ids[n.Uid]; ok && n.Uid != "" {
+			n.Id = id
+			err = r.client.UpdateAlertNotificationContext(ctx, &n)
+		} else {
+			n.Id = 0
+			_, err = r.client.NewAlertNotificationContext(ctx, &n)
+		}
+		if err != nil {
+			return fmt.Errorf("alert notification %q: %w", n.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) folders(ctx context.Context) error {
+	existing, err := r.client.FoldersContext(ctx)
+	if err != nil {
+		return err
+	}
+	current := map[string]gapi.Folder{}
+	for _, f := range existing {
+		current[f.Uid] = f
+	}
+
+	for _, f := range r.s.Folders {
+		folder, ok := current[f.Folder.Uid]
+		if !ok {
+			folder, err = r.client.NewFolderWithUIDContext(ctx, f.Folder.Title, f.Folder.Uid)
+		} else if folder.Title != f.Folder.Title {
+			err = r.client.UpdateFolderContext(ctx, folder.Uid, f.Folder.Title)
+		}
+		if err != nil {
+			return fmt.Errorf("folder %q: %w", f.Folder.Uid, err)
+		}
+		r.folderIDs[f.Folder.Uid] = folder.Id
+		r.report.FolderIDs[f.Folder.Id] = folder.Id
+	}
+	return nil
+}
+
+func (r *restorer) teams(ctx context.Context) error {
+	ids, err := r.teamIDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range r.s.Teams {
+		id, ok := ids[t.Team.Name]
+		if !ok {
+			if err := r.client.AddTeamContext(ctx, t.Team.Name, t.Team.Email); err != nil {
+				return fmt.Errorf("team %q: %w", t.Team.Name, err)
+			}
+			// Creating a team doesn't return its ID, so look it up.
+			if ids, err = r.teamIDs(ctx); err != nil {
+				return err
+			}
+			if id, ok = ids[t.Team.Name]; !ok {
+				return fmt.Errorf("team %q: not found after creation", t.Team.Name)
+			}
+		}
+		r.report.TeamIDs[t.Team.Id] = id
+
+		members, err := r.client.TeamMembersContext(ctx, id)
+		if err != nil {
+			return fmt.Errorf("team %q members: %w", t.Team.Name, err)
+		}
+		isMember := map[int64]bool{}
+		for _, m := range members {
+			isMember[m.UserId] = true
+		}
+		for _, m := range t.Members {
+			userID, ok := r.report.UserIDs[m.Login]
+			if !ok {
+				r.report.warn("team %q: user %q not found", t.Team.Name, m.Login)
+				continue
+			}
+			if isMember[userID] {
+				continue
+			}
+			if err := r.client.AddTeamMemberContext(ctx, id, userID); err != nil {
+				return fmt.Errorf("team %q member %q: %w", t.Team.Name, m.Login, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *restorer) teamIDs(ctx context.Context) (map[string]int64, error) {
+	teams, err := r.client.SearchTeamContext(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]int64{}
+	for _, t := range teams.Teams {
+		ids[t.Name] = t.Id
+	}
+	return ids, nil
+}
+
+func (r *restorer) folderPermissions(ctx context.Context) error {
+	for _, f := range r.s.Folders {
+		if f.Permissions == nil {
+			continue
+		}
+
+		items := &gapi.PermissionItems{Items: []*gapi.PermissionItem{}}
+		for _, p := range f.Permissions {
+			item := &gapi.PermissionItem{Role: p.Role, Permission: p.Permission}
+			switch {
+			case p.TeamId != 0:
+				id, ok := r.report.TeamIDs[p.TeamId]
+				if !ok {
+					r.report.warn("folder %q: team %q not found", f.Folder.Uid, p.Team)
+					continue
+				}
+				item.TeamId = id
+			case p.UserId != 0:
+				id, ok := r.report.UserIDs[p.UserLogin]
+				if !ok {
+					r.report.warn("folder %q: user %q not found", f.Folder.Uid, p.UserLogin)
+					continue
+				}
+				item.UserId = id
+			}
+			items.Items = append(items.Items, item)
+		}
+
+		if err := r.client.UpdateFolderPermissionsContext(ctx, f.Folder.Uid, items); err != nil {
+			return fmt.Errorf("folder %q: %w", f.Folder.Uid, err)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) dashboards(ctx context.Context) error {
+	for _, d := range r.s.Dashboards {
+		uid, _ := d.Model["uid"].(string)
+		folderID, ok := r.folderIDs[d.FolderUid]
+		if !ok && d.FolderUid != "" {
+			return fmt.Errorf("dashboard %q: folder %q not found", uid, d.FolderUid)
+		}
+
+		// Replay the previous versions first to rebuild the history.
+		current, _ := d.Model["version"].(float64)
+		for _, v := range d.Versions {
+			if float64(v.Version) >= current || v.Data == nil {
+				continue
+			}
+			if _, err := r.saveDashboard(ctx, v.Data, folderID, v.Message); err != nil {
+				return fmt.Errorf("dashboard %q version %d: %w", uid, v.Version, err)
+			}
+		}
+
+		resp, err := r.saveDashboard(ctx, d.Model, folderID, "")
+		if err != nil {
+			return fmt.Errorf("dashboard %q: %w", uid, err)
+		}
+		r.report.DashboardIDs[d.Id] = resp.Id
+	}
+	return nil
+}
+
+func (r *restorer) saveDashboard(ctx context.Context, model map[string]interface{}, folderID int64, message string) (*gapi.DashboardSaveResponse, error) {
+	m := make(map[string]interface{}, len(model))
+	for key, value := range model {
+		m[key] = value
+	}
+	m["id"] = nil
+	delete(m, "version")
+
+	return r.client.NewDashboardContext(ctx, gapi.Dashboard{
+		Model:     m,
+		Folder:    folderID,
+		Overwrite: true,
+		Message:   message,
+	})
+}
+
+// dashboardID returns the ID in the org of the dashboard whose snapshot ID
+// it's passed, or 0 for 0.
+func (r *restorer) dashboardID(id int64, what string) int64 {
+	if id == 0 {
+		return 0
+	}
+	newID, ok := r.report.DashboardIDs[id]
+	if !ok {
+		r.report.warn("%s: dashboard %d not found", what, id)
+	}
+	return newID
+}
+
+func (r *restorer) preferences(ctx context.Context) error {
+	if r.s.Preferences != nil {
+		p := *r.s.Preferences
+		p.HomeDashboardId = r.dashboardID(p.HomeDashboardId, "org preferences")
+		if err := r.client.UpdateOrgPreferencesContext(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range r.s.Teams {
+		p := t.Preferences
+		if p == nil {
+			continue
+		}
+		home := r.dashboardID(p.HomeDashboardId, fmt.Sprintf("team %q preferences", t.Team.Name))
+		err := r.client.UpdateTeamPreferencesContext(ctx, r.report.TeamIDs[t.Team.Id], p.Theme, home, p.Timezone)
+		if err != nil {
+			return fmt.Errorf("team %q: %w", t.Team.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) playlists(ctx context.Context) error {
+	existing, err := r.client.PlaylistsContext(ctx)
+	if err != nil {
+		return err
+	}
+	ids := map[string]int{}
+	for _, p := range existing {
+		ids[p.Name] = p.Id
+	}
+
+	for _, p := range r.s.Playlists {
+		items := make([]gapi.PlaylistItem, 0, len(p.Items))
+		for _, item := range p.Items {
+			if item.Type == "dashboard_by_id" {
+				id, _ := strconv.ParseInt(item.Value, 10, 64)
+				newID := r.dashboardID(id, fmt.Sprintf("playlist %q", p.Name))
+				if newID == 0 {
+					continue
+				}
+				item.Value = strconv.FormatInt(newID, 10)
+			}
+			items = append(items, item)
+		}
+		p.Items = items
+
+		if id, ok := ids[p.Name]; ok {
+			p.Id = id
+			err = r.client.UpdatePlaylistContext(ctx, p)
+		} else {
+			p.Id = 0
+			_, err = r.client.NewPlaylistContext(ctx, p)
+		}
+		if err != nil {
+			return fmt.Errorf("playlist %q: %w", p.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *restorer) annotations(ctx context.Context) error {
+	for _, a := range r.s.Annotations {
+		if a.DashboardID != 0 {
+			a.DashboardID = r.dashboardID(a.DashboardID, fmt.Sprintf("annotation %d", a.ID))
+			if a.DashboardID == 0 {
+				continue
+			}
+		}
+		id := a.ID
+		a.ID, a.AlertID, a.UserID, a.UserName, a.RegionID = 0, 0, 0, "", 0
+
+		if _, err := r.client.NewAnnotationContext(ctx, &a); err != nil {
+			return fmt.Errorf("annotation %d: %w", id, err)
+		}
+	}
+	return nil
+}
@@ -2,76 +2,135 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
+	"strconv"
 	"strings"
-
-	"github.com/hashicorp/go-cleanhttp"
+	"time"
 )
 
 // Client is a Grafana API client.
 type Client struct {
-	key     string
+	auth    Authenticator
 	baseURL url.URL
 	*http.Client
+
+	// Retry controls how failed requests are retried.
+	// A nil policy, the default, disables retries.
+	Retry *RetryPolicy
+
+	headers  http.Header
+	orgID    int64
+	logger   Logger
+	pageSize int
 }
 
-//New creates a new grafana client
-//auth can be in user:pass format, or it can be an api key
+// New creates a new grafana client
+// auth can be in user:pass format, or it can be an api key.
+// Use NewWithOptions and WithAuth for API keys containing a colon, or for
+// other authentication methods.
 func New(auth, baseURL string) (*Client, error) {
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		return nil, err
-	}
-	key := ""
+	var opts []Option
 	if strings.Contains(auth, ":") {
 		split := strings.SplitN(auth, ":", 2)
-		u.User = url.UserPassword(split[0], split[1])
+		opts = append(opts, WithAuth(BasicAuth(split[0], split[1])))
 	} else if auth != "" {
-		key = fmt.Sprintf("Bearer %s", auth)
+		opts = append(opts, WithAuth(TokenAuth(auth)))
 	}
-	return &Client{
-		key,
-		*u,
-		cleanhttp.DefaultClient(),
-	}, nil
+	return NewWithOptions(baseURL, opts...)
 }
 
-func (c *Client) request(method, requestPath string, query url.Values, body io.Reader, responseStruct interface{}) error {
-	r, err := c.newRequest(method, requestPath, query, body)
-	if err != nil {
-		return err
-	}
+// WithOrgID returns a copy of the client whose requests are scoped to the org
+// whose ID it's passed, through the X-Grafana-Org-Id header, instead of the
+// authenticated user's current org. The copy shares the underlying HTTP client
+// and its connection pool.
+func (c *Client) WithOrgID(id int64) *Client {
+	scoped := *c
+	scoped.orgID = id
+	return &scoped
+}
 
-	resp, err := c.Do(r)
-	if err != nil {
-		return err
+func (c *Client) request(ctx context.Context, method, requestPath string, query url.Values, body io.Reader, responseStruct interface{}) error {
+	// Buffer the body so that it can be replayed if the request is retried.
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = ioutil.ReadAll(body)
+		if err != nil {
+			return err
+		}
 	}
 
-	bodyContents, err := ioutil.ReadAll(resp.Body)
+	var (
+		resp         *http.Response
+		bodyContents []byte
+		err          error
+	)
+	for attempt := 1; ; attempt++ {
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewBuffer(data)
+		}
+
+		var r *http.Request
+		r, err = c.newRequest(ctx, method, requestPath, query, reqBody)
+		if err != nil {
+			return err
+		}
+
+		start := time.Now()
+		resp, err = c.Do(r)
+		if err == nil {
+			bodyContents, err = ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		c.logRequest(r, data, resp, bodyContents, err, attempt, time.Since(start))
+
+		if ctx.Err() != nil {
+			break
+		}
+		wait, retry := c.Retry.next(method, attempt, resp, err)
+		if !retry {
+			break
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
 	if err != nil {
 		return err
 	}
 
-	if os.Getenv("GF_LOG") != "" {
-		log.Printf("response status %d with body %v", resp.StatusCode, string(bodyContents))
+	if resp.StatusCode == http.StatusUnauthorized {
+		if r, ok := c.auth.(interface{ reset() }); ok {
+			r.reset()
+		}
 	}
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("status: %d, body: %v", resp.StatusCode, string(bodyContents))
+		return newAPIError(method, requestPath, resp.StatusCode, bodyContents)
 	}
 
 	if responseStruct == nil {
 		return nil
 	}
 
+	// Responses which aren't JSON can be read raw.
+	if raw, ok := responseStruct.(*[]byte); ok {
+		*raw = bodyContents
+		return nil
+	}
+
 	err = json.Unmarshal(bodyContents, responseStruct)
 	if err != nil {
 		return err
@@ -80,23 +139,30 @@ func (c *Client) request(method, requestPath string, query url.Values, body io.R
 	return nil
 }
 
-func (c *Client) newRequest(method, requestPath string, query url.Values, body io.Reader) (*http.Request, error) {
-	url := c.baseURL
-	url.Path = path.Join(url.Path, requestPath)
-	url.RawQuery = query.Encode()
-	req, err := http.NewRequest(method, url.String(), body)
+func (c *Client) newRequest(ctx context.Context, method, requestPath string, query url.Values, body io.Reader) (*http.Request, error) {
+	u := c.baseURL
+	// The path may have escaped parameters, such as names with slashes.
+	escaped := path.Join(u.EscapedPath(), requestPath)
+	if unescaped, err := url.PathUnescape(escaped); err == nil {
+		u.Path, u.RawPath = unescaped, escaped
+	} else {
+		u.Path, u.RawPath = path.Join(u.Path, requestPath), ""
+	}
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return req, err
 	}
-	if c.key != "" {
-		req.Header.Add("Authorization", c.key)
+	for k, v := range c.headers {
+		req.Header[k] = append([]string(nil), v...)
 	}
-
-	if os.Getenv("GF_LOG") != "" {
-		if body == nil {
-			log.Printf("request (%s) to %s with no body data", method, url.String())
-		} else {
-			log.Printf("request (%s) to %s with body data: %s", method, url.String(), body.(*bytes.Buffer).String())
+	// The org is set first, for authenticators that log in.
+	if c.orgID > 0 {
+		req.Header.Set("X-Grafana-Org-Id", strconv.FormatInt(c.orgID, 10))
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return nil, err
 		}
 	}
 
@@ -0,0 +1,922 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// command is a command of a resource, such as "dashboards list".
+type command struct {
+	resource, name string
+	// args describes the arguments in the usage.
+	args string
+	help string
+	run  func(e *env, args []string) error
+}
+
+var commands = []command{
+	{"dashboards", "list", "", "List or search dashboards.", dashboardsList},
+	{"dashboards", "get", "<uid>", "Print a dashboard.", dashboardsGet},
+	{"dashboards", "save", "<file>", "Create or update a dashboard from a JSON file, or - for stdin.", dashboardsSave},
+	{"dashboards", "delete", "<uid>", "Delete a dashboard.", dashboardsDelete},
+
+	{"datasources", "list", "", "List datasources.", dataSourcesList},
+	{"datasources", "get", "<id>", "Print a datasource.", dataSourcesGet},
+	{"datasources", "save", "<file>", "Create a datasource from a JSON file, or update it if the file has an id.", dataSourcesSave},
+	{"datasources", "delete", "<id>", "Delete a datasource.", dataSourcesDelete},
+
+	{"folders", "list", "", "List folders.", foldersList},
+	{"folders", "get", "<id>", "Print a folder.", foldersGet},
+	{"folders", "create", "<title>", "Create a folder.", foldersCreate},
+	{"folders", "delete", "<uid>", "Delete a folder.", foldersDelete},
+
+	{"teams", "list", "", "List or search teams.", teamsList},
+	{"teams", "get", "<id>", "Print a team.", teamsGet},
+	{"teams", "create", "<name>", "Create a team.", teamsCreate},
+	{"teams", "delete", "<id>", "Delete a team.", teamsDelete},
+	{"teams", "members", "<id>", "List the members of a team.", teamsMembers},
+	{"teams", "add-member", "<id> <user-id>", "Add a user to a team.", teamsAddMember},
+
+	{"orgs", "list", "", "List orgs.", orgsList},
+	{"orgs", "get", "<name>", "Print an org.", orgsGet},
+	{"orgs", "create", "<name>", "Create an org.", orgsCreate},
+	{"orgs", "delete", "<id>", "Delete an org.", orgsDelete},
+	{"orgs", "users", "<id>", "List the users of an org.", orgsUsers},
+
+	{"users", "list", "", "List or search users.", usersList},
+	{"users", "get", "<login-or-email>", "Print a user.", usersGet},
+
+	{"alerts", "list", "", "List alerts.", alertsList},
+	{"alerts", "get", "<id>", "Print an alert.", alertsGet},
+	{"alerts", "pause", "[<id>]", "Pause an alert, or every alert with -all.", alertsPause},
+
+	{"annotations", "list", "", "List annotations, newest first.", annotationsList},
+	{"annotations", "create", "<text>", "Create an annotation.", annotationsCreate},
+
+	{"playlists", "list", "", "List playlists.", playlistsList},
+	{"playlists", "get", "<id>", "Print a playlist.", playlistsGet},
+	{"playlists", "save", "<file>", "Create a playlist from a JSON file, or update it if the file has an id.", playlistsSave},
+	{"playlists", "delete", "<id>", "Delete a playlist.", playlistsDelete},
+
+	{"contexts", "list", "", "List the contexts of the config file.", contextsList},
+	{"contexts", "use", "<name>", "Set the current context of the config file.", contextsUse},
+}
+
+// stringsFlag is a flag that can be repeated.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+func parseID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %q", arg)
+	}
+	return id, nil
+}
+
+// readJSON decodes the JSON file at p, or stdin for -, into v.
+func (e *env) readJSON(p string, v interface{}) error {
+	var data []byte
+	var err error
+	if p == "-" {
+		data, err = ioutil.ReadAll(e.in)
+	} else {
+		data, err = ioutil.ReadFile(p)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return nil
+}
+
+func dashboardsList(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	q := gapi.SearchQuery{Type: gapi.SearchTypeDashboard}
+	fs.StringVar(&q.Query, "query", "", "match dashboard titles against `text`")
+	tags := &stringsFlag{}
+	fs.Var(tags, "tag", "only list dashboards with the `tag`; can be repeated")
+	folder := fs.Int64("folder", -1, "only list dashboards in the folder with this `id`; 0 is the General folder")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	q.Tags = *tags
+	if *folder >= 0 {
+		q.FolderIds = []int64{*folder}
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	dashboards, err := client.SearchDashboardsContext(e.ctx, q)
+	if err != nil {
+		return err
+	}
+	return e.print(dashboards, "uid", "title", "folderTitle", "tags", "url")
+}
+
+func dashboardsGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	dashboard, err := client.DashboardByUidContext(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	// Leave out the fields only used to save dashboards.
+	v := map[string]interface{}{"meta": dashboard.Meta, "dashboard": dashboard.Model}
+	return e.print(v, "dashboard.uid", "dashboard.title", "dashboard.version", "meta.folderId", "meta.slug")
+}
+
+func dashboardsSave(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	folder := fs.Int64("folder", 0, "`id` of the folder to save the dashboard in")
+	overwrite := fs.Bool("overwrite", false, "overwrite the dashboard with the same UID or title")
+	message := fs.String("message", "", "`message` recorded in the version history")
+	args, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	// Accept both a dashboard model and a dashboard as returned by
+	// "gapi -o json dashboards get".
+	file := map[string]interface{}{}
+	if err := e.readJSON(args[0], &file); err != nil {
+		return err
+	}
+	model, ok := file["dashboard"].(map[string]interface{})
+	if !ok {
+		model = file
+	}
+	if meta, ok := file["meta"].(map[string]interface{}); ok && !isFlagSet(fs, "folder") {
+		if id, ok := meta["folderId"].(float64); ok {
+			*folder = int64(id)
+		}
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	resp, err := client.NewDashboardContext(e.ctx, gapi.Dashboard{
+		Model:     model,
+		Folder:    *folder,
+		Overwrite: *overwrite,
+		Message:   *message,
+	})
+	if err != nil {
+		return err
+	}
+	return e.print(resp, "uid", "id", "version", "status")
+}
+
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func dashboardsDelete(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteDashboardByUidContext(e.ctx, args[0]); err != nil {
+		return err
+	}
+	return e.done("Deleted dashboard %s.", args[0])
+}
+
+func dataSourcesList(e *env, args []string) error {
+	if _, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	dataSources, err := client.DataSourcesContext(e.ctx)
+	if err != nil {
+		return err
+	}
+	return e.print(dataSources, "id", "name", "type", "url", "isDefault")
+}
+
+func dataSourcesGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	ds, err := client.DataSourceContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(ds, "id", "name", "type", "url", "access", "isDefault")
+}
+
+func dataSourcesSave(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	ds := &gapi.DataSource{}
+	if err := e.readJSON(args[0], ds); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	if ds.Id != 0 {
+		if err := client.UpdateDataSourceContext(e.ctx, ds); err != nil {
+			return err
+		}
+		return e.done("Updated datasource %d.", ds.Id)
+	}
+	if err := ds.Validate(); err != nil {
+		return err
+	}
+	id, err := client.NewDataSourceContext(e.ctx, ds)
+	if err != nil {
+		return err
+	}
+	return e.done("Created datasource %d.", id)
+}
+
+func dataSourcesDelete(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteDataSourceContext(e.ctx, id); err != nil {
+		return err
+	}
+	return e.done("Deleted datasource %d.", id)
+}
+
+func foldersList(e *env, args []string) error {
+	if _, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	folders, err := client.FoldersContext(e.ctx)
+	if err != nil {
+		return err
+	}
+	return e.print(folders, "id", "uid", "title")
+}
+
+func foldersGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	folder, err := client.FolderContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(folder, "id", "uid", "title")
+}
+
+func foldersCreate(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	uid := fs.String("uid", "", "`uid` of the folder, generated by Grafana if empty")
+	args, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	folder, err := client.NewFolderWithUIDContext(e.ctx, args[0], *uid)
+	if err != nil {
+		return err
+	}
+	return e.print(folder, "id", "uid", "title")
+}
+
+func foldersDelete(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteFolderContext(e.ctx, args[0]); err != nil {
+		return err
+	}
+	return e.done("Deleted folder %s.", args[0])
+}
+
+func teamsList(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	query := fs.String("query", "", "match team names against `text`")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	teams := []*gapi.Team{}
+	err = client.ForEachTeamContext(e.ctx, *query, func(t *gapi.Team) error {
+		teams = append(teams, t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.print(teams, "id", "name", "email", "memberCount")
+}
+
+func teamsGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	team, err := client.TeamContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(team, "id", "name", "email", "memberCount")
+}
+
+func teamsCreate(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	email := fs.String("email", "", "`email` of the team")
+	args, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.AddTeamContext(e.ctx, args[0], *email); err != nil {
+		return err
+	}
+	return e.done("Created team %s.", args[0])
+}
+
+func teamsDelete(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteTeamContext(e.ctx, id); err != nil {
+		return err
+	}
+	return e.done("Deleted team %d.", id)
+}
+
+func teamsMembers(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	members, err := client.TeamMembersContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(members, "userId", "login", "email")
+}
+
+func teamsAddMember(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	userID, err := parseID(args[1])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.AddTeamMemberContext(e.ctx, id, userID); err != nil {
+		return err
+	}
+	return e.done("Added user %d to team %d.", userID, id)
+}
+
+func orgsList(e *env, args []string) error {
+	if _, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	orgs, err := client.OrgsContext(e.ctx)
+	if err != nil {
+		return err
+	}
+	return e.print(orgs, "id", "name")
+}
+
+func orgsGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	org, err := client.OrgByNameContext(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.print(org, "id", "name")
+}
+
+func orgsCreate(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	id, err := client.NewOrgContext(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.print(gapi.Org{Id: id, Name: args[0]}, "id", "name")
+}
+
+func orgsDelete(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeleteOrgContext(e.ctx, id); err != nil {
+		return err
+	}
+	return e.done("Deleted org %d.", id)
+}
+
+func orgsUsers(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	users, err := client.OrgUsersContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(users, "userId", "login", "email", "role")
+}
+
+func usersList(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	query := fs.String("query", "", "match logins, emails and names against `text`")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	users := []gapi.User{}
+	err = client.ForEachUserContext(e.ctx, *query, func(u gapi.User) error {
+		users = append(users, u)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.print(users, "id", "login", "email", "name", "isAdmin")
+}
+
+func usersGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	user, err := client.UserByEmailContext(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return e.print(user, "id", "login", "email", "name", "isAdmin")
+}
+
+func alertsList(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	states := &stringsFlag{}
+	fs.Var(states, "state", "only list alerts in the `state`, such as alerting or paused; can be repeated")
+	dashboard := fs.Int64("dashboard", 0, "only list the alerts of the dashboard with this `id`")
+	query := fs.String("query", "", "match alert names against `text`")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	params := url.Values{}
+	for _, state := range *states {
+		params.Add("state", state)
+	}
+	if *dashboard != 0 {
+		params.Set("dashboardId", strconv.FormatInt(*dashboard, 10))
+	}
+	if *query != "" {
+		params.Set("query", *query)
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	alerts, err := client.AlertsContext(e.ctx, params)
+	if err != nil {
+		return err
+	}
+	return e.print(alerts, "id", "name", "state", "dashboardUid", "panelId")
+}
+
+func alertsGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	alert, err := client.AlertContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(alert, "id", "name", "state", "dashboardUid", "panelId")
+}
+
+func alertsPause(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	all := fs.Bool("all", false, "pause every alert")
+	args, err := e.parse(fs, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	if *all == (len(args) == 1) {
+		fs.Usage()
+		return errUsage
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	if *all {
+		resp, err := client.PauseAllAlertsContext(e.ctx)
+		if err != nil {
+			return err
+		}
+		return e.print(resp, "alertsAffected", "state", "message")
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	resp, err := client.PauseAlertContext(e.ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.print(resp, "alertId", "state", "message")
+}
+
+// parseTime parses a time flag: RFC 3339, or milliseconds since the epoch.
+func parseTime(v string) (int64, error) {
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return ms, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: use RFC 3339 or milliseconds since the epoch", v)
+	}
+	return t.UnixNano() / int64(time.Millisecond), nil
+}
+
+func annotationsList(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	dashboard := fs.Int64("dashboard", 0, "only list the annotations of the dashboard with this `id`")
+	from := fs.String("from", "", "only list annotations after this `time`")
+	to := fs.String("to", "", "only list annotations before this `time`")
+	tags := &stringsFlag{}
+	fs.Var(tags, "tag", "only list annotations with the `tag`; can be repeated")
+	limit := fs.Int("limit", 100, "list at most this `number` of annotations; 0 lists them all")
+	if _, err := e.parse(fs, args, 0, 0); err != nil {
+		return err
+	}
+	params := url.Values{}
+	if *dashboard != 0 {
+		params.Set("dashboardId", strconv.FormatInt(*dashboard, 10))
+	}
+	for name, v := range map[string]string{"from": *from, "to": *to} {
+		if v == "" {
+			continue
+		}
+		ms, err := parseTime(v)
+		if err != nil {
+			return err
+		}
+		params.Set(name, strconv.FormatInt(ms, 10))
+	}
+	for _, tag := range *tags {
+		params.Add("tags", tag)
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	annotations := []gapi.Annotation{}
+	err = client.ForEachAnnotationContext(e.ctx, params, func(a gapi.Annotation) error {
+		annotations = append(annotations, a)
+		if *limit > 0 && len(annotations) >= *limit {
+			return gapi.ErrStopPaging
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return e.print(annotations, "id", "time", "dashboardId", "panelId", "tags", "text")
+}
+
+func annotationsCreate(e *env, args []string) error {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	a := &gapi.Annotation{}
+	fs.Int64Var(&a.DashboardID, "dashboard", 0, "`id` of the dashboard of the annotation; organization-wide if 0")
+	fs.Int64Var(&a.PanelID, "panel", 0, "`id` of the panel of the annotation")
+	at := fs.String("time", "", "`time` of the annotation; defaults to now")
+	end := fs.String("time-end", "", "end `time` of a region annotation")
+	tags := &stringsFlag{}
+	fs.Var(tags, "tag", "`tag` of the annotation; can be repeated")
+	args, err := e.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	a.Text = args[0]
+	a.Tags = *tags
+	a.Time = time.Now().UnixNano() / int64(time.Millisecond)
+	if *at != "" {
+		if a.Time, err = parseTime(*at); err != nil {
+			return err
+		}
+	}
+	if *end != "" {
+		if a.TimeEnd, err = parseTime(*end); err != nil {
+			return err
+		}
+		a.IsRegion = true
+	}
+
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	id, err := client.NewAnnotationContext(e.ctx, a)
+	if err != nil {
+		return err
+	}
+	a.ID = id
+	return e.print(a, "id", "time", "dashboardId", "panelId", "tags", "text")
+}
+
+func playlistsList(e *env, args []string) error {
+	if _, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	playlists, err := client.PlaylistsContext(e.ctx)
+	if err != nil {
+		return err
+	}
+	return e.print(playlists, "id", "name", "interval")
+}
+
+func playlistsGet(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	playlist, err := client.PlaylistContext(e.ctx, int(id))
+	if err != nil {
+		return err
+	}
+	return e.print(playlist, "id", "name", "interval", "items")
+}
+
+func playlistsSave(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	playlist := gapi.Playlist{}
+	if err := e.readJSON(args[0], &playlist); err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+
+	if playlist.Id != 0 {
+		if err := client.UpdatePlaylistContext(e.ctx, playlist); err != nil {
+			return err
+		}
+		return e.done("Updated playlist %d.", playlist.Id)
+	}
+	id, err := client.NewPlaylistContext(e.ctx, playlist)
+	if err != nil {
+		return err
+	}
+	return e.done("Created playlist %d.", id)
+}
+
+func playlistsDelete(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	client, err := e.client()
+	if err != nil {
+		return err
+	}
+	if err := client.DeletePlaylistContext(e.ctx, int(id)); err != nil {
+		return err
+	}
+	return e.done("Deleted playlist %d.", id)
+}
+
+func contextsList(e *env, args []string) error {
+	if _, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	_, config, err := e.config()
+	if err != nil {
+		return err
+	}
+
+	// Leave credentials out.
+	type contextInfo struct {
+		Name    string `json:"name"`
+		URL     string `json:"url"`
+		OrgID   int64  `json:"orgId,omitempty"`
+		Current bool   `json:"current"`
+	}
+	contexts := make([]contextInfo, 0, len(config.Contexts))
+	for _, c := range config.Contexts {
+		contexts = append(contexts, contextInfo{c.Name, c.URL, c.OrgID, c.Name == config.CurrentContext})
+	}
+	return e.print(contexts, "name", "url", "orgId", "current")
+}
+
+func contextsUse(e *env, args []string) error {
+	args, err := e.parse(flag.NewFlagSet("", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	p, config, err := e.config()
+	if err != nil {
+		return err
+	}
+	if _, ok := config.context(args[0]); !ok {
+		return fmt.Errorf("context %q not found in %s", args[0], p)
+	}
+	config.CurrentContext = args[0]
+	if err := config.save(p); err != nil {
+		return err
+	}
+	return e.done("Switched to context %s.", args[0])
+}
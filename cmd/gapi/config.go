@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gapi "github.com/nytm/go-grafana-api"
+	"gopkg.in/yaml.v3"
+)
+
+// Environment variables read by the tool.
+const (
+	envConfig   = "GAPI_CONFIG"
+	envContext  = "GAPI_CONTEXT"
+	envURL      = "GRAFANA_URL"
+	envAuth     = "GRAFANA_AUTH"
+	envOrgID    = "GRAFANA_ORG_ID"
+	envInsecure = "GRAFANA_INSECURE_SKIP_VERIFY"
+)
+
+// Config is the content of the config file.
+type Config struct {
+	CurrentContext string    `yaml:"current-context,omitempty"`
+	Contexts       []Context `yaml:"contexts"`
+}
+
+// Context holds how to reach a Grafana instance.
+type Context struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Auth is either an API key or a "username:password" pair.
+	Auth               string `yaml:"auth,omitempty"`
+	OrgID              int64  `yaml:"org-id,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure-skip-verify,omitempty"`
+}
+
+// configPath returns the path of the config file: $GAPI_CONFIG, or
+// gapi/config.yaml in the user config directory.
+func configPath(getenv func(string) string) (string, error) {
+	if p := getenv(envConfig); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gapi", "config.yaml"), nil
+}
+
+// loadConfig reads the config file at p. A missing file is an empty config.
+func loadConfig(p string) (*Config, error) {
+	config := &Config{}
+	data, err := ioutil.ReadFile(p)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return config, nil
+}
+
+func (c *Config) save(p string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	// The file holds credentials.
+	return ioutil.WriteFile(p, data, 0600)
+}
+
+func (c *Config) context(name string) (Context, bool) {
+	for _, ctx := range c.Contexts {
+		if ctx.Name == name {
+			return ctx, true
+		}
+	}
+	return Context{}, false
+}
+
+// resolve returns the context to use: the one named, or $GAPI_CONTEXT, or
+// the current context of the config. The GRAFANA_* environment variables
+// override its settings, and are enough on their own without a config file.
+func (c *Config) resolve(name string, getenv func(string) string) (Context, error) {
+	if name == "" {
+		name = getenv(envContext)
+	}
+	if name == "" {
+		name = c.CurrentContext
+	}
+
+	ctx := Context{}
+	if name != "" {
+		var ok bool
+		if ctx, ok = c.context(name); !ok {
+			return ctx, fmt.Errorf("context %q not found", name)
+		}
+	}
+
+	if v := getenv(envURL); v != "" {
+		ctx.URL = v
+	}
+	if v := getenv(envAuth); v != "" {
+		ctx.Auth = v
+	}
+	if v := getenv(envOrgID); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return ctx, fmt.Errorf("%s: %w", envOrgID, err)
+		}
+		ctx.OrgID = id
+	}
+	if v := getenv(envInsecure); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return ctx, fmt.Errorf("%s: %w", envInsecure, err)
+		}
+		ctx.InsecureSkipVerify = insecure
+	}
+
+	if ctx.URL == "" {
+		return ctx, errors.New("no Grafana configured: set " + envURL + " or add a context to the config file")
+	}
+	return ctx, nil
+}
+
+// client returns a client for the Grafana instance of the context.
+func (ctx Context) client() (*gapi.Client, error) {
+	opts := []gapi.Option{gapi.WithUserAgent("gapi-cli")}
+	if ctx.Auth != "" {
+		if i := strings.Index(ctx.Auth, ":"); i >= 0 {
+			opts = append(opts, gapi.WithBasicAuth(ctx.Auth[:i], ctx.Auth[i+1:]))
+		} else {
+			opts = append(opts, gapi.WithAPIKey(ctx.Auth))
+		}
+	}
+	if ctx.OrgID != 0 {
+		opts = append(opts, gapi.WithOrgID(ctx.OrgID))
+	}
+	if ctx.InsecureSkipVerify {
+		opts = append(opts, gapi.WithInsecureSkipVerify())
+	}
+	return gapi.NewWithOptions(ctx.URL, opts...)
+}
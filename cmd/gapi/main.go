@@ -0,0 +1,206 @@
+// Command gapi is a command-line client for the Grafana HTTP API.
+//
+// Usage:
+//
+//	gapi [-context name] [-o table|json|yaml] <resource> <command> [flags] [args]
+//
+// For example:
+//
+//	gapi dashboards list -tag prod
+//	gapi -o json dashboards get nodes > nodes.json
+//	gapi dashboards save -folder 3 -overwrite nodes.json
+//	gapi alerts pause 12
+//
+// Run gapi -help for the list of commands.
+//
+// Grafana instances are configured as named contexts in a YAML file,
+// $GAPI_CONFIG or gapi/config.yaml in the user config directory:
+//
+//	current-context: prod
+//	contexts:
+//	  - name: prod
+//	    url: https://grafana.example.com
+//	    auth: glsa_xxxxxxxx
+//	  - name: staging
+//	    url: https://grafana.staging.example.com
+//	    auth: admin:admin
+//	    org-id: 2
+//
+// Auth is either an API key or a "username:password" pair. The context
+// used is the one passed with -context, else $GAPI_CONTEXT, else the current
+// context. GRAFANA_URL, GRAFANA_AUTH, GRAFANA_ORG_ID and
+// GRAFANA_INSECURE_SKIP_VERIFY override its settings, and are enough on
+// their own without a config file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// errUsage is returned for invalid command lines, after printing the usage.
+var errUsage = errors.New("usage")
+
+func main() {
+	err := run(os.Args[1:], os.Getenv, os.Stdin, os.Stdout, os.Stderr)
+	if err == errUsage {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gapi: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// env is what commands run with.
+type env struct {
+	ctx     context.Context
+	getenv  func(string) string
+	in      io.Reader
+	out     io.Writer
+	errOut  io.Writer
+	format  string
+	context string
+	cmd     *command
+}
+
+func run(args []string, getenv func(string) string, in io.Reader, out, errOut io.Writer) error {
+	e := &env{getenv: getenv, in: in, out: out, errOut: errOut}
+
+	fs := flag.NewFlagSet("gapi", flag.ContinueOnError)
+	fs.SetOutput(errOut)
+	fs.StringVar(&e.context, "context", "", "`name` of the config file context to use")
+	fs.StringVar(&e.format, "o", formatTable, "output `format`: table, json or yaml")
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit of the command")
+	fs.Usage = func() { usage(errOut, fs) }
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return errUsage
+	}
+	if !validFormat(e.format) {
+		fmt.Fprintf(errOut, "invalid output format %q\n", e.format)
+		return errUsage
+	}
+
+	args = fs.Args()
+	if len(args) < 2 {
+		fs.Usage()
+		return errUsage
+	}
+	e.cmd = findCommand(args[0], args[1])
+	if e.cmd == nil {
+		fmt.Fprintf(errOut, "unknown command %q\n", strings.Join(args[:2], " "))
+		fs.Usage()
+		return errUsage
+	}
+
+	var cancel context.CancelFunc
+	e.ctx, cancel = context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := e.cmd.run(e, args[2:]); err != errHelp {
+		return err
+	}
+	return nil
+}
+
+func usage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: gapi [flags] <resource> <command> [flags] [args]")
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+	fmt.Fprintln(w, "\nCommands:")
+
+	lines := make([]string, 0, len(commands))
+	for _, c := range commands {
+		lines = append(lines, fmt.Sprintf("  %s %s %s\t%s", c.resource, c.name, c.args, c.help))
+	}
+	sort.Strings(lines)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, line := range lines {
+		fmt.Fprintln(tw, line)
+	}
+	tw.Flush()
+	fmt.Fprintln(w, "\nRun gapi <resource> <command> -help for the flags of a command.")
+}
+
+func findCommand(resource, name string) *command {
+	for i, c := range commands {
+		if c.resource == resource && c.name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// parse parses the flags of the command and checks that it has between
+// min and max arguments.
+func (e *env) parse(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	fs.SetOutput(e.errOut)
+	fs.Usage = func() {
+		fmt.Fprintf(e.errOut, "Usage: gapi %s %s [flags] %s\n\n%s\n", e.cmd.resource, e.cmd.name, e.cmd.args, e.cmd.help)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, errHelp
+		}
+		return nil, errUsage
+	}
+	if fs.NArg() < min || fs.NArg() > max {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+// errHelp is returned when a command's help was asked for and printed.
+var errHelp = errors.New("help requested")
+
+// config returns the path and content of the config file.
+func (e *env) config() (string, *Config, error) {
+	p, err := configPath(e.getenv)
+	if err != nil {
+		return "", nil, err
+	}
+	config, err := loadConfig(p)
+	return p, config, err
+}
+
+// client returns a client for the Grafana instance of the context in use.
+func (e *env) client() (*gapi.Client, error) {
+	_, config, err := e.config()
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := config.resolve(e.context, e.getenv)
+	if err != nil {
+		return nil, err
+	}
+	return ctx.client()
+}
+
+// print writes v in the output format, with the columns in tables.
+func (e *env) print(v interface{}, columns ...string) error {
+	return printValue(e.out, e.format, v, columns)
+}
+
+// done reports the success of a command that returns nothing to print.
+func (e *env) done(format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if e.format != formatTable {
+		return e.print(map[string]string{"message": message})
+	}
+	_, err := fmt.Fprintln(e.out, message)
+	return err
+}
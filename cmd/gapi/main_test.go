@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nytm/go-grafana-api/internal/routetest"
+)
+
+// runGapi runs the tool against the server with the environment and
+// stdin it's passed, and returns its output.
+func runGapi(t *testing.T, server *httptest.Server, env map[string]string, stdin string, args ...string) (string, error) {
+	t.Helper()
+	if env == nil {
+		env = map[string]string{}
+	}
+	if _, ok := env[envURL]; !ok && server != nil {
+		env[envURL] = server.URL
+	}
+	if _, ok := env[envConfig]; !ok {
+		env[envConfig] = filepath.Join("testdata", "missing.yaml")
+	}
+	getenv := func(key string) string { return env[key] }
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	err := run(args, getenv, strings.NewReader(stdin), out, errOut)
+	if err != nil {
+		t.Logf("stderr:\n%s", errOut.String())
+	}
+	return out.String(), err
+}
+
+func TestDashboardsList(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"GET /api/search": `[
+			{"id":1,"uid":"nodes","title":"Nodes","folderTitle":"Infra","tags":["prod","linux"],"url":"/d/nodes/nodes"},
+			{"id":2,"uid":"home","title":"Home","url":"/d/home/home"}
+		]`,
+	}}
+	server := httptest.NewServer(routes)
+	defer server.Close()
+
+	out, err := runGapi(t, server, nil, "", "dashboards", "list", "-tag", "prod", "-folder", "3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `UID    TITLE  FOLDERTITLE  TAGS        URL
+nodes  Nodes  Infra        prod,linux  /d/nodes/nodes
+home   Home                            /d/home/home
+`
+	if out != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+	query := routes.Requests[0].Query
+	for _, param := range []string{"tag=prod", "folderIds=3", "type=dash-db"} {
+		if !strings.Contains(query, param) {
+			t.Errorf("query %q lacks %s", query, param)
+		}
+	}
+}
+
+func TestDashboardsGetFormats(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"GET /api/dashboards/uid/nodes": `{"meta":{"folderId":3,"slug":"nodes"},"dashboard":{"uid":"nodes","title":"Nodes","version":12}}`,
+	}}
+	server := httptest.NewServer(routes)
+	defer server.Close()
+
+	out, err := runGapi(t, server, nil, "", "-o", "yaml", "dashboards", "get", "nodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `dashboard:
+    title: Nodes
+    uid: nodes
+    version: 12
+meta:
+    folderId: 3
+    isStarred: false
+    slug: nodes
+`
+	if out != want {
+		t.Errorf("yaml:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = runGapi(t, server, nil, "", "-o", "json", "dashboards", "get", "nodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("json: %v\n%s", err, out)
+	}
+	if decoded["dashboard"].(map[string]interface{})["version"] != float64(12) {
+		t.Errorf("json: %s", out)
+	}
+
+	out, err = runGapi(t, server, nil, "", "dashboards", "get", "nodes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = `UID    TITLE  VERSION  FOLDERID  SLUG
+nodes  Nodes  12       3         nodes
+`
+	if out != want {
+		t.Errorf("table:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestDashboardsSave(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"POST /api/dashboards/db": `{"id":4,"uid":"nodes","version":13,"status":"success"}`,
+	}}
+	server := httptest.NewServer(routes)
+	defer server.Close()
+
+	// The output of "gapi -o json dashboards get" keeps its folder.
+	stdin := `{"meta":{"folderId":3},"dashboard":{"uid":"nodes","title":"Nodes","version":12}}`
+	if _, err := runGapi(t, server, nil, stdin, "dashboards", "save", "-overwrite", "-message", "tweak", "-"); err != nil {
+		t.Fatal(err)
+	}
+	body := routes.Requests[0].Body
+	if body["folderId"] != float64(3) || body["overwrite"] != true || body["message"] != "tweak" {
+		t.Errorf("body: %v", body)
+	}
+	if model := body["dashboard"].(map[string]interface{}); model["title"] != "Nodes" {
+		t.Errorf("model: %v", model)
+	}
+
+	// A bare model goes in the folder of the flag.
+	stdin = `{"uid":"nodes","title":"Nodes"}`
+	if _, err := runGapi(t, server, nil, stdin, "dashboards", "save", "-folder", "5", "-"); err != nil {
+		t.Fatal(err)
+	}
+	body = routes.Requests[1].Body
+	if body["folderId"] != float64(5) || body["dashboard"].(map[string]interface{})["uid"] != "nodes" {
+		t.Errorf("body: %v", body)
+	}
+}
+
+func TestAlertsPause(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"POST /api/alerts/12/pause":        `{"alertId":12,"state":"Paused","message":"Alert paused"}`,
+		"POST /api/admin/pause-all-alerts": `{"alertsAffected":7,"state":"Paused","message":"alerts paused"}`,
+	}}
+	server := httptest.NewServer(routes)
+	defer server.Close()
+
+	out, err := runGapi(t, server, nil, "", "alerts", "pause", "12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "12       Paused") {
+		t.Errorf("output:\n%s", out)
+	}
+
+	out, err = runGapi(t, server, nil, "", "-o", "json", "alerts", "pause", "-all")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"alertsAffected": 7`) {
+		t.Errorf("output:\n%s", out)
+	}
+
+	if _, err := runGapi(t, server, nil, "", "alerts", "pause"); err != errUsage {
+		t.Errorf("expected a usage error without an alert, got %v", err)
+	}
+	if _, err := runGapi(t, server, nil, "", "alerts", "pause", "-all", "12"); err != errUsage {
+		t.Errorf("expected a usage error with both -all and an alert, got %v", err)
+	}
+}
+
+func TestAnnotationsCreate(t *testing.T) {
+	routes := &routetest.Server{Routes: map[string]string{
+		"POST /api/annotations": `{"id":99,"message":"Annotation added"}`,
+	}}
+	server := httptest.NewServer(routes)
+	defer server.Close()
+
+	out, err := runGapi(t, server, nil, "", "-o", "json", "annotations", "create",
+		"-dashboard", "4", "-tag", "deploy", "-tag", "api", "-time", "2021-06-01T10:00:00Z", "Deployed v1.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := routes.Requests[0].Body
+	if body["text"] != "Deployed v1.2" || body["dashboardId"] != float64(4) || body["time"] != float64(1622541600000) {
+		t.Errorf("body: %v", body)
+	}
+	if tags := body["tags"].([]interface{}); len(tags) != 2 {
+		t.Errorf("tags: %v", tags)
+	}
+	if !strings.Contains(out, `"id": 99`) {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestContexts(t *testing.T) {
+	prod := &routetest.Server{Routes: map[string]string{"GET /api/folders": `[{"id":1,"uid":"prod","title":"Prod"}]`}}
+	prodServer := httptest.NewServer(prod)
+	defer prodServer.Close()
+	staging := &routetest.Server{Routes: map[string]string{"GET /api/folders": `[{"id":1,"uid":"staging","title":"Staging"}]`}}
+	stagingServer := httptest.NewServer(staging)
+	defer stagingServer.Close()
+
+	dir, err := ioutil.TempDir("", "gapi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yaml")
+	config := `current-context: prod
+contexts:
+  - name: prod
+    url: ` + prodServer.URL + `
+    auth: my-key
+  - name: staging
+    url: ` + stagingServer.URL + `
+    auth: admin:secret
+    org-id: 2
+`
+	if err := ioutil.WriteFile(configFile, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := func() map[string]string {
+		return map[string]string{envConfig: configFile, envURL: ""}
+	}
+
+	if _, err := runGapi(t, nil, env(), "", "folders", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if r := prod.Requests[0]; r.Auth != "Bearer my-key" || r.OrgID != "" {
+		t.Errorf("prod request: %+v", r)
+	}
+
+	if _, err := runGapi(t, nil, env(), "", "-context", "staging", "folders", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if r := staging.Requests[0]; !strings.HasPrefix(r.Auth, "Basic ") || r.OrgID != "2" {
+		t.Errorf("staging request: %+v", r)
+	}
+
+	// The environment overrides the context.
+	e := env()
+	e[envContext] = "staging"
+	e[envOrgID] = "3"
+	if _, err := runGapi(t, nil, e, "", "folders", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if r := staging.Requests[1]; r.OrgID != "3" {
+		t.Errorf("staging request: %+v", r)
+	}
+
+	if _, err := runGapi(t, nil, env(), "", "contexts", "use", "staging"); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runGapi(t, nil, env(), "", "contexts", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		rows = append(rows, strings.Fields(line))
+	}
+	want := [][]string{
+		{"NAME", "URL", "ORGID", "CURRENT"},
+		{"prod", prodServer.URL, "false"},
+		{"staging", stagingServer.URL, "2", "true"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("contexts:\n%s", out)
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("contexts list shows credentials:\n%s", out)
+	}
+
+	if _, err := runGapi(t, nil, env(), "", "-context", "dev", "folders", "list"); err == nil || !strings.Contains(err.Error(), `context "dev" not found`) {
+		t.Errorf("expected an unknown context error, got %v", err)
+	}
+}
+
+func TestNoConfig(t *testing.T) {
+	_, err := runGapi(t, nil, nil, "", "folders", "list")
+	if err == nil || !strings.Contains(err.Error(), "no Grafana configured") {
+		t.Errorf("expected a configuration error, got %v", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"dashboards"},
+		{"dashboards", "rename"},
+		{"dashboards", "get"},
+		{"-o", "xml", "dashboards", "list"},
+		{"folders", "get", "not-an-id"},
+	} {
+		if _, err := runGapi(t, nil, map[string]string{envURL: "http://localhost:3000"}, "", args...); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	if err := run([]string{"-help"}, func(string) string { return "" }, nil, out, errOut); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(errOut.String(), "alerts pause [<id>]") {
+		t.Errorf("usage lacks commands:\n%s", errOut.String())
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+func validFormat(format string) bool {
+	return format == formatTable || format == formatJSON || format == formatYAML
+}
+
+// printValue writes v in the format. Tables have a row per element of v,
+// or a single row if v isn't a list, and a column per field in columns,
+// named by their JSON name. Nested fields are named with dots, such as
+// "meta.folderTitle".
+func printValue(w io.Writer, format string, v interface{}, columns []string) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+
+	case formatYAML:
+		// Go through JSON so that fields are named as in the API.
+		generic, err := toGeneric(v)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(generic)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+
+	generic, err := toGeneric(v)
+	if err != nil {
+		return err
+	}
+	rows, ok := generic.([]interface{})
+	if !ok {
+		rows = []interface{}{generic}
+	}
+	if len(columns) == 0 {
+		columns = keys(rows)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = strings.ToUpper(column[strings.LastIndex(column, ".")+1:])
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = cell(lookup(row, column))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// toGeneric converts v to the maps, slices and scalars of its JSON
+// encoding, keeping numbers as json.Number.
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var generic interface{}
+	if err := d.Decode(&generic); err != nil {
+		return nil, err
+	}
+	return numbersToYAML(generic), nil
+}
+
+// numbersToYAML replaces json.Number values with int64 or float64, which
+// YAML encodes as numbers rather than strings.
+func numbersToYAML(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = numbersToYAML(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = numbersToYAML(value)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// keys returns the sorted top-level keys of the rows.
+func keys(rows []interface{}) []string {
+	seen := map[string]bool{}
+	var columns []string
+	for _, row := range rows {
+		m, ok := row.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+func lookup(v interface{}, column string) interface{} {
+	for _, key := range strings.Split(column, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = cell(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(v)
+}
@@ -2,9 +2,12 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
+	"time"
 )
 
 // DashboardMeta represents Grafana dashboard meta.
@@ -40,12 +43,106 @@ type DashboardSearchResponse struct {
 	FolderUrl   string   `json:"folderUrl"`
 }
 
+// Diff types, for use with DiffDashboardVersions.
+const (
+	DiffTypeJSON  = "json"
+	DiffTypeBasic = "basic"
+)
+
+// Search result types, for use in SearchQuery.Type.
+const (
+	SearchTypeDashboard = "dash-db"
+	SearchTypeFolder    = "dash-folder"
+)
+
+// SearchQuery represents the parameters of a Grafana dashboard search.
+// Zero values are left out of the search.
+type SearchQuery struct {
+	// Query is matched against dashboard and folder titles.
+	Query string
+	// Tags only matches dashboards with all of the tags.
+	Tags          []string
+	FolderIds     []int64
+	FolderUids    []string
+	DashboardIds  []int64
+	DashboardUids []string
+	Starred       bool
+	// Type is either SearchTypeDashboard or SearchTypeFolder.
+	Type string
+	// Sort is the sort order, such as "alpha-asc" or "alpha-desc".
+	Sort  string
+	Limit int
+	Page  int
+}
+
+func (q SearchQuery) values() url.Values {
+	values := url.Values{}
+	if q.Query != "" {
+		values.Set("query", q.Query)
+	}
+	for _, tag := range q.Tags {
+		values.Add("tag", tag)
+	}
+	for _, id := range q.FolderIds {
+		values.Add("folderIds", strconv.FormatInt(id, 10))
+	}
+	for _, uid := range q.FolderUids {
+		values.Add("folderUIDs", uid)
+	}
+	for _, id := range q.DashboardIds {
+		values.Add("dashboardIds", strconv.FormatInt(id, 10))
+	}
+	for _, uid := range q.DashboardUids {
+		values.Add("dashboardUIDs", uid)
+	}
+	if q.Starred {
+		values.Set("starred", "true")
+	}
+	if q.Type != "" {
+		values.Set("type", q.Type)
+	}
+	if q.Sort != "" {
+		values.Set("sort", q.Sort)
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Page > 0 {
+		values.Set("page", strconv.Itoa(q.Page))
+	}
+	return values
+}
+
 // Dashboard represents a Grafana dashboard.
 type Dashboard struct {
 	Meta      DashboardMeta          `json:"meta"`
 	Model     map[string]interface{} `json:"dashboard"`
 	Folder    int64                  `json:"folderId"`
 	Overwrite bool                   `json:"overwrite"`
+	// Message is recorded in the dashboard version history when saving.
+	Message string `json:"message,omitempty"`
+}
+
+// DashboardVersion represents a version of a Grafana dashboard.
+type DashboardVersion struct {
+	Id            int64     `json:"id"`
+	DashboardId   int64     `json:"dashboardId"`
+	ParentVersion int64     `json:"parentVersion"`
+	RestoredFrom  int64     `json:"restoredFrom"`
+	Version       int64     `json:"version"`
+	Created       time.Time `json:"created"`
+	CreatedBy     string    `json:"createdBy"`
+	Message       string    `json:"message"`
+
+	// Data is the dashboard model at this version. It is only set when
+	// fetching a single version.
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// DashboardDiffTarget identifies one side of a dashboard version diff.
+type DashboardDiffTarget struct {
+	DashboardId int64 `json:"dashboardId"`
+	Version     int64 `json:"version"`
 }
 
 // SaveDashboard is a deprecated method for saving a Grafana dashboard. Use NewDashboard.
@@ -61,7 +158,7 @@ func (c *Client) SaveDashboard(model map[string]interface{}, overwrite bool) (*D
 	}
 
 	result := &DashboardSaveResponse{}
-	err = c.request("POST", "/api/dashboards/db", nil, bytes.NewBuffer(data), &result)
+	err = c.request(context.Background(), "POST", "/api/dashboards/db", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return nil, err
 	}
@@ -71,13 +168,18 @@ func (c *Client) SaveDashboard(model map[string]interface{}, overwrite bool) (*D
 
 // NewDashboard creates a new Grafana dashboard.
 func (c *Client) NewDashboard(dashboard Dashboard) (*DashboardSaveResponse, error) {
+	return c.NewDashboardContext(context.Background(), dashboard)
+}
+
+// NewDashboardContext is like NewDashboard but takes a context.
+func (c *Client) NewDashboardContext(ctx context.Context, dashboard Dashboard) (*DashboardSaveResponse, error) {
 	data, err := json.Marshal(dashboard)
 	if err != nil {
 		return nil, err
 	}
 
 	result := &DashboardSaveResponse{}
-	err = c.request("POST", "/api/dashboards/db", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/dashboards/db", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return nil, err
 	}
@@ -87,12 +189,39 @@ func (c *Client) NewDashboard(dashboard Dashboard) (*DashboardSaveResponse, erro
 
 // Dashboards fetches and returns Grafana dashboards.
 func (c *Client) Dashboards() ([]DashboardSearchResponse, error) {
-	dashboards := make([]DashboardSearchResponse, 0)
-	query := url.Values{}
+	return c.DashboardsContext(context.Background())
+}
+
+// DashboardsContext is like Dashboards but takes a context.
+func (c *Client) DashboardsContext(ctx context.Context) ([]DashboardSearchResponse, error) {
 	// search only dashboards
-	query.Add("type", "dash-db")
+	return c.SearchDashboardsContext(ctx, SearchQuery{Type: SearchTypeDashboard})
+}
+
+// SearchDashboards searches Grafana dashboards and folders with the query
+// it's passed. When neither Limit nor Page is set, all pages of results are
+// fetched; otherwise the single page they describe is.
+func (c *Client) SearchDashboards(query SearchQuery) ([]DashboardSearchResponse, error) {
+	return c.SearchDashboardsContext(context.Background(), query)
+}
+
+// SearchDashboardsContext is like SearchDashboards but takes a context.
+func (c *Client) SearchDashboardsContext(ctx context.Context, query SearchQuery) ([]DashboardSearchResponse, error) {
+	dashboards := make([]DashboardSearchResponse, 0)
+
+	if query.Limit > 0 || query.Page > 0 {
+		err := c.request(ctx, "GET", "/api/search", query.values(), nil, &dashboards)
+		if err != nil {
+			return nil, err
+		}
 
-	err := c.request("GET", "/api/search", query, nil, &dashboards)
+		return dashboards, err
+	}
+
+	err := c.ForEachDashboardContext(ctx, query.values(), func(d DashboardSearchResponse) error {
+		dashboards = append(dashboards, d)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -100,26 +229,163 @@ func (c *Client) Dashboards() ([]DashboardSearchResponse, error) {
 	return dashboards, err
 }
 
+// ForEachDashboard calls fn for each result of a search with the params it's
+// passed, walking all pages of results. Return ErrStopPaging from fn to stop early.
+func (c *Client) ForEachDashboard(params url.Values, fn func(DashboardSearchResponse) error) error {
+	return c.ForEachDashboardContext(context.Background(), params, fn)
+}
+
+// ForEachDashboardContext is like ForEachDashboard but takes a context.
+func (c *Client) ForEachDashboardContext(ctx context.Context, params url.Values, fn func(DashboardSearchResponse) error) error {
+	limit := c.perPage()
+	var previous *DashboardSearchResponse
+
+	return paginate(ctx, func(page int) (bool, error) {
+		query := url.Values{}
+		for k, v := range params {
+			query[k] = v
+		}
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("page", strconv.Itoa(page))
+
+		results := make([]DashboardSearchResponse, 0)
+		err := c.request(ctx, "GET", "/api/search", query, nil, &results)
+		if err != nil {
+			return false, err
+		}
+
+		// Grafana versions without search paging ignore the page parameter
+		// and send the first page again.
+		if len(results) > 0 && previous != nil && results[0].Uid == previous.Uid && results[0].Id == previous.Id {
+			return false, nil
+		}
+		if len(results) > 0 {
+			previous = &results[0]
+		}
+
+		for _, result := range results {
+			if err := fn(result); err != nil {
+				return false, err
+			}
+		}
+
+		return morePages(page, limit, len(results), 0), nil
+	})
+}
+
 // DashboardByUid fetches and returns the dashboard whose UID is passed.
 func (c *Client) DashboardByUid(uid string) (*Dashboard, error) {
-	return c.dashboard(fmt.Sprintf("/api/dashboards/uid/%s", uid))
+	return c.DashboardByUidContext(context.Background(), uid)
+}
+
+// DashboardByUidContext is like DashboardByUid but takes a context.
+func (c *Client) DashboardByUidContext(ctx context.Context, uid string) (*Dashboard, error) {
+	return c.dashboard(ctx, fmt.Sprintf("/api/dashboards/uid/%s", uid))
 }
 
 // Dashboard will be removed.
 // Deprecated: Starting from Grafana v5.0. Use DashboardByUid instead.
 func (c *Client) Dashboard(slug string) (*Dashboard, error) {
-	return c.dashboard(fmt.Sprintf("/api/dashboards/db/%s", slug))
+	return c.dashboard(context.Background(), fmt.Sprintf("/api/dashboards/db/%s", slug))
 }
 
 // DashboardByUID will be removed.
 // Deprecated: Interface typo. Use DashboardByUid instead.
 func (c *Client) DashboardByUID(uid string) (*Dashboard, error) {
-	return c.dashboard(fmt.Sprintf("/api/dashboards/uid/%s", uid))
+	return c.dashboard(context.Background(), fmt.Sprintf("/api/dashboards/uid/%s", uid))
 }
 
-func (c *Client) dashboard(path string) (*Dashboard, error) {
+func (c *Client) dashboard(ctx context.Context, path string) (*Dashboard, error) {
 	result := &Dashboard{}
-	err := c.request("GET", path, nil, nil, &result)
+	err := c.request(ctx, "GET", path, nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, err
+}
+
+// DashboardVersions fetches and returns the versions of the dashboard whose ID
+// it's passed, newest first. params may set `limit` and `start`.
+func (c *Client) DashboardVersions(dashboardID int64, params url.Values) ([]DashboardVersion, error) {
+	return c.DashboardVersionsContext(context.Background(), dashboardID, params)
+}
+
+// DashboardVersionsContext is like DashboardVersions but takes a context.
+func (c *Client) DashboardVersionsContext(ctx context.Context, dashboardID int64, params url.Values) ([]DashboardVersion, error) {
+	path := fmt.Sprintf("/api/dashboards/id/%d/versions", dashboardID)
+	versions := make([]DashboardVersion, 0)
+	err := c.request(ctx, "GET", path, params, nil, &versions)
+	if err != nil {
+		return nil, err
+	}
+
+	return versions, err
+}
+
+// DashboardVersion fetches and returns a version of the dashboard whose ID
+// it's passed, including the dashboard model at that version.
+func (c *Client) DashboardVersion(dashboardID, versionID int64) (*DashboardVersion, error) {
+	return c.DashboardVersionContext(context.Background(), dashboardID, versionID)
+}
+
+// DashboardVersionContext is like DashboardVersion but takes a context.
+func (c *Client) DashboardVersionContext(ctx context.Context, dashboardID, versionID int64) (*DashboardVersion, error) {
+	path := fmt.Sprintf("/api/dashboards/id/%d/versions/%d", dashboardID, versionID)
+	result := &DashboardVersion{}
+	err := c.request(ctx, "GET", path, nil, nil, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, err
+}
+
+// DiffDashboardVersions compares the target dashboard version to the base one
+// and returns the diff as rendered by Grafana.
+// diffType is either DiffTypeJSON or DiffTypeBasic.
+func (c *Client) DiffDashboardVersions(base, target DashboardDiffTarget, diffType string) (string, error) {
+	return c.DiffDashboardVersionsContext(context.Background(), base, target, diffType)
+}
+
+// DiffDashboardVersionsContext is like DiffDashboardVersions but takes a context.
+func (c *Client) DiffDashboardVersionsContext(ctx context.Context, base, target DashboardDiffTarget, diffType string) (string, error) {
+	data, err := json.Marshal(map[string]interface{}{
+		"base":     base,
+		"new":      target,
+		"diffType": diffType,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var result []byte
+	err = c.request(ctx, "POST", "/api/dashboards/calculate-diff", nil, bytes.NewBuffer(data), &result)
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), err
+}
+
+// RestoreDashboardVersion restores the dashboard whose ID it's passed to an
+// earlier version. The restore is saved as a new version.
+func (c *Client) RestoreDashboardVersion(dashboardID, version int64) (*DashboardSaveResponse, error) {
+	return c.RestoreDashboardVersionContext(context.Background(), dashboardID, version)
+}
+
+// RestoreDashboardVersionContext is like RestoreDashboardVersion but takes a context.
+func (c *Client) RestoreDashboardVersionContext(ctx context.Context, dashboardID, version int64) (*DashboardSaveResponse, error) {
+	path := fmt.Sprintf("/api/dashboards/id/%d/restore", dashboardID)
+	data, err := json.Marshal(map[string]int64{
+		"version": version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DashboardSaveResponse{}
+	err = c.request(ctx, "POST", path, nil, bytes.NewBuffer(data), result)
 	if err != nil {
 		return nil, err
 	}
@@ -129,21 +395,26 @@ func (c *Client) dashboard(path string) (*Dashboard, error) {
 
 // DeleteDashboardByUid deletes the dashboard whose UID it's passed.
 func (c *Client) DeleteDashboardByUid(uid string) error {
-	return c.deleteDashboard(fmt.Sprintf("/api/dashboards/uid/%s", uid))
+	return c.DeleteDashboardByUidContext(context.Background(), uid)
+}
+
+// DeleteDashboardByUidContext is like DeleteDashboardByUid but takes a context.
+func (c *Client) DeleteDashboardByUidContext(ctx context.Context, uid string) error {
+	return c.deleteDashboard(ctx, fmt.Sprintf("/api/dashboards/uid/%s", uid))
 }
 
 // DeleteDashboard will be removed.
 // Deprecated: Starting from Grafana v5.0. Use DeleteDashboardByUid instead.
 func (c *Client) DeleteDashboard(slug string) error {
-	return c.deleteDashboard(fmt.Sprintf("/api/dashboards/db/%s", slug))
+	return c.deleteDashboard(context.Background(), fmt.Sprintf("/api/dashboards/db/%s", slug))
 }
 
 // DeleteDashboardByUID will be removed.
 // Deprecated: Interface typo. Use DeleteDashboardByUid instead.
 func (c *Client) DeleteDashboardByUID(uid string) error {
-	return c.deleteDashboard(fmt.Sprintf("/api/dashboards/uid/%s", uid))
+	return c.deleteDashboard(context.Background(), fmt.Sprintf("/api/dashboards/uid/%s", uid))
 }
 
-func (c *Client) deleteDashboard(path string) error {
-	return c.request("DELETE", path, nil, nil, nil)
+func (c *Client) deleteDashboard(ctx context.Context, path string) error {
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
 }
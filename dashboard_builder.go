@@ -0,0 +1,625 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// builderSchemaVersion is the schema version of built dashboards.
+	builderSchemaVersion = 27
+
+	gridWidth          = 24
+	defaultPanelWidth  = 12
+	defaultPanelHeight = 8
+)
+
+// Alert condition reducers, evaluators and operators.
+const (
+	ReducerAvg  = "avg"
+	ReducerMin  = "min"
+	ReducerMax  = "max"
+	ReducerSum  = "sum"
+	ReducerLast = "last"
+
+	EvaluatorAbove   = "gt"
+	EvaluatorBelow   = "lt"
+	EvaluatorOutside = "outside_range"
+	EvaluatorWithin  = "within_range"
+	EvaluatorNoValue = "no_value"
+
+	OperatorAnd = "and"
+	OperatorOr  = "or"
+)
+
+// DashboardBuilder builds dashboards in Go. Panels are laid out left to
+// right, wrapping at the grid width, and rows stack below each other.
+//
+//	b := NewDashboardBuilder("Payments").
+//		Tags("team-payments").
+//		Variable(QueryVariable("env", prometheus, "label_values(env)").Multi()).
+//		Row("Traffic",
+//			TimeSeriesPanel("Requests").
+//				Unit("reqps").
+//				Target(PrometheusTarget(`sum(rate(http_requests_total{env="$env"}[5m]))`, "")),
+//		)
+//	dashboard, err := b.Dashboard(folderID, true)
+type DashboardBuilder struct {
+	model  *DashboardModel
+	nextID int64
+	x, y   int64
+	rowH   int64
+	errs   []error
+}
+
+// NewDashboardBuilder starts building a dashboard with the title it's passed.
+func NewDashboardBuilder(title string) *DashboardBuilder {
+	return &DashboardBuilder{
+		model: &DashboardModel{
+			Title:         title,
+			Editable:      true,
+			SchemaVersion: builderSchemaVersion,
+			Time:          &TimeRange{From: "now-6h", To: "now"},
+			Templating:    &Templating{List: []TemplateVar{}},
+			Annotations:   &DashboardAnnotations{List: []AnnotationQuery{}},
+			Panels:        []Panel{},
+		},
+		nextID: 1,
+	}
+}
+
+// Uid sets the dashboard UID.
+func (b *DashboardBuilder) Uid(uid string) *DashboardBuilder {
+	b.model.Uid = uid
+	return b
+}
+
+// Description sets the dashboard description.
+func (b *DashboardBuilder) Description(description string) *DashboardBuilder {
+	b.model.Description = description
+	return b
+}
+
+// Tags adds tags to the dashboard.
+func (b *DashboardBuilder) Tags(tags ...string) *DashboardBuilder {
+	b.model.Tags = append(b.model.Tags, tags...)
+	return b
+}
+
+// Timezone sets the dashboard timezone, such as "browser" or "utc".
+func (b *DashboardBuilder) Timezone(timezone string) *DashboardBuilder {
+	b.model.Timezone = timezone
+	return b
+}
+
+// Time sets the default time range of the dashboard, such as "now-6h" to "now".
+func (b *DashboardBuilder) Time(from, to string) *DashboardBuilder {
+	b.model.Time = &TimeRange{From: from, To: to}
+	return b
+}
+
+// Refresh sets the auto-refresh interval of the dashboard, such as "30s".
+func (b *DashboardBuilder) Refresh(interval string) *DashboardBuilder {
+	b.model.Refresh = RefreshInterval(interval)
+	return b
+}
+
+// ReadOnly makes the dashboard read-only in the Grafana UI.
+func (b *DashboardBuilder) ReadOnly() *DashboardBuilder {
+	b.model.Editable = false
+	return b
+}
+
+// Link adds a link to the top of the dashboard.
+func (b *DashboardBuilder) Link(link DashboardLink) *DashboardBuilder {
+	b.model.Links = append(b.model.Links, link)
+	return b
+}
+
+// Variable adds a template variable to the dashboard.
+func (b *DashboardBuilder) Variable(v *VariableBuilder) *DashboardBuilder {
+	b.model.Templating.List = append(b.model.Templating.List, v.variable)
+	return b
+}
+
+// Annotation adds an annotation query to the dashboard.
+func (b *DashboardBuilder) Annotation(query AnnotationQuery) *DashboardBuilder {
+	b.model.Annotations.List = append(b.model.Annotations.List, query)
+	return b
+}
+
+// Row adds a row with the title and panels it's passed, below the panels
+// added so far.
+func (b *DashboardBuilder) Row(title string, panels ...*PanelBuilder) *DashboardBuilder {
+	b.newLine()
+	b.model.Panels = append(b.model.Panels, Panel{
+		Id:      b.id(),
+		Type:    PanelTypeRow,
+		Title:   title,
+		GridPos: &GridPos{H: 1, W: gridWidth, X: 0, Y: b.y},
+		Panels:  []Panel{},
+	})
+	b.y++
+
+	return b.Panels(panels...)
+}
+
+// Panels adds panels to the dashboard, after the panels added so far.
+func (b *DashboardBuilder) Panels(panels ...*PanelBuilder) *DashboardBuilder {
+	for _, p := range panels {
+		panel, err := p.build()
+		if err != nil {
+			b.errs = append(b.errs, fmt.Errorf("panel %q: %w", p.panel.Title, err))
+			continue
+		}
+
+		w, h := panel.GridPos.W, panel.GridPos.H
+		if b.x+w > gridWidth {
+			b.newLine()
+		}
+		panel.Id = b.id()
+		panel.GridPos.X = b.x
+		panel.GridPos.Y = b.y
+		b.x += w
+		if h > b.rowH {
+			b.rowH = h
+		}
+
+		b.model.Panels = append(b.model.Panels, panel)
+	}
+	return b
+}
+
+func (b *DashboardBuilder) newLine() {
+	if b.x > 0 {
+		b.y += b.rowH
+	}
+	b.x, b.rowH = 0, 0
+}
+
+func (b *DashboardBuilder) id() int64 {
+	id := b.nextID
+	b.nextID++
+	return id
+}
+
+// Build returns the dashboard model, or the errors found while building it.
+// The model is a copy, which later calls to the builder don't change, so
+// the builder can go on being used to build variants of the dashboard.
+func (b *DashboardBuilder) Build() (*DashboardModel, error) {
+	errs := append([]error(nil), b.errs...)
+	if b.model.Title == "" {
+		errs = append(errs, errors.New("dashboard title is required"))
+	}
+	names := map[string]bool{}
+	for _, v := range b.model.Templating.List {
+		if names[v.Name] {
+			errs = append(errs, fmt.Errorf("duplicate variable %q", v.Name))
+		}
+		names[v.Name] = true
+	}
+
+	if len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, fmt.Errorf("invalid dashboard: %s", strings.Join(msgs, "; "))
+	}
+
+	data, err := json.Marshal(b.model)
+	if err != nil {
+		return nil, err
+	}
+	model := &DashboardModel{}
+	if err := json.Unmarshal(data, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// Dashboard builds the dashboard, ready to be saved with NewDashboard into
+// the folder whose ID it's passed.
+func (b *DashboardBuilder) Dashboard(folder int64, overwrite bool) (Dashboard, error) {
+	dashboard := Dashboard{
+		Folder:    folder,
+		Overwrite: overwrite,
+	}
+
+	model, err := b.Build()
+	if err != nil {
+		return dashboard, err
+	}
+
+	err = dashboard.SetModel(model)
+	return dashboard, err
+}
+
+// PanelBuilder builds a dashboard panel.
+type PanelBuilder struct {
+	panel   Panel
+	options interface{}
+	errs    []error
+}
+
+// NewPanelBuilder starts building a panel of the type and title it's passed.
+func NewPanelBuilder(panelType, title string) *PanelBuilder {
+	return &PanelBuilder{
+		panel: Panel{
+			Type:    panelType,
+			Title:   title,
+			GridPos: &GridPos{W: defaultPanelWidth, H: defaultPanelHeight},
+		},
+	}
+}
+
+// TimeSeriesPanel starts building a time series panel.
+func TimeSeriesPanel(title string) *PanelBuilder {
+	return NewPanelBuilder(PanelTypeTimeSeries, title)
+}
+
+// GraphPanel starts building a legacy graph panel, which supports alerts.
+func GraphPanel(title string) *PanelBuilder {
+	p := NewPanelBuilder(PanelTypeGraph, title)
+	p.panel.Lines = true
+	p.panel.Fill = 1
+	p.panel.LineWidth = 1
+	p.panel.Legend = &Legend{Show: true}
+	p.panel.YAxes = []Axis{{Format: "short", Show: true}, {Format: "short", Show: true}}
+	return p
+}
+
+// StatPanel starts building a stat panel.
+func StatPanel(title string) *PanelBuilder {
+	return NewPanelBuilder(PanelTypeStat, title).Size(6, 4)
+}
+
+// GaugePanel starts building a gauge panel.
+func GaugePanel(title string) *PanelBuilder {
+	return NewPanelBuilder(PanelTypeGauge, title).Size(6, 6)
+}
+
+// TablePanel starts building a table panel.
+func TablePanel(title string) *PanelBuilder {
+	return NewPanelBuilder(PanelTypeTable, title).Size(gridWidth, defaultPanelHeight)
+}
+
+// TextPanel starts building a text panel showing the markdown it's passed.
+func TextPanel(title, markdown string) *PanelBuilder {
+	return NewPanelBuilder(PanelTypeText, title).Options(map[string]string{
+		"mode":    "markdown",
+		"content": markdown,
+	})
+}
+
+// Description sets the panel description.
+func (p *PanelBuilder) Description(description string) *PanelBuilder {
+	p.panel.Description = description
+	return p
+}
+
+// Size sets the width and height of the panel, in grid units.
+// The grid is 24 units wide.
+func (p *PanelBuilder) Size(w, h int64) *PanelBuilder {
+	if w <= 0 || w > gridWidth || h <= 0 {
+		p.errs = append(p.errs, fmt.Errorf("invalid size %dx%d", w, h))
+		return p
+	}
+	p.panel.GridPos.W, p.panel.GridPos.H = w, h
+	return p
+}
+
+// Datasource sets the datasource queried by the panel.
+func (p *PanelBuilder) Datasource(ref DataSourceRef) *PanelBuilder {
+	p.panel.Datasource = &ref
+	return p
+}
+
+// Target adds a query to the panel. Reference IDs are assigned in order,
+// from A, unless the target sets one.
+func (p *PanelBuilder) Target(target Target) *PanelBuilder {
+	if target.RefId == "" {
+		target.RefId = refID(len(p.panel.Targets))
+	}
+	p.panel.Targets = append(p.panel.Targets, target)
+	return p
+}
+
+// Unit sets the unit of the panel values, such as "reqps", "bytes" or "percent".
+func (p *PanelBuilder) Unit(unit string) *PanelBuilder {
+	p.defaults().Unit = unit
+	return p
+}
+
+// Decimals sets the number of decimals shown for the panel values.
+func (p *PanelBuilder) Decimals(decimals int64) *PanelBuilder {
+	p.defaults().Decimals = &decimals
+	return p
+}
+
+// Min sets the minimum of the panel value range.
+func (p *PanelBuilder) Min(min float64) *PanelBuilder {
+	p.defaults().Min = &min
+	return p
+}
+
+// Max sets the maximum of the panel value range.
+func (p *PanelBuilder) Max(max float64) *PanelBuilder {
+	p.defaults().Max = &max
+	return p
+}
+
+// Threshold adds a threshold at which panel values change color. Values
+// below every threshold are green.
+func (p *PanelBuilder) Threshold(value float64, color string) *PanelBuilder {
+	d := p.defaults()
+	if d.Thresholds == nil {
+		d.Thresholds = &Thresholds{
+			Mode:  "absolute",
+			Steps: []ThresholdStep{{Color: "green"}},
+		}
+	}
+	d.Thresholds.Steps = append(d.Thresholds.Steps, ThresholdStep{Color: color, Value: &value})
+	return p
+}
+
+// Options sets the panel specific options, encoded as JSON.
+func (p *PanelBuilder) Options(options interface{}) *PanelBuilder {
+	p.options = options
+	return p
+}
+
+// Alert sets the legacy alert rule of a graph panel.
+func (p *PanelBuilder) Alert(alert *AlertBuilder) *PanelBuilder {
+	p.panel.Alert = &alert.alert
+	return p
+}
+
+func (p *PanelBuilder) defaults() *FieldConfigDefaults {
+	if p.panel.FieldConfig == nil {
+		p.panel.FieldConfig = &FieldConfig{Overrides: []FieldOverride{}}
+	}
+	return &p.panel.FieldConfig.Defaults
+}
+
+func (p *PanelBuilder) build() (Panel, error) {
+	if len(p.errs) > 0 {
+		return Panel{}, p.errs[0]
+	}
+
+	// Copy the panel, so that later calls don't change the panels built.
+	data, err := json.Marshal(p.panel)
+	if err != nil {
+		return Panel{}, err
+	}
+	panel := Panel{}
+	if err := json.Unmarshal(data, &panel); err != nil {
+		return Panel{}, err
+	}
+	if p.options != nil {
+		if err := panel.SetOptions(p.options); err != nil {
+			return Panel{}, err
+		}
+	}
+	if panel.Alert != nil {
+		if panel.Type != PanelTypeGraph {
+			return Panel{}, errors.New("alerts are only supported by graph panels")
+		}
+		if len(panel.Alert.Conditions) == 0 {
+			return Panel{}, errors.New("alert has no conditions")
+		}
+		for _, c := range panel.Alert.Conditions {
+			if !hasTarget(panel.Targets, c.Query.Params[0]) {
+				return Panel{}, fmt.Errorf("alert condition references unknown query %q", c.Query.Params[0])
+			}
+		}
+	}
+	return panel, nil
+}
+
+func hasTarget(targets []Target, refID string) bool {
+	for _, t := range targets {
+		if t.RefId == refID {
+			return true
+		}
+	}
+	return false
+}
+
+// refID returns the reference ID of the i-th target: A to Z, then AA, AB...
+func refID(i int) string {
+	id := ""
+	for i >= 0 {
+		id = string(rune('A'+i%26)) + id
+		i = i/26 - 1
+	}
+	return id
+}
+
+// PrometheusTarget returns a Prometheus query with the legend format it's passed.
+func PrometheusTarget(expr, legendFormat string) Target {
+	return Target{Expr: expr, LegendFormat: legendFormat}
+}
+
+// LokiTarget returns a Loki query.
+func LokiTarget(expr string) Target {
+	return Target{Expr: expr}
+}
+
+// GraphiteTarget returns a Graphite query.
+func GraphiteTarget(target string) Target {
+	return Target{Target: target}
+}
+
+// InfluxDBTarget returns a raw InfluxQL query.
+func InfluxDBTarget(query string) Target {
+	return Target{Query: query, RawQuery: true, ResultFormat: "time_series"}
+}
+
+// SQLTarget returns a MySQL, PostgreSQL or MSSQL query, whose format is
+// either "time_series" or "table".
+func SQLTarget(rawSQL, format string) Target {
+	return Target{RawSQL: rawSQL, Format: format}
+}
+
+// AlertBuilder builds a legacy graph panel alert rule.
+type AlertBuilder struct {
+	alert PanelAlert
+}
+
+// NewAlertBuilder starts building an alert rule, evaluated every minute.
+func NewAlertBuilder(name string) *AlertBuilder {
+	return &AlertBuilder{
+		alert: PanelAlert{
+			Name:                name,
+			Frequency:           "1m",
+			Handler:             1,
+			NoDataState:         "no_data",
+			ExecutionErrorState: "alerting",
+			Conditions:          []AlertCondition{},
+		},
+	}
+}
+
+// Message sets the message sent with alert notifications.
+func (a *AlertBuilder) Message(message string) *AlertBuilder {
+	a.alert.Message = message
+	return a
+}
+
+// Frequency sets how often the rule is evaluated, such as "1m".
+func (a *AlertBuilder) Frequency(frequency string) *AlertBuilder {
+	a.alert.Frequency = frequency
+	return a
+}
+
+// For sets how long the condition must hold before the alert fires, such as "5m".
+func (a *AlertBuilder) For(duration string) *AlertBuilder {
+	a.alert.For = duration
+	return a
+}
+
+// Notify adds the alert notification channel whose UID it's passed.
+func (a *AlertBuilder) Notify(uid string) *AlertBuilder {
+	a.alert.Notifications = append(a.alert.Notifications, map[string]interface{}{"uid": uid})
+	return a
+}
+
+// Condition adds a condition to the rule: the reducer, such as ReducerAvg,
+// applied to the query refID over the time range from "from" to now, is
+// compared with the evaluator, such as EvaluatorAbove, and its params.
+// Conditions after the first are joined with the operator, such as OperatorAnd.
+func (a *AlertBuilder) Condition(operator, reducer, refID, from, evaluator string, params ...float64) *AlertBuilder {
+	if params == nil {
+		params = []float64{}
+	}
+	a.alert.Conditions = append(a.alert.Conditions, AlertCondition{
+		Type:      "query",
+		Query:     AlertConditionQuery{Params: []string{refID, from, "now"}},
+		Reducer:   AlertConditionReducer{Type: reducer, Params: []interface{}{}},
+		Evaluator: AlertConditionParams{Type: evaluator, Params: params},
+		Operator:  AlertConditionParams{Type: operator},
+	})
+	return a
+}
+
+// VariableBuilder builds a dashboard template variable.
+type VariableBuilder struct {
+	variable TemplateVar
+}
+
+// QueryVariable starts building a variable whose values are queried from a datasource.
+func QueryVariable(name string, datasource DataSourceRef, query string) *VariableBuilder {
+	return &VariableBuilder{
+		variable: TemplateVar{
+			Name:       name,
+			Type:       TemplateVarQuery,
+			Datasource: &datasource,
+			Query:      query,
+			Definition: query,
+			Refresh:    1,
+		},
+	}
+}
+
+// CustomVariable starts building a variable with the values it's passed.
+func CustomVariable(name string, values ...string) *VariableBuilder {
+	v := &VariableBuilder{
+		variable: TemplateVar{
+			Name:  name,
+			Type:  TemplateVarCustom,
+			Query: strings.Join(values, ","),
+		},
+	}
+	for i, value := range values {
+		v.variable.Options = append(v.variable.Options, TemplateVarOption{Selected: i == 0, Text: value, Value: value})
+	}
+	if len(values) > 0 {
+		v.variable.Current = &TemplateVarOption{Selected: true, Text: values[0], Value: values[0]}
+	}
+	return v
+}
+
+// IntervalVariable starts building a variable with the intervals it's passed, such as "1m".
+func IntervalVariable(name string, intervals ...string) *VariableBuilder {
+	v := CustomVariable(name, intervals...)
+	v.variable.Type = TemplateVarInterval
+	return v
+}
+
+// ConstantVariable starts building a hidden variable with a constant value.
+func ConstantVariable(name, value string) *VariableBuilder {
+	return &VariableBuilder{
+		variable: TemplateVar{
+			Name:  name,
+			Type:  TemplateVarConstant,
+			Query: value,
+			Hide:  2,
+		},
+	}
+}
+
+// DataSourceVariable starts building a variable selecting a datasource of
+// the plugin type it's passed, such as "prometheus".
+func DataSourceVariable(name, pluginType string) *VariableBuilder {
+	return &VariableBuilder{
+		variable: TemplateVar{
+			Name:    name,
+			Type:    TemplateVarDataSource,
+			Query:   pluginType,
+			Refresh: 1,
+		},
+	}
+}
+
+// Label sets the label shown for the variable.
+func (v *VariableBuilder) Label(label string) *VariableBuilder {
+	v.variable.Label = label
+	return v
+}
+
+// Multi allows several values of the variable to be selected.
+func (v *VariableBuilder) Multi() *VariableBuilder {
+	v.variable.Multi = true
+	return v
+}
+
+// IncludeAll adds an "All" option to the variable.
+func (v *VariableBuilder) IncludeAll() *VariableBuilder {
+	v.variable.IncludeAll = true
+	return v
+}
+
+// Regex sets the regex filtering or capturing the values of the variable.
+func (v *VariableBuilder) Regex(regex string) *VariableBuilder {
+	v.variable.Regex = regex
+	return v
+}
+
+// Hide hides the variable from the dashboard.
+func (v *VariableBuilder) Hide() *VariableBuilder {
+	v.variable.Hide = 2
+	return v
+}
@@ -0,0 +1,188 @@
+package gapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gobs/pretty"
+)
+
+func TestDashboardBuilder(t *testing.T) {
+	prometheus := DataSourceRef{Type: "prometheus", Uid: "prom"}
+
+	dashboard, err := NewDashboardBuilder("Payments").
+		Uid("payments").
+		Tags("team-payments").
+		Refresh("30s").
+		Variable(QueryVariable("env", prometheus, "label_values(env)").Multi().IncludeAll()).
+		Variable(IntervalVariable("interval", "1m", "5m")).
+		Row("Traffic",
+			GraphPanel("Requests").
+				Datasource(prometheus).
+				Unit("reqps").
+				Target(PrometheusTarget(`sum(rate(http_requests_total{env="$env"}[5m]))`, "")).
+				Target(PrometheusTarget(`sum(rate(http_requests_total{code=~"5.."}[5m]))`, "errors")).
+				Alert(NewAlertBuilder("High error rate").
+					For("5m").
+					Condition(OperatorAnd, ReducerAvg, "B", "5m", EvaluatorAbove, 10)),
+			StatPanel("Error ratio").
+				Unit("percent").
+				Threshold(1, "orange").
+				Threshold(5, "red"),
+			TablePanel("Top endpoints"),
+		).
+		Row("Notes", TextPanel("Runbook", "# Runbook")).
+		Dashboard(3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(dashboard))
+
+	if dashboard.Folder != 3 || !dashboard.Overwrite {
+		t.Errorf("Unexpected folder or overwrite: %d, %v", dashboard.Folder, dashboard.Overwrite)
+	}
+
+	// The dashboard must survive a trip through JSON, as NewDashboard sends it.
+	data, err := json.Marshal(dashboard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent Dashboard
+	if err := json.Unmarshal(data, &sent); err != nil {
+		t.Fatal(err)
+	}
+	model, err := sent.DecodeModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if model.Uid != "payments" || model.Title != "Payments" || string(model.Refresh) != "30s" {
+		t.Errorf("Unexpected dashboard: %#v", model)
+	}
+	if len(model.Templating.List) != 2 || !model.Templating.List[0].Multi || model.Templating.List[1].Type != TemplateVarInterval {
+		t.Errorf("Unexpected variables: %#v", model.Templating.List)
+	}
+
+	type layout struct {
+		id         int64
+		panelType  string
+		x, y, w, h int64
+	}
+	expected := []layout{
+		{1, PanelTypeRow, 0, 0, 24, 1},
+		{2, PanelTypeGraph, 0, 1, 12, 8},
+		{3, PanelTypeStat, 12, 1, 6, 4},
+		{4, PanelTypeTable, 0, 9, 24, 8},
+		{5, PanelTypeRow, 0, 17, 24, 1},
+		{6, PanelTypeText, 0, 18, 12, 8},
+	}
+	if len(model.Panels) != len(expected) {
+		t.Fatalf("Expected %d panels, got %d", len(expected), len(model.Panels))
+	}
+	for i, e := range expected {
+		p := model.Panels[i]
+		actual := layout{p.Id, p.Type, p.GridPos.X, p.GridPos.Y, p.GridPos.W, p.GridPos.H}
+		if actual != e {
+			t.Errorf("Panel %d: expected %+v, got %+v", i, e, actual)
+		}
+	}
+
+	graph := model.Panels[1]
+	if graph.Targets[0].RefId != "A" || graph.Targets[1].RefId != "B" {
+		t.Errorf("Unexpected targets: %#v", graph.Targets)
+	}
+	if graph.Alert == nil || graph.Alert.Conditions[0].Evaluator.Params[0] != 10 || graph.Alert.Conditions[0].Query.Params[0] != "B" {
+		t.Errorf("Unexpected alert: %#v", graph.Alert)
+	}
+
+	steps := model.Panels[2].FieldConfig.Defaults.Thresholds.Steps
+	if len(steps) != 3 || steps[0].Value != nil || steps[0].Color != "green" || *steps[2].Value != 5 {
+		t.Errorf("Unexpected thresholds: %#v", steps)
+	}
+
+	var options map[string]string
+	if err := model.Panels[5].DecodeOptions(&options); err != nil {
+		t.Fatal(err)
+	}
+	if options["content"] != "# Runbook" {
+		t.Errorf("Unexpected text panel options: %#v", options)
+	}
+}
+
+func TestDashboardBuilder_invalid(t *testing.T) {
+	builders := map[string]*DashboardBuilder{
+		"no title": NewDashboardBuilder(""),
+		"duplicate variable": NewDashboardBuilder("d").
+			Variable(ConstantVariable("x", "1")).
+			Variable(ConstantVariable("x", "2")),
+		"alert on time series": NewDashboardBuilder("d").
+			Panels(TimeSeriesPanel("p").
+				Target(PrometheusTarget("up", "")).
+				Alert(NewAlertBuilder("a").Condition(OperatorAnd, ReducerAvg, "A", "5m", EvaluatorAbove, 1))),
+		"alert on unknown query": NewDashboardBuilder("d").
+			Panels(GraphPanel("p").
+				Target(PrometheusTarget("up", "")).
+				Alert(NewAlertBuilder("a").Condition(OperatorAnd, ReducerAvg, "B", "5m", EvaluatorAbove, 1))),
+		"alert without conditions": NewDashboardBuilder("d").
+			Panels(GraphPanel("p").
+				Target(PrometheusTarget("up", "")).
+				Alert(NewAlertBuilder("a"))),
+		"invalid size": NewDashboardBuilder("d").Panels(StatPanel("p").Size(30, 4)),
+	}
+
+	for name, b := range builders {
+		if _, err := b.Dashboard(0, false); err == nil {
+			t.Errorf("%s: error not detected", name)
+		}
+	}
+}
+
+func TestRefID(t *testing.T) {
+	for i, expected := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if actual := refID(i); actual != expected {
+			t.Errorf("refID(%d): expected %s, got %s", i, expected, actual)
+		}
+	}
+}
+
+func TestDashboardBuilder_reuse(t *testing.T) {
+	b := NewDashboardBuilder("Service").Panels(StatPanel("Up"))
+	first, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Description("Staging").Panels(StatPanel("Errors"))
+	second, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Description != "" || len(first.Panels) != 1 || second.Description != "Staging" || len(second.Panels) != 2 {
+		t.Errorf("Built models share state: %q %d, %q %d", first.Description, len(first.Panels), second.Description, len(second.Panels))
+	}
+
+	// Panels don't change with their builders once added.
+	alert := NewAlertBuilder("Down").Condition(OperatorAnd, ReducerLast, "A", "5m", EvaluatorBelow, 1)
+	panel := GraphPanel("Up").Target(PrometheusTarget("up", "")).Threshold(1, "red").Alert(alert)
+	b = NewDashboardBuilder("Service").Panels(panel)
+	panel.Target(PrometheusTarget("down", "")).Threshold(2, "orange")
+	alert.Condition(OperatorOr, ReducerLast, "B", "5m", EvaluatorAbove, 1)
+	model, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	built := model.Panels[0]
+	if len(built.Targets) != 1 || len(built.FieldConfig.Defaults.Thresholds.Steps) != 2 || len(built.Alert.Conditions) != 1 {
+		t.Errorf("Added panel changed: %d targets, %d steps, %d conditions",
+			len(built.Targets), len(built.FieldConfig.Defaults.Thresholds.Steps), len(built.Alert.Conditions))
+	}
+
+	// Errors are reported once per build.
+	b = NewDashboardBuilder("")
+	b.Build()
+	if _, err := b.Build(); err == nil || strings.Count(err.Error(), "title is required") != 1 {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,438 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Dashboard input types.
+const (
+	DashboardInputDataSource = "datasource"
+	DashboardInputConstant   = "constant"
+)
+
+// DashboardInput is an input of a portable dashboard, listed in its
+// `__inputs`. The dashboard refers to it as ${Name}.
+type DashboardInput struct {
+	Name        string `json:"name"`
+	Label       string `json:"label,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type"`
+	PluginId    string `json:"pluginId,omitempty"`
+	PluginName  string `json:"pluginName,omitempty"`
+	// Value is the name or UID of the datasource for datasource inputs,
+	// and the value for constant inputs.
+	Value string `json:"value,omitempty"`
+}
+
+// DashboardImport represents a request to import a portable dashboard.
+type DashboardImport struct {
+	Dashboard map[string]interface{} `json:"dashboard"`
+	// Inputs sets the values of the dashboard inputs. Datasource inputs left
+	// out are resolved to the default datasource of their plugin, or the
+	// only one; constant inputs left out keep their exported value.
+	Inputs    []DashboardInput `json:"inputs"`
+	Folder    int64            `json:"folderId"`
+	FolderUid string           `json:"folderUid,omitempty"`
+	Overwrite bool             `json:"overwrite"`
+}
+
+// DashboardImportResponse represents the Grafana API response to importing a dashboard.
+type DashboardImportResponse struct {
+	Uid         string `json:"uid"`
+	PluginId    string `json:"pluginId"`
+	Title       string `json:"title"`
+	Imported    bool   `json:"imported"`
+	ImportedUrl string `json:"importedUrl"`
+	Slug        string `json:"slug"`
+	DashboardId int64  `json:"dashboardId"`
+	FolderId    int64  `json:"folderId"`
+	Revision    int64  `json:"revision"`
+	Path        string `json:"path"`
+}
+
+// dataSourceSummary is a datasource as listed by /api/datasources.
+type dataSourceSummary struct {
+	Uid       string `json:"uid"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	TypeName  string `json:"typeName"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+func (c *Client) dataSourceSummaries(ctx context.Context) ([]dataSourceSummary, error) {
+	result := []dataSourceSummary{}
+	err := c.request(ctx, "GET", "/api/datasources", nil, nil, &result)
+	return result, err
+}
+
+// ExportDashboard fetches the dashboard whose UID it's passed and returns it
+// in Grafana's portable format: its datasources are replaced with
+// ${DS_NAME} placeholders listed in `__inputs`, to be resolved by ImportDashboard.
+func (c *Client) ExportDashboard(uid string) (map[string]interface{}, error) {
+	return c.ExportDashboardContext(context.Background(), uid)
+}
+
+// ExportDashboardContext is like ExportDashboard but takes a context.
+func (c *Client) ExportDashboardContext(ctx context.Context, uid string) (map[string]interface{}, error) {
+	dashboard, err := c.DashboardByUidContext(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	dataSources, err := c.dataSourceSummaries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return exportDashboard(dashboard.Model, dataSources), nil
+}
+
+// ImportDashboard imports a portable dashboard, such as one returned by
+// ExportDashboard, resolving its inputs to datasources of the org.
+func (c *Client) ImportDashboard(imp DashboardImport) (*DashboardImportResponse, error) {
+	return c.ImportDashboardContext(context.Background(), imp)
+}
+
+// ImportDashboardContext is like ImportDashboard but takes a context.
+func (c *Client) ImportDashboardContext(ctx context.Context, imp DashboardImport) (*DashboardImportResponse, error) {
+	inputs := DashboardInputs(imp.Dashboard)
+	if len(inputs) > 0 {
+		var dataSources []dataSourceSummary
+		for _, input := range inputs {
+			if input.Type != DashboardInputDataSource {
+				continue
+			}
+			var err error
+			if dataSources, err = c.dataSourceSummaries(ctx); err != nil {
+				return nil, err
+			}
+			break
+		}
+
+		resolved, err := resolveDashboardInputs(inputs, imp.Inputs, dataSources)
+		if err != nil {
+			return nil, err
+		}
+		imp.Dashboard = substituteDashboardInputs(imp.Dashboard, resolved)
+		imp.Inputs = make([]DashboardInput, 0, len(resolved))
+		for _, r := range resolved {
+			imp.Inputs = append(imp.Inputs, r.input)
+		}
+	}
+	if imp.Inputs == nil {
+		imp.Inputs = []DashboardInput{}
+	}
+
+	data, err := json.Marshal(imp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DashboardImportResponse{}
+	err = c.request(ctx, "POST", "/api/dashboards/import", nil, bytes.NewBuffer(data), result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, err
+}
+
+// DashboardInputs returns the inputs listed in the `__inputs` of a portable dashboard.
+func DashboardInputs(dashboard map[string]interface{}) []DashboardInput {
+	raw, ok := dashboard["__inputs"]
+	if !ok {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	inputs := []DashboardInput{}
+	if json.Unmarshal(data, &inputs) != nil {
+		return nil
+	}
+	return inputs
+}
+
+func exportDashboard(model map[string]interface{}, dataSources []dataSourceSummary) map[string]interface{} {
+	e := &dashboardExporter{
+		dataSources: dataSources,
+		inputs:      map[string]string{},
+		names:       map[string]bool{},
+	}
+	exported := e.walk(model, false).(map[string]interface{})
+	exported["id"] = nil
+
+	// Maps are walked in random order, so sort the inputs found.
+	sort.Slice(e.used, func(i, j int) bool {
+		return e.input(e.used[i]) < e.input(e.used[j])
+	})
+	inputs := make([]interface{}, 0, len(e.used))
+	requires := map[string]map[string]interface{}{}
+	for _, ds := range e.used {
+		pluginName := ds.TypeName
+		if pluginName == "" {
+			pluginName = ds.Type
+		}
+		inputs = append(inputs, map[string]interface{}{
+			"name":        e.input(ds),
+			"label":       ds.Name,
+			"description": "",
+			"type":        DashboardInputDataSource,
+			"pluginId":    ds.Type,
+			"pluginName":  pluginName,
+		})
+		requires["datasource/"+ds.Type] = map[string]interface{}{
+			"type": "datasource", "id": ds.Type, "name": pluginName, "version": "",
+		}
+	}
+	for _, panelType := range panelTypes(model) {
+		requires["panel/"+panelType] = map[string]interface{}{
+			"type": "panel", "id": panelType, "name": panelType, "version": "",
+		}
+	}
+
+	keys := make([]string, 0, len(requires))
+	for key := range requires {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	requireList := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		requireList = append(requireList, requires[key])
+	}
+
+	exported["__inputs"] = inputs
+	exported["__requires"] = requireList
+	return exported
+}
+
+type dashboardExporter struct {
+	dataSources []dataSourceSummary
+	// inputs maps datasources, by UID and name, to input names.
+	inputs map[string]string
+	names  map[string]bool
+	used   []dataSourceSummary
+}
+
+// walk returns a copy of v with datasource references replaced by
+// placeholders. isRef is set when v is the value of a "datasource" key.
+func (e *dashboardExporter) walk(v interface{}, isRef bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if isRef {
+			if uid, ok := v["uid"].(string); ok {
+				if ds := e.find(func(ds dataSourceSummary) bool { return ds.Uid == uid }, uid); ds != nil {
+					ref := make(map[string]interface{}, len(v))
+					for key, value := range v {
+						ref[key] = value
+					}
+					ref["uid"] = "${" + e.input(*ds) + "}"
+					return ref
+				}
+			}
+		}
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[key] = e.walk(value, key == "datasource")
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, value := range v {
+			result[i] = e.walk(value, false)
+		}
+		return result
+	case string:
+		if isRef {
+			if ds := e.find(func(ds dataSourceSummary) bool { return ds.Name == v }, v); ds != nil {
+				return "${" + e.input(*ds) + "}"
+			}
+		}
+		return v
+	}
+	return v
+}
+
+func (e *dashboardExporter) find(match func(dataSourceSummary) bool, ref string) *dataSourceSummary {
+	// Variables and built-in datasources such as "-- Grafana --" stay as they are.
+	if ref == "" || strings.HasPrefix(ref, "$") || strings.HasPrefix(ref, "-- ") {
+		return nil
+	}
+	for i := range e.dataSources {
+		if match(e.dataSources[i]) {
+			return &e.dataSources[i]
+		}
+	}
+	return nil
+}
+
+// input returns the name of the input standing for the datasource, such as DS_PROMETHEUS.
+func (e *dashboardExporter) input(ds dataSourceSummary) string {
+	key := ds.Uid + "/" + ds.Name
+	if name, ok := e.inputs[key]; ok {
+		return name
+	}
+
+	base := "DS_" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, ds.Name)
+	name := base
+	for i := 2; e.names[name]; i++ {
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+
+	e.inputs[key] = name
+	e.names[name] = true
+	e.used = append(e.used, ds)
+	return name
+}
+
+// panelTypes returns the sorted types of the panels of a dashboard model,
+// including panels nested in rows.
+func panelTypes(model map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var collect func(panels interface{})
+	collect = func(panels interface{}) {
+		list, _ := panels.([]interface{})
+		for _, p := range list {
+			panel, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if t, ok := panel["type"].(string); ok && t != PanelTypeRow {
+				seen[t] = true
+			}
+			collect(panel["panels"])
+		}
+	}
+	collect(model["panels"])
+	if rows, ok := model["rows"].([]interface{}); ok {
+		for _, row := range rows {
+			if r, ok := row.(map[string]interface{}); ok {
+				collect(r["panels"])
+			}
+		}
+	}
+
+	types := make([]string, 0, len(seen))
+	for t := range seen {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+type resolvedInput struct {
+	input DashboardInput
+	// name and uid are set for datasource inputs.
+	name, uid string
+}
+
+func resolveDashboardInputs(inputs, values []DashboardInput, dataSources []dataSourceSummary) ([]resolvedInput, error) {
+	given := map[string]string{}
+	for _, v := range values {
+		given[v.Name] = v.Value
+	}
+
+	resolved := make([]resolvedInput, 0, len(inputs))
+	for _, input := range inputs {
+		value, ok := given[input.Name]
+		if input.Type != DashboardInputDataSource {
+			if !ok {
+				value = input.Value
+			}
+			resolved = append(resolved, resolvedInput{
+				input: DashboardInput{Name: input.Name, Type: input.Type, Value: value},
+			})
+			continue
+		}
+
+		var ds *dataSourceSummary
+		var candidates []dataSourceSummary
+		for i := range dataSources {
+			d := dataSources[i]
+			if ok && (d.Uid == value || d.Name == value) {
+				ds = &dataSources[i]
+				break
+			}
+			if !ok && d.Type == input.PluginId {
+				candidates = append(candidates, d)
+				if d.IsDefault {
+					ds = &dataSources[i]
+				}
+			}
+		}
+		if ds == nil && len(candidates) == 1 {
+			ds = &candidates[0]
+		}
+		if ds == nil {
+			if ok {
+				return nil, fmt.Errorf("input %s: datasource %q not found", input.Name, value)
+			}
+			return nil, fmt.Errorf("input %s: found %d %s datasources, set one in Inputs", input.Name, len(candidates), input.PluginId)
+		}
+
+		value = ds.Uid
+		if value == "" {
+			value = ds.Name
+		}
+		resolved = append(resolved, resolvedInput{
+			input: DashboardInput{Name: input.Name, Type: input.Type, PluginId: ds.Type, Value: value},
+			name:  ds.Name,
+			uid:   ds.Uid,
+		})
+	}
+	return resolved, nil
+}
+
+// substituteDashboardInputs returns a copy of the dashboard with the input
+// placeholders replaced. Datasources referenced by UID get the UID of the
+// resolved datasource; every other placeholder gets its name or value.
+func substituteDashboardInputs(dashboard map[string]interface{}, inputs []resolvedInput) map[string]interface{} {
+	uids := map[string]string{}
+	pairs := []string{}
+	for _, r := range inputs {
+		placeholder := "${" + r.input.Name + "}"
+		if r.input.Type == DashboardInputDataSource {
+			uids[placeholder] = r.uid
+			pairs = append(pairs, placeholder, r.name)
+		} else {
+			pairs = append(pairs, placeholder, r.input.Value)
+		}
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	var walk func(v interface{}, isRef bool) interface{}
+	walk = func(v interface{}, isRef bool) interface{} {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			result := make(map[string]interface{}, len(v))
+			for key, value := range v {
+				result[key] = walk(value, key == "datasource")
+			}
+			if uid, ok := v["uid"].(string); ok && isRef && uids[uid] != "" {
+				result["uid"] = uids[uid]
+			}
+			return result
+		case []interface{}:
+			result := make([]interface{}, len(v))
+			for i, value := range v {
+				result[i] = walk(value, false)
+			}
+			return result
+		case string:
+			return replacer.Replace(v)
+		}
+		return v
+	}
+	return walk(dashboard, false).(map[string]interface{})
+}
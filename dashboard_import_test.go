@@ -0,0 +1,189 @@
+package gapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gobs/pretty"
+)
+
+const (
+	exportDashboardJSON = `{
+	"meta": {"slug": "payments"},
+	"dashboard": {
+		"id": 12,
+		"uid": "payments",
+		"title": "Payments",
+		"annotations": {"list": [{"name": "Annotations & Alerts", "datasource": "-- Grafana --", "builtIn": 1}]},
+		"templating": {"list": [{"name": "env", "type": "query", "datasource": {"type": "prometheus", "uid": "prom-uid"}}]},
+		"panels": [
+			{"id": 1, "type": "timeseries", "datasource": {"type": "prometheus", "uid": "prom-uid"},
+			 "targets": [{"refId": "A", "expr": "up", "datasource": {"type": "prometheus", "uid": "prom-uid"}}]},
+			{"id": 2, "type": "row", "panels": [
+				{"id": 3, "type": "logs", "datasource": "Logs"},
+				{"id": 4, "type": "stat", "datasource": "$ds"}
+			]}
+		]
+	}
+}`
+
+	dataSourcesJSON = `[
+	{"id": 1, "uid": "prom-uid", "name": "Prometheus", "type": "prometheus", "typeName": "Prometheus", "isDefault": true},
+	{"id": 2, "uid": "loki-uid", "name": "Logs", "type": "loki", "typeName": "Loki"},
+	{"id": 3, "uid": "thanos-uid", "name": "Thanos", "type": "prometheus", "typeName": "Prometheus"}
+]`
+
+	importDashboardJSON = `{
+	"uid": "payments",
+	"pluginId": "",
+	"title": "Payments",
+	"imported": true,
+	"importedUrl": "/d/payments/payments",
+	"slug": "payments",
+	"dashboardId": 13,
+	"folderId": 5
+}`
+)
+
+func importExportServer(t *testing.T, imported *DashboardImport) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/dashboards/uid/payments":
+			w.Write([]byte(exportDashboardJSON))
+		case "/api/datasources":
+			w.Write([]byte(dataSourcesJSON))
+		case "/api/dashboards/import":
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, imported); err != nil {
+				t.Error(err)
+			}
+			w.Write([]byte(importDashboardJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestExportImportDashboard(t *testing.T) {
+	var imported DashboardImport
+	server := importExportServer(t, &imported)
+	defer server.Close()
+
+	client, err := New("123", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exported, err := client.ExportDashboard("payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(exported))
+
+	if exported["id"] != nil {
+		t.Errorf("Exported dashboard kept its ID: %v", exported["id"])
+	}
+	inputs := DashboardInputs(exported)
+	expectedInputs := []DashboardInput{
+		{Name: "DS_LOGS", Label: "Logs", Type: "datasource", PluginId: "loki", PluginName: "Loki"},
+		{Name: "DS_PROMETHEUS", Label: "Prometheus", Type: "datasource", PluginId: "prometheus", PluginName: "Prometheus"},
+	}
+	if !reflect.DeepEqual(inputs, expectedInputs) {
+		t.Errorf("Unexpected inputs: %#v", inputs)
+	}
+
+	panels := exported["panels"].([]interface{})
+	ref := panels[0].(map[string]interface{})["datasource"].(map[string]interface{})
+	if ref["uid"] != "${DS_PROMETHEUS}" || ref["type"] != "prometheus" {
+		t.Errorf("Unexpected panel datasource: %v", ref)
+	}
+	nested := panels[1].(map[string]interface{})["panels"].([]interface{})
+	if ds := nested[0].(map[string]interface{})["datasource"]; ds != "${DS_LOGS}" {
+		t.Errorf("Unexpected nested panel datasource: %v", ds)
+	}
+	if ds := nested[1].(map[string]interface{})["datasource"]; ds != "$ds" {
+		t.Errorf("Variable datasource was replaced: %v", ds)
+	}
+	if len(exported["__requires"].([]interface{})) != 5 {
+		t.Errorf("Unexpected requires: %v", exported["__requires"])
+	}
+
+	// DS_PROMETHEUS is resolved to Thanos, DS_LOGS to the only Loki datasource.
+	resp, err := client.ImportDashboard(DashboardImport{
+		Dashboard: exported,
+		Inputs:    []DashboardInput{{Name: "DS_PROMETHEUS", Value: "Thanos"}},
+		Folder:    5,
+		Overwrite: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.DashboardId != 13 || resp.ImportedUrl != "/d/payments/payments" {
+		t.Errorf("Unexpected response: %#v", resp)
+	}
+
+	if imported.Folder != 5 || !imported.Overwrite {
+		t.Errorf("Unexpected folder or overwrite: %#v", imported)
+	}
+	expectedInputs = []DashboardInput{
+		{Name: "DS_LOGS", Type: "datasource", PluginId: "loki", Value: "loki-uid"},
+		{Name: "DS_PROMETHEUS", Type: "datasource", PluginId: "prometheus", Value: "thanos-uid"},
+	}
+	if !reflect.DeepEqual(imported.Inputs, expectedInputs) {
+		t.Errorf("Unexpected import inputs: %#v", imported.Inputs)
+	}
+	panels = imported.Dashboard["panels"].([]interface{})
+	ref = panels[0].(map[string]interface{})["datasource"].(map[string]interface{})
+	if ref["uid"] != "thanos-uid" {
+		t.Errorf("Unexpected imported panel datasource: %v", ref)
+	}
+	nested = panels[1].(map[string]interface{})["panels"].([]interface{})
+	if ds := nested[0].(map[string]interface{})["datasource"]; ds != "Logs" {
+		t.Errorf("Unexpected imported nested panel datasource: %v", ds)
+	}
+}
+
+func TestImportDashboard_unresolved(t *testing.T) {
+	var imported DashboardImport
+	server := importExportServer(t, &imported)
+	defer server.Close()
+
+	client, err := New("123", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dashboard := map[string]interface{}{
+		"title":    "Graphite",
+		"__inputs": []interface{}{map[string]interface{}{"name": "DS_GRAPHITE", "type": "datasource", "pluginId": "graphite"}},
+	}
+	if _, err := client.ImportDashboard(DashboardImport{Dashboard: dashboard}); err == nil {
+		t.Error("Missing datasource not detected")
+	}
+
+	_, err = client.ImportDashboard(DashboardImport{
+		Dashboard: dashboard,
+		Inputs:    []DashboardInput{{Name: "DS_GRAPHITE", Value: "unknown"}},
+	})
+	if err == nil {
+		t.Error("Unknown datasource not detected")
+	}
+}
+
+func TestImportDashboard_errors(t *testing.T) {
+	server, client := gapiTestTools(200, importDashboardJSON)
+	defer server.Close()
+
+	for _, code := range []int{400, 401, 403, 412, 500} {
+		server.code = code
+		_, err := client.ImportDashboard(DashboardImport{Dashboard: map[string]interface{}{"title": "t"}})
+		if err == nil {
+			t.Errorf("%d not detected", code)
+		}
+	}
+}
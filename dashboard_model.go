@@ -0,0 +1,897 @@
+package gapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Panel types.
+const (
+	PanelTypeTimeSeries = "timeseries"
+	PanelTypeGraph      = "graph"
+	PanelTypeStat       = "stat"
+	PanelTypeSingleStat = "singlestat"
+	PanelTypeGauge      = "gauge"
+	PanelTypeBarGauge   = "bargauge"
+	PanelTypeTable      = "table"
+	PanelTypeText       = "text"
+	PanelTypeHeatmap    = "heatmap"
+	PanelTypeLogs       = "logs"
+	PanelTypeRow        = "row"
+)
+
+// Template variable types.
+const (
+	TemplateVarQuery      = "query"
+	TemplateVarCustom     = "custom"
+	TemplateVarConstant   = "constant"
+	TemplateVarDataSource = "datasource"
+	TemplateVarInterval   = "interval"
+	TemplateVarTextBox    = "textbox"
+	TemplateVarAdHoc      = "adhoc"
+)
+
+// DashboardModel is a typed representation of the dashboard JSON model held
+// in Dashboard.Model. Every object in the model keeps the JSON fields it
+// doesn't know about in Extra, so that dashboards round-trip without loss.
+type DashboardModel struct {
+	Id            int64                 `json:"id,omitempty"`
+	Uid           string                `json:"uid,omitempty"`
+	Title         string                `json:"title,omitempty"`
+	Description   string                `json:"description,omitempty"`
+	Tags          []string              `json:"tags,omitempty"`
+	Timezone      string                `json:"timezone,omitempty"`
+	Editable      bool                  `json:"editable,omitempty"`
+	GraphTooltip  int64                 `json:"graphTooltip,omitempty"`
+	Style         string                `json:"style,omitempty"`
+	SchemaVersion int64                 `json:"schemaVersion,omitempty"`
+	Version       int64                 `json:"version,omitempty"`
+	Time          *TimeRange            `json:"time,omitempty"`
+	TimePicker    *TimePicker           `json:"timepicker,omitempty"`
+	Refresh       RefreshInterval       `json:"refresh,omitempty"`
+	Templating    *Templating           `json:"templating,omitempty"`
+	Annotations   *DashboardAnnotations `json:"annotations,omitempty"`
+	Links         []DashboardLink       `json:"links,omitempty"`
+	Panels        []Panel               `json:"panels,omitempty"`
+	// Rows are only used by dashboards older than schema version 16.
+	Rows []Row `json:"rows,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// TimeRange is a dashboard time range, such as "now-6h" to "now".
+type TimeRange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+
+	ModelExtra `json:"-"`
+}
+
+// TimePicker configures the dashboard time picker.
+type TimePicker struct {
+	Hidden           bool     `json:"hidden,omitempty"`
+	RefreshIntervals []string `json:"refresh_intervals,omitempty"`
+	TimeOptions      []string `json:"time_options,omitempty"`
+	NowDelay         string   `json:"nowDelay,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// RefreshInterval is the dashboard auto-refresh interval, such as "30s".
+// An empty interval disables auto-refresh; it is decoded from either "" or false.
+type RefreshInterval string
+
+// UnmarshalJSON accepts either a string or false.
+func (r *RefreshInterval) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("false")) {
+		*r = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*r = RefreshInterval(s)
+	return nil
+}
+
+// Templating holds the dashboard template variables.
+type Templating struct {
+	List []TemplateVar `json:"list"`
+
+	ModelExtra `json:"-"`
+}
+
+// TemplateVar is a dashboard template variable.
+type TemplateVar struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"`
+	Label       string         `json:"label,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Datasource  *DataSourceRef `json:"datasource,omitempty"`
+	// Query is a string for most variables, and an object for some
+	// datasources' query variables.
+	Query      interface{}         `json:"query,omitempty"`
+	Definition string              `json:"definition,omitempty"`
+	Regex      string              `json:"regex,omitempty"`
+	Current    *TemplateVarOption  `json:"current,omitempty"`
+	Options    []TemplateVarOption `json:"options,omitempty"`
+	Refresh    int64               `json:"refresh,omitempty"`
+	Sort       int64               `json:"sort,omitempty"`
+	Multi      bool                `json:"multi,omitempty"`
+	IncludeAll bool                `json:"includeAll,omitempty"`
+	AllValue   string              `json:"allValue,omitempty"`
+	Hide       int64               `json:"hide,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// TemplateVarOption is a value of a template variable. Text and Value are
+// strings, or lists of strings for multi-value variables.
+type TemplateVarOption struct {
+	Selected bool        `json:"selected,omitempty"`
+	Text     interface{} `json:"text,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// DashboardAnnotations holds the annotation queries of a dashboard.
+type DashboardAnnotations struct {
+	List []AnnotationQuery `json:"list"`
+
+	ModelExtra `json:"-"`
+}
+
+// AnnotationQuery is an annotation query of a dashboard.
+type AnnotationQuery struct {
+	Name       string         `json:"name"`
+	Datasource *DataSourceRef `json:"datasource,omitempty"`
+	Enable     bool           `json:"enable"`
+	Hide       bool           `json:"hide,omitempty"`
+	IconColor  string         `json:"iconColor,omitempty"`
+	BuiltIn    int64          `json:"builtIn,omitempty"`
+	Type       string         `json:"type,omitempty"`
+	Expr       string         `json:"expr,omitempty"`
+	Query      string         `json:"query,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// DashboardLink is a link shown at the top of a dashboard, or on a panel.
+type DashboardLink struct {
+	Title       string   `json:"title"`
+	Type        string   `json:"type,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Icon        string   `json:"icon,omitempty"`
+	Tooltip     string   `json:"tooltip,omitempty"`
+	AsDropdown  bool     `json:"asDropdown,omitempty"`
+	IncludeVars bool     `json:"includeVars,omitempty"`
+	KeepTime    bool     `json:"keepTime,omitempty"`
+	TargetBlank bool     `json:"targetBlank,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// DataSourceRef references a datasource from a panel, target, variable or
+// annotation query. Older dashboards reference datasources by name, newer
+// ones by type and UID; each form is encoded the way it was decoded.
+type DataSourceRef struct {
+	Type string `json:"type,omitempty"`
+	Uid  string `json:"uid,omitempty"`
+	// Name is set for references made by name.
+	Name string `json:"-"`
+}
+
+// UnmarshalJSON accepts either a datasource name or a {type, uid} object.
+func (r *DataSourceRef) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = DataSourceRef{Name: name}
+		return nil
+	}
+	type plain DataSourceRef
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// MarshalJSON encodes references made by name as a string.
+func (r DataSourceRef) MarshalJSON() ([]byte, error) {
+	if r.Name != "" && r.Uid == "" && r.Type == "" {
+		return json.Marshal(r.Name)
+	}
+	type plain DataSourceRef
+	return json.Marshal(plain(r))
+}
+
+// Row is a row of panels in dashboards older than schema version 16.
+// Newer dashboards use panels of type "row" instead.
+type Row struct {
+	Title     string  `json:"title,omitempty"`
+	ShowTitle bool    `json:"showTitle,omitempty"`
+	Height    string  `json:"height,omitempty"`
+	Collapse  bool    `json:"collapse,omitempty"`
+	Repeat    string  `json:"repeat,omitempty"`
+	Panels    []Panel `json:"panels,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// Panel is a dashboard panel. Fields shared by every panel type are at the
+// top; the rest are only used by some types. Panel specific options can be
+// read and written with DecodeOptions and SetOptions.
+type Panel struct {
+	Id            int64           `json:"id,omitempty"`
+	Type          string          `json:"type"`
+	Title         string          `json:"title,omitempty"`
+	Description   string          `json:"description,omitempty"`
+	GridPos       *GridPos        `json:"gridPos,omitempty"`
+	Datasource    *DataSourceRef  `json:"datasource,omitempty"`
+	Targets       []Target        `json:"targets,omitempty"`
+	FieldConfig   *FieldConfig    `json:"fieldConfig,omitempty"`
+	Options       json.RawMessage `json:"options,omitempty"`
+	Transparent   bool            `json:"transparent,omitempty"`
+	Links         []DashboardLink `json:"links,omitempty"`
+	Repeat        string          `json:"repeat,omitempty"`
+	Interval      string          `json:"interval,omitempty"`
+	MaxDataPoints int64           `json:"maxDataPoints,omitempty"`
+
+	// Used by rows
+	Collapsed bool    `json:"collapsed,omitempty"`
+	Panels    []Panel `json:"panels,omitempty"`
+
+	// Used by text panels, before Grafana 7
+	Content string `json:"content,omitempty"`
+	Mode    string `json:"mode,omitempty"`
+
+	// Used by graph panels
+	Lines           bool                     `json:"lines,omitempty"`
+	Bars            bool                     `json:"bars,omitempty"`
+	Points          bool                     `json:"points,omitempty"`
+	Stack           bool                     `json:"stack,omitempty"`
+	Fill            int64                    `json:"fill,omitempty"`
+	LineWidth       int64                    `json:"linewidth,omitempty"`
+	NullPointMode   string                   `json:"nullPointMode,omitempty"`
+	YAxes           []Axis                   `json:"yaxes,omitempty"`
+	Legend          *Legend                  `json:"legend,omitempty"`
+	SeriesOverrides []map[string]interface{} `json:"seriesOverrides,omitempty"`
+	Alert           *PanelAlert              `json:"alert,omitempty"`
+
+	// Used by singlestat panels
+	Format    string `json:"format,omitempty"`
+	ValueName string `json:"valueName,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// DecodeOptions decodes the panel options into v.
+func (p *Panel) DecodeOptions(v interface{}) error {
+	if len(p.Options) == 0 {
+		return nil
+	}
+	return json.Unmarshal(p.Options, v)
+}
+
+// SetOptions replaces the panel options with the JSON encoding of v.
+func (p *Panel) SetOptions(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	p.Options = data
+	return nil
+}
+
+// GridPos is the position and size of a panel on the dashboard grid, which
+// is 24 columns wide.
+type GridPos struct {
+	H int64 `json:"h"`
+	W int64 `json:"w"`
+	X int64 `json:"x"`
+	Y int64 `json:"y"`
+
+	ModelExtra `json:"-"`
+}
+
+// Axis is a graph panel Y axis.
+type Axis struct {
+	Format  string      `json:"format,omitempty"`
+	Label   string      `json:"label,omitempty"`
+	LogBase int64       `json:"logBase,omitempty"`
+	Min     interface{} `json:"min,omitempty"`
+	Max     interface{} `json:"max,omitempty"`
+	Show    bool        `json:"show"`
+
+	ModelExtra `json:"-"`
+}
+
+// Legend is a graph panel legend.
+type Legend struct {
+	Show         bool `json:"show"`
+	AlignAsTable bool `json:"alignAsTable,omitempty"`
+	RightSide    bool `json:"rightSide,omitempty"`
+	Values       bool `json:"values,omitempty"`
+	Min          bool `json:"min,omitempty"`
+	Max          bool `json:"max,omitempty"`
+	Avg          bool `json:"avg,omitempty"`
+	Current      bool `json:"current,omitempty"`
+	Total        bool `json:"total,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// FieldConfig configures how panels built on data frames display fields.
+type FieldConfig struct {
+	Defaults  FieldConfigDefaults `json:"defaults"`
+	Overrides []FieldOverride     `json:"overrides,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// FieldConfigDefaults is the configuration applied to every field.
+type FieldConfigDefaults struct {
+	Unit        string                 `json:"unit,omitempty"`
+	Decimals    *int64                 `json:"decimals,omitempty"`
+	Min         *float64               `json:"min,omitempty"`
+	Max         *float64               `json:"max,omitempty"`
+	DisplayName string                 `json:"displayName,omitempty"`
+	NoValue     string                 `json:"noValue,omitempty"`
+	Color       map[string]interface{} `json:"color,omitempty"`
+	Thresholds  *Thresholds            `json:"thresholds,omitempty"`
+	Mappings    []interface{}          `json:"mappings,omitempty"`
+	Links       []DashboardLink        `json:"links,omitempty"`
+	// Custom holds options specific to the panel type.
+	Custom map[string]interface{} `json:"custom,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// Thresholds are the steps at which a field changes color.
+type Thresholds struct {
+	// Mode is either "absolute" or "percentage".
+	Mode  string          `json:"mode"`
+	Steps []ThresholdStep `json:"steps"`
+
+	ModelExtra `json:"-"`
+}
+
+// ThresholdStep is a threshold. The first step has no value, and applies
+// below the other steps.
+type ThresholdStep struct {
+	Color string   `json:"color"`
+	Value *float64 `json:"value"`
+
+	ModelExtra `json:"-"`
+}
+
+// FieldOverride overrides field configuration for the fields its matcher selects.
+type FieldOverride struct {
+	Matcher    FieldMatcher    `json:"matcher"`
+	Properties []FieldProperty `json:"properties"`
+
+	ModelExtra `json:"-"`
+}
+
+// FieldMatcher selects fields, for instance by name.
+type FieldMatcher struct {
+	Id      string      `json:"id"`
+	Options interface{} `json:"options,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// FieldProperty is a field configuration property set by an override.
+type FieldProperty struct {
+	Id    string      `json:"id"`
+	Value interface{} `json:"value"`
+
+	ModelExtra `json:"-"`
+}
+
+// Target is a panel query. Fields shared by every datasource are at the top,
+// followed by the fields of the common datasources.
+type Target struct {
+	RefId      string         `json:"refId,omitempty"`
+	Datasource *DataSourceRef `json:"datasource,omitempty"`
+	Hide       bool           `json:"hide,omitempty"`
+	QueryType  string         `json:"queryType,omitempty"`
+
+	// Used by Prometheus and Loki
+	Expr           string `json:"expr,omitempty"`
+	LegendFormat   string `json:"legendFormat,omitempty"`
+	Instant        bool   `json:"instant,omitempty"`
+	Range          bool   `json:"range,omitempty"`
+	Interval       string `json:"interval,omitempty"`
+	IntervalFactor int64  `json:"intervalFactor,omitempty"`
+	Step           int64  `json:"step,omitempty"`
+
+	// Used by Prometheus, MySQL, PostgreSQL and MSSQL
+	Format string `json:"format,omitempty"`
+
+	// Used by Loki
+	MaxLines int64 `json:"maxLines,omitempty"`
+
+	// Used by Graphite
+	Target string `json:"target,omitempty"`
+
+	// Used by InfluxDB and Elasticsearch
+	Query string `json:"query,omitempty"`
+
+	// Used by InfluxDB
+	RawQuery     bool   `json:"rawQuery,omitempty"`
+	ResultFormat string `json:"resultFormat,omitempty"`
+
+	// Used by Elasticsearch
+	Metrics    []map[string]interface{} `json:"metrics,omitempty"`
+	BucketAggs []map[string]interface{} `json:"bucketAggs,omitempty"`
+	TimeField  string                   `json:"timeField,omitempty"`
+	Alias      string                   `json:"alias,omitempty"`
+
+	// Used by Cloudwatch
+	Region     string              `json:"region,omitempty"`
+	Namespace  string              `json:"namespace,omitempty"`
+	MetricName string              `json:"metricName,omitempty"`
+	Dimensions map[string][]string `json:"dimensions,omitempty"`
+	Statistic  string              `json:"statistic,omitempty"`
+	Period     string              `json:"period,omitempty"`
+
+	// Used by MySQL, PostgreSQL and MSSQL
+	RawSQL string `json:"rawSql,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// PanelAlert is a legacy graph panel alert rule.
+type PanelAlert struct {
+	Name                string                   `json:"name"`
+	Message             string                   `json:"message,omitempty"`
+	Frequency           string                   `json:"frequency,omitempty"`
+	For                 string                   `json:"for,omitempty"`
+	Handler             int64                    `json:"handler,omitempty"`
+	NoDataState         string                   `json:"noDataState,omitempty"`
+	ExecutionErrorState string                   `json:"executionErrorState,omitempty"`
+	Conditions          []AlertCondition         `json:"conditions"`
+	Notifications       []map[string]interface{} `json:"notifications,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// AlertCondition is a condition of a legacy alert rule, such as "the average
+// of query A over the last 5 minutes is above 80".
+type AlertCondition struct {
+	Type      string                `json:"type"`
+	Query     AlertConditionQuery   `json:"query"`
+	Reducer   AlertConditionReducer `json:"reducer"`
+	Evaluator AlertConditionParams  `json:"evaluator"`
+	Operator  AlertConditionParams  `json:"operator"`
+
+	ModelExtra `json:"-"`
+}
+
+// AlertConditionQuery selects the query of a condition and its time range,
+// for instance ["A", "5m", "now"].
+type AlertConditionQuery struct {
+	Params []string `json:"params"`
+
+	ModelExtra `json:"-"`
+}
+
+// AlertConditionReducer reduces a series to a single value, for instance "avg".
+type AlertConditionReducer struct {
+	Type   string        `json:"type"`
+	Params []interface{} `json:"params"`
+
+	ModelExtra `json:"-"`
+}
+
+// AlertConditionParams is the evaluator or operator of a condition, for
+// instance "gt" [80], or "and".
+type AlertConditionParams struct {
+	Type   string    `json:"type"`
+	Params []float64 `json:"params,omitempty"`
+
+	ModelExtra `json:"-"`
+}
+
+// DecodeModel decodes the dashboard model into a DashboardModel.
+func (d *Dashboard) DecodeModel() (*DashboardModel, error) {
+	data, err := json.Marshal(d.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &DashboardModel{}
+	if err := json.Unmarshal(data, model); err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// SetModel replaces the dashboard model with the DashboardModel it's passed.
+func (d *Dashboard) SetModel(model *DashboardModel) error {
+	data, err := json.Marshal(model)
+	if err != nil {
+		return err
+	}
+
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	d.Model = m
+	return nil
+}
+
+func (m *DashboardModel) UnmarshalJSON(data []byte) error {
+	type plain DashboardModel
+	return unmarshalModel(data, (*plain)(m), &m.ModelExtra)
+}
+
+func (m DashboardModel) MarshalJSON() ([]byte, error) {
+	type plain DashboardModel
+	return marshalModel(plain(m), m.ModelExtra)
+}
+
+func (t *TimePicker) UnmarshalJSON(data []byte) error {
+	type plain TimePicker
+	return unmarshalModel(data, (*plain)(t), &t.ModelExtra)
+}
+
+func (t TimePicker) MarshalJSON() ([]byte, error) {
+	type plain TimePicker
+	return marshalModel(plain(t), t.ModelExtra)
+}
+
+func (v *TemplateVar) UnmarshalJSON(data []byte) error {
+	type plain TemplateVar
+	return unmarshalModel(data, (*plain)(v), &v.ModelExtra)
+}
+
+func (v TemplateVar) MarshalJSON() ([]byte, error) {
+	type plain TemplateVar
+	return marshalModel(plain(v), v.ModelExtra)
+}
+
+func (q *AnnotationQuery) UnmarshalJSON(data []byte) error {
+	type plain AnnotationQuery
+	return unmarshalModel(data, (*plain)(q), &q.ModelExtra)
+}
+
+func (q AnnotationQuery) MarshalJSON() ([]byte, error) {
+	type plain AnnotationQuery
+	return marshalModel(plain(q), q.ModelExtra)
+}
+
+func (l *DashboardLink) UnmarshalJSON(data []byte) error {
+	type plain DashboardLink
+	return unmarshalModel(data, (*plain)(l), &l.ModelExtra)
+}
+
+func (l DashboardLink) MarshalJSON() ([]byte, error) {
+	type plain DashboardLink
+	return marshalModel(plain(l), l.ModelExtra)
+}
+
+func (r *Row) UnmarshalJSON(data []byte) error {
+	type plain Row
+	return unmarshalModel(data, (*plain)(r), &r.ModelExtra)
+}
+
+func (r Row) MarshalJSON() ([]byte, error) {
+	type plain Row
+	return marshalModel(plain(r), r.ModelExtra)
+}
+
+func (p *Panel) UnmarshalJSON(data []byte) error {
+	type plain Panel
+	return unmarshalModel(data, (*plain)(p), &p.ModelExtra)
+}
+
+func (p Panel) MarshalJSON() ([]byte, error) {
+	type plain Panel
+	return marshalModel(plain(p), p.ModelExtra)
+}
+
+func (a *Axis) UnmarshalJSON(data []byte) error {
+	type plain Axis
+	return unmarshalModel(data, (*plain)(a), &a.ModelExtra)
+}
+
+func (a Axis) MarshalJSON() ([]byte, error) {
+	type plain Axis
+	return marshalModel(plain(a), a.ModelExtra)
+}
+
+func (l *Legend) UnmarshalJSON(data []byte) error {
+	type plain Legend
+	return unmarshalModel(data, (*plain)(l), &l.ModelExtra)
+}
+
+func (l Legend) MarshalJSON() ([]byte, error) {
+	type plain Legend
+	return marshalModel(plain(l), l.ModelExtra)
+}
+
+func (c *FieldConfig) UnmarshalJSON(data []byte) error {
+	type plain FieldConfig
+	return unmarshalModel(data, (*plain)(c), &c.ModelExtra)
+}
+
+func (c FieldConfig) MarshalJSON() ([]byte, error) {
+	type plain FieldConfig
+	return marshalModel(plain(c), c.ModelExtra)
+}
+
+func (d *FieldConfigDefaults) UnmarshalJSON(data []byte) error {
+	type plain FieldConfigDefaults
+	return unmarshalModel(data, (*plain)(d), &d.ModelExtra)
+}
+
+func (d FieldConfigDefaults) MarshalJSON() ([]byte, error) {
+	type plain FieldConfigDefaults
+	return marshalModel(plain(d), d.ModelExtra)
+}
+
+func (t *Target) UnmarshalJSON(data []byte) error {
+	type plain Target
+	return unmarshalModel(data, (*plain)(t), &t.ModelExtra)
+}
+
+func (t Target) MarshalJSON() ([]byte, error) {
+	type plain Target
+	return marshalModel(plain(t), t.ModelExtra)
+}
+
+func (a *PanelAlert) UnmarshalJSON(data []byte) error {
+	type plain PanelAlert
+	return unmarshalModel(data, (*plain)(a), &a.ModelExtra)
+}
+
+func (a PanelAlert) MarshalJSON() ([]byte, error) {
+	type plain PanelAlert
+	return marshalModel(plain(a), a.ModelExtra)
+}
+
+func (r *TimeRange) UnmarshalJSON(data []byte) error {
+	type plain TimeRange
+	return unmarshalModel(data, (*plain)(r), &r.ModelExtra)
+}
+
+func (r TimeRange) MarshalJSON() ([]byte, error) {
+	type plain TimeRange
+	return marshalModel(plain(r), r.ModelExtra)
+}
+
+func (t *Templating) UnmarshalJSON(data []byte) error {
+	type plain Templating
+	return unmarshalModel(data, (*plain)(t), &t.ModelExtra)
+}
+
+func (t Templating) MarshalJSON() ([]byte, error) {
+	type plain Templating
+	return marshalModel(plain(t), t.ModelExtra)
+}
+
+func (o *TemplateVarOption) UnmarshalJSON(data []byte) error {
+	type plain TemplateVarOption
+	return unmarshalModel(data, (*plain)(o), &o.ModelExtra)
+}
+
+func (o TemplateVarOption) MarshalJSON() ([]byte, error) {
+	type plain TemplateVarOption
+	return marshalModel(plain(o), o.ModelExtra)
+}
+
+func (a *DashboardAnnotations) UnmarshalJSON(data []byte) error {
+	type plain DashboardAnnotations
+	return unmarshalModel(data, (*plain)(a), &a.ModelExtra)
+}
+
+func (a DashboardAnnotations) MarshalJSON() ([]byte, error) {
+	type plain DashboardAnnotations
+	return marshalModel(plain(a), a.ModelExtra)
+}
+
+func (g *GridPos) UnmarshalJSON(data []byte) error {
+	type plain GridPos
+	return unmarshalModel(data, (*plain)(g), &g.ModelExtra)
+}
+
+func (g GridPos) MarshalJSON() ([]byte, error) {
+	type plain GridPos
+	return marshalModel(plain(g), g.ModelExtra)
+}
+
+func (t *Thresholds) UnmarshalJSON(data []byte) error {
+	type plain Thresholds
+	return unmarshalModel(data, (*plain)(t), &t.ModelExtra)
+}
+
+func (t Thresholds) MarshalJSON() ([]byte, error) {
+	type plain Thresholds
+	return marshalModel(plain(t), t.ModelExtra)
+}
+
+func (s *ThresholdStep) UnmarshalJSON(data []byte) error {
+	type plain ThresholdStep
+	return unmarshalModel(data, (*plain)(s), &s.ModelExtra)
+}
+
+func (s ThresholdStep) MarshalJSON() ([]byte, error) {
+	type plain ThresholdStep
+	return marshalModel(plain(s), s.ModelExtra)
+}
+
+func (o *FieldOverride) UnmarshalJSON(data []byte) error {
+	type plain FieldOverride
+	return unmarshalModel(data, (*plain)(o), &o.ModelExtra)
+}
+
+func (o FieldOverride) MarshalJSON() ([]byte, error) {
+	type plain FieldOverride
+	return marshalModel(plain(o), o.ModelExtra)
+}
+
+func (m *FieldMatcher) UnmarshalJSON(data []byte) error {
+	type plain FieldMatcher
+	return unmarshalModel(data, (*plain)(m), &m.ModelExtra)
+}
+
+func (m FieldMatcher) MarshalJSON() ([]byte, error) {
+	type plain FieldMatcher
+	return marshalModel(plain(m), m.ModelExtra)
+}
+
+func (p *FieldProperty) UnmarshalJSON(data []byte) error {
+	type plain FieldProperty
+	return unmarshalModel(data, (*plain)(p), &p.ModelExtra)
+}
+
+func (p FieldProperty) MarshalJSON() ([]byte, error) {
+	type plain FieldProperty
+	return marshalModel(plain(p), p.ModelExtra)
+}
+
+func (c *AlertCondition) UnmarshalJSON(data []byte) error {
+	type plain AlertCondition
+	return unmarshalModel(data, (*plain)(c), &c.ModelExtra)
+}
+
+func (c AlertCondition) MarshalJSON() ([]byte, error) {
+	type plain AlertCondition
+	return marshalModel(plain(c), c.ModelExtra)
+}
+
+func (q *AlertConditionQuery) UnmarshalJSON(data []byte) error {
+	type plain AlertConditionQuery
+	return unmarshalModel(data, (*plain)(q), &q.ModelExtra)
+}
+
+func (q AlertConditionQuery) MarshalJSON() ([]byte, error) {
+	type plain AlertConditionQuery
+	return marshalModel(plain(q), q.ModelExtra)
+}
+
+func (r *AlertConditionReducer) UnmarshalJSON(data []byte) error {
+	type plain AlertConditionReducer
+	return unmarshalModel(data, (*plain)(r), &r.ModelExtra)
+}
+
+func (r AlertConditionReducer) MarshalJSON() ([]byte, error) {
+	type plain AlertConditionReducer
+	return marshalModel(plain(r), r.ModelExtra)
+}
+
+func (p *AlertConditionParams) UnmarshalJSON(data []byte) error {
+	type plain AlertConditionParams
+	return unmarshalModel(data, (*plain)(p), &p.ModelExtra)
+}
+
+func (p AlertConditionParams) MarshalJSON() ([]byte, error) {
+	type plain AlertConditionParams
+	return marshalModel(plain(p), p.ModelExtra)
+}
+
+// ModelExtra keeps the parts of a dashboard model object's JSON which its
+// struct fields don't capture.
+type ModelExtra struct {
+	// Extra holds the fields which aren't mapped to struct fields.
+	Extra map[string]json.RawMessage
+
+	// present holds the original value of the mapped fields that were
+	// decoded, so that empty values are encoded again rather than omitted.
+	present map[string]json.RawMessage
+}
+
+// unmarshalModel decodes data into v, a pointer to a struct, recording the
+// fields that v doesn't map in extra.
+func unmarshalModel(data []byte, v interface{}, extra *ModelExtra) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	known := modelFields(reflect.TypeOf(v).Elem())
+	extra.Extra = nil
+	extra.present = map[string]json.RawMessage{}
+	for name, value := range fields {
+		if _, ok := known[name]; ok {
+			extra.present[name] = value
+			continue
+		}
+		if extra.Extra == nil {
+			extra.Extra = map[string]json.RawMessage{}
+		}
+		extra.Extra[name] = value
+	}
+	return nil
+}
+
+// marshalModel encodes v, a struct, along with the fields recorded in extra.
+func marshalModel(v interface{}, extra ModelExtra) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	// Fields left out because they're empty are kept if they were decoded,
+	// in their original form when their value hasn't changed since.
+	rv := reflect.ValueOf(v)
+	for name, index := range modelFields(rv.Type()) {
+		original, ok := extra.present[name]
+		if _, encoded := fields[name]; encoded || !ok {
+			continue
+		}
+		field := rv.Field(index)
+		decoded := reflect.New(field.Type())
+		if json.Unmarshal(original, decoded.Interface()) == nil && reflect.DeepEqual(decoded.Elem().Interface(), field.Interface()) {
+			fields[name] = original
+			continue
+		}
+		value, err := json.Marshal(field.Interface())
+		if err != nil {
+			return nil, err
+		}
+		fields[name] = value
+	}
+
+	for name, value := range extra.Extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+
+	return json.Marshal(fields)
+}
+
+// modelFields maps the JSON field names of a struct type to field indexes.
+func modelFields(t reflect.Type) map[string]int {
+	fields := map[string]int{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = i
+	}
+	return fields
+}
@@ -0,0 +1,163 @@
+package gapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const dashboardModelJSON = `{
+	"id": null,
+	"uid": "abc",
+	"title": "Service",
+	"tags": [],
+	"editable": false,
+	"schemaVersion": 27,
+	"version": 3,
+	"refresh": false,
+	"fiscalYearStartMonth": 0,
+	"time": {"from": "now-6h", "to": "now", "raw": {"from": "now-6h", "to": "now"}},
+	"timepicker": {"refresh_intervals": ["5s", "1m"], "hidden": false},
+	"templating": {
+		"enable": true,
+		"list": [
+			{
+				"name": "env",
+				"type": "query",
+				"datasource": {"type": "prometheus", "uid": "prom"},
+				"query": {"query": "label_values(env)", "refId": "A"},
+				"current": {"selected": true, "text": ["prod"], "value": ["prod"], "tags": []},
+				"options": [{"text": "prod", "value": "prod"}],
+				"multi": true,
+				"skipUrlSync": false
+			}
+		]
+	},
+	"annotations": {
+		"enable": false,
+		"list": [
+			{"name": "Annotations & Alerts", "builtIn": 1, "datasource": "-- Grafana --", "enable": true, "type": "dashboard"}
+		]
+	},
+	"links": [{"title": "Docs", "type": "link", "url": "https://example.com"}],
+	"panels": [
+		{
+			"id": 1,
+			"type": "timeseries",
+			"title": "Requests",
+			"gridPos": {"h": 8, "w": 12, "x": 0, "y": 0, "static": true},
+			"datasource": null,
+			"targets": [
+				{"refId": "A", "expr": "sum(rate(http_requests_total[5m]))", "legendFormat": "{{code}}", "exemplar": true}
+			],
+			"fieldConfig": {
+				"defaults": {
+					"unit": "reqps",
+					"thresholds": {"mode": "absolute", "steps": [{"color": "green", "value": null}, {"color": "red", "value": 80, "state": "critical"}]},
+					"custom": {"lineWidth": 1}
+				},
+				"overrides": [
+					{"matcher": {"id": "byName", "options": "5xx", "scope": "series"}, "properties": [{"id": "color", "value": {"mode": "fixed"}, "hidden": false}], "__systemRef": "hideSeriesFrom"}
+				]
+			},
+			"options": {"legend": {"displayMode": "list", "placement": "bottom"}},
+			"pluginVersion": "8.0.0"
+		},
+		{
+			"id": 2,
+			"type": "row",
+			"title": "Details",
+			"collapsed": true,
+			"panels": [
+				{"id": 3, "type": "graph", "lines": true, "yaxes": [{"format": "short", "show": true, "min": null}], "alert": {"name": "High", "conditions": [{"type": "query", "query": {"params": ["A", "5m", "now"], "model": {}}, "reducer": {"type": "avg", "params": []}, "evaluator": {"type": "gt", "params": [80]}, "operator": {"type": "and"}}]}}
+			]
+		}
+	]
+}`
+
+func TestDashboardModel_roundTrip(t *testing.T) {
+	model := &DashboardModel{}
+	if err := json.Unmarshal([]byte(dashboardModelJSON), model); err != nil {
+		t.Fatal(err)
+	}
+
+	if model.Title != "Service" || model.Time.From != "now-6h" || len(model.Panels) != 2 {
+		t.Fatalf("Not correctly parsing dashboard model: %+v", model)
+	}
+	if model.Templating.List[0].Datasource.Uid != "prom" {
+		t.Error("Not correctly parsing datasource reference.")
+	}
+	if model.Annotations.List[0].Datasource.Name != "-- Grafana --" {
+		t.Error("Not correctly parsing datasource name.")
+	}
+	if model.Panels[0].Targets[0].Expr != "sum(rate(http_requests_total[5m]))" {
+		t.Error("Not correctly parsing panel targets.")
+	}
+	if *model.Panels[0].FieldConfig.Defaults.Thresholds.Steps[1].Value != 80 {
+		t.Error("Not correctly parsing thresholds.")
+	}
+	if string(model.Panels[0].Extra["pluginVersion"]) != `"8.0.0"` {
+		t.Error("Not keeping unknown panel fields.")
+	}
+	if model.Panels[1].Panels[0].Alert.Name != "High" {
+		t.Error("Not correctly parsing row panels.")
+	}
+
+	data, err := json.Marshal(model)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var expected, got interface{}
+	json.Unmarshal([]byte(dashboardModelJSON), &expected)
+	json.Unmarshal(data, &got)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected round trip to be lossless; got: %s", data)
+	}
+}
+
+func TestDashboardModel_options(t *testing.T) {
+	panel := Panel{Type: PanelTypeText}
+	err := panel.SetOptions(map[string]string{"mode": "markdown", "content": "# Hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := struct {
+		Mode    string `json:"mode"`
+		Content string `json:"content"`
+	}{}
+	if err := panel.DecodeOptions(&options); err != nil {
+		t.Fatal(err)
+	}
+	if options.Mode != "markdown" || options.Content != "# Hello" {
+		t.Errorf("unexpected options: %+v", options)
+	}
+}
+
+func TestDashboard_model(t *testing.T) {
+	server, client := gapiTestTools(200, getDashboardResponse)
+	defer server.Close()
+
+	dashboard, err := client.DashboardByUid("cIBgcSjkk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := dashboard.DecodeModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Uid != "cIBgcSjkk" || model.Title != "Production Overview" {
+		t.Errorf("Not correctly decoding dashboard model: %+v", model)
+	}
+
+	model.Title = "Staging Overview"
+	model.Tags = []string{"staging"}
+	if err := dashboard.SetModel(model); err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Model["title"] != "Staging Overview" || dashboard.Model["version"] != float64(0) {
+		t.Errorf("Not correctly setting dashboard model: %v", dashboard.Model)
+	}
+}
@@ -0,0 +1,87 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// DashboardPermission has information such as a dashboard, user, team, role and permission.
+type DashboardPermission struct {
+	DashboardId  int64  `json:"dashboardId"`
+	DashboardUid string `json:"uid"`
+	UserId       int64  `json:"userId"`
+	UserLogin    string `json:"userLogin"`
+	TeamId       int64  `json:"teamId"`
+	Team         string `json:"team"`
+	Role         string `json:"role"`
+	IsFolder     bool   `json:"isFolder"`
+	// Inherited is set for permissions inherited from the dashboard's folder.
+	Inherited bool `json:"inherited"`
+
+	// Permission is one of PermissionView, PermissionEdit or PermissionAdmin.
+	Permission     PermissionLevel `json:"permission"`
+	PermissionName string          `json:"permissionName"`
+}
+
+// DashboardPermissions fetches and returns the permissions for the dashboard whose ID it's passed.
+func (c *Client) DashboardPermissions(id int64) ([]*DashboardPermission, error) {
+	return c.DashboardPermissionsContext(context.Background(), id)
+}
+
+// DashboardPermissionsContext is like DashboardPermissions but takes a context.
+func (c *Client) DashboardPermissionsContext(ctx context.Context, id int64) ([]*DashboardPermission, error) {
+	return c.dashboardPermissions(ctx, fmt.Sprintf("/api/dashboards/id/%d/permissions", id))
+}
+
+// DashboardPermissionsByUid fetches and returns the permissions for the dashboard whose UID it's passed.
+func (c *Client) DashboardPermissionsByUid(uid string) ([]*DashboardPermission, error) {
+	return c.DashboardPermissionsByUidContext(context.Background(), uid)
+}
+
+// DashboardPermissionsByUidContext is like DashboardPermissionsByUid but takes a context.
+func (c *Client) DashboardPermissionsByUidContext(ctx context.Context, uid string) ([]*DashboardPermission, error) {
+	return c.dashboardPermissions(ctx, fmt.Sprintf("/api/dashboards/uid/%s/permissions", uid))
+}
+
+func (c *Client) dashboardPermissions(ctx context.Context, path string) ([]*DashboardPermission, error) {
+	permissions := make([]*DashboardPermission, 0)
+	err := c.request(ctx, "GET", path, nil, nil, &permissions)
+	if err != nil {
+		return permissions, err
+	}
+
+	return permissions, nil
+}
+
+// UpdateDashboardPermissions remove existing permissions if items are not included in the request.
+// Permissions inherited from the dashboard's folder are not affected.
+func (c *Client) UpdateDashboardPermissions(id int64, items *PermissionItems) error {
+	return c.UpdateDashboardPermissionsContext(context.Background(), id, items)
+}
+
+// UpdateDashboardPermissionsContext is like UpdateDashboardPermissions but takes a context.
+func (c *Client) UpdateDashboardPermissionsContext(ctx context.Context, id int64, items *PermissionItems) error {
+	return c.updateDashboardPermissions(ctx, fmt.Sprintf("/api/dashboards/id/%d/permissions", id), items)
+}
+
+// UpdateDashboardPermissionsByUid is like UpdateDashboardPermissions but
+// identifies the dashboard by its UID.
+func (c *Client) UpdateDashboardPermissionsByUid(uid string, items *PermissionItems) error {
+	return c.UpdateDashboardPermissionsByUidContext(context.Background(), uid, items)
+}
+
+// UpdateDashboardPermissionsByUidContext is like UpdateDashboardPermissionsByUid but takes a context.
+func (c *Client) UpdateDashboardPermissionsByUidContext(ctx context.Context, uid string, items *PermissionItems) error {
+	return c.updateDashboardPermissions(ctx, fmt.Sprintf("/api/dashboards/uid/%s/permissions", uid), items)
+}
+
+func (c *Client) updateDashboardPermissions(ctx context.Context, path string, items *PermissionItems) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	return c.request(ctx, "POST", path, nil, bytes.NewBuffer(data), nil)
+}
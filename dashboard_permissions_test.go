@@ -0,0 +1,145 @@
+package gapi
+
+import (
+	"testing"
+
+	"github.com/gobs/pretty"
+)
+
+const (
+	getDashboardPermissionsJSON = `
+[
+  {
+    "id": 1,
+    "dashboardId": 1,
+    "created": "2017-06-20T02:00:00+02:00",
+    "updated": "2017-06-20T02:00:00+02:00",
+    "userId": 0,
+    "userLogin": "",
+    "userEmail": "",
+    "teamId": 0,
+    "team": "",
+    "role": "Viewer",
+    "permission": 1,
+    "permissionName": "View",
+    "uid": "",
+    "title": "",
+    "slug": "",
+    "isFolder": false,
+    "url": "",
+    "inherited": true
+  },
+  {
+    "id": 2,
+    "dashboardId": 1,
+    "created": "2017-06-20T02:00:00+02:00",
+    "updated": "2017-06-20T02:00:00+02:00",
+    "userId": 0,
+    "userLogin": "",
+    "userEmail": "",
+    "teamId": 2,
+    "team": "MyTestTeam",
+    "role": "",
+    "permission": 4,
+    "permissionName": "Admin",
+    "uid": "",
+    "title": "",
+    "slug": "",
+    "isFolder": false,
+    "url": ""
+  }
+]
+`
+	updateDashboardPermissionsJSON = `
+{
+	"message": "Dashboard permissions updated"
+}
+`
+)
+
+func TestDashboardPermissions(t *testing.T) {
+	server, client := gapiTestTools(200, getDashboardPermissionsJSON)
+	defer server.Close()
+
+	resp, err := client.DashboardPermissions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(resp))
+
+	expects := []*DashboardPermission{
+		{
+			DashboardId:    1,
+			Role:           "Viewer",
+			Inherited:      true,
+			Permission:     PermissionView,
+			PermissionName: "View",
+		},
+		{
+			DashboardId:    1,
+			TeamId:         2,
+			Team:           "MyTestTeam",
+			Permission:     PermissionAdmin,
+			PermissionName: "Admin",
+		},
+	}
+
+	for i, expect := range expects {
+		if *resp[i] != *expect {
+			t.Errorf("expected: %+v; got: %+v", expect, resp[i])
+		}
+	}
+
+	if _, err := client.DashboardPermissionsByUid("nErXDvCkzz"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUpdateDashboardPermissions(t *testing.T) {
+	server, client := gapiTestTools(200, updateDashboardPermissionsJSON)
+	defer server.Close()
+
+	items := &PermissionItems{
+		Items: []*PermissionItem{
+			{
+				Role:       "Viewer",
+				Permission: PermissionView,
+			},
+			{
+				TeamId:     1,
+				Permission: PermissionEdit,
+			},
+			{
+				UserId:     11,
+				Permission: PermissionAdmin,
+			},
+		},
+	}
+	if err := client.UpdateDashboardPermissions(1, items); err != nil {
+		t.Error(err)
+	}
+	if err := client.UpdateDashboardPermissionsByUid("nErXDvCkzz", items); err != nil {
+		t.Error(err)
+	}
+
+	for _, code := range []int{401, 403, 404} {
+		server.code = code
+		if err := client.UpdateDashboardPermissions(1, items); err == nil {
+			t.Errorf("%d not detected", code)
+		}
+	}
+}
+
+func TestPermissionLevelString(t *testing.T) {
+	for level, expected := range map[PermissionLevel]string{
+		PermissionView:     "View",
+		PermissionEdit:     "Edit",
+		PermissionAdmin:    "Admin",
+		PermissionLevel(3): "PermissionLevel(3)",
+	} {
+		if level.String() != expected {
+			t.Errorf("expected: %s; got: %s", expected, level.String())
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package gapi
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/gobs/pretty"
 )
@@ -160,3 +165,192 @@ func TestDashboards(t *testing.T) {
 		t.Error("Not correctly parsing returned dashboards.")
 	}
 }
+
+func TestDashboardByUidContext(t *testing.T) {
+	server, client := gapiTestTools(200, getDashboardResponse)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	resp, err := client.DashboardByUidContext(ctx, "cIBgcSjkk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, ok := resp.Model["uid"]
+	if !ok || uid != "cIBgcSjkk" {
+		t.Errorf("Invalid uid - %s, Expected %s", uid, "cIBgcSjkk")
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.DashboardByUidContext(canceled, "cIBgcSjkk")
+	if err == nil {
+		t.Error("canceled context not detected")
+	}
+}
+
+func TestSearchDashboards(t *testing.T) {
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(getDashboardsJSON))
+	}))
+	defer server.Close()
+
+	client, err := New("123", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dashboards, err := client.SearchDashboards(SearchQuery{
+		Query:         "payments",
+		Tags:          []string{"team-payments", "prod"},
+		FolderIds:     []int64{3, 4},
+		FolderUids:    []string{"abc"},
+		DashboardUids: []string{"RGAPB1cZz"},
+		Starred:       true,
+		Type:          SearchTypeDashboard,
+		Sort:          "alpha-desc",
+		Limit:         10,
+		Page:          2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboards) != 1 || dashboards[0].Uid != "RGAPB1cZz" {
+		t.Error("Not correctly parsing returned dashboards.")
+	}
+
+	expected := url.Values{
+		"query":         {"payments"},
+		"tag":           {"team-payments", "prod"},
+		"folderIds":     {"3", "4"},
+		"folderUIDs":    {"abc"},
+		"dashboardUIDs": {"RGAPB1cZz"},
+		"starred":       {"true"},
+		"type":          {"dash-db"},
+		"sort":          {"alpha-desc"},
+		"limit":         {"10"},
+		"page":          {"2"},
+	}
+	if query.Encode() != expected.Encode() {
+		t.Errorf("expected query: %s; got: %s", expected.Encode(), query.Encode())
+	}
+}
+
+const (
+	getDashboardVersionsJSON = `[
+		{
+			"id": 2,
+			"dashboardId": 1,
+			"parentVersion": 1,
+			"restoredFrom": 0,
+			"version": 2,
+			"created": "2017-06-08T17:24:33-04:00",
+			"createdBy": "admin",
+			"message": "Updated panel title"
+		},
+		{
+			"id": 1,
+			"dashboardId": 1,
+			"parentVersion": 0,
+			"restoredFrom": 0,
+			"version": 1,
+			"created": "2017-06-08T17:23:33-04:00",
+			"createdBy": "admin",
+			"message": "Initial save"
+		}
+	]`
+
+	getDashboardVersionJSON = `{
+		"id": 1,
+		"dashboardId": 1,
+		"parentVersion": 0,
+		"restoredFrom": 0,
+		"version": 1,
+		"created": "2017-04-26T17:18:38-04:00",
+		"message": "Initial save",
+		"data": {
+			"id": 1,
+			"title": "Orgs",
+			"version": 1
+		},
+		"createdBy": "admin"
+	}`
+
+	restoreDashboardVersionJSON = `{
+		"slug": "my-dashboard",
+		"status": "success",
+		"uid": "QA7wKklGz",
+		"version": 3
+	}`
+)
+
+func TestDashboardVersions(t *testing.T) {
+	server, client := gapiTestTools(200, getDashboardVersionsJSON)
+	defer server.Close()
+
+	versions, err := client.DashboardVersions(1, url.Values{"limit": {"2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(versions))
+
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Message != "Initial save" {
+		t.Error("Not correctly parsing returned dashboard versions.")
+	}
+}
+
+func TestDashboardVersion(t *testing.T) {
+	server, client := gapiTestTools(200, getDashboardVersionJSON)
+	defer server.Close()
+
+	version, err := client.DashboardVersion(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if version.Version != 1 || version.Data["title"] != "Orgs" {
+		t.Error("Not correctly parsing returned dashboard version.")
+	}
+}
+
+func TestDiffDashboardVersions(t *testing.T) {
+	server, client := gapiTestTools(200, `<div class="diff-group">...</div>`)
+	defer server.Close()
+
+	diff, err := client.DiffDashboardVersions(
+		DashboardDiffTarget{DashboardId: 1, Version: 1},
+		DashboardDiffTarget{DashboardId: 1, Version: 2},
+		DiffTypeBasic,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != `<div class="diff-group">...</div>` {
+		t.Errorf("unexpected diff: %s", diff)
+	}
+}
+
+func TestRestoreDashboardVersion(t *testing.T) {
+	server, client := gapiTestTools(200, restoreDashboardVersionJSON)
+	defer server.Close()
+
+	resp, err := client.RestoreDashboardVersion(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Uid != "QA7wKklGz" || resp.Version != 3 {
+		t.Error("Not correctly parsing restore response.")
+	}
+
+	for _, code := range []int{401, 403, 404} {
+		server.code = code
+		_, err = client.RestoreDashboardVersion(1, 1)
+		if err == nil {
+			t.Errorf("%d not detected", code)
+		}
+	}
+}
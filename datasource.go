@@ -2,13 +2,18 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
 )
 
 // DataSource represents a Grafana data source.
 type DataSource struct {
 	Id     int64  `json:"id,omitempty"`
+	UID    string `json:"uid,omitempty"`
 	Name   string `json:"name"`
 	Type   string `json:"type"`
 	URL    string `json:"url"`
@@ -29,6 +34,9 @@ type DataSource struct {
 
 	JSONData       JSONData       `json:"jsonData,omitempty"`
 	SecureJSONData SecureJSONData `json:"secureJsonData,omitempty"`
+	// SecureJSONFields lists the secure fields that are set. Grafana never
+	// returns their values.
+	SecureJSONFields map[string]bool `json:"secureJsonFields,omitempty"`
 }
 
 // JSONData is a representation of the datasource `jsonData` property
@@ -47,7 +55,7 @@ type JSONData struct {
 	// Used by Elasticsearch
 	EsVersion       int64  `json:"esVersion,omitempty"`
 	TimeField       string `json:"timeField,omitempty"`
-	Interval        string `json:"inteval,omitempty"`
+	Interval        string `json:"interval,omitempty"`
 	LogMessageField string `json:"logMessageField,omitempty"`
 	LogLevelField   string `json:"logLevelField,omitempty"`
 
@@ -83,6 +91,36 @@ type JSONData struct {
 	ClientEmail        string `json:"clientEmail,omitempty"`
 	DefaultProject     string `json:"defaultProject,omitempty"`
 	TokenUri           string `json:"tokenUri,omitempty"`
+
+	// Extra holds the settings without a field above, such as those of
+	// other plugins. Settings of DataSourceConfig types end up here too.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the fields of d and its extra settings as one object.
+func (d JSONData) MarshalJSON() ([]byte, error) {
+	type plain JSONData
+	return marshalWithExtra(plain(d), d.Extra)
+}
+
+// UnmarshalJSON decodes the settings of d, keeping those without a field
+// in Extra.
+func (d *JSONData) UnmarshalJSON(data []byte) error {
+	type plain JSONData
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	return unmarshalExtra(data, plain{}, func(key string, value json.RawMessage) error {
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return err
+		}
+		if d.Extra == nil {
+			d.Extra = map[string]interface{}{}
+		}
+		d.Extra[key] = v
+		return nil
+	})
 }
 
 // SecureJSONData is a representation of the datasource `secureJsonData` property
@@ -100,10 +138,108 @@ type SecureJSONData struct {
 
 	// Used by Stackdriver
 	PrivateKey string `json:"privateKey,omitempty"`
+
+	// Extra holds the secrets without a field above.
+	Extra map[string]string `json:"-"`
+}
+
+// MarshalJSON encodes the fields of d and its extra secrets as one object.
+func (d SecureJSONData) MarshalJSON() ([]byte, error) {
+	type plain SecureJSONData
+	extra := make(map[string]interface{}, len(d.Extra))
+	for key, value := range d.Extra {
+		extra[key] = value
+	}
+	return marshalWithExtra(plain(d), extra)
+}
+
+// UnmarshalJSON decodes the secrets of d, keeping those without a field in
+// Extra.
+func (d *SecureJSONData) UnmarshalJSON(data []byte) error {
+	type plain SecureJSONData
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+	return unmarshalExtra(data, plain{}, func(key string, value json.RawMessage) error {
+		var v string
+		if err := json.Unmarshal(value, &v); err != nil {
+			return fmt.Errorf("secureJsonData.%s: %w", key, err)
+		}
+		if d.Extra == nil {
+			d.Extra = map[string]string{}
+		}
+		d.Extra[key] = v
+		return nil
+	})
+}
+
+// marshalWithExtra encodes v, a struct, adding the extra keys it has no
+// field for.
+func marshalWithExtra(v interface{}, extra map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	known := jsonFieldNames(reflect.TypeOf(v))
+	for key, value := range extra {
+		if known[strings.ToLower(key)] {
+			continue
+		}
+		if fields[key], err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// unmarshalExtra calls set with the keys of the object in data that v, a
+// struct, has no field for.
+func unmarshalExtra(data []byte, v interface{}, set func(key string, value json.RawMessage) error) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	known := jsonFieldNames(reflect.TypeOf(v))
+	for key, value := range fields {
+		if known[strings.ToLower(key)] {
+			continue
+		}
+		if err := set(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFieldNames returns the lowercased JSON names of the fields of a struct
+// type, which encoding/json matches without regard to case.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[strings.ToLower(name)] = true
+	}
+	return names
 }
 
 // NewDataSource creates a new Grafana data source.
 func (c *Client) NewDataSource(s *DataSource) (int64, error) {
+	return c.NewDataSourceContext(context.Background(), s)
+}
+
+// NewDataSourceContext is like NewDataSource but takes a context.
+func (c *Client) NewDataSourceContext(ctx context.Context, s *DataSource) (int64, error) {
 	data, err := json.Marshal(s)
 	if err != nil {
 		return 0, err
@@ -113,7 +249,7 @@ func (c *Client) NewDataSource(s *DataSource) (int64, error) {
 		Id int64 `json:"id"`
 	}{}
 
-	err = c.request("POST", "/api/datasources", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/datasources", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return 0, err
 	}
@@ -123,20 +259,86 @@ func (c *Client) NewDataSource(s *DataSource) (int64, error) {
 
 // UpdateDataSource updates a Grafana data source.
 func (c *Client) UpdateDataSource(s *DataSource) error {
+	return c.UpdateDataSourceContext(context.Background(), s)
+}
+
+// UpdateDataSourceContext is like UpdateDataSource but takes a context.
+func (c *Client) UpdateDataSourceContext(ctx context.Context, s *DataSource) error {
 	path := fmt.Sprintf("/api/datasources/%d", s.Id)
 	data, err := json.Marshal(s)
 	if err != nil {
 		return err
 	}
 
-	return c.request("PUT", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), nil)
+}
+
+// UpdateDataSourceByUID updates the Grafana data source whose UID is set
+// in s. Unlike UpdateDataSource, it doesn't need the data source's ID.
+func (c *Client) UpdateDataSourceByUID(s *DataSource) error {
+	return c.UpdateDataSourceByUIDContext(context.Background(), s)
+}
+
+// UpdateDataSourceByUIDContext is like UpdateDataSourceByUID but takes a context.
+func (c *Client) UpdateDataSourceByUIDContext(ctx context.Context, s *DataSource) error {
+	path := fmt.Sprintf("/api/datasources/uid/%s", url.PathEscape(s.UID))
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), nil)
+}
+
+// DataSources fetches and returns the Grafana data sources of the org.
+func (c *Client) DataSources() ([]*DataSource, error) {
+	return c.DataSourcesContext(context.Background())
+}
+
+// DataSourcesContext is like DataSources but takes a context.
+func (c *Client) DataSourcesContext(ctx context.Context) ([]*DataSource, error) {
+	result := make([]*DataSource, 0)
+	err := c.request(ctx, "GET", "/api/datasources", nil, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, err
 }
 
 // DataSource fetches and returns the Grafana data source whose ID it's passed.
 func (c *Client) DataSource(id int64) (*DataSource, error) {
-	path := fmt.Sprintf("/api/datasources/%d", id)
+	return c.DataSourceContext(context.Background(), id)
+}
+
+// DataSourceContext is like DataSource but takes a context.
+func (c *Client) DataSourceContext(ctx context.Context, id int64) (*DataSource, error) {
+	return c.dataSource(ctx, fmt.Sprintf("/api/datasources/%d", id))
+}
+
+// DataSourceByUID fetches and returns the Grafana data source whose UID it's passed.
+func (c *Client) DataSourceByUID(uid string) (*DataSource, error) {
+	return c.DataSourceByUIDContext(context.Background(), uid)
+}
+
+// DataSourceByUIDContext is like DataSourceByUID but takes a context.
+func (c *Client) DataSourceByUIDContext(ctx context.Context, uid string) (*DataSource, error) {
+	return c.dataSource(ctx, fmt.Sprintf("/api/datasources/uid/%s", url.PathEscape(uid)))
+}
+
+// DataSourceByName fetches and returns the Grafana data source whose name it's passed.
+func (c *Client) DataSourceByName(name string) (*DataSource, error) {
+	return c.DataSourceByNameContext(context.Background(), name)
+}
+
+// DataSourceByNameContext is like DataSourceByName but takes a context.
+func (c *Client) DataSourceByNameContext(ctx context.Context, name string) (*DataSource, error) {
+	return c.dataSource(ctx, fmt.Sprintf("/api/datasources/name/%s", url.PathEscape(name)))
+}
+
+func (c *Client) dataSource(ctx context.Context, path string) (*DataSource, error) {
 	result := &DataSource{}
-	err := c.request("GET", path, nil, nil, result)
+	err := c.request(ctx, "GET", path, nil, nil, result)
 	if err != nil {
 		return nil, err
 	}
@@ -144,9 +346,57 @@ func (c *Client) DataSource(id int64) (*DataSource, error) {
 	return result, err
 }
 
+// DataSourceIDByName returns the ID of the Grafana data source whose name it's passed.
+func (c *Client) DataSourceIDByName(name string) (int64, error) {
+	return c.DataSourceIDByNameContext(context.Background(), name)
+}
+
+// DataSourceIDByNameContext is like DataSourceIDByName but takes a context.
+func (c *Client) DataSourceIDByNameContext(ctx context.Context, name string) (int64, error) {
+	path := fmt.Sprintf("/api/datasources/id/%s", url.PathEscape(name))
+	result := struct {
+		Id int64 `json:"id"`
+	}{}
+	err := c.request(ctx, "GET", path, nil, nil, &result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Id, err
+}
+
 // DeleteDataSource deletes the Grafana data source whose ID it's passed.
 func (c *Client) DeleteDataSource(id int64) error {
+	return c.DeleteDataSourceContext(context.Background(), id)
+}
+
+// DeleteDataSourceContext is like DeleteDataSource but takes a context.
+func (c *Client) DeleteDataSourceContext(ctx context.Context, id int64) error {
 	path := fmt.Sprintf("/api/datasources/%d", id)
 
-	return c.request("DELETE", path, nil, nil, nil)
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// DeleteDataSourceByUID deletes the Grafana data source whose UID it's passed.
+func (c *Client) DeleteDataSourceByUID(uid string) error {
+	return c.DeleteDataSourceByUIDContext(context.Background(), uid)
+}
+
+// DeleteDataSourceByUIDContext is like DeleteDataSourceByUID but takes a context.
+func (c *Client) DeleteDataSourceByUIDContext(ctx context.Context, uid string) error {
+	path := fmt.Sprintf("/api/datasources/uid/%s", url.PathEscape(uid))
+
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
+}
+
+// DeleteDataSourceByName deletes the Grafana data source whose name it's passed.
+func (c *Client) DeleteDataSourceByName(name string) error {
+	return c.DeleteDataSourceByNameContext(context.Background(), name)
+}
+
+// DeleteDataSourceByNameContext is like DeleteDataSourceByName but takes a context.
+func (c *Client) DeleteDataSourceByNameContext(ctx context.Context, name string) error {
+	path := fmt.Sprintf("/api/datasources/name/%s", url.PathEscape(name))
+
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
 }
@@ -0,0 +1,538 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrInvalidDataSource is matched through errors.Is by the errors of
+// DataSource.Validate and the Validate methods of DataSourceConfig types.
+var ErrInvalidDataSource = errors.New("gapi: invalid datasource")
+
+// DataSourceConfig is the configuration of a data source plugin, stored in
+// the jsonData and secureJsonData of data sources by DataSource.SetConfig
+// and read back by DataSource.Config.
+//
+// The fields of the config types are encoded as JSON into jsonData, except
+// for secrets: those are tagged `secure:"name"` and go to secureJsonData
+// under that name.
+type DataSourceConfig interface {
+	// DataSourceType returns the ID of the plugin, such as "loki".
+	DataSourceType() string
+	// Validate checks that the settings the plugin requires are set.
+	Validate() error
+}
+
+// dataSourceTypes lists the plugins with a DataSourceConfig type, and
+// whether their data sources need a URL.
+var dataSourceTypes = map[string]struct {
+	config   func() DataSourceConfig
+	needsURL bool
+}{
+	"prometheus":                       {func() DataSourceConfig { return &PrometheusConfig{} }, true},
+	"loki":                             {func() DataSourceConfig { return &LokiConfig{} }, true},
+	"tempo":                            {func() DataSourceConfig { return &TempoConfig{} }, true},
+	"jaeger":                           {func() DataSourceConfig { return &JaegerConfig{} }, true},
+	"zipkin":                           {func() DataSourceConfig { return &ZipkinConfig{} }, true},
+	"influxdb":                         {func() DataSourceConfig { return &InfluxDBFluxConfig{} }, true},
+	"elasticsearch":                    {func() DataSourceConfig { return &ElasticsearchConfig{} }, true},
+	"graphite":                         {func() DataSourceConfig { return &GraphiteConfig{} }, true},
+	"postgres":                         {func() DataSourceConfig { return &PostgresConfig{} }, true},
+	"cloudwatch":                       {func() DataSourceConfig { return &CloudWatchConfig{} }, false},
+	"stackdriver":                      {func() DataSourceConfig { return &StackdriverConfig{} }, false},
+	"grafana-azure-monitor-datasource": {func() DataSourceConfig { return &AzureMonitorConfig{} }, false},
+}
+
+// SetConfig validates cfg and stores it in the JSONData and SecureJSONData
+// of the data source, over the settings they already have. It sets the
+// type of the data source when it has none.
+func (ds *DataSource) SetConfig(cfg DataSourceConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	typ := cfg.DataSourceType()
+	if ds.Type == "" {
+		ds.Type = typ
+	} else if typ != ds.Type {
+		return invalidDataSource("%s config for a %s datasource", typ, ds.Type)
+	}
+
+	jsonData, secureJSONData, err := encodeConfig(cfg)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(jsonData)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &ds.JSONData); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(secureJSONData); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &ds.SecureJSONData)
+}
+
+// Config returns the configuration of the data source's plugin, decoded
+// from its JSONData and SecureJSONData: a pointer to a config type such as
+// *LokiConfig, or a *GenericDataSourceConfig for plugins without one.
+// Grafana never returns secrets, so those are only set in the configs of
+// data sources that aren't fetched from it.
+func (ds *DataSource) Config() (DataSourceConfig, error) {
+	jsonData, err := json.Marshal(ds.JSONData)
+	if err != nil {
+		return nil, err
+	}
+	secureJSONData := map[string]string{}
+	data, err := json.Marshal(ds.SecureJSONData)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &secureJSONData); err != nil {
+		return nil, err
+	}
+
+	t, ok := dataSourceTypes[ds.Type]
+	if !ok || (ds.Type == "influxdb" && ds.JSONData.Extra["version"] != influxDBFlux) {
+		cfg := &GenericDataSourceConfig{Type: ds.Type, SecureJSONData: secureJSONData}
+		if err := json.Unmarshal(jsonData, &cfg.JSONData); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	cfg := t.config()
+	if err := json.Unmarshal(jsonData, cfg); err != nil {
+		return nil, fmt.Errorf("%s jsonData: %w", ds.Type, err)
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if name := v.Type().Field(i).Tag.Get("secure"); name != "" {
+			v.Field(i).SetString(secureJSONData[name])
+		}
+	}
+	return cfg, nil
+}
+
+// Validate checks that the data source has a name and a type, and the
+// settings its plugin requires, as returned by Config. Settings with values
+// it doesn't know, such as newer authentication types, are left to
+// Grafana. Call it before
+// NewDataSource to catch mistakes without making a request. Secrets
+// listed in SecureJSONFields count as set, so data sources fetched from
+// Grafana validate without them.
+func (ds *DataSource) Validate() error {
+	switch {
+	case ds.Name == "":
+		return invalidDataSource("name is required")
+	case ds.Type == "":
+		return invalidDataSource("%q: type is required", ds.Name)
+	case ds.URL == "" && dataSourceTypes[ds.Type].needsURL:
+		return invalidDataSource("%q: url is required", ds.Name)
+	}
+
+	cfg, err := ds.Config()
+	if err != nil {
+		return fmt.Errorf("%w: %q: %v", ErrInvalidDataSource, ds.Name, err)
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("secure")
+		if name != "" && v.Field(i).String() == "" && ds.SecureJSONFields[name] {
+			v.Field(i).SetString("set")
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("datasource %q: %w", ds.Name, err)
+	}
+	return nil
+}
+
+// encodeConfig returns the jsonData and secureJsonData of a config.
+func encodeConfig(cfg DataSourceConfig) (interface{}, map[string]string, error) {
+	if g, ok := cfg.(*GenericDataSourceConfig); ok {
+		return g.JSONData, g.SecureJSONData, nil
+	}
+
+	secureJSONData := map[string]string{}
+	v := reflect.Indirect(reflect.ValueOf(cfg))
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("gapi: %T is not a struct", cfg)
+	}
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get("secure")
+		if name != "" && v.Field(i).String() != "" {
+			secureJSONData[name] = v.Field(i).String()
+		}
+	}
+	return cfg, secureJSONData, nil
+}
+
+func invalidDataSource(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidDataSource}, args...)...)
+}
+
+func requiredSetting(typ, setting string) error {
+	return invalidDataSource("%s: %s is required", typ, setting)
+}
+
+// GenericDataSourceConfig is the configuration of a plugin without a config
+// type, as raw settings.
+type GenericDataSourceConfig struct {
+	Type           string
+	JSONData       map[string]interface{}
+	SecureJSONData map[string]string
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *GenericDataSourceConfig) DataSourceType() string { return c.Type }
+
+// Validate implements DataSourceConfig. Only the type is required.
+func (c *GenericDataSourceConfig) Validate() error {
+	if c.Type == "" {
+		return invalidDataSource("type is required")
+	}
+	return nil
+}
+
+// PrometheusConfig is the configuration of Prometheus data sources.
+type PrometheusConfig struct {
+	// HTTPMethod is the method of queries, GET or POST.
+	HTTPMethod            string `json:"httpMethod,omitempty"`
+	TimeInterval          string `json:"timeInterval,omitempty"`
+	QueryTimeout          string `json:"queryTimeout,omitempty"`
+	CustomQueryParameters string `json:"customQueryParameters,omitempty"`
+
+	ExemplarTraceIDDestinations []PrometheusExemplarDestination `json:"exemplarTraceIdDestinations,omitempty"`
+}
+
+// PrometheusExemplarDestination links the trace IDs of exemplars to a
+// tracing data source or to a URL.
+type PrometheusExemplarDestination struct {
+	// Name is the label of exemplars holding the trace ID.
+	Name          string `json:"name"`
+	DatasourceUID string `json:"datasourceUid,omitempty"`
+	URL           string `json:"url,omitempty"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *PrometheusConfig) DataSourceType() string { return "prometheus" }
+
+// Validate implements DataSourceConfig.
+func (c *PrometheusConfig) Validate() error {
+	for _, d := range c.ExemplarTraceIDDestinations {
+		if d.Name == "" {
+			return requiredSetting("prometheus", "exemplarTraceIdDestinations.name")
+		}
+		if d.DatasourceUID == "" && d.URL == "" {
+			return invalidDataSource("prometheus: exemplar destination %q needs a datasourceUid or url", d.Name)
+		}
+	}
+	return nil
+}
+
+// LokiConfig is the configuration of Loki data sources.
+type LokiConfig struct {
+	MaxLines      int64              `json:"maxLines,omitempty"`
+	DerivedFields []LokiDerivedField `json:"derivedFields,omitempty"`
+}
+
+// LokiDerivedField extracts a value from log lines with a regular
+// expression, to link it to a data source, such as a trace ID, or a URL.
+type LokiDerivedField struct {
+	Name            string `json:"name"`
+	MatcherRegex    string `json:"matcherRegex"`
+	URL             string `json:"url,omitempty"`
+	URLDisplayLabel string `json:"urlDisplayLabel,omitempty"`
+	DatasourceUID   string `json:"datasourceUid,omitempty"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *LokiConfig) DataSourceType() string { return "loki" }
+
+// Validate implements DataSourceConfig.
+func (c *LokiConfig) Validate() error {
+	for _, f := range c.DerivedFields {
+		if f.Name == "" {
+			return requiredSetting("loki", "derivedFields.name")
+		}
+		if f.MatcherRegex == "" {
+			return invalidDataSource("loki: derived field %q: matcherRegex is required", f.Name)
+		}
+	}
+	return nil
+}
+
+// TracesToLogs links the spans of traces to the logs of a Loki data source.
+type TracesToLogs struct {
+	DatasourceUID string `json:"datasourceUid"`
+	// Tags are the span attributes used as log labels.
+	Tags               []string `json:"tags,omitempty"`
+	SpanStartTimeShift string   `json:"spanStartTimeShift,omitempty"`
+	SpanEndTimeShift   string   `json:"spanEndTimeShift,omitempty"`
+	FilterByTraceID    bool     `json:"filterByTraceID,omitempty"`
+	FilterBySpanID     bool     `json:"filterBySpanID,omitempty"`
+}
+
+// NodeGraph enables the node graph of traces.
+type NodeGraph struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (t *TracesToLogs) validate(typ string) error {
+	if t != nil && t.DatasourceUID == "" {
+		return requiredSetting(typ, "tracesToLogs.datasourceUid")
+	}
+	return nil
+}
+
+// TempoConfig is the configuration of Tempo data sources.
+type TempoConfig struct {
+	TracesToLogs *TracesToLogs `json:"tracesToLogs,omitempty"`
+	NodeGraph    *NodeGraph    `json:"nodeGraph,omitempty"`
+	// ServiceMap is the Prometheus data source of the service graph.
+	ServiceMap *TempoDatasourceLink `json:"serviceMap,omitempty"`
+	// LokiSearch is the Loki data source used to search traces.
+	LokiSearch *TempoDatasourceLink `json:"lokiSearch,omitempty"`
+	Search     *TempoSearch         `json:"search,omitempty"`
+}
+
+// TempoDatasourceLink names a data source Tempo data sources rely on.
+type TempoDatasourceLink struct {
+	DatasourceUID string `json:"datasourceUid"`
+}
+
+// TempoSearch sets whether the search tab of Tempo data sources is hidden.
+type TempoSearch struct {
+	Hide bool `json:"hide"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *TempoConfig) DataSourceType() string { return "tempo" }
+
+// Validate implements DataSourceConfig.
+func (c *TempoConfig) Validate() error {
+	switch {
+	case c.ServiceMap != nil && c.ServiceMap.DatasourceUID == "":
+		return requiredSetting("tempo", "serviceMap.datasourceUid")
+	case c.LokiSearch != nil && c.LokiSearch.DatasourceUID == "":
+		return requiredSetting("tempo", "lokiSearch.datasourceUid")
+	}
+	return c.TracesToLogs.validate("tempo")
+}
+
+// JaegerConfig is the configuration of Jaeger data sources.
+type JaegerConfig struct {
+	TracesToLogs *TracesToLogs `json:"tracesToLogs,omitempty"`
+	NodeGraph    *NodeGraph    `json:"nodeGraph,omitempty"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *JaegerConfig) DataSourceType() string { return "jaeger" }
+
+// Validate implements DataSourceConfig.
+func (c *JaegerConfig) Validate() error {
+	return c.TracesToLogs.validate("jaeger")
+}
+
+// ZipkinConfig is the configuration of Zipkin data sources.
+type ZipkinConfig struct {
+	TracesToLogs *TracesToLogs `json:"tracesToLogs,omitempty"`
+	NodeGraph    *NodeGraph    `json:"nodeGraph,omitempty"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *ZipkinConfig) DataSourceType() string { return "zipkin" }
+
+// Validate implements DataSourceConfig.
+func (c *ZipkinConfig) Validate() error {
+	return c.TracesToLogs.validate("zipkin")
+}
+
+const influxDBFlux = "Flux"
+
+// InfluxDBFluxConfig is the configuration of InfluxDB data sources queried
+// with Flux. Those queried with InfluxQL have a GenericDataSourceConfig.
+type InfluxDBFluxConfig struct {
+	Organization  string `json:"organization"`
+	DefaultBucket string `json:"defaultBucket"`
+	TimeInterval  string `json:"timeInterval,omitempty"`
+	// Token is the API token, a secret.
+	Token string `json:"-" secure:"token"`
+}
+
+// MarshalJSON encodes c with the Flux version setting.
+func (c InfluxDBFluxConfig) MarshalJSON() ([]byte, error) {
+	type plain InfluxDBFluxConfig
+	return marshalWithExtra(plain(c), map[string]interface{}{"version": influxDBFlux})
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *InfluxDBFluxConfig) DataSourceType() string { return "influxdb" }
+
+// Validate implements DataSourceConfig.
+func (c *InfluxDBFluxConfig) Validate() error {
+	switch {
+	case c.Organization == "":
+		return requiredSetting("influxdb", "organization")
+	case c.DefaultBucket == "":
+		return requiredSetting("influxdb", "defaultBucket")
+	case c.Token == "":
+		return requiredSetting("influxdb", "token")
+	}
+	return nil
+}
+
+// ElasticsearchConfig is the configuration of Elasticsearch data sources.
+// Their index is the Database of the data source.
+type ElasticsearchConfig struct {
+	EsVersion int64  `json:"esVersion,omitempty"`
+	TimeField string `json:"timeField"`
+	// Interval is the pattern of the index names: Hourly, Daily, Weekly,
+	// Monthly or Yearly, or empty for no pattern.
+	Interval                   string `json:"interval,omitempty"`
+	TimeInterval               string `json:"timeInterval,omitempty"`
+	LogMessageField            string `json:"logMessageField,omitempty"`
+	LogLevelField              string `json:"logLevelField,omitempty"`
+	MaxConcurrentShardRequests int64  `json:"maxConcurrentShardRequests,omitempty"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *ElasticsearchConfig) DataSourceType() string { return "elasticsearch" }
+
+// Validate implements DataSourceConfig.
+func (c *ElasticsearchConfig) Validate() error {
+	if c.TimeField == "" {
+		return requiredSetting("elasticsearch", "timeField")
+	}
+	return nil
+}
+
+// GraphiteConfig is the configuration of Graphite data sources.
+type GraphiteConfig struct {
+	GraphiteVersion string `json:"graphiteVersion,omitempty"`
+	// GraphiteType is "default" or "metrictank".
+	GraphiteType string `json:"graphiteType,omitempty"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *GraphiteConfig) DataSourceType() string { return "graphite" }
+
+// Validate implements DataSourceConfig. No settings are required.
+func (c *GraphiteConfig) Validate() error {
+	return nil
+}
+
+// PostgresConfig is the configuration of PostgreSQL data sources. Their
+// host is the URL of the data source.
+type PostgresConfig struct {
+	Sslmode         string `json:"sslmode,omitempty"`
+	PostgresVersion int64  `json:"postgresVersion,omitempty"`
+	Timescaledb     bool   `json:"timescaledb,omitempty"`
+	TimeInterval    string `json:"timeInterval,omitempty"`
+	MaxOpenConns    int64  `json:"maxOpenConns,omitempty"`
+	MaxIdleConns    int64  `json:"maxIdleConns,omitempty"`
+	ConnMaxLifetime int64  `json:"connMaxLifetime,omitempty"`
+	Password        string `json:"-" secure:"password"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *PostgresConfig) DataSourceType() string { return "postgres" }
+
+// Validate implements DataSourceConfig. No settings are required.
+func (c *PostgresConfig) Validate() error {
+	return nil
+}
+
+// CloudWatchConfig is the configuration of CloudWatch data sources.
+type CloudWatchConfig struct {
+	// AuthType is default, keys, credentials, ec2_iam_role or arn.
+	AuthType                string `json:"authType,omitempty"`
+	Profile                 string `json:"profile,omitempty"`
+	AssumeRoleArn           string `json:"assumeRoleArn,omitempty"`
+	ExternalID              string `json:"externalId,omitempty"`
+	DefaultRegion           string `json:"defaultRegion"`
+	CustomMetricsNamespaces string `json:"customMetricsNamespaces,omitempty"`
+	AccessKey               string `json:"-" secure:"accessKey"`
+	SecretKey               string `json:"-" secure:"secretKey"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *CloudWatchConfig) DataSourceType() string { return "cloudwatch" }
+
+// Validate implements DataSourceConfig.
+func (c *CloudWatchConfig) Validate() error {
+	switch {
+	case c.DefaultRegion == "":
+		return requiredSetting("cloudwatch", "defaultRegion")
+	case c.AuthType == "keys" && c.AccessKey == "":
+		return requiredSetting("cloudwatch", "accessKey")
+	case c.AuthType == "keys" && c.SecretKey == "":
+		return requiredSetting("cloudwatch", "secretKey")
+	}
+	return nil
+}
+
+// StackdriverConfig is the configuration of Google Cloud Monitoring data
+// sources.
+type StackdriverConfig struct {
+	// AuthenticationType is jwt, for a service account key, or gce.
+	AuthenticationType string `json:"authenticationType,omitempty"`
+	ClientEmail        string `json:"clientEmail,omitempty"`
+	DefaultProject     string `json:"defaultProject,omitempty"`
+	TokenURI           string `json:"tokenUri,omitempty"`
+	PrivateKey         string `json:"-" secure:"privateKey"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *StackdriverConfig) DataSourceType() string { return "stackdriver" }
+
+// Validate implements DataSourceConfig.
+func (c *StackdriverConfig) Validate() error {
+	if c.AuthenticationType == "" || c.AuthenticationType == "jwt" {
+		switch {
+		case c.ClientEmail == "":
+			return requiredSetting("stackdriver", "clientEmail")
+		case c.DefaultProject == "":
+			return requiredSetting("stackdriver", "defaultProject")
+		case c.PrivateKey == "":
+			return requiredSetting("stackdriver", "privateKey")
+		}
+	}
+	return nil
+}
+
+// AzureMonitorConfig is the configuration of Azure Monitor data sources.
+type AzureMonitorConfig struct {
+	// CloudName is azuremonitor, chinaazuremonitor or govazuremonitor.
+	CloudName string `json:"cloudName,omitempty"`
+	// AzureAuthType is clientsecret, the default, msi for managed
+	// identities, workloadidentity or currentuser.
+	AzureAuthType  string `json:"azureAuthType,omitempty"`
+	TenantID       string `json:"tenantId,omitempty"`
+	ClientID       string `json:"clientId,omitempty"`
+	SubscriptionID string `json:"subscriptionId,omitempty"`
+	ClientSecret   string `json:"-" secure:"clientSecret"`
+}
+
+// DataSourceType implements DataSourceConfig.
+func (c *AzureMonitorConfig) DataSourceType() string { return "grafana-azure-monitor-datasource" }
+
+// Validate implements DataSourceConfig.
+func (c *AzureMonitorConfig) Validate() error {
+	const typ = "azure monitor"
+	if c.AzureAuthType == "" || c.AzureAuthType == "clientsecret" {
+		switch {
+		case c.TenantID == "":
+			return requiredSetting(typ, "tenantId")
+		case c.ClientID == "":
+			return requiredSetting(typ, "clientId")
+		case c.ClientSecret == "":
+			return requiredSetting(typ, "clientSecret")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDataSourceSetConfig(t *testing.T) {
+	ds := &DataSource{Name: "InfluxDB", URL: "http://influxdb:8086", Access: "proxy"}
+	ds.JSONData.TlsSkipVerify = true
+	err := ds.SetConfig(&InfluxDBFluxConfig{
+		Organization:  "ops",
+		DefaultBucket: "metrics",
+		TimeInterval:  "10s",
+		Token:         "s3cr3t",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(ds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := struct {
+		Type           string                 `json:"type"`
+		JSONData       map[string]interface{} `json:"jsonData"`
+		SecureJSONData map[string]string      `json:"secureJsonData"`
+	}{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	expectedJSONData := map[string]interface{}{
+		"version":       "Flux",
+		"organization":  "ops",
+		"defaultBucket": "metrics",
+		"timeInterval":  "10s",
+		"tlsSkipVerify": true,
+	}
+	if got.Type != "influxdb" || !reflect.DeepEqual(got.JSONData, expectedJSONData) {
+		t.Errorf("type %q, jsonData %v", got.Type, got.JSONData)
+	}
+	if !reflect.DeepEqual(got.SecureJSONData, map[string]string{"token": "s3cr3t"}) {
+		t.Errorf("secureJsonData = %v", got.SecureJSONData)
+	}
+
+	// The data source decodes back to its config, as if fetched.
+	fetched := &DataSource{}
+	if err := json.Unmarshal(data, fetched); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := fetched.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	flux, ok := cfg.(*InfluxDBFluxConfig)
+	if !ok || flux.Organization != "ops" || flux.DefaultBucket != "metrics" || flux.Token != "s3cr3t" {
+		t.Errorf("Config = %#v", cfg)
+	}
+	if err := fetched.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	if err := ds.SetConfig(&LokiConfig{}); !errors.Is(err, ErrInvalidDataSource) {
+		t.Errorf("config of another type: got %v", err)
+	}
+}
+
+func TestDataSourceConfigTypes(t *testing.T) {
+	configs := []DataSourceConfig{
+		&PrometheusConfig{HTTPMethod: "POST", ExemplarTraceIDDestinations: []PrometheusExemplarDestination{{Name: "traceID", DatasourceUID: "tempo"}}},
+		&LokiConfig{MaxLines: 1000, DerivedFields: []LokiDerivedField{{Name: "TraceID", MatcherRegex: `traceID=(\w+)`, DatasourceUID: "tempo"}}},
+		&TempoConfig{TracesToLogs: &TracesToLogs{DatasourceUID: "loki", Tags: []string{"job"}}, ServiceMap: &TempoDatasourceLink{DatasourceUID: "prom"}, NodeGraph: &NodeGraph{Enabled: true}},
+		&JaegerConfig{TracesToLogs: &TracesToLogs{DatasourceUID: "loki", FilterByTraceID: true}},
+		&ZipkinConfig{NodeGraph: &NodeGraph{Enabled: true}},
+		&ElasticsearchConfig{EsVersion: 70, TimeField: "@timestamp", Interval: "Daily", MaxConcurrentShardRequests: 5},
+		&GraphiteConfig{GraphiteVersion: "1.1", GraphiteType: "default"},
+		&PostgresConfig{Sslmode: "disable", PostgresVersion: 1200, Password: "pw"},
+		&CloudWatchConfig{AuthType: "keys", DefaultRegion: "us-east-1", AccessKey: "ak", SecretKey: "sk"},
+		&StackdriverConfig{ClientEmail: "grafana@example.iam.gserviceaccount.com", DefaultProject: "ops", PrivateKey: "pk"},
+		&AzureMonitorConfig{TenantID: "t", ClientID: "c", SubscriptionID: "s", ClientSecret: "cs"},
+		&GenericDataSourceConfig{Type: "grafana-clock-panel", JSONData: map[string]interface{}{"mode": "utc"}, SecureJSONData: map[string]string{"apiKey": "k"}},
+	}
+	for _, cfg := range configs {
+		ds := &DataSource{Name: "ds", URL: "http://example.com"}
+		if err := ds.SetConfig(cfg); err != nil {
+			t.Errorf("%s: %v", cfg.DataSourceType(), err)
+			continue
+		}
+		data, err := json.Marshal(ds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetched := &DataSource{}
+		if err := json.Unmarshal(data, fetched); err != nil {
+			t.Fatal(err)
+		}
+		got, err := fetched.Config()
+		if err != nil {
+			t.Errorf("%s: %v", cfg.DataSourceType(), err)
+		} else if !reflect.DeepEqual(got, cfg) {
+			t.Errorf("%s: got %#v, want %#v", cfg.DataSourceType(), got, cfg)
+		}
+	}
+}
+
+func TestDataSourceValidate(t *testing.T) {
+	tests := map[string]struct {
+		ds  DataSource
+		err string
+	}{
+		"no name": {DataSource{Type: "loki"}, "name is required"},
+		"no type": {DataSource{Name: "ds"}, "type is required"},
+		"no url":  {DataSource{Name: "ds", Type: "loki"}, "url is required"},
+		"secret": {
+			DataSource{Name: "ds", Type: "cloudwatch", JSONData: JSONData{AuthType: "keys", DefaultRegion: "eu-west-1"}, SecureJSONData: SecureJSONData{AccessKey: "ak"}},
+			"cloudwatch: secretKey is required",
+		},
+		"secret in grafana": {
+			DataSource{Name: "ds", Type: "cloudwatch", JSONData: JSONData{AuthType: "keys", DefaultRegion: "eu-west-1"}, SecureJSONData: SecureJSONData{AccessKey: "ak"}, SecureJSONFields: map[string]bool{"secretKey": true}},
+			"",
+		},
+		"extra settings": {
+			DataSource{Name: "ds", Type: "loki", URL: "http://loki", JSONData: JSONData{Extra: map[string]interface{}{"derivedFields": []interface{}{map[string]interface{}{"name": "TraceID"}}}}},
+			`derived field "TraceID": matcherRegex is required`,
+		},
+		"bad type": {
+			DataSource{Name: "ds", Type: "loki", URL: "http://loki", JSONData: JSONData{Extra: map[string]interface{}{"maxLines": "many"}}},
+			"loki jsonData",
+		},
+		"no timeField": {
+			DataSource{Name: "ds", Type: "elasticsearch", URL: "http://es:9200", Database: "logs-*"},
+			"elasticsearch: timeField is required",
+		},
+		"valid":       {DataSource{Name: "ds", Type: "cloudwatch", JSONData: JSONData{DefaultRegion: "eu-west-1"}}, ""},
+		"other types": {DataSource{Name: "ds", Type: "grafana-simple-json-datasource"}, ""},
+
+		// Grafana accepts these, so they validate too.
+		"other access": {DataSource{Name: "ds", Type: "loki", URL: "http://loki", Access: "browser"}, ""},
+		"other enum": {
+			DataSource{Name: "ds", Type: "prometheus", URL: "http://prom", JSONData: JSONData{HttpMethod: "PUT"}},
+			"",
+		},
+		"index in jsonData": {
+			DataSource{Name: "ds", Type: "elasticsearch", URL: "http://es:9200", JSONData: JSONData{TimeField: "@timestamp", Extra: map[string]interface{}{"index": "logs-*"}}},
+			"",
+		},
+		"postgres without user": {
+			DataSource{Name: "ds", Type: "postgres", URL: "db:5432", JSONData: JSONData{Extra: map[string]interface{}{"database": "metrics"}}},
+			"",
+		},
+		"cloudwatch arn": {DataSource{Name: "ds", Type: "cloudwatch", JSONData: JSONData{AuthType: "arn", DefaultRegion: "eu-west-1"}}, ""},
+		"azure workload identity": {
+			DataSource{Name: "ds", Type: "grafana-azure-monitor-datasource", JSONData: JSONData{Extra: map[string]interface{}{"azureAuthType": "workloadidentity"}}},
+			"",
+		},
+		"azure current user": {
+			DataSource{Name: "ds", Type: "grafana-azure-monitor-datasource", JSONData: JSONData{Extra: map[string]interface{}{"azureAuthType": "currentuser"}}},
+			"",
+		},
+	}
+	for name, test := range tests {
+		err := test.ds.Validate()
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrInvalidDataSource) || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %v, want %q", name, err, test.err)
+		}
+	}
+}
+
+func TestJSONDataExtra(t *testing.T) {
+	data := []byte(`{"jsonData":{"timeInterval":"15s","interval":"Daily","maxLines":1000,"nested":{"a":[1]}},"secureJsonData":{"password":"p","token":"t"}}`)
+	ds := &DataSource{}
+	if err := json.Unmarshal(data, ds); err != nil {
+		t.Fatal(err)
+	}
+	if ds.JSONData.TimeInterval != "15s" || ds.JSONData.Interval != "Daily" {
+		t.Errorf("jsonData = %+v", ds.JSONData)
+	}
+	expectedExtra := map[string]interface{}{"maxLines": float64(1000), "nested": map[string]interface{}{"a": []interface{}{float64(1)}}}
+	if !reflect.DeepEqual(ds.JSONData.Extra, expectedExtra) {
+		t.Errorf("jsonData extra = %v", ds.JSONData.Extra)
+	}
+	if ds.SecureJSONData.Password != "p" || !reflect.DeepEqual(ds.SecureJSONData.Extra, map[string]string{"token": "t"}) {
+		t.Errorf("secureJsonData = %+v", ds.SecureJSONData)
+	}
+
+	// Extra settings survive a round trip, but never override fields.
+	ds.JSONData.Extra["timeInterval"] = "1m"
+	encoded, err := json.Marshal(ds.JSONData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"interval":"Daily","maxLines":1000,"nested":{"a":[1]},"timeInterval":"15s"}`
+	if string(encoded) != expected {
+		t.Errorf("got %s, want %s", encoded, expected)
+	}
+
+	if err := json.Unmarshal([]byte(`{"token":1}`), &SecureJSONData{}); err == nil {
+		t.Error("decoded a secret that isn't a string")
+	}
+}
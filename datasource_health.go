@@ -0,0 +1,134 @@
+package gapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Statuses of data source health checks.
+const (
+	HealthStatusOK      = "OK"
+	HealthStatusError   = "ERROR"
+	HealthStatusUnknown = "UNKNOWN"
+)
+
+// DataSourceHealth is the result of a data source health check.
+type DataSourceHealth struct {
+	// Status is HealthStatusOK, HealthStatusError or HealthStatusUnknown.
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Details are plugin specific, such as the errors of the backend.
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// OK reports whether the data source is working.
+func (h *DataSourceHealth) OK() bool {
+	return h.Status == HealthStatusOK
+}
+
+// dataSourceProbes are the requests made through the datasource proxy to
+// check the health of data sources on Grafana versions without health
+// checks, or for plugins without one. Others are probed at their root.
+var dataSourceProbes = map[string]string{
+	"prometheus":    "api/v1/query?query=1%2B1",
+	"loki":          "loki/api/v1/labels",
+	"tempo":         "api/echo",
+	"jaeger":        "api/services",
+	"zipkin":        "api/v2/services",
+	"influxdb":      "ping",
+	"elasticsearch": "",
+	"graphite":      "metrics/find?query=*",
+}
+
+// CheckDataSourceHealth checks that the Grafana data source whose UID it's
+// passed works, as the "Save & test" button of Grafana does, and returns
+// the result. A data source failing its check isn't an error: the health
+// has an error status and the message of the plugin.
+//
+// Where Grafana has no health check, before version 9 or for plugins
+// without one, a request is made to the backend of the data source through
+// the datasource proxy instead, and the details of the health have its
+// "statusCode". The status is unknown when such a request fails for a
+// plugin it isn't known to work with.
+func (c *Client) CheckDataSourceHealth(uid string) (*DataSourceHealth, error) {
+	return c.CheckDataSourceHealthContext(context.Background(), uid)
+}
+
+// CheckDataSourceHealthContext is like CheckDataSourceHealth but takes a context.
+func (c *Client) CheckDataSourceHealthContext(ctx context.Context, uid string) (*DataSourceHealth, error) {
+	path := fmt.Sprintf("/api/datasources/uid/%s/health", url.PathEscape(uid))
+	health := &DataSourceHealth{}
+	err := c.request(ctx, "GET", path, nil, nil, health)
+	if err == nil {
+		return health, nil
+	}
+
+	apiErr := &APIError{}
+	if !errors.As(err, &apiErr) {
+		return nil, err
+	}
+	// Failed checks are reported with a 400 and the health as the body.
+	if apiErr.StatusCode == http.StatusBadRequest && apiErr.Status != "" {
+		return &DataSourceHealth{Status: apiErr.Status, Message: apiErr.Message, Details: healthDetails(apiErr.Body)}, nil
+	}
+	if !IsNotFound(err) {
+		return nil, err
+	}
+
+	ds, err := c.DataSourceByUIDContext(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	return c.probeDataSource(ctx, ds)
+}
+
+// probeDataSource checks the health of a data source with a request
+// through the datasource proxy.
+func (c *Client) probeDataSource(ctx context.Context, ds *DataSource) (*DataSourceHealth, error) {
+	probe, known := dataSourceProbes[ds.Type]
+	u, err := url.Parse(probe)
+	if err != nil {
+		return nil, err
+	}
+	path := fmt.Sprintf("/api/datasources/proxy/%d/%s", ds.Id, u.Path)
+	var body []byte
+	err = c.request(ctx, "GET", path, u.Query(), nil, &body)
+	if err == nil {
+		return &DataSourceHealth{
+			Status:  HealthStatusOK,
+			Message: "Data source is working",
+			Details: map[string]interface{}{"statusCode": http.StatusOK},
+		}, nil
+	}
+
+	apiErr := &APIError{}
+	if !errors.As(err, &apiErr) {
+		return nil, err
+	}
+	health := &DataSourceHealth{
+		Status:  HealthStatusError,
+		Message: apiErr.Message,
+		Details: map[string]interface{}{"statusCode": apiErr.StatusCode},
+	}
+	if health.Message == "" {
+		health.Message = http.StatusText(apiErr.StatusCode)
+	}
+	if !known {
+		health.Status = HealthStatusUnknown
+		health.Message = fmt.Sprintf("%s data sources can't be checked through the proxy: %s", ds.Type, health.Message)
+	}
+	return health, nil
+}
+
+// healthDetails returns the details of the health in a response body.
+func healthDetails(body []byte) map[string]interface{} {
+	health := &DataSourceHealth{}
+	if json.Unmarshal(body, health) != nil {
+		return nil
+	}
+	return health.Details
+}
@@ -0,0 +1,94 @@
+package gapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckDataSourceHealth(t *testing.T) {
+	server, client := gapiTestTools(200, `{"status":"OK","message":"Data source is working","details":{"version":"2.40"}}`)
+	defer server.Close()
+
+	health, err := client.CheckDataSourceHealth("P1809F7CD0C75ACF3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.OK() || health.Message != "Data source is working" || health.Details["version"] != "2.40" {
+		t.Errorf("health = %+v", health)
+	}
+}
+
+func TestCheckDataSourceHealthFailing(t *testing.T) {
+	server, client := gapiTestTools(400, `{"status":"ERROR","message":"Unauthorized","details":{"verboseMessage":"401 from backend"}}`)
+	defer server.Close()
+
+	health, err := client.CheckDataSourceHealth("P1809F7CD0C75ACF3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.OK() || health.Status != HealthStatusError || health.Message != "Unauthorized" ||
+		health.Details["verboseMessage"] != "401 from backend" {
+		t.Errorf("health = %+v", health)
+	}
+}
+
+func TestCheckDataSourceHealthThroughProxy(t *testing.T) {
+	// Grafana before version 9 has no health route.
+	backendStatus := http.StatusOK
+	var probed string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/datasources/uid/prom":
+			w.Write([]byte(`{"id":3,"uid":"prom","name":"Prometheus","type":"prometheus"}`))
+		case "/api/datasources/uid/simple":
+			w.Write([]byte(`{"id":4,"uid":"simple","name":"JSON","type":"grafana-simple-json-datasource"}`))
+		case "/api/datasources/proxy/3/api/v1/query", "/api/datasources/proxy/4":
+			probed = r.URL.RequestURI()
+			w.WriteHeader(backendStatus)
+			w.Write([]byte(`{}`))
+		case "/api/datasources/uid/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Data source not found"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not found"}`))
+		}
+	}))
+	defer server.Close()
+	client, err := New("my-key", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	health, err := client.CheckDataSourceHealth("prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !health.OK() || probed != "/api/datasources/proxy/3/api/v1/query?query=1%2B1" {
+		t.Errorf("health = %+v, probed %s", health, probed)
+	}
+
+	backendStatus = http.StatusBadGateway
+	health, err = client.CheckDataSourceHealth("prom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != HealthStatusError || health.Message != "Bad Gateway" || health.Details["statusCode"] != http.StatusBadGateway {
+		t.Errorf("health = %+v", health)
+	}
+
+	// Other plugins are probed at their root, which they may not serve.
+	health, err = client.CheckDataSourceHealth("simple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if health.Status != HealthStatusUnknown || probed != "/api/datasources/proxy/4" {
+		t.Errorf("health = %+v, probed %s", health, probed)
+	}
+
+	if _, err := client.CheckDataSourceHealth("missing"); !IsNotFound(err) {
+		t.Errorf("missing datasource: got %v, want not found", err)
+	}
+}
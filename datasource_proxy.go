@@ -0,0 +1,115 @@
+package gapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DataSourceProxy returns an http.RoundTripper that sends requests to the
+// backend of the data source whose ID it's passed, through the datasource
+// proxy of Grafana, which adds the credentials of the data source. Only
+// the path and query of requests are kept, so clients of the backend can
+// be given any address:
+//
+//	prom, err := api.NewClient(api.Config{
+//		Address:      "http://prometheus",
+//		RoundTripper: client.DataSourceProxy(id),
+//	})
+//
+// Requests are authenticated as those of the client and sent with its
+// transport, but aren't retried or logged. They time out after the Timeout
+// of the client's http.Client, which covers reading the response body.
+func (c *Client) DataSourceProxy(id int64) http.RoundTripper {
+	return &dataSourceProxy{client: c, prefix: fmt.Sprintf("/api/datasources/proxy/%d", id)}
+}
+
+// DataSourceProxyByUID is like DataSourceProxy but takes the UID of the
+// data source. It needs Grafana 9 or later.
+func (c *Client) DataSourceProxyByUID(uid string) http.RoundTripper {
+	return &dataSourceProxy{client: c, prefix: fmt.Sprintf("/api/datasources/proxy/uid/%s", url.PathEscape(uid))}
+}
+
+type dataSourceProxy struct {
+	client *Client
+	// prefix is the escaped path of the proxy.
+	prefix string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (p *dataSourceProxy) RoundTrip(req *http.Request) (*http.Response, error) {
+	c := p.client
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if c.Client != nil && c.Client.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Client.Timeout)
+	}
+	proxied := req.Clone(ctx)
+	proxied.Host = ""
+	proxied.URL.Scheme = c.baseURL.Scheme
+	proxied.URL.Host = c.baseURL.Host
+	proxied.URL.User = nil
+	rawPath := strings.TrimSuffix(c.baseURL.EscapedPath(), "/") + p.prefix + "/" + strings.TrimPrefix(req.URL.EscapedPath(), "/")
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		cancel()
+		closeBody(req)
+		return nil, err
+	}
+	proxied.URL.Path, proxied.URL.RawPath = path, rawPath
+
+	for k, v := range c.headers {
+		proxied.Header[k] = append([]string(nil), v...)
+	}
+	if c.orgID > 0 {
+		proxied.Header.Set("X-Grafana-Org-Id", strconv.FormatInt(c.orgID, 10))
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(proxied); err != nil {
+			cancel()
+			closeBody(req)
+			return nil, err
+		}
+	}
+
+	transport := http.DefaultTransport
+	if c.Client != nil && c.Client.Transport != nil {
+		transport = c.Client.Transport
+	}
+	resp, err := transport.RoundTrip(proxied)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		if r, ok := c.auth.(interface{ reset() }); ok {
+			r.reset()
+		}
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the timeout of a proxied request when its response
+// body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// closeBody closes the body of a request, as RoundTrip must even when it
+// fails.
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,91 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDataSourceProxy(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	client, err := NewWithOptions(server.URL+"/grafana/", WithAPIKey("my-key"), WithHeader("X-Team", "sre"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Clients of the backend, such as that of Prometheus, can use any address.
+	backend := &http.Client{Transport: client.WithOrgID(2).DataSourceProxy(3)}
+	req, err := http.NewRequest("POST", "http://prometheus:9090/api/v1/query?timeout=5s", strings.NewReader("query=up"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := backend.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+
+	if got.Method != "POST" || got.URL.Path != "/grafana/api/datasources/proxy/3/api/v1/query" || got.URL.RawQuery != "timeout=5s" {
+		t.Errorf("request = %s %s", got.Method, got.URL)
+	}
+	if gotBody != "query=up" || got.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		t.Errorf("body = %q, %q", gotBody, got.Header.Get("Content-Type"))
+	}
+	if got.Header.Get("Authorization") != "Bearer my-key" || got.Header.Get("X-Grafana-Org-Id") != "2" || got.Header.Get("X-Team") != "sre" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	if got.Host != strings.TrimPrefix(server.URL, "http://") {
+		t.Errorf("host = %s", got.Host)
+	}
+
+	// Escaped paths are kept escaped.
+	req, _ = http.NewRequest("GET", "http://loki/loki/api/v1/label/a%2Fb/values", nil)
+	if _, err := (&http.Client{Transport: client.DataSourceProxyByUID("team/loki")}).Do(req); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.EscapedPath() != "/grafana/api/datasources/proxy/uid/team%2Floki/loki/api/v1/label/a%2Fb/values" {
+		t.Errorf("path = %s", got.URL.EscapedPath())
+	}
+}
+
+func TestDataSourceProxyTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewWithOptions(server.URL, WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("GET", "http://prometheus/api/v1/query?query=up", nil)
+	start := time.Now()
+	_, err = (&http.Client{Transport: client.DataSourceProxy(3)}).Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want deadline exceeded", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("request took %s", time.Since(start))
+	}
+}
@@ -0,0 +1,253 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExpressionDataSource is the data source of server side expressions, such
+// as math on the results of other queries.
+var ExpressionDataSource = DataSourceRef{Type: "__expr__", Uid: "__expr__"}
+
+// Ref returns a reference to the data source, for queries and dashboards.
+func (ds *DataSource) Ref() DataSourceRef {
+	return DataSourceRef{Type: ds.Type, Uid: ds.UID}
+}
+
+// AbsoluteTimeRange returns the time range between two times, with the
+// millisecond precision of Grafana.
+func AbsoluteTimeRange(from, to time.Time) TimeRange {
+	ms := func(t time.Time) string {
+		return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+	}
+	return TimeRange{From: ms(from), To: ms(to)}
+}
+
+// DataSourceQuery is a query run by QueryDataSources.
+type DataSourceQuery struct {
+	// RefID identifies the query, and its result in the response.
+	RefID      string        `json:"refId"`
+	DataSource DataSourceRef `json:"datasource"`
+	// Expr is the query expression of Prometheus and Loki data sources,
+	// and of math expressions.
+	Expr          string `json:"expr,omitempty"`
+	IntervalMs    int64  `json:"intervalMs,omitempty"`
+	MaxDataPoints int64  `json:"maxDataPoints,omitempty"`
+
+	// Extra holds the other fields of the query, which depend on the plugin,
+	// such as "rawSql" or "instant".
+	Extra map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON encodes the fields of q and its extra fields as one object.
+func (q DataSourceQuery) MarshalJSON() ([]byte, error) {
+	type plain DataSourceQuery
+	return marshalWithExtra(plain(q), q.Extra)
+}
+
+// QueryResponse is the response to QueryDataSources.
+type QueryResponse struct {
+	// Results are the results of the queries by RefID.
+	Results map[string]QueryResult `json:"results"`
+}
+
+// QueryResult is the result of a query.
+type QueryResult struct {
+	// Status and Error are set when the query failed.
+	Status int         `json:"status,omitempty"`
+	Error  string      `json:"error,omitempty"`
+	Frames []DataFrame `json:"frames,omitempty"`
+}
+
+// Err returns an error for the first query that failed, in the order of
+// their RefID, or nil.
+func (r *QueryResponse) Err() error {
+	refIDs := make([]string, 0, len(r.Results))
+	for refID := range r.Results {
+		refIDs = append(refIDs, refID)
+	}
+	sort.Strings(refIDs)
+	for _, refID := range refIDs {
+		if result := r.Results[refID]; result.Error != "" {
+			return fmt.Errorf("gapi: query %s failed: %s", refID, result.Error)
+		}
+	}
+	return nil
+}
+
+// DataFrame is a table of results, such as a time series, as a list of
+// columns of the same length.
+type DataFrame struct {
+	Name  string
+	RefID string
+	// Meta is the metadata of the data source, such as the query it ran.
+	Meta   map[string]interface{}
+	Fields []DataFrameField
+}
+
+// DataFrameField is a column of a data frame.
+type DataFrameField struct {
+	Name string
+	// Type is time, number, string, boolean or other.
+	Type   string
+	Labels map[string]string
+	Config map[string]interface{}
+	// Values are time.Time for time fields, int64 or float64 for number
+	// fields, depending on their type in the data source, string, bool, or
+	// the decoded JSON otherwise. Missing values are nil.
+	Values []interface{}
+}
+
+// Len returns the number of rows of the frame.
+func (f *DataFrame) Len() int {
+	if len(f.Fields) == 0 {
+		return 0
+	}
+	return len(f.Fields[0].Values)
+}
+
+// Field returns the field of the frame with the name, or nil.
+func (f *DataFrame) Field(name string) *DataFrameField {
+	for i := range f.Fields {
+		if f.Fields[i].Name == name {
+			return &f.Fields[i]
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes a data frame from the schema and data that Grafana
+// encodes frames as.
+func (f *DataFrame) UnmarshalJSON(data []byte) error {
+	frame := struct {
+		Schema struct {
+			Name   string                 `json:"name"`
+			RefID  string                 `json:"refId"`
+			Meta   map[string]interface{} `json:"meta"`
+			Fields []struct {
+				Name     string `json:"name"`
+				Type     string `json:"type"`
+				TypeInfo struct {
+					Frame string `json:"frame"`
+				} `json:"typeInfo"`
+				Labels map[string]string      `json:"labels"`
+				Config map[string]interface{} `json:"config"`
+			} `json:"fields"`
+		} `json:"schema"`
+		Data struct {
+			Values   [][]interface{}    `json:"values"`
+			Nanos    [][]int64          `json:"nanos"`
+			Entities []map[string][]int `json:"entities"`
+		} `json:"data"`
+	}{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&frame); err != nil {
+		return err
+	}
+
+	*f = DataFrame{Name: frame.Schema.Name, RefID: frame.Schema.RefID, Meta: frame.Schema.Meta}
+	for i, field := range frame.Schema.Fields {
+		values := []interface{}{}
+		if i < len(frame.Data.Values) {
+			values = frame.Data.Values[i]
+		}
+		for j, v := range values {
+			var err error
+			if values[j], err = frameValue(field.Type, field.TypeInfo.Frame, v); err != nil {
+				return fmt.Errorf("frame %q: field %q: %w", f.Name, field.Name, err)
+			}
+		}
+		if field.Type == "time" && i < len(frame.Data.Nanos) {
+			for j, nanos := range frame.Data.Nanos[i] {
+				if j >= len(values) {
+					break
+				}
+				if t, ok := values[j].(time.Time); ok {
+					values[j] = t.Add(time.Duration(nanos))
+				}
+			}
+		}
+		if i < len(frame.Data.Entities) {
+			special := map[string]float64{"NaN": math.NaN(), "Inf": math.Inf(1), "NegInf": math.Inf(-1)}
+			for entity, rows := range frame.Data.Entities[i] {
+				for _, j := range rows {
+					if j < len(values) {
+						values[j] = special[entity]
+					}
+				}
+			}
+		}
+
+		f.Fields = append(f.Fields, DataFrameField{
+			Name:   field.Name,
+			Type:   field.Type,
+			Labels: field.Labels,
+			Config: field.Config,
+			Values: values,
+		})
+	}
+	return nil
+}
+
+// frameValue converts a value of a field, decoded with json.Number.
+func frameValue(typ, frameType string, v interface{}) (interface{}, error) {
+	n, isNumber := v.(json.Number)
+	switch {
+	case v == nil:
+		return nil, nil
+	case typ == "time" && isNumber:
+		ms, err := n.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(0, int64(ms)*int64(time.Millisecond)), nil
+	case typ == "number" && isNumber && (strings.HasPrefix(frameType, "int") || strings.HasPrefix(frameType, "uint")):
+		return n.Int64()
+	case isNumber:
+		return n.Float64()
+	}
+	return v, nil
+}
+
+// QueryDataSources runs queries of data sources over the time range, as
+// panels do, and returns their results. When some queries fail, the
+// results of the others are returned along with the error.
+func (c *Client) QueryDataSources(timeRange TimeRange, queries ...DataSourceQuery) (*QueryResponse, error) {
+	return c.QueryDataSourcesContext(context.Background(), timeRange, queries...)
+}
+
+// QueryDataSourcesContext is like QueryDataSources but takes a context.
+func (c *Client) QueryDataSourcesContext(ctx context.Context, timeRange TimeRange, queries ...DataSourceQuery) (*QueryResponse, error) {
+	data, err := json.Marshal(struct {
+		From    string            `json:"from"`
+		To      string            `json:"to"`
+		Queries []DataSourceQuery `json:"queries"`
+	}{timeRange.From, timeRange.To, queries})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QueryResponse{}
+	err = c.request(ctx, "POST", "/api/ds/query", nil, bytes.NewBuffer(data), result)
+	apiErr := &APIError{}
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+		// Grafana answers with the results when queries fail.
+		if json.Unmarshal(apiErr.Body, result) != nil || len(result.Results) == 0 {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return result, result.Err()
+}
@@ -0,0 +1,143 @@
+package gapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const queryResponseJSON = `{
+  "results": {
+    "A": {
+      "status": 200,
+      "frames": [{
+        "schema": {
+          "name": "up",
+          "refId": "A",
+          "meta": {"executedQueryString": "Expr: up"},
+          "fields": [
+            {"name": "Time", "type": "time", "typeInfo": {"frame": "time.Time"}},
+            {"name": "Value", "type": "number", "typeInfo": {"frame": "float64", "nullable": true}, "labels": {"job": "grafana"}, "config": {"displayNameFromDS": "grafana"}},
+            {"name": "Count", "type": "number", "typeInfo": {"frame": "int64"}},
+            {"name": "Instance", "type": "string", "typeInfo": {"frame": "string"}}
+          ]
+        },
+        "data": {
+          "values": [
+            [1672531200000, 1672531215000, 1672531230000],
+            [1, null, null],
+            [9007199254740993, 2, 3],
+            ["a", "b", "c"]
+          ],
+          "nanos": [[0, 500, 0]],
+          "entities": [null, {"NaN": [2]}, null, null]
+        }
+      }]
+    }
+  }
+}`
+
+func TestQueryDataSources(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		if r.Method != "POST" || r.URL.Path != "/api/ds/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(queryResponseJSON))
+	}))
+	defer server.Close()
+	client, err := New("my-key", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prometheus := &DataSource{UID: "P1809F7CD0C75ACF3", Type: "prometheus"}
+	from := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	resp, err := client.QueryDataSources(AbsoluteTimeRange(from, from.Add(time.Hour)), DataSourceQuery{
+		RefID:      "A",
+		DataSource: prometheus.Ref(),
+		Expr:       "up",
+		IntervalMs: 15000,
+		Extra:      map[string]interface{}{"instant": false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedRequest := map[string]interface{}{
+		"from": "1672531200000",
+		"to":   "1672534800000",
+		"queries": []interface{}{map[string]interface{}{
+			"refId":      "A",
+			"datasource": map[string]interface{}{"type": "prometheus", "uid": "P1809F7CD0C75ACF3"},
+			"expr":       "up",
+			"intervalMs": float64(15000),
+			"instant":    false,
+		}},
+	}
+	if !reflect.DeepEqual(request, expectedRequest) {
+		t.Errorf("request = %v", request)
+	}
+
+	frames := resp.Results["A"].Frames
+	if len(frames) != 1 || frames[0].Name != "up" || frames[0].RefID != "A" || frames[0].Len() != 3 {
+		t.Fatalf("frames = %+v", frames)
+	}
+	frame := frames[0]
+	if frame.Meta["executedQueryString"] != "Expr: up" {
+		t.Errorf("meta = %v", frame.Meta)
+	}
+
+	times := frame.Field("Time").Values
+	if !times[0].(time.Time).Equal(from) || !times[1].(time.Time).Equal(from.Add(15*time.Second+500)) {
+		t.Errorf("times = %v", times)
+	}
+	value := frame.Field("Value")
+	if value.Labels["job"] != "grafana" || value.Config["displayNameFromDS"] != "grafana" {
+		t.Errorf("value field = %+v", value)
+	}
+	if value.Values[0] != 1.0 || value.Values[1] != nil || !math.IsNaN(value.Values[2].(float64)) {
+		t.Errorf("values = %v", value.Values)
+	}
+	if count := frame.Field("Count").Values; count[0] != int64(9007199254740993) {
+		t.Errorf("counts = %v", count)
+	}
+	if instances := frame.Field("Instance").Values; instances[2] != "c" {
+		t.Errorf("instances = %v", instances)
+	}
+	if frame.Field("Missing") != nil {
+		t.Error("found a missing field")
+	}
+}
+
+func TestQueryDataSourcesErrors(t *testing.T) {
+	server, client := gapiTestTools(400, `{"results":{"A":{"status":200,"frames":[]},"B":{"status":400,"error":"parse error at char 4"}}}`)
+	defer server.Close()
+
+	resp, err := client.QueryDataSources(TimeRange{From: "now-1h", To: "now"},
+		DataSourceQuery{RefID: "A", DataSource: DataSourceRef{Uid: "prom"}, Expr: "up"},
+		DataSourceQuery{RefID: "B", DataSource: DataSourceRef{Uid: "prom"}, Expr: "up{"},
+	)
+	if err == nil || !strings.Contains(err.Error(), "query B failed: parse error at char 4") {
+		t.Errorf("got error %v", err)
+	}
+	if resp == nil || resp.Results["B"].Status != 400 || len(resp.Results) != 2 {
+		t.Errorf("resp = %+v", resp)
+	}
+
+	server, client = gapiTestTools(400, `{"message":"Query data error"}`)
+	defer server.Close()
+	resp, err = client.QueryDataSources(TimeRange{From: "now-1h", To: "now"})
+	if resp != nil || err == nil {
+		t.Errorf("QueryDataSources = %v, %v", resp, err)
+	}
+}
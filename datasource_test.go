@@ -1,6 +1,8 @@
 package gapi
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/gobs/pretty"
@@ -73,3 +75,120 @@ func TestNewPrometheusDataSource(t *testing.T) {
 		t.Error("datasource creation response should return the created datasource ID")
 	}
 }
+
+func TestDataSources(t *testing.T) {
+	server, client := gapiTestTools(200, `[{"id":1,"name":"foo","type":"prometheus","url":"http://some-url.com","access":"proxy","isDefault":true}]`)
+	defer server.Close()
+
+	datasources, err := client.DataSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(datasources))
+
+	if len(datasources) != 1 || datasources[0].Id != 1 || datasources[0].Name != "foo" {
+		t.Error("Not correctly parsing returned datasources.")
+	}
+}
+
+func TestDataSourceByUID(t *testing.T) {
+	server, client := gapiTestTools(200, `{"id":1,"uid":"P1809F7CD0C75ACF3","name":"foo","type":"prometheus"}`)
+	defer server.Close()
+
+	ds, err := client.DataSourceByUID("P1809F7CD0C75ACF3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ds.Id != 1 || ds.UID != "P1809F7CD0C75ACF3" || ds.Name != "foo" {
+		t.Errorf("Not correctly parsing returned datasource: %v", pretty.PrettyFormat(ds))
+	}
+}
+
+func TestDataSourceByName(t *testing.T) {
+	server, client := gapiTestTools(200, `{"id":1,"uid":"P1809F7CD0C75ACF3","name":"foo","type":"prometheus"}`)
+	defer server.Close()
+
+	ds, err := client.DataSourceByName("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ds.Id != 1 || ds.Name != "foo" {
+		t.Errorf("Not correctly parsing returned datasource: %v", pretty.PrettyFormat(ds))
+	}
+}
+
+func TestDataSourceIDByName(t *testing.T) {
+	server, client := gapiTestTools(200, `{"id":7}`)
+	defer server.Close()
+
+	id, err := client.DataSourceIDByName("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if id != 7 {
+		t.Errorf("DataSourceIDByName = %d, want 7", id)
+	}
+}
+
+func TestDataSourceByNameEscaped(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		w.Write([]byte(`{"id":1,"uid":"a/b","name":"team a/b"}`))
+	}))
+	defer server.Close()
+	client, err := New("my-key", server.URL+"/grafana")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.DataSourceByName("team a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DataSourceIDByName("team a/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DeleteDataSourceByUID("a/b"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"/grafana/api/datasources/name/team%20a%2Fb",
+		"/grafana/api/datasources/id/team%20a%2Fb",
+		"/grafana/api/datasources/uid/a%2Fb",
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("got paths %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("got path %s, want %s", paths[i], want[i])
+		}
+	}
+}
+
+func TestDataSourceByNameNotFound(t *testing.T) {
+	server, client := gapiTestTools(404, `{"message":"Data source not found"}`)
+	defer server.Close()
+
+	if _, err := client.DataSourceByName("bar"); !IsNotFound(err) {
+		t.Errorf("DataSourceByName: got %v, want not found", err)
+	}
+	if err := client.DeleteDataSourceByName("bar"); !IsNotFound(err) {
+		t.Errorf("DeleteDataSourceByName: got %v, want not found", err)
+	}
+}
+
+func TestUpdateDataSourceByUID(t *testing.T) {
+	server, client := gapiTestTools(200, `{"id":1,"message":"Datasource updated","name":"foo"}`)
+	defer server.Close()
+
+	err := client.UpdateDataSourceByUID(&DataSource{UID: "P1809F7CD0C75ACF3", Name: "foo", Type: "prometheus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
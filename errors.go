@@ -0,0 +1,111 @@
+package gapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Sentinel errors matched by APIError through errors.Is.
+var (
+	ErrBadRequest         = errors.New("gapi: bad request")
+	ErrUnauthorized       = errors.New("gapi: unauthorized")
+	ErrForbidden          = errors.New("gapi: forbidden")
+	ErrNotFound           = errors.New("gapi: not found")
+	ErrConflict           = errors.New("gapi: conflict")
+	ErrPreconditionFailed = errors.New("gapi: precondition failed")
+	ErrServerError        = errors.New("gapi: server error")
+)
+
+// APIError represents an error response returned by the Grafana API.
+type APIError struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Method and Path identify the request that failed.
+	Method string
+	Path   string
+	// Message and Status are the `message` and `status` fields of the
+	// response body, when Grafana sends them. Status carries values such as
+	// "version-mismatch", "name-exists" or "not-found".
+	Message string
+	Status  string
+	// Body is the raw response body.
+	Body []byte
+}
+
+func newAPIError(method, path string, statusCode int, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		Path:       path,
+		Body:       body,
+	}
+
+	fields := struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	}{}
+	if json.Unmarshal(body, &fields) == nil {
+		e.Message = fields.Message
+		e.Status = fields.Status
+	}
+
+	return e
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("status: %d, body: %s", e.StatusCode, e.Body)
+}
+
+// Is reports whether the error matches one of the package's sentinel errors.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrServerError:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// IsNotFound reports whether err is an APIError with a 404 status.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict reports whether err is an APIError with a 409 status.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsUnauthorized reports whether err is an APIError with a 401 status.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsForbidden reports whether err is an APIError with a 403 status.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+// IsPreconditionFailed reports whether err is an APIError with a 412 status,
+// which Grafana uses for dashboard version mismatches.
+func IsPreconditionFailed(err error) bool {
+	return errors.Is(err, ErrPreconditionFailed)
+}
+
+// IsServerError reports whether err is an APIError with a 5xx status.
+func IsServerError(err error) bool {
+	return errors.Is(err, ErrServerError)
+}
@@ -0,0 +1,67 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+	server, client := gapiTestTools(412, `{"message":"The dashboard has been changed by someone else","status":"version-mismatch"}`)
+	defer server.Close()
+
+	err := client.request(context.Background(), "POST", "/api/dashboards/db", nil, nil, nil)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError; got: %T", err)
+	}
+	if apiErr.StatusCode != 412 || apiErr.Method != "POST" || apiErr.Path != "/api/dashboards/db" {
+		t.Errorf("unexpected error fields: %+v", apiErr)
+	}
+	if apiErr.Status != "version-mismatch" {
+		t.Errorf("expected status: version-mismatch; got: %s", apiErr.Status)
+	}
+	if apiErr.Message != "The dashboard has been changed by someone else" {
+		t.Errorf("unexpected message: %s", apiErr.Message)
+	}
+	if !IsPreconditionFailed(err) {
+		t.Error("expected IsPreconditionFailed to be true")
+	}
+	if IsNotFound(err) {
+		t.Error("expected IsNotFound to be false")
+	}
+}
+
+func TestAPIError_is(t *testing.T) {
+	for _, tc := range []struct {
+		code   int
+		target error
+		check  func(error) bool
+	}{
+		{401, ErrUnauthorized, IsUnauthorized},
+		{403, ErrForbidden, IsForbidden},
+		{404, ErrNotFound, IsNotFound},
+		{409, ErrConflict, IsConflict},
+		{412, ErrPreconditionFailed, IsPreconditionFailed},
+	} {
+		err := error(newAPIError("GET", "/foo", tc.code, []byte(`not json`)))
+		if !errors.Is(err, tc.target) {
+			t.Errorf("%d: expected errors.Is(%v)", tc.code, tc.target)
+		}
+		if !tc.check(err) {
+			t.Errorf("%d: helper did not match", tc.code)
+		}
+	}
+
+	err := newAPIError("GET", "/foo", 502, nil)
+	if !errors.Is(err, ErrServerError) {
+		t.Error("expected 502 to match ErrServerError")
+	}
+	if !IsServerError(err) {
+		t.Error("expected IsServerError to be true")
+	}
+	if IsNotFound(errors.New("status: 404")) {
+		t.Error("plain errors should not match")
+	}
+}
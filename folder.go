@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -11,12 +12,19 @@ type Folder struct {
 	Id    int64  `json:"id"`
 	Uid   string `json:"uid"`
 	Title string `json:"title"`
+	// Version is incremented by Grafana when the folder is updated.
+	Version int64 `json:"version,omitempty"`
 }
 
 // Folders fetches and returns Grafana folders.
 func (c *Client) Folders() ([]Folder, error) {
+	return c.FoldersContext(context.Background())
+}
+
+// FoldersContext is like Folders but takes a context.
+func (c *Client) FoldersContext(ctx context.Context) ([]Folder, error) {
 	folders := make([]Folder, 0)
-	err := c.request("GET", "/api/folders/", nil, nil, &folders)
+	err := c.request(ctx, "GET", "/api/folders/", nil, nil, &folders)
 	if err != nil {
 		return folders, err
 	}
@@ -26,8 +34,29 @@ func (c *Client) Folders() ([]Folder, error) {
 
 // Folder fetches and returns the Grafana folder whose ID it's passed.
 func (c *Client) Folder(id int64) (*Folder, error) {
+	return c.FolderContext(context.Background(), id)
+}
+
+// FolderContext is like Folder but takes a context.
+func (c *Client) FolderContext(ctx context.Context, id int64) (*Folder, error) {
 	folder := &Folder{}
-	err := c.request("GET", fmt.Sprintf("/api/folders/id/%d", id), nil, nil, folder)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/folders/id/%d", id), nil, nil, folder)
+	if err != nil {
+		return folder, err
+	}
+
+	return folder, err
+}
+
+// FolderByUid fetches and returns the Grafana folder whose UID it's passed.
+func (c *Client) FolderByUid(uid string) (*Folder, error) {
+	return c.FolderByUidContext(context.Background(), uid)
+}
+
+// FolderByUidContext is like FolderByUid but takes a context.
+func (c *Client) FolderByUidContext(ctx context.Context, uid string) (*Folder, error) {
+	folder := &Folder{}
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/folders/%s", uid), nil, nil, folder)
 	if err != nil {
 		return folder, err
 	}
@@ -37,16 +66,35 @@ func (c *Client) Folder(id int64) (*Folder, error) {
 
 // NewFolder creates a new Grafana folder.
 func (c *Client) NewFolder(title string) (Folder, error) {
+	return c.NewFolderContext(context.Background(), title)
+}
+
+// NewFolderContext is like NewFolder but takes a context.
+func (c *Client) NewFolderContext(ctx context.Context, title string) (Folder, error) {
+	return c.NewFolderWithUIDContext(ctx, title, "")
+}
+
+// NewFolderWithUID creates a new Grafana folder with the UID it's passed.
+// Grafana generates the UID when it's empty.
+func (c *Client) NewFolderWithUID(title, uid string) (Folder, error) {
+	return c.NewFolderWithUIDContext(context.Background(), title, uid)
+}
+
+// NewFolderWithUIDContext is like NewFolderWithUID but takes a context.
+func (c *Client) NewFolderWithUIDContext(ctx context.Context, title, uid string) (Folder, error) {
 	folder := Folder{}
 	dataMap := map[string]string{
 		"title": title,
 	}
+	if uid != "" {
+		dataMap["uid"] = uid
+	}
 	data, err := json.Marshal(dataMap)
 	if err != nil {
 		return folder, err
 	}
 
-	err = c.request("POST", "/api/folders", nil, bytes.NewBuffer(data), &folder)
+	err = c.request(ctx, "POST", "/api/folders", nil, bytes.NewBuffer(data), &folder)
 	if err != nil {
 		return folder, err
 	}
@@ -54,20 +102,52 @@ func (c *Client) NewFolder(title string) (Folder, error) {
 	return folder, err
 }
 
-// UpdateFolder updates the folder whose ID it's passed.
+// UpdateFolder renames the folder whose UID it's passed. It fetches the
+// folder to send its current version, as Grafana requires one, so it only
+// fails with a 412 error on a change made between the two requests. Use
+// UpdateFolderVersion to guard against changes made since the folder was
+// read.
 func (c *Client) UpdateFolder(id string, name string) error {
-	dataMap := map[string]string{
-		"name": name,
+	return c.UpdateFolderContext(context.Background(), id, name)
+}
+
+// UpdateFolderContext is like UpdateFolder but takes a context.
+func (c *Client) UpdateFolderContext(ctx context.Context, id string, name string) error {
+	folder, err := c.FolderByUidContext(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.UpdateFolderVersionContext(ctx, id, name, folder.Version)
+}
+
+// UpdateFolderVersion renames the folder whose UID it's passed if it's
+// still at the version it's passed, such as that of a folder returned by
+// FolderByUid. Otherwise it fails with a 412 error, which IsPreconditionFailed
+// reports.
+func (c *Client) UpdateFolderVersion(uid, title string, version int64) error {
+	return c.UpdateFolderVersionContext(context.Background(), uid, title, version)
+}
+
+// UpdateFolderVersionContext is like UpdateFolderVersion but takes a context.
+func (c *Client) UpdateFolderVersionContext(ctx context.Context, uid, title string, version int64) error {
+	dataMap := map[string]interface{}{
+		"title":   title,
+		"version": version,
 	}
 	data, err := json.Marshal(dataMap)
 	if err != nil {
 		return err
 	}
 
-	return c.request("PUT", fmt.Sprintf("/api/folders/%s", id), nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PUT", fmt.Sprintf("/api/folders/%s", uid), nil, bytes.NewBuffer(data), nil)
 }
 
 // DeleteFolder deletes the folder whose ID it's passed.
 func (c *Client) DeleteFolder(id string) error {
-	return c.request("DELETE", fmt.Sprintf("/api/folders/%s", id), nil, nil, nil)
+	return c.DeleteFolderContext(context.Background(), id)
+}
+
+// DeleteFolderContext is like DeleteFolder but takes a context.
+func (c *Client) DeleteFolderContext(ctx context.Context, id string) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("/api/folders/%s", id), nil, nil, nil)
 }
@@ -2,50 +2,77 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 )
 
+// PermissionLevel is the level of access a permission grants.
+type PermissionLevel int64
+
+// Permission levels.
+const (
+	PermissionView  PermissionLevel = 1
+	PermissionEdit  PermissionLevel = 2
+	PermissionAdmin PermissionLevel = 4
+)
+
+func (p PermissionLevel) String() string {
+	switch p {
+	case PermissionView:
+		return "View"
+	case PermissionEdit:
+		return "Edit"
+	case PermissionAdmin:
+		return "Admin"
+	}
+	return fmt.Sprintf("PermissionLevel(%d)", int64(p))
+}
+
 // FolderPermission has information such as a folder, user, team, role and permission.
 type FolderPermission struct {
 	Id        int64  `json:"id"`
 	FolderUid string `json:"uid"`
 	UserId    int64  `json:"userId"`
+	UserLogin string `json:"userLogin"`
 	TeamId    int64  `json:"teamId"`
+	Team      string `json:"team"`
 	Role      string `json:"role"`
 	IsFolder  bool   `json:"isFolder"`
 
-	// Permission levels are
-	// 1 = View
-	// 2 = Edit
-	// 4 = Admin
-	Permission     int64  `json:"permission"`
-	PermissionName string `json:"permissionName"`
+	// Permission is one of PermissionView, PermissionEdit or PermissionAdmin.
+	Permission     PermissionLevel `json:"permission"`
+	PermissionName string          `json:"permissionName"`
 
 	// optional fields
 	FolderId    int64 `json:"folderId,omitempty"`
 	DashboardId int64 `json:"dashboardId,omitempty"`
 }
 
-// PermissionItems represents Grafana folder permission items.
+// PermissionItems represents Grafana folder or dashboard permission items.
 type PermissionItems struct {
 	Items []*PermissionItem `json:"items"`
 }
 
-// PermissionItem represents a Grafana folder permission item.
+// PermissionItem represents a Grafana folder or dashboard permission item.
 type PermissionItem struct {
 	// As you can see the docs, each item has a pair of [Role|TeamId|UserId] and Permission.
 	// unnecessary fields are omitted.
-	Role       string `json:"role,omitempty"`
-	TeamId     int64  `json:"teamId,omitempty"`
-	UserId     int64  `json:"userId,omitempty"`
-	Permission int64  `json:"permission"`
+	Role       string          `json:"role,omitempty"`
+	TeamId     int64           `json:"teamId,omitempty"`
+	UserId     int64           `json:"userId,omitempty"`
+	Permission PermissionLevel `json:"permission"`
 }
 
 // FolderPermissions fetches and returns the permissions for the folder whose ID it's passed.
 func (c *Client) FolderPermissions(fid string) ([]*FolderPermission, error) {
+	return c.FolderPermissionsContext(context.Background(), fid)
+}
+
+// FolderPermissionsContext is like FolderPermissions but takes a context.
+func (c *Client) FolderPermissionsContext(ctx context.Context, fid string) ([]*FolderPermission, error) {
 	permissions := make([]*FolderPermission, 0)
-	err := c.request("GET", fmt.Sprintf("/api/folders/%s/permissions", fid), nil, nil, &permissions)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/folders/%s/permissions", fid), nil, nil, &permissions)
 	if err != nil {
 		return permissions, err
 	}
@@ -55,11 +82,16 @@ func (c *Client) FolderPermissions(fid string) ([]*FolderPermission, error) {
 
 // UpdateFolderPermissions remove existing permissions if items are not included in the request.
 func (c *Client) UpdateFolderPermissions(fid string, items *PermissionItems) error {
+	return c.UpdateFolderPermissionsContext(context.Background(), fid, items)
+}
+
+// UpdateFolderPermissionsContext is like UpdateFolderPermissions but takes a context.
+func (c *Client) UpdateFolderPermissionsContext(ctx context.Context, fid string, items *PermissionItems) error {
 	path := fmt.Sprintf("/api/folders/%s/permissions", fid)
 	data, err := json.Marshal(items)
 	if err != nil {
 		return err
 	}
 
-	return c.request("POST", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "POST", path, nil, bytes.NewBuffer(data), nil)
 }
@@ -1,6 +1,10 @@
 package gapi
 
 import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/gobs/pretty"
@@ -120,6 +124,22 @@ func TestFolder(t *testing.T) {
 	}
 }
 
+func TestFolderByUid(t *testing.T) {
+	server, client := gapiTestTools(200, getFolderJSON)
+	defer server.Close()
+
+	resp, err := client.FolderByUid("nErXDvCkzz")
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Log(pretty.PrettyFormat(resp))
+
+	if resp.Uid != "nErXDvCkzz" || resp.Version != 1 {
+		t.Error("Not correctly parsing returned folder.")
+	}
+}
+
 func TestNewFolder(t *testing.T) {
 	server, client := gapiTestTools(200, createdFolderJSON)
 	defer server.Close()
@@ -136,14 +156,88 @@ func TestNewFolder(t *testing.T) {
 	}
 }
 
+func TestNewFolderWithUID(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		w.Write([]byte(createdFolderJSON))
+	}))
+	defer server.Close()
+	client, err := New("my-key", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.NewFolderWithUID("test-folder", "nErXDvCkzz")
+	if err != nil {
+		t.Error(err)
+	}
+	if resp.Uid != "nErXDvCkzz" || body["uid"] != "nErXDvCkzz" || body["title"] != "test-folder" {
+		t.Errorf("Unexpected folder creation: %v", body)
+	}
+}
+
 func TestUpdateFolder(t *testing.T) {
-	server, client := gapiTestTools(200, updatedFolderJSON)
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/folders/nErXDvCkzz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "PUT" {
+			data, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(data, &body)
+		}
+		w.Write([]byte(updatedFolderJSON))
+	}))
 	defer server.Close()
+	client, err := New("my-key", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.UpdateFolder("nErXDvCkzz", "test-folder")
+	if err != nil {
+		t.Error(err)
+	}
 
-	err := client.UpdateFolder("nErXDvCkzz", "test-folder")
+	// The fetched version is sent, as Grafana requires one.
+	if body["title"] != "test-folder" || body["version"] != float64(1) || body["overwrite"] != nil {
+		t.Errorf("body = %v", body)
+	}
+}
+
+func TestUpdateFolderVersion(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		body := map[string]interface{}{}
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		if body["version"] != float64(1) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`{"message":"The folder has been changed by someone else","status":"version-mismatch"}`))
+			return
+		}
+		w.Write([]byte(updatedFolderJSON))
+	}))
+	defer server.Close()
+	client, err := New("my-key", server.URL)
 	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.UpdateFolderVersion("nErXDvCkzz", "test-folder", 1); err != nil {
 		t.Error(err)
 	}
+	if err := client.UpdateFolderVersion("nErXDvCkzz", "test-folder", 0); !IsPreconditionFailed(err) {
+		t.Errorf("stale version: got %v, want precondition failed", err)
+	}
+	// The version isn't fetched.
+	if len(methods) != 2 || methods[0] != "PUT" || methods[1] != "PUT" {
+		t.Errorf("methods = %v", methods)
+	}
 }
 
 func TestDeleteFolder(t *testing.T) {
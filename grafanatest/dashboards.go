@@ -0,0 +1,930 @@
+package grafanatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// dashboard is a dashboard or a folder.
+type dashboard struct {
+	id       int64
+	uid      string
+	title    string
+	isFolder bool
+	folderID int64
+	version  int64
+	model    map[string]interface{}
+
+	created   time.Time
+	updated   time.Time
+	createdBy string
+	updatedBy string
+
+	versions []*gapi.DashboardVersion
+	// acl is nil until permissions are set, when the defaults apply.
+	acl []*gapi.PermissionItem
+}
+
+var slugDisallowed = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify returns the slug of a title, as found in dashboard URLs.
+func slugify(title string) string {
+	return strings.Trim(slugDisallowed.ReplaceAllString(strings.ToLower(title), "-"), "-")
+}
+
+func (d *dashboard) slug() string {
+	return slugify(d.title)
+}
+
+func (d *dashboard) url() string {
+	if d.isFolder {
+		return fmt.Sprintf("/dashboards/f/%s/%s", d.uid, d.slug())
+	}
+	return fmt.Sprintf("/d/%s/%s", d.uid, d.slug())
+}
+
+func (d *dashboard) tags() []string {
+	tags := []string{}
+	if list, ok := d.model["tags"].([]interface{}); ok {
+		for _, tag := range list {
+			if s, ok := tag.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+	return tags
+}
+
+func (d *dashboard) removeTeam(id int64) {
+	for i := 0; i < len(d.acl); i++ {
+		if d.acl[i].TeamId == id {
+			d.acl = append(d.acl[:i], d.acl[i+1:]...)
+			i--
+		}
+	}
+}
+
+// find returns the dashboard or folder with the ID or UID, or nil.
+func (o *org) find(isFolder bool, id int64, uid string) *dashboard {
+	for _, d := range o.dashboards {
+		if d.isFolder == isFolder && ((id != 0 && d.id == id) || (uid != "" && d.uid == uid)) {
+			return d
+		}
+	}
+	return nil
+}
+
+// findByTitle returns the dashboard or folder of the folder with the title.
+func (o *org) findByTitle(isFolder bool, folderID int64, title string) *dashboard {
+	for _, d := range o.dashboards {
+		if d.isFolder == isFolder && d.folderID == folderID && strings.EqualFold(d.title, title) {
+			return d
+		}
+	}
+	return nil
+}
+
+func (o *org) uidTaken(uid string) bool {
+	for _, d := range o.dashboards {
+		if d.uid == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// pathDashboard returns the dashboard of the :uid, :slug or :id path
+// parameter.
+func pathDashboard(c *call) *dashboard {
+	if slug, ok := c.params["slug"]; ok {
+		for _, d := range c.org.dashboards {
+			if !d.isFolder && d.slug() == slug {
+				return d
+			}
+		}
+		return nil
+	}
+	return c.org.find(false, c.id("id"), c.params["uid"])
+}
+
+func (s *Server) folderOf(o *org, d *dashboard) *dashboard {
+	if d.folderID == 0 {
+		return nil
+	}
+	return o.dashboards[d.folderID]
+}
+
+var uidPattern = regexp.MustCompile(`^[a-zA-Z0-9\-_]*$`)
+
+// saveRequest is the body of dashboard saves and imports.
+type saveRequest struct {
+	Dashboard map[string]interface{} `json:"dashboard"`
+	FolderID  int64                  `json:"folderId"`
+	FolderUID string                 `json:"folderUid"`
+	Overwrite bool                   `json:"overwrite"`
+	Message   string                 `json:"message"`
+}
+
+func int64Field(m map[string]interface{}, key string) int64 {
+	switch v := m[key].(type) {
+	case float64:
+		return int64(v)
+	case json.Number:
+		n, _ := v.Int64()
+		return n
+	}
+	return 0
+}
+
+func stringField(m map[string]interface{}, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// save saves the dashboard of the request as Grafana does, returning the
+// saved dashboard or the error response.
+func (c *call) save(req saveRequest, restoredFrom int64) (*dashboard, int, interface{}) {
+	model := clone(req.Dashboard)
+	if model == nil {
+		return nil, http.StatusBadRequest, badRequest
+	}
+	title := strings.TrimSpace(stringField(model, "title"))
+	if title == "" {
+		return nil, http.StatusBadRequest, message("Dashboard title cannot be empty")
+	}
+	uid := stringField(model, "uid")
+	if len(uid) > 40 {
+		return nil, http.StatusBadRequest, message("Dashboard UID too long")
+	}
+	if !uidPattern.MatchString(uid) {
+		return nil, http.StatusBadRequest, message("uid contains illegal characters")
+	}
+
+	folderID := req.FolderID
+	if req.FolderUID != "" {
+		folder := c.org.find(true, 0, req.FolderUID)
+		if folder == nil {
+			return nil, http.StatusBadRequest, message("Folder not found")
+		}
+		folderID = folder.id
+	} else if folderID != 0 && c.org.find(true, folderID, "") == nil {
+		return nil, http.StatusBadRequest, message("Folder not found")
+	}
+
+	var existing *dashboard
+	if id := int64Field(model, "id"); id != 0 {
+		if existing = c.org.find(false, id, ""); existing == nil {
+			return nil, http.StatusNotFound, message("Dashboard not found")
+		}
+	} else if uid != "" {
+		if existing = c.org.find(false, 0, uid); existing == nil && c.org.uidTaken(uid) {
+			return nil, http.StatusBadRequest, message("Dashboard with the same uid already exists")
+		}
+	}
+	if existing != nil && !req.Overwrite && int64Field(model, "version") != existing.version {
+		return nil, http.StatusPreconditionFailed, map[string]interface{}{
+			"message": "The dashboard has been changed by someone else",
+			"status":  "version-mismatch",
+		}
+	}
+	if same := c.org.findByTitle(false, folderID, title); same != nil && same != existing {
+		if !req.Overwrite || existing != nil {
+			return nil, http.StatusPreconditionFailed, map[string]interface{}{
+				"message": "A dashboard with the same name in the folder already exists",
+				"status":  "name-exists",
+			}
+		}
+		existing = same
+	}
+
+	now := time.Now()
+	d := existing
+	if d == nil {
+		d = &dashboard{
+			id:        c.s.nextID("dashboard"),
+			uid:       uid,
+			created:   now,
+			createdBy: c.login,
+		}
+		if d.uid == "" {
+			d.uid = c.s.newUID()
+		}
+		c.org.dashboards[d.id] = d
+	}
+	parent := d.version
+	d.title = title
+	d.folderID = folderID
+	d.version++
+	d.updated = now
+	d.updatedBy = c.login
+	model["id"] = d.id
+	model["uid"] = d.uid
+	model["version"] = d.version
+	model["title"] = title
+	d.model = model
+
+	d.versions = append(d.versions, &gapi.DashboardVersion{
+		Id:            c.s.nextID("dashboard_version"),
+		DashboardId:   d.id,
+		ParentVersion: parent,
+		RestoredFrom:  restoredFrom,
+		Version:       d.version,
+		Created:       now,
+		CreatedBy:     c.login,
+		Message:       req.Message,
+		Data:          clone(model),
+	})
+	c.s.syncAlerts(c.org, d)
+	return d, 0, nil
+}
+
+func saveResponse(d *dashboard) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      d.id,
+		"uid":     d.uid,
+		"url":     d.url(),
+		"status":  "success",
+		"version": d.version,
+		"slug":    d.slug(),
+	}
+}
+
+func saveDashboard(c *call) (int, interface{}) {
+	req := saveRequest{}
+	if err := c.decode(&req); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	d, code, body := c.save(req, 0)
+	if d == nil {
+		return code, body
+	}
+	return http.StatusOK, saveResponse(d)
+}
+
+func importDashboard(c *call) (int, interface{}) {
+	req := struct {
+		saveRequest
+		Inputs []gapi.DashboardInput `json:"inputs"`
+	}{}
+	if err := c.decode(&req); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	model := clone(req.Dashboard)
+	if model == nil {
+		return http.StatusBadRequest, badRequest
+	}
+	delete(model, "__inputs")
+	delete(model, "__requires")
+	delete(model, "id")
+	data, err := json.Marshal(model)
+	if err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	text := string(data)
+	for _, input := range req.Inputs {
+		value, err := json.Marshal(input.Value)
+		if err != nil {
+			return http.StatusBadRequest, badRequest
+		}
+		text = strings.Replace(text, "${"+input.Name+"}", strings.Trim(string(value), `"`), -1)
+	}
+	req.Dashboard = nil
+	if err := json.Unmarshal([]byte(text), &req.Dashboard); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+
+	d, code, body := c.save(req.saveRequest, 0)
+	if d == nil {
+		return code, body
+	}
+	return http.StatusOK, map[string]interface{}{
+		"uid":         d.uid,
+		"pluginId":    "",
+		"title":       d.title,
+		"imported":    true,
+		"importedUri": "db/" + d.slug(),
+		"importedUrl": d.url(),
+		"slug":        d.slug(),
+		"dashboardId": d.id,
+		"folderId":    d.folderID,
+		"removed":     false,
+	}
+}
+
+func getDashboard(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	meta := map[string]interface{}{
+		"type":        "db",
+		"canSave":     c.role != RoleViewer,
+		"canEdit":     c.role != RoleViewer,
+		"canAdmin":    c.role == RoleAdmin,
+		"canStar":     c.user != nil,
+		"slug":        d.slug(),
+		"url":         d.url(),
+		"expires":     "0001-01-01T00:00:00Z",
+		"created":     formatTime(d.created),
+		"updated":     formatTime(d.updated),
+		"createdBy":   d.createdBy,
+		"updatedBy":   d.updatedBy,
+		"version":     d.version,
+		"hasAcl":      d.acl != nil,
+		"isFolder":    false,
+		"folderId":    d.folderID,
+		"folderUid":   "",
+		"folderTitle": "General",
+		"folderUrl":   "",
+		"provisioned": false,
+	}
+	if folder := c.s.folderOf(c.org, d); folder != nil {
+		meta["folderUid"] = folder.uid
+		meta["folderTitle"] = folder.title
+		meta["folderUrl"] = folder.url()
+	}
+	return http.StatusOK, map[string]interface{}{
+		"meta":      meta,
+		"dashboard": clone(d.model),
+	}
+}
+
+func deleteDashboard(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	c.s.deleteDashboard(c.org, d)
+	return http.StatusOK, map[string]interface{}{
+		"title":   d.title,
+		"message": fmt.Sprintf("Dashboard %s deleted", d.title),
+		"id":      d.id,
+	}
+}
+
+func (s *Server) deleteDashboard(o *org, d *dashboard) {
+	delete(o.dashboards, d.id)
+	for id, a := range s.alerts {
+		if a.orgID == o.id && a.dashboardID == d.id {
+			delete(s.alerts, id)
+		}
+	}
+}
+
+func dashboardVersions(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	start := c.queryInt("start", 0)
+	limit := c.queryInt("limit", 1000)
+	result := []gapi.DashboardVersion{}
+	for i := len(d.versions) - 1 - start; i >= 0 && len(result) < limit; i-- {
+		v := *d.versions[i]
+		v.Data = nil
+		result = append(result, v)
+	}
+	return http.StatusOK, result
+}
+
+func (d *dashboard) versionOf(version int64) *gapi.DashboardVersion {
+	for _, v := range d.versions {
+		if v.Version == version {
+			return v
+		}
+	}
+	return nil
+}
+
+func dashboardVersion(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	v := d.versionOf(c.id("version"))
+	if v == nil {
+		return fail(http.StatusNotFound, "Dashboard version not found")
+	}
+	result := *v
+	result.Data = clone(v.Data)
+	return http.StatusOK, result
+}
+
+func restoreDashboardVersion(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	body := struct {
+		Version int64 `json:"version"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	v := d.versionOf(body.Version)
+	if v == nil {
+		return fail(http.StatusNotFound, "Dashboard version not found")
+	}
+
+	model := clone(v.Data)
+	model["id"] = d.id
+	model["version"] = d.version
+	d, code, result := c.save(saveRequest{
+		Dashboard: model,
+		FolderID:  d.folderID,
+		Message:   fmt.Sprintf("Restored from version %d", v.Version),
+	}, v.Version)
+	if d == nil {
+		return code, result
+	}
+	return http.StatusOK, saveResponse(d)
+}
+
+// diffDashboards returns a line diff of the indented JSON of the two
+// versions, for both diff types.
+func diffDashboards(c *call) (int, interface{}) {
+	body := struct {
+		Base gapi.DashboardDiffTarget `json:"base"`
+		New  gapi.DashboardDiffTarget `json:"new"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	var lines [2][]string
+	for i, target := range []gapi.DashboardDiffTarget{body.Base, body.New} {
+		d := c.org.find(false, target.DashboardId, "")
+		if d == nil {
+			return fail(http.StatusNotFound, "Dashboard not found")
+		}
+		v := d.versionOf(target.Version)
+		if v == nil {
+			return fail(http.StatusNotFound, "Dashboard version not found")
+		}
+		data, err := json.MarshalIndent(v.Data, "", "  ")
+		if err != nil {
+			panic(err)
+		}
+		lines[i] = strings.Split(string(data), "\n")
+	}
+	return http.StatusOK, diffLines(lines[0], lines[1])
+}
+
+// diffLines returns the lines of a and b prefixed with "-", "+" or " "
+// depending on whether they were removed, added or kept.
+func diffLines(a, b []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var sb strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			sb.WriteString(" " + a[i] + "\n")
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			sb.WriteString("-" + a[i] + "\n")
+			i++
+		default:
+			sb.WriteString("+" + b[j] + "\n")
+			j++
+		}
+	}
+	return sb.String()
+}
+
+func searchDashboards(c *call) (int, interface{}) {
+	q := c.r.URL.Query()
+	query := strings.ToLower(q.Get("query"))
+	typ := q.Get("type")
+	if q.Get("starred") == "true" {
+		// The fake has no stars.
+		return http.StatusOK, []interface{}{}
+	}
+	ids := map[string]bool{}
+	for _, id := range q["dashboardIds"] {
+		ids[id] = true
+	}
+	uids := map[string]bool{}
+	for _, uid := range q["dashboardUIDs"] {
+		uids[uid] = true
+	}
+	folderIDs := map[int64]bool{}
+	for _, id := range q["folderIds"] {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		folderIDs[n] = true
+	}
+	for _, uid := range q["folderUIDs"] {
+		if folder := c.org.find(true, 0, uid); folder != nil {
+			folderIDs[folder.id] = true
+		} else if uid == "general" {
+			folderIDs[0] = true
+		}
+	}
+
+	var found []*dashboard
+	for _, d := range c.org.dashboards {
+		switch {
+		case typ == gapi.SearchTypeDashboard && d.isFolder,
+			typ == gapi.SearchTypeFolder && !d.isFolder,
+			!strings.Contains(strings.ToLower(d.title), query),
+			len(ids) > 0 && !ids[strconv.FormatInt(d.id, 10)],
+			len(uids) > 0 && !uids[d.uid],
+			len(folderIDs) > 0 && (d.isFolder || !folderIDs[d.folderID]),
+			!hasTags(d.tags(), q["tag"]):
+			continue
+		}
+		found = append(found, d)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := strings.ToLower(found[i].title), strings.ToLower(found[j].title)
+		if q.Get("sort") == "alpha-desc" {
+			a, b = b, a
+		}
+		if a != b {
+			return a < b
+		}
+		return found[i].id < found[j].id
+	})
+
+	limit := c.queryInt("limit", 1000)
+	if limit <= 0 || limit > 5000 {
+		limit = 1000
+	}
+	page := c.queryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	result := []map[string]interface{}{}
+	for i := (page - 1) * limit; i < len(found) && i < page*limit; i++ {
+		result = append(result, c.s.searchHit(c.org, found[i]))
+	}
+	return http.StatusOK, result
+}
+
+func hasTags(tags, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, tag := range tags {
+			if tag == w {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) searchHit(o *org, d *dashboard) map[string]interface{} {
+	hit := map[string]interface{}{
+		"id":        d.id,
+		"uid":       d.uid,
+		"title":     d.title,
+		"uri":       "db/" + d.slug(),
+		"url":       d.url(),
+		"slug":      "",
+		"type":      gapi.SearchTypeDashboard,
+		"tags":      d.tags(),
+		"isStarred": false,
+		"sortMeta":  0,
+	}
+	if d.isFolder {
+		hit["type"] = gapi.SearchTypeFolder
+	}
+	if folder := s.folderOf(o, d); folder != nil {
+		hit["folderId"] = folder.id
+		hit["folderUid"] = folder.uid
+		hit["folderTitle"] = folder.title
+		hit["folderUrl"] = folder.url()
+	}
+	return hit
+}
+
+// defaultACL is what dashboards and folders get until their permissions
+// are set.
+var defaultACL = []*gapi.PermissionItem{
+	{Role: RoleViewer, Permission: gapi.PermissionView},
+	{Role: RoleEditor, Permission: gapi.PermissionEdit},
+}
+
+func (d *dashboard) permissions() []*gapi.PermissionItem {
+	if d.acl == nil {
+		return defaultACL
+	}
+	return d.acl
+}
+
+var permissionNames = map[gapi.PermissionLevel]string{
+	gapi.PermissionView:  "View",
+	gapi.PermissionEdit:  "Edit",
+	gapi.PermissionAdmin: "Admin",
+}
+
+func (s *Server) permissionJSON(o *org, d *dashboard, item *gapi.PermissionItem) map[string]interface{} {
+	p := map[string]interface{}{
+		"dashboardId":    d.id,
+		"created":        formatTime(d.created),
+		"updated":        formatTime(d.updated),
+		"userId":         item.UserId,
+		"userLogin":      "",
+		"userEmail":      "",
+		"teamId":         item.TeamId,
+		"team":           "",
+		"role":           item.Role,
+		"permission":     item.Permission,
+		"permissionName": permissionNames[item.Permission],
+		"uid":            d.uid,
+		"title":          d.title,
+		"slug":           d.slug(),
+		"isFolder":       d.isFolder,
+		"url":            d.url(),
+		"inherited":      false,
+	}
+	if u := s.users[item.UserId]; u != nil {
+		p["userLogin"] = u.login
+		p["userEmail"] = u.email
+	}
+	if t := o.teams[item.TeamId]; t != nil {
+		p["team"] = t.Name
+	}
+	return p
+}
+
+func getDashboardPermissions(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	result := []map[string]interface{}{}
+	if folder := c.s.folderOf(c.org, d); folder != nil {
+		for _, item := range folder.permissions() {
+			p := c.s.permissionJSON(c.org, folder, item)
+			p["inherited"] = true
+			result = append(result, p)
+		}
+	} else if d.acl != nil {
+		// Dashboards of the General folder inherit the defaults.
+		for _, item := range defaultACL {
+			p := c.s.permissionJSON(c.org, d, item)
+			p["inherited"] = true
+			result = append(result, p)
+		}
+	}
+	for _, item := range d.permissions() {
+		result = append(result, c.s.permissionJSON(c.org, d, item))
+	}
+	return http.StatusOK, result
+}
+
+func updateDashboardPermissions(c *call) (int, interface{}) {
+	d := pathDashboard(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	return updatePermissions(c, d, "Dashboard permissions updated")
+}
+
+func updatePermissions(c *call, d *dashboard, done string) (int, interface{}) {
+	body := gapi.PermissionItems{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	seen := map[string]bool{}
+	acl := []*gapi.PermissionItem{}
+	for _, item := range body.Items {
+		if item == nil {
+			return http.StatusBadRequest, badRequest
+		}
+		if _, ok := permissionNames[item.Permission]; !ok {
+			return fail(http.StatusBadRequest, "Invalid permission")
+		}
+		var key string
+		switch {
+		case item.UserId != 0 && item.TeamId == 0 && item.Role == "":
+			if c.s.users[item.UserId] == nil {
+				return fail(http.StatusBadRequest, "User not found")
+			}
+			key = fmt.Sprintf("user %d", item.UserId)
+		case item.TeamId != 0 && item.UserId == 0 && item.Role == "":
+			if c.org.teams[item.TeamId] == nil {
+				return fail(http.StatusBadRequest, "Team not found")
+			}
+			key = fmt.Sprintf("team %d", item.TeamId)
+		case item.Role != "" && item.UserId == 0 && item.TeamId == 0:
+			if item.Role != RoleViewer && item.Role != RoleEditor {
+				return fail(http.StatusBadRequest, "Invalid role")
+			}
+			key = "role " + item.Role
+		default:
+			return fail(http.StatusBadRequest, "Permission should have exactly one of a user, a team or a role")
+		}
+		if seen[key] {
+			return fail(http.StatusBadRequest, "Duplicate permission")
+		}
+		seen[key] = true
+		copied := *item
+		acl = append(acl, &copied)
+	}
+	d.acl = acl
+	return http.StatusOK, message(done)
+}
+
+func folderJSON(c *call, d *dashboard) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        d.id,
+		"uid":       d.uid,
+		"title":     d.title,
+		"url":       d.url(),
+		"hasAcl":    d.acl != nil,
+		"canSave":   c.role != RoleViewer,
+		"canEdit":   c.role != RoleViewer,
+		"canAdmin":  c.role == RoleAdmin,
+		"createdBy": d.createdBy,
+		"created":   formatTime(d.created),
+		"updatedBy": d.updatedBy,
+		"updated":   formatTime(d.updated),
+		"version":   d.version,
+	}
+}
+
+// pathFolder returns the folder of the :uid or :id path parameter.
+func pathFolder(c *call) *dashboard {
+	return c.org.find(true, c.id("id"), c.params["uid"])
+}
+
+func listFolders(c *call) (int, interface{}) {
+	var folders []*dashboard
+	for _, d := range c.org.dashboards {
+		if d.isFolder {
+			folders = append(folders, d)
+		}
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		return strings.ToLower(folders[i].title) < strings.ToLower(folders[j].title)
+	})
+	limit := c.queryInt("limit", 1000)
+	result := []map[string]interface{}{}
+	for i := 0; i < len(folders) && i < limit; i++ {
+		result = append(result, map[string]interface{}{
+			"id":    folders[i].id,
+			"uid":   folders[i].uid,
+			"title": folders[i].title,
+		})
+	}
+	return http.StatusOK, result
+}
+
+func getFolder(c *call) (int, interface{}) {
+	d := pathFolder(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Folder not found")
+	}
+	return http.StatusOK, folderJSON(c, d)
+}
+
+func createFolder(c *call) (int, interface{}) {
+	body := struct {
+		UID   string `json:"uid"`
+		Title string `json:"title"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	body.Title = strings.TrimSpace(body.Title)
+	if body.Title == "" {
+		return fail(http.StatusBadRequest, "folder title cannot be empty")
+	}
+	if len(body.UID) > 40 || !uidPattern.MatchString(body.UID) {
+		return fail(http.StatusBadRequest, "uid contains illegal characters")
+	}
+	if body.UID != "" && c.org.uidTaken(body.UID) {
+		return fail(http.StatusConflict, "a folder/dashboard with the same uid already exists")
+	}
+	if c.org.findByTitle(true, 0, body.Title) != nil {
+		return fail(http.StatusConflict, "a folder or dashboard in the general folder with the same name already exists")
+	}
+
+	now := time.Now()
+	d := &dashboard{
+		id:        c.s.nextID("dashboard"),
+		uid:       body.UID,
+		title:     body.Title,
+		isFolder:  true,
+		version:   1,
+		created:   now,
+		updated:   now,
+		createdBy: c.login,
+		updatedBy: c.login,
+	}
+	if d.uid == "" {
+		d.uid = c.s.newUID()
+	}
+	c.org.dashboards[d.id] = d
+	return http.StatusOK, folderJSON(c, d)
+}
+
+func updateFolder(c *call) (int, interface{}) {
+	d := pathFolder(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Folder not found")
+	}
+	body := struct {
+		UID       string `json:"uid"`
+		Title     string `json:"title"`
+		Version   int64  `json:"version"`
+		Overwrite bool   `json:"overwrite"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	body.Title = strings.TrimSpace(body.Title)
+	if body.Title == "" {
+		return fail(http.StatusBadRequest, "folder title cannot be empty")
+	}
+	if !body.Overwrite && body.Version != d.version {
+		return http.StatusPreconditionFailed, map[string]interface{}{
+			"message": "the folder has been changed by someone else",
+			"status":  "version-mismatch",
+		}
+	}
+	if other := c.org.findByTitle(true, 0, body.Title); other != nil && other != d {
+		return fail(http.StatusConflict, "a folder or dashboard in the general folder with the same name already exists")
+	}
+	if body.UID != "" && body.UID != d.uid {
+		if c.org.uidTaken(body.UID) {
+			return fail(http.StatusConflict, "a folder/dashboard with the same uid already exists")
+		}
+		d.uid = body.UID
+	}
+	d.title = body.Title
+	d.version++
+	d.updated = time.Now()
+	d.updatedBy = c.login
+	return http.StatusOK, folderJSON(c, d)
+}
+
+func deleteFolder(c *call) (int, interface{}) {
+	d := pathFolder(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Folder not found")
+	}
+	for _, child := range c.org.dashboards {
+		if child.folderID == d.id {
+			c.s.deleteDashboard(c.org, child)
+		}
+	}
+	delete(c.org.dashboards, d.id)
+	return http.StatusOK, map[string]interface{}{
+		"title":   d.title,
+		"message": fmt.Sprintf("Folder %s deleted", d.title),
+		"id":      d.id,
+	}
+}
+
+func getFolderPermissions(c *call) (int, interface{}) {
+	d := pathFolder(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Folder not found")
+	}
+	result := []map[string]interface{}{}
+	for _, item := range d.permissions() {
+		p := c.s.permissionJSON(c.org, d, item)
+		p["folderId"] = d.id
+		result = append(result, p)
+	}
+	return http.StatusOK, result
+}
+
+func updateFolderPermissions(c *call) (int, interface{}) {
+	d := pathFolder(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Folder not found")
+	}
+	return updatePermissions(c, d, "Folder permissions updated")
+}
@@ -0,0 +1,661 @@
+package grafanatest
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// org holds the content of a Grafana organization.
+type org struct {
+	id   int64
+	name string
+	// members maps the IDs of the users of the org to their role.
+	members map[int64]string
+	prefs   gapi.Preferences
+
+	// dashboards holds dashboards and folders, which share their IDs and
+	// UIDs like they do in Grafana.
+	dashboards         map[int64]*dashboard
+	dataSources        map[int64]*dataSource
+	alertNotifications map[int64]*gapi.AlertNotification
+	teams              map[int64]*team
+	annotations        map[int64]*annotation
+	playlists          map[int64]*playlist
+}
+
+type team struct {
+	gapi.Team
+	members []int64
+	prefs   gapi.Preferences
+}
+
+func (s *Server) newOrg(name string) *org {
+	o := &org{
+		id:                 s.nextID("org"),
+		name:               name,
+		members:            map[int64]string{},
+		dashboards:         map[int64]*dashboard{},
+		dataSources:        map[int64]*dataSource{},
+		alertNotifications: map[int64]*gapi.AlertNotification{},
+		teams:              map[int64]*team{},
+		annotations:        map[int64]*annotation{},
+		playlists:          map[int64]*playlist{},
+	}
+	s.orgs[o.id] = o
+	return o
+}
+
+func (s *Server) orgByName(name string) *org {
+	for _, o := range s.orgs {
+		if strings.EqualFold(o.name, name) {
+			return o
+		}
+	}
+	return nil
+}
+
+func orgJSON(o *org) map[string]interface{} {
+	return map[string]interface{}{
+		"id":   o.id,
+		"name": o.name,
+		"address": map[string]string{
+			"address1": "",
+			"address2": "",
+			"city":     "",
+			"zipCode":  "",
+			"state":    "",
+			"country":  "",
+		},
+	}
+}
+
+func listOrgs(c *call) (int, interface{}) {
+	query := strings.ToLower(c.query("query"))
+	name := c.query("name")
+	var orgs []*org
+	for _, o := range c.s.orgs {
+		if name != "" && !strings.EqualFold(o.name, name) {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(o.name), query) {
+			continue
+		}
+		orgs = append(orgs, o)
+	}
+	sort.Slice(orgs, func(i, j int) bool { return strings.ToLower(orgs[i].name) < strings.ToLower(orgs[j].name) })
+
+	start, end := c.page("perpage", 1000, len(orgs))
+	result := []map[string]interface{}{}
+	for _, o := range orgs[start:end] {
+		result = append(result, map[string]interface{}{"id": o.id, "name": o.name})
+	}
+	return http.StatusOK, result
+}
+
+// pathOrg returns the org of the :id or :name path parameter.
+func pathOrg(c *call) *org {
+	if name, ok := c.params["name"]; ok {
+		return c.s.orgByName(name)
+	}
+	return c.s.orgs[c.id("id")]
+}
+
+func getOrg(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	return http.StatusOK, orgJSON(o)
+}
+
+func createOrg(c *call) (int, interface{}) {
+	body := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if body.Name == "" {
+		return fail(http.StatusBadRequest, "Organization name cannot be empty")
+	}
+	if c.s.orgByName(body.Name) != nil {
+		return fail(http.StatusConflict, "Organization name taken")
+	}
+
+	o := c.s.newOrg(body.Name)
+	o.members[c.user.id] = RoleAdmin
+	return http.StatusOK, map[string]interface{}{
+		"orgId":   o.id,
+		"message": "Organization created",
+	}
+}
+
+func updateOrg(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	body := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if other := c.s.orgByName(body.Name); other != nil && other != o {
+		return fail(http.StatusConflict, "Organization name taken")
+	}
+	o.name = body.Name
+	return http.StatusOK, message("Organization updated")
+}
+
+func deleteOrg(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	delete(c.s.orgs, o.id)
+	for id, a := range c.s.alerts {
+		if a.orgID == o.id {
+			delete(c.s.alerts, id)
+		}
+	}
+	for _, u := range c.s.users {
+		if u.orgID == o.id {
+			u.orgID = c.s.firstOrg(u)
+		}
+	}
+	return http.StatusOK, message("Organization deleted")
+}
+
+// firstOrg returns the ID of the first org the user is a member of.
+func (s *Server) firstOrg(u *user) int64 {
+	first := int64(0)
+	for id, o := range s.orgs {
+		if o.members[u.id] != "" && (first == 0 || id < first) {
+			first = id
+		}
+	}
+	return first
+}
+
+func orgUsersJSON(s *Server, o *org) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	for _, u := range s.sortedUsers() {
+		role := o.members[u.id]
+		if role == "" {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"orgId":         o.id,
+			"userId":        u.id,
+			"email":         u.email,
+			"name":          u.name,
+			"avatarUrl":     avatarURL(u.email),
+			"login":         u.login,
+			"role":          role,
+			"lastSeenAt":    formatTime(u.created),
+			"lastSeenAtAge": "10 years",
+		})
+	}
+	return result
+}
+
+func orgUsers(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	return http.StatusOK, orgUsersJSON(c.s, o)
+}
+
+func currentOrgUsers(c *call) (int, interface{}) {
+	return http.StatusOK, orgUsersJSON(c.s, c.org)
+}
+
+func validRole(role string) bool {
+	_, ok := roleAccess[role]
+	return ok
+}
+
+func addOrgUser(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	body := struct {
+		LoginOrEmail string `json:"loginOrEmail"`
+		Role         string `json:"role"`
+	}{}
+	if err := c.decode(&body); err != nil || !validRole(body.Role) {
+		return http.StatusBadRequest, badRequest
+	}
+	u := c.s.userByLoginOrEmail(body.LoginOrEmail)
+	if u == nil {
+		return fail(http.StatusNotFound, "User not found")
+	}
+	if o.members[u.id] != "" {
+		return fail(http.StatusConflict, "User is already member of this organization")
+	}
+	o.members[u.id] = body.Role
+	if u.orgID == 0 {
+		u.orgID = o.id
+	}
+	return http.StatusOK, map[string]interface{}{
+		"message": "User added to organization",
+		"userId":  u.id,
+	}
+}
+
+func updateOrgUser(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	body := struct {
+		Role string `json:"role"`
+	}{}
+	if err := c.decode(&body); err != nil || !validRole(body.Role) {
+		return http.StatusBadRequest, badRequest
+	}
+	userID := c.id("userId")
+	if o.members[userID] == "" {
+		return fail(http.StatusNotFound, "User not found")
+	}
+	if body.Role != RoleAdmin && o.members[userID] == RoleAdmin && o.admins() == 1 {
+		return fail(http.StatusBadRequest, "Cannot change role so that there is no organization admin left")
+	}
+	o.members[userID] = body.Role
+	return http.StatusOK, message("Organization user updated")
+}
+
+func removeOrgUser(c *call) (int, interface{}) {
+	o := pathOrg(c)
+	if o == nil {
+		return fail(http.StatusNotFound, "Organization not found")
+	}
+	userID := c.id("userId")
+	if o.members[userID] == "" {
+		return fail(http.StatusNotFound, "User not found")
+	}
+	if o.members[userID] == RoleAdmin && o.admins() == 1 {
+		return fail(http.StatusBadRequest, "Cannot remove last organization admin")
+	}
+	delete(o.members, userID)
+	for _, t := range o.teams {
+		t.members = removeID(t.members, userID)
+	}
+	if u := c.s.users[userID]; u.orgID == o.id {
+		u.orgID = c.s.firstOrg(u)
+	}
+	return http.StatusOK, message("User removed from organization")
+}
+
+func (o *org) admins() int {
+	n := 0
+	for _, role := range o.members {
+		if role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+func removeID(ids []int64, id int64) []int64 {
+	result := ids[:0]
+	for _, i := range ids {
+		if i != id {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func getOrgPreferences(c *call) (int, interface{}) {
+	return http.StatusOK, c.org.prefs
+}
+
+func updateOrgPreferences(c *call) (int, interface{}) {
+	return updatePreferences(c, &c.org.prefs)
+}
+
+func updatePreferences(c *call, prefs *gapi.Preferences) (int, interface{}) {
+	body := gapi.Preferences{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if body.HomeDashboardId != 0 && c.org.dashboards[body.HomeDashboardId] == nil {
+		return fail(http.StatusNotFound, "Dashboard not found")
+	}
+	*prefs = body
+	return http.StatusOK, message("Preferences updated")
+}
+
+func (s *Server) sortedUsers() []*user {
+	users := make([]*user, 0, len(s.users))
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].login < users[j].login })
+	return users
+}
+
+// avatarURL returns the Gravatar path Grafana gives users.
+func avatarURL(email string) string {
+	return "/avatar/" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func userJSON(u *user) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            u.id,
+		"name":          u.name,
+		"login":         u.login,
+		"email":         u.email,
+		"avatarUrl":     avatarURL(u.email),
+		"isAdmin":       u.isAdmin,
+		"isDisabled":    false,
+		"lastSeenAt":    formatTime(u.created),
+		"lastSeenAtAge": "10 years",
+	}
+}
+
+// filterUsers returns the users whose login, email or name contain the
+// query parameter.
+func filterUsers(c *call) []map[string]interface{} {
+	query := strings.ToLower(c.query("query"))
+	result := []map[string]interface{}{}
+	for _, u := range c.s.sortedUsers() {
+		if strings.Contains(strings.ToLower(u.login), query) ||
+			strings.Contains(strings.ToLower(u.email), query) ||
+			strings.Contains(strings.ToLower(u.name), query) {
+			result = append(result, userJSON(u))
+		}
+	}
+	return result
+}
+
+func listUsers(c *call) (int, interface{}) {
+	users := filterUsers(c)
+	start, end := c.page("perpage", 1000, len(users))
+	return http.StatusOK, users[start:end]
+}
+
+func searchUsers(c *call) (int, interface{}) {
+	users := filterUsers(c)
+	start, end := c.page("perpage", 1000, len(users))
+	return http.StatusOK, map[string]interface{}{
+		"totalCount": len(users),
+		"users":      users[start:end],
+		"page":       c.queryInt("page", 1),
+		"perPage":    c.queryInt("perpage", 1000),
+	}
+}
+
+func lookupUser(c *call) (int, interface{}) {
+	u := c.s.userByLoginOrEmail(c.query("loginOrEmail"))
+	if u == nil {
+		return fail(http.StatusNotFound, "user not found")
+	}
+	return http.StatusOK, map[string]interface{}{
+		"id":             u.id,
+		"email":          u.email,
+		"name":           u.name,
+		"login":          u.login,
+		"theme":          "",
+		"orgId":          u.orgID,
+		"isGrafanaAdmin": u.isAdmin,
+		"isDisabled":     false,
+		"createdAt":      formatTime(u.created),
+		"updatedAt":      formatTime(u.created),
+	}
+}
+
+func createUser(c *call) (int, interface{}) {
+	body := struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Login    string `json:"login"`
+		Password string `json:"password"`
+		OrgID    int64  `json:"OrgId"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if body.Login == "" {
+		body.Login = body.Email
+	}
+	if body.Login == "" {
+		return fail(http.StatusBadRequest, "Login or email is required")
+	}
+	if len(body.Password) < 4 {
+		return fail(http.StatusBadRequest, "Password is missing or too short")
+	}
+	if c.s.userByLoginOrEmail(body.Login) != nil || (body.Email != "" && c.s.userByLoginOrEmail(body.Email) != nil) {
+		return fail(http.StatusPreconditionFailed, "User already exists")
+	}
+
+	orgID := body.OrgID
+	if orgID == 0 {
+		orgID = 1
+	}
+	o := c.s.orgs[orgID]
+	if o == nil {
+		return fail(http.StatusBadRequest, "Organization not found")
+	}
+	u := &user{
+		id:       c.s.nextID("user"),
+		login:    body.Login,
+		email:    body.Email,
+		name:     body.Name,
+		password: body.Password,
+		orgID:    o.id,
+		created:  time.Now(),
+	}
+	c.s.users[u.id] = u
+	o.members[u.id] = RoleViewer
+	return http.StatusOK, map[string]interface{}{
+		"id":      u.id,
+		"message": "User created",
+	}
+}
+
+func deleteUser(c *call) (int, interface{}) {
+	u := c.s.users[c.id("id")]
+	if u == nil {
+		return fail(http.StatusNotFound, "User not found")
+	}
+	delete(c.s.users, u.id)
+	for _, o := range c.s.orgs {
+		delete(o.members, u.id)
+		for _, t := range o.teams {
+			t.members = removeID(t.members, u.id)
+		}
+	}
+	return http.StatusOK, message("User deleted")
+}
+
+func (o *org) teamByName(name string) *team {
+	for _, t := range o.teams {
+		if strings.EqualFold(t.Name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+func teamJSON(t *team) gapi.Team {
+	result := t.Team
+	result.AvatarUrl = avatarURL(t.Email)
+	result.MemberCount = int64(len(t.members))
+	return result
+}
+
+func searchTeams(c *call) (int, interface{}) {
+	query := strings.ToLower(c.query("query"))
+	name := c.query("name")
+	var teams []*team
+	for _, t := range c.org.teams {
+		if name != "" && !strings.EqualFold(t.Name, name) {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(t.Name), query) {
+			continue
+		}
+		teams = append(teams, t)
+	}
+	sort.Slice(teams, func(i, j int) bool { return strings.ToLower(teams[i].Name) < strings.ToLower(teams[j].Name) })
+
+	start, end := c.page("perPage", 1000, len(teams))
+	result := []gapi.Team{}
+	for _, t := range teams[start:end] {
+		result = append(result, teamJSON(t))
+	}
+	return http.StatusOK, map[string]interface{}{
+		"totalCount": len(teams),
+		"teams":      result,
+		"page":       c.queryInt("page", 1),
+		"perPage":    c.queryInt("perPage", 1000),
+	}
+}
+
+func pathTeam(c *call) *team {
+	return c.org.teams[c.id("id")]
+}
+
+func getTeam(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	return http.StatusOK, teamJSON(t)
+}
+
+func createTeam(c *call) (int, interface{}) {
+	body := gapi.Team{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if body.Name == "" {
+		return fail(http.StatusBadRequest, "Team name is required")
+	}
+	if c.org.teamByName(body.Name) != nil {
+		return fail(http.StatusConflict, "Team name taken")
+	}
+
+	t := &team{Team: gapi.Team{
+		Id:    c.s.nextID("team"),
+		OrgId: c.org.id,
+		Name:  body.Name,
+		Email: body.Email,
+	}}
+	c.org.teams[t.Id] = t
+	return http.StatusOK, map[string]interface{}{
+		"message": "Team created",
+		"teamId":  t.Id,
+	}
+}
+
+func updateTeam(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	body := gapi.Team{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if other := c.org.teamByName(body.Name); other != nil && other != t {
+		return fail(http.StatusConflict, "Team name taken")
+	}
+	t.Name, t.Email = body.Name, body.Email
+	return http.StatusOK, message("Team updated")
+}
+
+func deleteTeam(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Failed to delete Team. ID not found")
+	}
+	delete(c.org.teams, t.Id)
+	for _, d := range c.org.dashboards {
+		d.removeTeam(t.Id)
+	}
+	return http.StatusOK, message("Team deleted")
+}
+
+func teamMembers(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	result := []gapi.TeamMember{}
+	for _, id := range t.members {
+		u := c.s.users[id]
+		result = append(result, gapi.TeamMember{
+			OrgId:     c.org.id,
+			TeamId:    t.Id,
+			UserId:    u.id,
+			Email:     u.email,
+			Login:     u.login,
+			AvatarUrl: avatarURL(u.email),
+		})
+	}
+	return http.StatusOK, result
+}
+
+func addTeamMember(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	body := struct {
+		UserID int64 `json:"userId"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if c.s.users[body.UserID] == nil || c.org.members[body.UserID] == "" {
+		return fail(http.StatusNotFound, "User not found")
+	}
+	for _, id := range t.members {
+		if id == body.UserID {
+			return fail(http.StatusBadRequest, "User is already added to this team")
+		}
+	}
+	t.members = append(t.members, body.UserID)
+	return http.StatusOK, message("Member added to Team")
+}
+
+func removeTeamMember(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	userID := c.id("userId")
+	for _, id := range t.members {
+		if id == userID {
+			t.members = removeID(t.members, userID)
+			return http.StatusOK, message("Team Member removed")
+		}
+	}
+	return fail(http.StatusNotFound, "Team member not found")
+}
+
+func getTeamPreferences(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	return http.StatusOK, t.prefs
+}
+
+func updateTeamPreferences(c *call) (int, interface{}) {
+	t := pathTeam(c)
+	if t == nil {
+		return fail(http.StatusNotFound, "Team not found")
+	}
+	return updatePreferences(c, &t.prefs)
+}
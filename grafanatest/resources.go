@@ -0,0 +1,913 @@
+package grafanatest
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// dataSource keeps the fields of a datasource as they were sent, so that
+// plugin specific settings survive, and its secure fields apart, as
+// Grafana never returns them.
+type dataSource struct {
+	id     int64
+	uid    string
+	fields map[string]interface{}
+	secure map[string]interface{}
+	// health is the result of its health checks, OK when nil.
+	health *gapi.DataSourceHealth
+}
+
+func (d *dataSource) name() string {
+	return stringField(d.fields, "name")
+}
+
+var dataSourceTypeNames = map[string]string{
+	"prometheus":                       "Prometheus",
+	"graphite":                         "Graphite",
+	"influxdb":                         "InfluxDB",
+	"elasticsearch":                    "Elasticsearch",
+	"loki":                             "Loki",
+	"mysql":                            "MySQL",
+	"postgres":                         "PostgreSQL",
+	"mssql":                            "Microsoft SQL Server",
+	"cloudwatch":                       "CloudWatch",
+	"stackdriver":                      "Google Cloud Monitoring",
+	"opentsdb":                         "OpenTSDB",
+	"tempo":                            "Tempo",
+	"jaeger":                           "Jaeger",
+	"zipkin":                           "Zipkin",
+	"grafana-azure-monitor-datasource": "Azure Monitor",
+}
+
+func dataSourceJSON(o *org, d *dataSource, secureFields bool) map[string]interface{} {
+	result := clone(d.fields)
+	result["id"] = d.id
+	result["uid"] = d.uid
+	result["orgId"] = o.id
+	result["typeName"] = dataSourceTypeNames[stringField(d.fields, "type")]
+	result["readOnly"] = false
+	if result["jsonData"] == nil {
+		result["jsonData"] = map[string]interface{}{}
+	}
+	if secureFields {
+		fields := map[string]bool{}
+		for key := range d.secure {
+			fields[key] = true
+		}
+		result["secureJsonFields"] = fields
+	}
+	return result
+}
+
+func (o *org) dataSourceByName(name string) *dataSource {
+	for _, d := range o.dataSources {
+		if d.name() == name {
+			return d
+		}
+	}
+	return nil
+}
+
+func (o *org) dataSourceByUID(uid string) *dataSource {
+	for _, d := range o.dataSources {
+		if d.uid == uid {
+			return d
+		}
+	}
+	return nil
+}
+
+// pathDataSource returns the datasource of the :id, :uid or :name path
+// parameter.
+func pathDataSource(c *call) *dataSource {
+	if uid, ok := c.params["uid"]; ok {
+		return c.org.dataSourceByUID(uid)
+	}
+	if name, ok := c.params["name"]; ok {
+		return c.org.dataSourceByName(name)
+	}
+	return c.org.dataSources[c.id("id")]
+}
+
+func listDataSources(c *call) (int, interface{}) {
+	var sources []*dataSource
+	for _, d := range c.org.dataSources {
+		sources = append(sources, d)
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return strings.ToLower(sources[i].name()) < strings.ToLower(sources[j].name())
+	})
+	result := []map[string]interface{}{}
+	for _, d := range sources {
+		result = append(result, dataSourceJSON(c.org, d, false))
+	}
+	return http.StatusOK, result
+}
+
+func getDataSource(c *call) (int, interface{}) {
+	d := pathDataSource(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Data source not found")
+	}
+	return http.StatusOK, dataSourceJSON(c.org, d, true)
+}
+
+func checkDataSourceHealth(c *call) (int, interface{}) {
+	d := pathDataSource(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Data source not found")
+	}
+	if d.health == nil {
+		return http.StatusOK, gapi.DataSourceHealth{Status: gapi.HealthStatusOK, Message: "Data source is working"}
+	}
+	if !d.health.OK() {
+		return http.StatusBadRequest, d.health
+	}
+	return http.StatusOK, d.health
+}
+
+func dataSourceIDByName(c *call) (int, interface{}) {
+	d := c.org.dataSourceByName(c.params["name"])
+	if d == nil {
+		return fail(http.StatusNotFound, "Data source not found")
+	}
+	return http.StatusOK, map[string]interface{}{"id": d.id}
+}
+
+// decodeDataSource decodes and checks the datasource of the body. Its UID
+// and secure fields are returned apart.
+func decodeDataSource(c *call) (fields map[string]interface{}, uid string, secure map[string]interface{}, ok bool) {
+	if err := c.decode(&fields); err != nil || fields == nil {
+		return nil, "", nil, false
+	}
+	if stringField(fields, "name") == "" || stringField(fields, "type") == "" {
+		return nil, "", nil, false
+	}
+	uid = stringField(fields, "uid")
+	secure, _ = fields["secureJsonData"].(map[string]interface{})
+	for _, key := range []string{"id", "uid", "orgId", "secureJsonData", "secureJsonFields", "typeName", "readOnly", "version"} {
+		delete(fields, key)
+	}
+	return fields, uid, secure, true
+}
+
+// setDefault makes the datasource the only default one of the org if it's
+// set as default.
+func (o *org) setDefault(d *dataSource) {
+	if isDefault, _ := d.fields["isDefault"].(bool); !isDefault {
+		return
+	}
+	for _, other := range o.dataSources {
+		if other != d {
+			other.fields["isDefault"] = false
+		}
+	}
+}
+
+func createDataSource(c *call) (int, interface{}) {
+	fields, uid, secure, ok := decodeDataSource(c)
+	if !ok {
+		return http.StatusBadRequest, badRequest
+	}
+	if c.org.dataSourceByName(stringField(fields, "name")) != nil {
+		return fail(http.StatusConflict, "Data source with the same name already exists")
+	}
+	if uid != "" && c.org.dataSourceByUID(uid) != nil {
+		return fail(http.StatusConflict, "Data source with the same uid already exists")
+	}
+
+	d := &dataSource{
+		id:     c.s.nextID("data_source"),
+		uid:    uid,
+		fields: fields,
+		secure: map[string]interface{}{},
+	}
+	if d.uid == "" {
+		d.uid = c.s.newUID()
+	}
+	for key, value := range secure {
+		d.secure[key] = value
+	}
+	c.org.dataSources[d.id] = d
+	c.org.setDefault(d)
+
+	return http.StatusOK, map[string]interface{}{
+		"id":         d.id,
+		"message":    "Datasource added",
+		"name":       d.name(),
+		"datasource": dataSourceJSON(c.org, d, true),
+	}
+}
+
+func updateDataSource(c *call) (int, interface{}) {
+	d := pathDataSource(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Data source not found")
+	}
+	fields, uid, secure, ok := decodeDataSource(c)
+	if !ok {
+		return http.StatusBadRequest, badRequest
+	}
+	if other := c.org.dataSourceByName(stringField(fields, "name")); other != nil && other != d {
+		return fail(http.StatusConflict, "Data source with the same name already exists")
+	}
+	if other := c.org.dataSourceByUID(uid); uid != "" && other != nil && other != d {
+		return fail(http.StatusConflict, "Data source with the same uid already exists")
+	}
+
+	if uid != "" {
+		d.uid = uid
+	}
+	d.fields = fields
+	for key, value := range secure {
+		d.secure[key] = value
+	}
+	c.org.setDefault(d)
+
+	return http.StatusOK, map[string]interface{}{
+		"id":         d.id,
+		"message":    "Datasource updated",
+		"name":       d.name(),
+		"datasource": dataSourceJSON(c.org, d, true),
+	}
+}
+
+func deleteDataSource(c *call) (int, interface{}) {
+	d := pathDataSource(c)
+	if d == nil {
+		return fail(http.StatusNotFound, "Data source not found")
+	}
+	delete(c.org.dataSources, d.id)
+	return http.StatusOK, map[string]interface{}{
+		"id":      d.id,
+		"message": "Data source deleted",
+	}
+}
+
+func listAlertNotifications(c *call) (int, interface{}) {
+	result := []*gapi.AlertNotification{}
+	for _, n := range c.org.alertNotifications {
+		result = append(result, n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return http.StatusOK, result
+}
+
+func pathAlertNotification(c *call) *gapi.AlertNotification {
+	if uid, ok := c.params["uid"]; ok {
+		for _, n := range c.org.alertNotifications {
+			if n.Uid == uid {
+				return n
+			}
+		}
+		return nil
+	}
+	return c.org.alertNotifications[c.id("id")]
+}
+
+func getAlertNotification(c *call) (int, interface{}) {
+	n := pathAlertNotification(c)
+	if n == nil {
+		return fail(http.StatusNotFound, "Alert notification not found")
+	}
+	return http.StatusOK, n
+}
+
+// checkAlertNotification returns the error response for the notification
+// of the body, if it's invalid or conflicts with another one.
+func checkAlertNotification(c *call, n, current *gapi.AlertNotification) (int, interface{}) {
+	if n.Name == "" || n.Type == "" {
+		return http.StatusBadRequest, badRequest
+	}
+	for _, other := range c.org.alertNotifications {
+		if other == current {
+			continue
+		}
+		if other.Name == n.Name {
+			return fail(http.StatusConflict, "Alert notification name taken")
+		}
+		if n.Uid != "" && other.Uid == n.Uid {
+			return fail(http.StatusConflict, "Alert notification uid taken")
+		}
+	}
+	return 0, nil
+}
+
+func createAlertNotification(c *call) (int, interface{}) {
+	n := &gapi.AlertNotification{}
+	if err := c.decode(n); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if code, body := checkAlertNotification(c, n, nil); code != 0 {
+		return code, body
+	}
+	n.Id = c.s.nextID("alert_notification")
+	if n.Uid == "" {
+		n.Uid = c.s.newUID()
+	}
+	c.org.alertNotifications[n.Id] = n
+	return http.StatusOK, n
+}
+
+func updateAlertNotification(c *call) (int, interface{}) {
+	current := pathAlertNotification(c)
+	if current == nil {
+		return fail(http.StatusNotFound, "Alert notification not found")
+	}
+	n := &gapi.AlertNotification{}
+	if err := c.decode(n); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if code, body := checkAlertNotification(c, n, current); code != 0 {
+		return code, body
+	}
+	n.Id = current.Id
+	if n.Uid == "" {
+		n.Uid = current.Uid
+	}
+	*current = *n
+	return http.StatusOK, current
+}
+
+func deleteAlertNotification(c *call) (int, interface{}) {
+	n := pathAlertNotification(c)
+	if n == nil {
+		return fail(http.StatusNotFound, "Alert notification not found")
+	}
+	delete(c.org.alertNotifications, n.Id)
+	return http.StatusOK, message("Notification deleted")
+}
+
+// alert is a legacy alert rule, defined in a dashboard panel.
+type alert struct {
+	id           int64
+	orgID        int64
+	dashboardID  int64
+	panelID      int64
+	name         string
+	state        string
+	newStateDate time.Time
+	settings     map[string]interface{}
+}
+
+// panels returns the panels of the dashboard model, including the ones of
+// collapsed rows and of the rows of old dashboards.
+func panels(model map[string]interface{}) []map[string]interface{} {
+	var result []map[string]interface{}
+	var walk func(list interface{})
+	walk = func(list interface{}) {
+		items, _ := list.([]interface{})
+		for _, item := range items {
+			panel, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			result = append(result, panel)
+			walk(panel["panels"])
+		}
+	}
+	walk(model["panels"])
+	rows, _ := model["rows"].([]interface{})
+	for _, row := range rows {
+		if row, ok := row.(map[string]interface{}); ok {
+			walk(row["panels"])
+		}
+	}
+	return result
+}
+
+// syncAlerts updates the alerts of the org from the panels of the saved
+// dashboard, as Grafana does.
+func (s *Server) syncAlerts(o *org, d *dashboard) {
+	existing := map[int64]*alert{}
+	for _, a := range s.alerts {
+		if a.orgID == o.id && a.dashboardID == d.id {
+			existing[a.panelID] = a
+		}
+	}
+
+	for _, panel := range panels(d.model) {
+		settings, ok := panel["alert"].(map[string]interface{})
+		panelID := int64Field(panel, "id")
+		if !ok || panelID == 0 {
+			continue
+		}
+		a := existing[panelID]
+		if a == nil {
+			a = &alert{
+				id:           s.nextID("alert"),
+				orgID:        o.id,
+				dashboardID:  d.id,
+				panelID:      panelID,
+				state:        "unknown",
+				newStateDate: time.Now(),
+			}
+			s.alerts[a.id] = a
+		}
+		delete(existing, panelID)
+		a.name = stringField(settings, "name")
+		a.settings = clone(settings)
+	}
+
+	for _, a := range existing {
+		delete(s.alerts, a.id)
+	}
+}
+
+func (s *Server) setAlertState(a *alert, state string) {
+	prev := a.state
+	a.state = state
+	a.newStateDate = time.Now()
+
+	now := a.newStateDate.UnixNano() / int64(time.Millisecond)
+	an := &annotation{
+		Annotation: gapi.Annotation{
+			ID:          s.nextID("annotation"),
+			AlertID:     a.id,
+			DashboardID: a.dashboardID,
+			PanelID:     a.panelID,
+			PrevState:   prev,
+			NewState:    state,
+			Time:        now,
+			TimeEnd:     now,
+			Text:        "",
+		},
+		Created: now,
+		Updated: now,
+	}
+	s.orgs[a.orgID].annotations[an.ID] = an
+}
+
+func (s *Server) alertJSON(a *alert) map[string]interface{} {
+	result := map[string]interface{}{
+		"id":             a.id,
+		"dashboardId":    a.dashboardID,
+		"dashboardUid":   "",
+		"dashboardSlug":  "",
+		"panelId":        a.panelID,
+		"name":           a.name,
+		"state":          a.state,
+		"newStateDate":   formatTime(a.newStateDate),
+		"evalDate":       "0001-01-01T00:00:00Z",
+		"evalData":       nil,
+		"executionError": "",
+		"url":            "",
+	}
+	if d := s.orgs[a.orgID].dashboards[a.dashboardID]; d != nil {
+		result["dashboardUid"] = d.uid
+		result["dashboardSlug"] = d.slug()
+		result["url"] = d.url()
+	}
+	return result
+}
+
+func listAlerts(c *call) (int, interface{}) {
+	q := c.r.URL.Query()
+	dashboardIDs := map[string]bool{}
+	for _, id := range q["dashboardId"] {
+		dashboardIDs[id] = true
+	}
+	folderIDs := map[int64]bool{}
+	for _, id := range q["folderId"] {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		folderIDs[n] = true
+	}
+	states := map[string]bool{}
+	for _, state := range q["state"] {
+		if !strings.EqualFold(state, "all") {
+			states[strings.ToLower(state)] = true
+		}
+	}
+	panelID := int64(c.queryInt("panelId", 0))
+	query := strings.ToLower(q.Get("query"))
+
+	var alerts []*alert
+	for _, a := range c.s.alerts {
+		d := c.org.dashboards[a.dashboardID]
+		switch {
+		case a.orgID != c.org.id || d == nil,
+			len(dashboardIDs) > 0 && !dashboardIDs[strconv.FormatInt(a.dashboardID, 10)],
+			len(folderIDs) > 0 && !folderIDs[d.folderID],
+			len(states) > 0 && !states[a.state],
+			panelID != 0 && a.panelID != panelID,
+			!strings.Contains(strings.ToLower(a.name), query):
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].name != alerts[j].name {
+			return alerts[i].name < alerts[j].name
+		}
+		return alerts[i].id < alerts[j].id
+	})
+
+	limit := c.queryInt("limit", 0)
+	result := []map[string]interface{}{}
+	for _, a := range alerts {
+		if limit > 0 && len(result) == limit {
+			break
+		}
+		result = append(result, c.s.alertJSON(a))
+	}
+	return http.StatusOK, result
+}
+
+func pathAlert(c *call) *alert {
+	a := c.s.alerts[c.id("id")]
+	if a == nil || a.orgID != c.org.id {
+		return nil
+	}
+	return a
+}
+
+func getAlert(c *call) (int, interface{}) {
+	a := pathAlert(c)
+	if a == nil {
+		return fail(http.StatusNotFound, "Alert not found")
+	}
+	result := c.s.alertJSON(a)
+	result["settings"] = clone(a.settings)
+	return http.StatusOK, result
+}
+
+func pauseAlert(c *call) (int, interface{}) {
+	a := pathAlert(c)
+	if a == nil {
+		return fail(http.StatusNotFound, "Alert not found")
+	}
+	body := gapi.PauseAlertRequest{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	pausedState := "un-paused"
+	a.state = "unknown"
+	if body.Paused {
+		pausedState = "paused"
+		a.state = "paused"
+	}
+	a.newStateDate = time.Now()
+	return http.StatusOK, map[string]interface{}{
+		"alertId": a.id,
+		"state":   a.state,
+		"message": "Alert " + pausedState,
+	}
+}
+
+func pauseAllAlerts(c *call) (int, interface{}) {
+	body := gapi.PauseAlertRequest{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	state, pausedState := "unknown", "unpaused"
+	if body.Paused {
+		state, pausedState = "paused", "paused"
+	}
+	for _, a := range c.s.alerts {
+		a.state = state
+		a.newStateDate = time.Now()
+	}
+	return http.StatusOK, map[string]interface{}{
+		"alertsAffected": len(c.s.alerts),
+		"state":          state,
+		"message":        "alerts " + pausedState,
+	}
+}
+
+// annotation is an annotation as listed by the API.
+type annotation struct {
+	gapi.Annotation
+	DashboardUID string `json:"dashboardUID,omitempty"`
+	Login        string `json:"login"`
+	Email        string `json:"email"`
+	AvatarURL    string `json:"avatarUrl"`
+	Created      int64  `json:"created"`
+	Updated      int64  `json:"updated"`
+}
+
+func nowMillis() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+func listAnnotations(c *call) (int, interface{}) {
+	q := c.r.URL.Query()
+	from := int64(c.queryInt("from", 0))
+	to := int64(c.queryInt("to", 0))
+	dashboardID := int64(c.queryInt("dashboardId", 0))
+	if uid := q.Get("dashboardUID"); uid != "" {
+		d := c.org.find(false, 0, uid)
+		if d == nil {
+			return http.StatusOK, []interface{}{}
+		}
+		dashboardID = d.id
+	}
+	panelID := int64(c.queryInt("panelId", 0))
+	alertID := int64(c.queryInt("alertId", 0))
+	userID := int64(c.queryInt("userId", 0))
+	typ := q.Get("type")
+	tags := q["tags"]
+	matchAny := q.Get("matchAny") == "true"
+
+	var found []*annotation
+	for _, a := range c.org.annotations {
+		end := a.TimeEnd
+		if end == 0 {
+			end = a.Time
+		}
+		switch {
+		case from != 0 && end < from,
+			to != 0 && a.Time > to,
+			dashboardID != 0 && a.DashboardID != dashboardID,
+			panelID != 0 && a.PanelID != panelID,
+			alertID != 0 && a.AlertID != alertID,
+			userID != 0 && a.UserID != userID,
+			typ == "alert" && a.AlertID == 0,
+			typ == "annotation" && a.AlertID != 0,
+			len(tags) > 0 && !matchAny && !hasTags(a.Tags, tags),
+			len(tags) > 0 && matchAny && !hasAnyTag(a.Tags, tags):
+			continue
+		}
+		found = append(found, a)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Time != found[j].Time {
+			return found[i].Time > found[j].Time
+		}
+		return found[i].ID > found[j].ID
+	})
+
+	limit := c.queryInt("limit", 100)
+	result := []annotation{}
+	for _, a := range found {
+		if len(result) == limit {
+			break
+		}
+		listed := *a
+		if d := c.org.dashboards[a.DashboardID]; d != nil {
+			listed.DashboardUID = d.uid
+		}
+		result = append(result, listed)
+	}
+	return http.StatusOK, result
+}
+
+func hasAnyTag(tags, wanted []string) bool {
+	for _, w := range wanted {
+		if hasTags(tags, []string{w}) {
+			return true
+		}
+	}
+	return false
+}
+
+// newAnnotation returns an annotation made by the caller.
+func (c *call) newAnnotation() *annotation {
+	now := nowMillis()
+	a := &annotation{
+		Annotation: gapi.Annotation{ID: c.s.nextID("annotation")},
+		Created:    now,
+		Updated:    now,
+	}
+	if c.user != nil {
+		a.UserID = c.user.id
+		a.Login = c.user.login
+		a.Email = c.user.email
+		a.AvatarURL = avatarURL(c.user.email)
+	}
+	return a
+}
+
+func createAnnotation(c *call) (int, interface{}) {
+	body := struct {
+		gapi.Annotation
+		DashboardUID string `json:"dashboardUID"`
+	}{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if body.Text == "" {
+		return fail(http.StatusBadRequest, "Failed to save annotation")
+	}
+	if body.DashboardUID != "" {
+		d := c.org.find(false, 0, body.DashboardUID)
+		if d == nil {
+			return fail(http.StatusBadRequest, "Dashboard not found")
+		}
+		body.DashboardID = d.id
+	}
+
+	a := c.newAnnotation()
+	a.DashboardID = body.DashboardID
+	a.PanelID = body.PanelID
+	a.Text = body.Text
+	a.Tags = body.Tags
+	a.Time = body.Time
+	if a.Time == 0 {
+		a.Time = a.Created
+	}
+	a.TimeEnd = body.TimeEnd
+	if a.TimeEnd < a.Time {
+		a.TimeEnd = a.Time
+	}
+	if a.TimeEnd > a.Time {
+		a.RegionID = a.ID
+	}
+	c.org.annotations[a.ID] = a
+	return http.StatusOK, map[string]interface{}{
+		"message": "Annotation added",
+		"id":      a.ID,
+	}
+}
+
+func createGraphiteAnnotation(c *call) (int, interface{}) {
+	body := gapi.GraphiteAnnotation{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	if body.What == "" {
+		return fail(http.StatusBadRequest, "what field should not be empty")
+	}
+
+	a := c.newAnnotation()
+	a.Text = body.What
+	if body.Data != "" {
+		a.Text += "\n" + body.Data
+	}
+	a.Tags = body.Tags
+	a.Time = body.When * 1000
+	if a.Time == 0 {
+		a.Time = a.Created
+	}
+	a.TimeEnd = a.Time
+	c.org.annotations[a.ID] = a
+	return http.StatusOK, map[string]interface{}{
+		"message": "Graphite annotation added",
+		"id":      a.ID,
+	}
+}
+
+// updateAnnotation replaces the text and tags of the annotation on PUT,
+// and only the fields that are set on PATCH. Times are changed when set.
+func updateAnnotation(c *call) (int, interface{}) {
+	a := c.org.annotations[c.id("id")]
+	if a == nil {
+		return fail(http.StatusNotFound, "Annotation not found")
+	}
+	body := gapi.Annotation{}
+	if err := c.decode(&body); err != nil {
+		return http.StatusBadRequest, badRequest
+	}
+	patch := c.r.Method == "PATCH"
+
+	if body.Text != "" || !patch {
+		a.Text = body.Text
+	}
+	if body.Tags != nil || !patch {
+		a.Tags = body.Tags
+	}
+	if body.Time != 0 {
+		a.Time = body.Time
+	}
+	if body.TimeEnd != 0 {
+		a.TimeEnd = body.TimeEnd
+	}
+	if a.TimeEnd < a.Time {
+		a.TimeEnd = a.Time
+	}
+	a.Updated = nowMillis()
+
+	if patch {
+		return http.StatusOK, message("Annotation patched")
+	}
+	return http.StatusOK, message("Annotation updated")
+}
+
+func deleteAnnotation(c *call) (int, interface{}) {
+	a := c.org.annotations[c.id("id")]
+	if a == nil {
+		return fail(http.StatusNotFound, "Annotation not found")
+	}
+	delete(c.org.annotations, a.ID)
+	return http.StatusOK, message("Annotation deleted")
+}
+
+func deleteAnnotationRegion(c *call) (int, interface{}) {
+	regionID := c.id("id")
+	for id, a := range c.org.annotations {
+		if a.RegionID == regionID {
+			delete(c.org.annotations, id)
+		}
+	}
+	return http.StatusOK, message("Annotation region deleted")
+}
+
+type playlist struct {
+	ID       int64          `json:"id"`
+	Name     string         `json:"name"`
+	Interval string         `json:"interval"`
+	OrgID    int64          `json:"orgId"`
+	Items    []playlistItem `json:"items"`
+}
+
+type playlistItem struct {
+	ID         int64 `json:"id"`
+	PlaylistID int64 `json:"playlistId"`
+	gapi.PlaylistItem
+}
+
+func listPlaylists(c *call) (int, interface{}) {
+	query := strings.ToLower(c.query("query"))
+	var playlists []*playlist
+	for _, p := range c.org.playlists {
+		if strings.Contains(strings.ToLower(p.Name), query) {
+			playlists = append(playlists, p)
+		}
+	}
+	sort.Slice(playlists, func(i, j int) bool { return playlists[i].ID < playlists[j].ID })
+
+	limit := c.queryInt("limit", 1000)
+	result := []map[string]interface{}{}
+	for i := 0; i < len(playlists) && i < limit; i++ {
+		p := playlists[i]
+		result = append(result, map[string]interface{}{
+			"id":       p.ID,
+			"name":     p.Name,
+			"interval": p.Interval,
+		})
+	}
+	return http.StatusOK, result
+}
+
+func getPlaylist(c *call) (int, interface{}) {
+	p := c.org.playlists[c.id("id")]
+	if p == nil {
+		return fail(http.StatusNotFound, "Playlist not found")
+	}
+	return http.StatusOK, p
+}
+
+func getPlaylistItems(c *call) (int, interface{}) {
+	p := c.org.playlists[c.id("id")]
+	if p == nil {
+		return fail(http.StatusNotFound, "Playlist not found")
+	}
+	return http.StatusOK, p.Items
+}
+
+// setPlaylist sets the playlist from the body of the call.
+func setPlaylist(c *call, p *playlist) (int, interface{}) {
+	body := gapi.Playlist{}
+	if err := c.decode(&body); err != nil || body.Name == "" {
+		return http.StatusBadRequest, badRequest
+	}
+	p.Name = body.Name
+	p.Interval = body.Interval
+	p.Items = []playlistItem{}
+	for i, item := range body.Items {
+		if item.Order == 0 {
+			item.Order = i + 1
+		}
+		p.Items = append(p.Items, playlistItem{
+			ID:           c.s.nextID("playlist_item"),
+			PlaylistID:   p.ID,
+			PlaylistItem: item,
+		})
+	}
+	return 0, nil
+}
+
+func createPlaylist(c *call) (int, interface{}) {
+	p := &playlist{ID: c.s.nextID("playlist"), OrgID: c.org.id}
+	if code, body := setPlaylist(c, p); code != 0 {
+		return code, body
+	}
+	c.org.playlists[p.ID] = p
+	return http.StatusOK, p
+}
+
+func updatePlaylist(c *call) (int, interface{}) {
+	p := c.org.playlists[c.id("id")]
+	if p == nil {
+		return fail(http.StatusNotFound, "Playlist not found")
+	}
+	updated := *p
+	if code, body := setPlaylist(c, &updated); code != 0 {
+		return code, body
+	}
+	*p = updated
+	return http.StatusOK, p
+}
+
+func deletePlaylist(c *call) (int, interface{}) {
+	p := c.org.playlists[c.id("id")]
+	if p == nil {
+		return fail(http.StatusNotFound, "Playlist not found")
+	}
+	delete(c.org.playlists, p.ID)
+	return http.StatusOK, map[string]interface{}{}
+}
@@ -0,0 +1,543 @@
+// Package grafanatest provides an in-memory fake Grafana for testing code
+// that uses the gapi client, in the manner of net/http/httptest.
+//
+// The fake keeps state across requests: dashboards are versioned, IDs are
+// allocated in sequence per kind of object, UIDs are generated as Grafana
+// does, and errors have the status codes and messages of the real API.
+// Requests are authenticated with basic auth or API keys, and checked
+// against the org role of the caller:
+//
+//	srv := grafanatest.NewServer()
+//	defer srv.Close()
+//
+//	client := srv.Client()
+//	folder, err := client.NewFolder("Team A")
+//	...
+//
+// It starts with a server admin, admin:admin, who is an Admin of the Main
+// Org. with ID 1. Users created through the API join it as Viewers.
+//
+// Roles are enforced per org, but dashboard and folder permissions are
+// only stored and returned, not enforced. Alerts are the legacy dashboard
+// alerts: they're created from the `alert` of the panels of saved
+// dashboards and never evaluated, so tests set their state with
+// SetAlertState. Likewise, datasources are never queried, and their health
+// checks pass until tests set their result with SetDataSourceHealth.
+package grafanatest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// Credentials of the server admin the fake starts with.
+const (
+	AdminLogin    = "admin"
+	AdminPassword = "admin"
+)
+
+// Org roles.
+const (
+	RoleViewer = "Viewer"
+	RoleEditor = "Editor"
+	RoleAdmin  = "Admin"
+)
+
+// Server is a fake Grafana listening on a local address.
+type Server struct {
+	// URL is the base URL of the server, of the form http://ipaddr:port.
+	URL string
+
+	srv    *httptest.Server
+	routes []route
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	ids     map[string]int64
+	users   map[int64]*user
+	orgs    map[int64]*org
+	apiKeys map[string]*apiKey
+	alerts  map[int64]*alert
+}
+
+type user struct {
+	id       int64
+	login    string
+	email    string
+	name     string
+	password string
+	isAdmin  bool
+	// orgID is the org used when requests don't set X-Grafana-Org-Id.
+	orgID   int64
+	created time.Time
+}
+
+type apiKey struct {
+	name  string
+	orgID int64
+	role  string
+}
+
+// NewServer starts and returns a new fake Grafana. The caller should call
+// Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		rand:    rand.New(rand.NewSource(1)),
+		ids:     map[string]int64{},
+		users:   map[int64]*user{},
+		orgs:    map[int64]*org{},
+		apiKeys: map[string]*apiKey{},
+		alerts:  map[int64]*alert{},
+	}
+	s.routes = s.allRoutes()
+
+	o := s.newOrg("Main Org.")
+	admin := &user{
+		id:       s.nextID("user"),
+		login:    AdminLogin,
+		email:    "admin@localhost",
+		password: AdminPassword,
+		isAdmin:  true,
+		orgID:    o.id,
+		created:  time.Now(),
+	}
+	s.users[admin.id] = admin
+	o.members[admin.id] = RoleAdmin
+
+	s.srv = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server and blocks until all outstanding requests
+// on it have completed.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a client of the server authenticated as the server admin.
+// opts are applied after the ones setting the credentials.
+func (s *Server) Client(opts ...gapi.Option) *gapi.Client {
+	opts = append([]gapi.Option{gapi.WithBasicAuth(AdminLogin, AdminPassword)}, opts...)
+	client, err := gapi.NewWithOptions(s.URL, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+// APIKey creates and returns an API key with the role in the org whose ID
+// it's passed.
+func (s *Server) APIKey(orgID int64, role string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := "eyJrIjoi" + s.randomString(32)
+	s.apiKeys[key] = &apiKey{
+		name:  fmt.Sprintf("key-%d", s.nextID("api_key")),
+		orgID: orgID,
+		role:  role,
+	}
+	return key
+}
+
+// SetAlertState moves the alert whose ID it's passed to the state, such
+// as "alerting" or "ok", and records the change as an alert annotation.
+func (s *Server) SetAlertState(id int64, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.alerts[id]
+	if !ok {
+		return errors.New("grafanatest: alert not found")
+	}
+	s.setAlertState(a, state)
+	return nil
+}
+
+// SetDataSourceHealth sets the result of the health checks of the
+// datasource whose UID it's passed, which are OK until then.
+func (s *Server) SetDataSourceHealth(uid string, health gapi.DataSourceHealth) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, o := range s.orgs {
+		if d := o.dataSourceByUID(uid); d != nil {
+			d.health = &health
+			return nil
+		}
+	}
+	return errors.New("grafanatest: datasource not found")
+}
+
+// nextID returns the next ID of the kind of object. Like the tables of a
+// Grafana database, each kind has its own sequence.
+func (s *Server) nextID(kind string) int64 {
+	s.ids[kind]++
+	return s.ids[kind]
+}
+
+const uidChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func (s *Server) randomString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = uidChars[s.rand.Intn(len(uidChars))]
+	}
+	return string(b)
+}
+
+// newUID returns a UID of the length of the ones Grafana generates.
+func (s *Server) newUID() string {
+	return s.randomString(9)
+}
+
+// Access levels of routes.
+const (
+	accessViewer = iota
+	accessEditor
+	accessAdmin
+	// accessServerAdmin is for users that are Grafana server admins,
+	// which API keys never are.
+	accessServerAdmin
+)
+
+var roleAccess = map[string]int{
+	RoleViewer: accessViewer,
+	RoleEditor: accessEditor,
+	RoleAdmin:  accessAdmin,
+}
+
+type route struct {
+	method  string
+	pattern []string
+	access  int
+	handle  func(c *call) (int, interface{})
+}
+
+// call is a request being handled.
+type call struct {
+	s      *Server
+	r      *http.Request
+	params map[string]string
+	org    *org
+	role   string
+	// user is nil for requests made with API keys.
+	user  *user
+	login string
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	code, body := s.handle(r)
+	if text, ok := body.(string); ok {
+		// Like the diffs of dashboard versions.
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.WriteHeader(code)
+		fmt.Fprint(w, text)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		panic(err)
+	}
+}
+
+func (s *Server) handle(r *http.Request) (int, interface{}) {
+	rt, params := s.match(r)
+	if rt == nil {
+		return fail(http.StatusNotFound, "Not found")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := &call{s: s, r: r, params: params}
+	if code, body := s.authenticate(c); code != 0 {
+		return code, body
+	}
+	if rt.access == accessServerAdmin {
+		if c.user == nil || !c.user.isAdmin {
+			return fail(http.StatusForbidden, "Permission denied")
+		}
+	} else if roleAccess[c.role] < rt.access {
+		return fail(http.StatusForbidden, "Permission denied")
+	}
+	return rt.handle(c)
+}
+
+func (s *Server) match(r *http.Request) (*route, map[string]string) {
+	// Parameters may have escaped slashes.
+	segments := strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = unescaped
+		}
+	}
+	for i, rt := range s.routes {
+		if rt.method != r.Method || len(rt.pattern) != len(segments) {
+			continue
+		}
+		params := map[string]string{}
+		matched := true
+		for j, p := range rt.pattern {
+			if strings.HasPrefix(p, ":") {
+				params[p[1:]] = segments[j]
+			} else if p != segments[j] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return &s.routes[i], params
+		}
+	}
+	return nil, nil
+}
+
+// authenticate sets the user, org and role of the call, or returns the
+// error response.
+func (s *Server) authenticate(c *call) (int, interface{}) {
+	header := c.r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		key, ok := s.apiKeys[strings.TrimPrefix(header, "Bearer ")]
+		if !ok || s.orgs[key.orgID] == nil {
+			return fail(http.StatusUnauthorized, "Invalid API key")
+		}
+		c.org, c.role, c.login = s.orgs[key.orgID], key.role, key.name
+		return 0, nil
+	}
+
+	login, password, ok := c.r.BasicAuth()
+	if !ok {
+		return fail(http.StatusUnauthorized, "Unauthorized")
+	}
+	u := s.userByLoginOrEmail(login)
+	if u == nil || u.password != password {
+		return fail(http.StatusUnauthorized, "Invalid username or password")
+	}
+	c.user, c.login = u, u.login
+
+	orgID := u.orgID
+	if v := c.r.Header.Get("X-Grafana-Org-Id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fail(http.StatusBadRequest, "Invalid X-Grafana-Org-Id header")
+		}
+		orgID = id
+	}
+	o := s.orgs[orgID]
+	if o == nil || o.members[u.id] == "" {
+		return fail(http.StatusUnauthorized, "User not a member of the organization")
+	}
+	c.org, c.role = o, o.members[u.id]
+	return 0, nil
+}
+
+func (s *Server) userByLoginOrEmail(loginOrEmail string) *user {
+	for _, u := range s.users {
+		if u.login == loginOrEmail || (u.email != "" && u.email == loginOrEmail) {
+			return u
+		}
+	}
+	return nil
+}
+
+// decode decodes the JSON body of the call into v.
+func (c *call) decode(v interface{}) error {
+	return json.NewDecoder(c.r.Body).Decode(v)
+}
+
+// id returns the integer path parameter, or 0 if it isn't one.
+func (c *call) id(name string) int64 {
+	id, _ := strconv.ParseInt(c.params[name], 10, 64)
+	return id
+}
+
+func (c *call) query(name string) string {
+	return c.r.URL.Query().Get(name)
+}
+
+// queryInt returns the integer query parameter, or def if it isn't set.
+func (c *call) queryInt(name string, def int) int {
+	n, err := strconv.Atoi(c.query(name))
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func message(text string) map[string]interface{} {
+	return map[string]interface{}{"message": text}
+}
+
+func fail(code int, text string) (int, interface{}) {
+	return code, message(text)
+}
+
+var badRequest = message("bad request data")
+
+// page returns the bounds of the page of n items set by the perpage and
+// page query parameters.
+func (c *call) page(perPageParam string, defaultPerPage, n int) (int, int) {
+	perPage := c.queryInt(perPageParam, defaultPerPage)
+	if perPage <= 0 {
+		perPage = defaultPerPage
+	}
+	page := c.queryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	start := (page - 1) * perPage
+	if start > n {
+		start = n
+	}
+	end := start + perPage
+	if end > n {
+		end = n
+	}
+	return start, end
+}
+
+// clone returns a deep copy of the JSON value.
+func clone(v map[string]interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (s *Server) allRoutes() []route {
+	var routes []route
+	add := func(method, pattern string, access int, handle func(c *call) (int, interface{})) {
+		routes = append(routes, route{
+			method:  method,
+			pattern: strings.Split(strings.Trim(pattern, "/"), "/"),
+			access:  access,
+			handle:  handle,
+		})
+	}
+
+	add("GET", "/api/search", accessViewer, searchDashboards)
+
+	add("POST", "/api/dashboards/db", accessEditor, saveDashboard)
+	add("POST", "/api/dashboards/import", accessEditor, importDashboard)
+	add("POST", "/api/dashboards/calculate-diff", accessEditor, diffDashboards)
+	add("GET", "/api/dashboards/uid/:uid", accessViewer, getDashboard)
+	add("DELETE", "/api/dashboards/uid/:uid", accessEditor, deleteDashboard)
+	add("GET", "/api/dashboards/db/:slug", accessViewer, getDashboard)
+	add("DELETE", "/api/dashboards/db/:slug", accessEditor, deleteDashboard)
+	add("GET", "/api/dashboards/uid/:uid/permissions", accessAdmin, getDashboardPermissions)
+	add("POST", "/api/dashboards/uid/:uid/permissions", accessAdmin, updateDashboardPermissions)
+	add("GET", "/api/dashboards/id/:id/permissions", accessAdmin, getDashboardPermissions)
+	add("POST", "/api/dashboards/id/:id/permissions", accessAdmin, updateDashboardPermissions)
+	add("GET", "/api/dashboards/id/:id/versions", accessEditor, dashboardVersions)
+	add("GET", "/api/dashboards/id/:id/versions/:version", accessEditor, dashboardVersion)
+	add("POST", "/api/dashboards/id/:id/restore", accessEditor, restoreDashboardVersion)
+
+	add("GET", "/api/folders", accessViewer, listFolders)
+	add("POST", "/api/folders", accessEditor, createFolder)
+	add("GET", "/api/folders/id/:id", accessViewer, getFolder)
+	add("GET", "/api/folders/:uid", accessViewer, getFolder)
+	add("PUT", "/api/folders/:uid", accessEditor, updateFolder)
+	add("DELETE", "/api/folders/:uid", accessEditor, deleteFolder)
+	add("GET", "/api/folders/:uid/permissions", accessAdmin, getFolderPermissions)
+	add("POST", "/api/folders/:uid/permissions", accessAdmin, updateFolderPermissions)
+
+	add("GET", "/api/datasources", accessAdmin, listDataSources)
+	add("POST", "/api/datasources", accessAdmin, createDataSource)
+	add("GET", "/api/datasources/id/:name", accessViewer, dataSourceIDByName)
+	add("GET", "/api/datasources/uid/:uid", accessAdmin, getDataSource)
+	add("PUT", "/api/datasources/uid/:uid", accessAdmin, updateDataSource)
+	add("DELETE", "/api/datasources/uid/:uid", accessAdmin, deleteDataSource)
+	add("GET", "/api/datasources/uid/:uid/health", accessViewer, checkDataSourceHealth)
+	add("GET", "/api/datasources/name/:name", accessAdmin, getDataSource)
+	add("DELETE", "/api/datasources/name/:name", accessAdmin, deleteDataSource)
+	add("GET", "/api/datasources/:id", accessAdmin, getDataSource)
+	add("PUT", "/api/datasources/:id", accessAdmin, updateDataSource)
+	add("DELETE", "/api/datasources/:id", accessAdmin, deleteDataSource)
+
+	add("GET", "/api/alert-notifications", accessEditor, listAlertNotifications)
+	add("POST", "/api/alert-notifications", accessEditor, createAlertNotification)
+	add("GET", "/api/alert-notifications/uid/:uid", accessEditor, getAlertNotification)
+	add("GET", "/api/alert-notifications/:id", accessEditor, getAlertNotification)
+	add("PUT", "/api/alert-notifications/:id", accessEditor, updateAlertNotification)
+	add("DELETE", "/api/alert-notifications/:id", accessEditor, deleteAlertNotification)
+
+	add("GET", "/api/alerts", accessViewer, listAlerts)
+	add("GET", "/api/alerts/:id", accessViewer, getAlert)
+	add("POST", "/api/alerts/:id/pause", accessEditor, pauseAlert)
+	add("POST", "/api/admin/pause-all-alerts", accessServerAdmin, pauseAllAlerts)
+
+	add("GET", "/api/annotations", accessViewer, listAnnotations)
+	add("POST", "/api/annotations", accessEditor, createAnnotation)
+	add("POST", "/api/annotations/graphite", accessEditor, createGraphiteAnnotation)
+	add("PUT", "/api/annotations/:id", accessEditor, updateAnnotation)
+	add("PATCH", "/api/annotations/:id", accessEditor, updateAnnotation)
+	add("DELETE", "/api/annotations/:id", accessEditor, deleteAnnotation)
+	add("DELETE", "/api/annotations/region/:id", accessEditor, deleteAnnotationRegion)
+
+	add("GET", "/api/playlists", accessViewer, listPlaylists)
+	add("POST", "/api/playlists", accessEditor, createPlaylist)
+	add("GET", "/api/playlists/:id", accessViewer, getPlaylist)
+	add("GET", "/api/playlists/:id/items", accessViewer, getPlaylistItems)
+	add("PUT", "/api/playlists/:id", accessEditor, updatePlaylist)
+	add("DELETE", "/api/playlists/:id", accessEditor, deletePlaylist)
+
+	add("GET", "/api/teams/search", accessAdmin, searchTeams)
+	add("POST", "/api/teams", accessAdmin, createTeam)
+	add("GET", "/api/teams/:id", accessAdmin, getTeam)
+	add("PUT", "/api/teams/:id", accessAdmin, updateTeam)
+	add("DELETE", "/api/teams/:id", accessAdmin, deleteTeam)
+	add("GET", "/api/teams/:id/members", accessAdmin, teamMembers)
+	add("POST", "/api/teams/:id/members", accessAdmin, addTeamMember)
+	add("DELETE", "/api/teams/:id/members/:userId", accessAdmin, removeTeamMember)
+	add("GET", "/api/teams/:id/preferences", accessAdmin, getTeamPreferences)
+	add("PUT", "/api/teams/:id/preferences", accessAdmin, updateTeamPreferences)
+
+	add("GET", "/api/org/users", accessAdmin, currentOrgUsers)
+	add("GET", "/api/org/preferences", accessAdmin, getOrgPreferences)
+	add("PUT", "/api/org/preferences", accessAdmin, updateOrgPreferences)
+
+	add("GET", "/api/orgs", accessServerAdmin, listOrgs)
+	add("POST", "/api/orgs", accessServerAdmin, createOrg)
+	add("GET", "/api/orgs/name/:name", accessServerAdmin, getOrg)
+	add("GET", "/api/orgs/:id", accessServerAdmin, getOrg)
+	add("PUT", "/api/orgs/:id", accessServerAdmin, updateOrg)
+	add("DELETE", "/api/orgs/:id", accessServerAdmin, deleteOrg)
+	add("GET", "/api/orgs/:id/users", accessServerAdmin, orgUsers)
+	add("POST", "/api/orgs/:id/users", accessServerAdmin, addOrgUser)
+	add("PATCH", "/api/orgs/:id/users/:userId", accessServerAdmin, updateOrgUser)
+	add("DELETE", "/api/orgs/:id/users/:userId", accessServerAdmin, removeOrgUser)
+
+	add("GET", "/api/users", accessServerAdmin, listUsers)
+	add("GET", "/api/users/search", accessServerAdmin, searchUsers)
+	add("GET", "/api/users/lookup", accessServerAdmin, lookupUser)
+	add("POST", "/api/admin/users", accessServerAdmin, createUser)
+	add("DELETE", "/api/admin/users/:id", accessServerAdmin, deleteUser)
+
+	return routes
+}
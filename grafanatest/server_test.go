@@ -0,0 +1,582 @@
+package grafanatest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+func newServer(t *testing.T) (*Server, *gapi.Client) {
+	t.Helper()
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	return srv, srv.Client()
+}
+
+func TestAuth(t *testing.T) {
+	srv, _ := newServer(t)
+
+	anonymous, err := gapi.NewWithOptions(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := anonymous.Folders(); !gapi.IsUnauthorized(err) {
+		t.Errorf("anonymous: got %v, want unauthorized", err)
+	}
+
+	wrong, err := gapi.New("admin:wrong", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Folders(); !gapi.IsUnauthorized(err) {
+		t.Errorf("wrong password: got %v, want unauthorized", err)
+	}
+
+	viewer, err := gapi.New(srv.APIKey(1, RoleViewer), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := viewer.Folders(); err != nil {
+		t.Errorf("viewer reading folders: %v", err)
+	}
+	if _, err := viewer.NewFolder("Ops"); !gapi.IsForbidden(err) {
+		t.Errorf("viewer creating a folder: got %v, want forbidden", err)
+	}
+	if _, err := viewer.Orgs(); !gapi.IsForbidden(err) {
+		t.Errorf("API key listing orgs: got %v, want forbidden", err)
+	}
+
+	if _, err := srv.Client().WithOrgID(42).Folders(); !gapi.IsUnauthorized(err) {
+		t.Errorf("unknown org: got %v, want unauthorized", err)
+	}
+}
+
+func TestUsersAndOrgs(t *testing.T) {
+	srv, client := newServer(t)
+
+	id, err := client.CreateUser(gapi.User{Login: "jane", Email: "jane@example.com", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 2 {
+		t.Errorf("user ID = %d, want 2", id)
+	}
+	if _, err := client.CreateUser(gapi.User{Login: "jane", Password: "secret"}); !gapi.IsPreconditionFailed(err) {
+		t.Errorf("duplicate user: got %v, want precondition failed", err)
+	}
+	user, err := client.UserByEmail("jane@example.com")
+	if err != nil || user.Login != "jane" {
+		t.Fatalf("UserByEmail = %+v, %v", user, err)
+	}
+
+	orgID, err := client.NewOrg("Team B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.NewOrg("team b"); !gapi.IsConflict(err) {
+		t.Errorf("duplicate org: got %v, want conflict", err)
+	}
+	if err := client.AddOrgUser(orgID, "jane", RoleEditor); err != nil {
+		t.Fatal(err)
+	}
+	users, err := client.OrgUsers(orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roles := map[string]string{}
+	for _, u := range users {
+		roles[u.Login] = u.Role
+	}
+	if roles["admin"] != RoleAdmin || roles["jane"] != RoleEditor || len(roles) != 2 {
+		t.Errorf("org users = %v", roles)
+	}
+
+	// Jane can now work in both of her orgs.
+	jane, err := gapi.NewWithOptions(srv.URL, gapi.WithBasicAuth("jane", "secret"), gapi.WithOrgID(orgID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jane.NewFolder("Jane's"); err != nil {
+		t.Errorf("editor creating a folder: %v", err)
+	}
+	if _, err := jane.WithOrgID(1).NewFolder("Jane's"); !gapi.IsForbidden(err) {
+		t.Errorf("viewer creating a folder: got %v, want forbidden", err)
+	}
+
+	if err := client.RemoveOrgUser(orgID, 1); err == nil {
+		t.Error("removing the last admin of an org succeeded")
+	}
+	if err := client.DeleteUser(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jane.Folders(); !gapi.IsUnauthorized(err) {
+		t.Errorf("deleted user: got %v, want unauthorized", err)
+	}
+}
+
+func TestDashboards(t *testing.T) {
+	_, client := newServer(t)
+
+	folder, err := client.NewFolder("Ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(folder.Uid) != 9 {
+		t.Errorf("folder UID = %q, want 9 characters", folder.Uid)
+	}
+
+	saved, err := client.NewDashboard(gapi.Dashboard{
+		Model:  map[string]interface{}{"title": "Node Exporter", "tags": []string{"prod"}},
+		Folder: folder.Id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.Version != 1 || saved.Slug != "node-exporter" || saved.Id == folder.Id {
+		t.Errorf("saved = %+v", saved)
+	}
+
+	dashboard, err := client.DashboardByUid(saved.Uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dashboard.Meta.Folder != folder.Id || dashboard.Model["version"].(float64) != 1 {
+		t.Errorf("dashboard = %+v", dashboard)
+	}
+
+	// Saving an outdated version fails unless overwriting.
+	dashboard.Model["title"] = "Nodes"
+	if _, err := client.NewDashboard(gapi.Dashboard{Model: dashboard.Model, Folder: folder.Id}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.NewDashboard(gapi.Dashboard{Model: dashboard.Model, Folder: folder.Id})
+	if apiErr, ok := err.(*gapi.APIError); !ok || apiErr.Status != "version-mismatch" {
+		t.Errorf("outdated save: got %v, want version-mismatch", err)
+	}
+	_, err = client.NewDashboard(gapi.Dashboard{
+		Model:  map[string]interface{}{"title": "nodes"},
+		Folder: folder.Id,
+	})
+	if apiErr, ok := err.(*gapi.APIError); !ok || apiErr.Status != "name-exists" {
+		t.Errorf("same name: got %v, want name-exists", err)
+	}
+
+	versions, err := client.DashboardVersions(saved.Id, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].ParentVersion != 0 {
+		t.Fatalf("versions = %+v", versions)
+	}
+	restored, err := client.RestoreDashboardVersion(saved.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version, err := client.DashboardVersion(saved.Id, restored.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version.RestoredFrom != 1 || version.Data["title"] != "Node Exporter" {
+		t.Errorf("restored version = %+v", version)
+	}
+	diff, err := client.DiffDashboardVersions(
+		gapi.DashboardDiffTarget{DashboardId: saved.Id, Version: 1},
+		gapi.DashboardDiffTarget{DashboardId: saved.Id, Version: 2},
+		gapi.DiffTypeJSON,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, `-  "title": "Node Exporter",`) || !strings.Contains(diff, `+  "title": "Nodes",`) {
+		t.Errorf("diff = %s", diff)
+	}
+
+	results, err := client.SearchDashboards(gapi.SearchQuery{Tags: []string{"prod"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].FolderUid != folder.Uid || results[0].Type != gapi.SearchTypeDashboard {
+		t.Errorf("search = %+v", results)
+	}
+	results, err = client.SearchDashboards(gapi.SearchQuery{Type: gapi.SearchTypeFolder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Uid != folder.Uid {
+		t.Errorf("folder search = %+v", results)
+	}
+
+	// Deleting the folder deletes its dashboards.
+	if err := client.DeleteFolder(folder.Uid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DashboardByUid(saved.Uid); !gapi.IsNotFound(err) {
+		t.Errorf("dashboard of deleted folder: got %v, want not found", err)
+	}
+}
+
+func TestPermissions(t *testing.T) {
+	_, client := newServer(t)
+
+	folder, err := client.NewFolderWithUID("Ops", "ops")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.NewFolderWithUID("Ops 2", "ops"); !gapi.IsConflict(err) {
+		t.Errorf("duplicate folder UID: got %v, want conflict", err)
+	}
+	if err := client.AddTeam("SRE", ""); err != nil {
+		t.Fatal(err)
+	}
+	teams, err := client.SearchTeam("SRE")
+	if err != nil || len(teams.Teams) != 1 {
+		t.Fatalf("SearchTeam = %+v, %v", teams, err)
+	}
+	team := teams.Teams[0]
+
+	err = client.UpdateFolderPermissions(folder.Uid, &gapi.PermissionItems{Items: []*gapi.PermissionItem{
+		{TeamId: team.Id, Permission: gapi.PermissionAdmin},
+		{Role: RoleViewer, Permission: gapi.PermissionView},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := client.NewDashboard(gapi.Dashboard{
+		Model:  map[string]interface{}{"title": "Nodes"},
+		Folder: folder.Id,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateDashboardPermissions(saved.Id, &gapi.PermissionItems{Items: []*gapi.PermissionItem{
+		{UserId: 1, Permission: gapi.PermissionEdit},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	permissions, err := client.DashboardPermissionsByUid(saved.Uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range permissions {
+		got = append(got, strings.Join([]string{p.Team, p.Role, p.UserLogin, p.PermissionName}, "/"))
+		if p.Inherited != (p.UserId == 0) {
+			t.Errorf("permission %+v: inherited = %v", p, p.Inherited)
+		}
+	}
+	if want := "SRE///Admin /Viewer//View //admin/Edit"; strings.Join(got, " ") != want {
+		t.Errorf("permissions = %q, want %q", strings.Join(got, " "), want)
+	}
+
+	err = client.UpdateFolderPermissions(folder.Uid, &gapi.PermissionItems{Items: []*gapi.PermissionItem{
+		{Role: RoleViewer, Permission: gapi.PermissionView},
+		{Role: RoleViewer, Permission: gapi.PermissionEdit},
+	}})
+	if err == nil {
+		t.Error("duplicate permissions were accepted")
+	}
+}
+
+func TestDataSources(t *testing.T) {
+	srv, client := newServer(t)
+
+	id, err := client.NewDataSource(&gapi.DataSource{
+		Name:      "Prometheus",
+		Type:      "prometheus",
+		URL:       "http://prometheus:9090",
+		Access:    "proxy",
+		IsDefault: true,
+		BasicAuth: true,
+		SecureJSONData: gapi.SecureJSONData{
+			BasicAuthPassword: "secret",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.NewDataSource(&gapi.DataSource{Name: "Prometheus", Type: "prometheus"}); !gapi.IsConflict(err) {
+		t.Errorf("duplicate datasource: got %v, want conflict", err)
+	}
+
+	ds, err := client.DataSource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.SecureJSONData.BasicAuthPassword != "" || !ds.SecureJSONFields["basicAuthPassword"] {
+		t.Errorf("secure fields: %+v, %v", ds.SecureJSONData, ds.SecureJSONFields)
+	}
+
+	// Updates keep the secure fields that aren't sent.
+	ds.URL = "http://prometheus:9091"
+	if err := client.UpdateDataSource(ds); err != nil {
+		t.Fatal(err)
+	}
+	ds, err = client.DataSource(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.URL != "http://prometheus:9091" || !ds.SecureJSONFields["basicAuthPassword"] {
+		t.Errorf("updated datasource = %+v", ds)
+	}
+
+	// Only one datasource is the default.
+	if _, err := client.NewDataSource(&gapi.DataSource{Name: "Loki", Type: "loki", IsDefault: true}); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := client.DataSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 2 || sources[0].Name != "Loki" || !sources[0].IsDefault || sources[1].IsDefault {
+		t.Errorf("datasources = %+v, %+v", sources[0], sources[1])
+	}
+
+	// Datasources can be looked up and changed by name and UID too.
+	loki, err := client.DataSourceByName("Loki")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loki.UID == "" {
+		t.Fatalf("datasource has no UID: %+v", loki)
+	}
+	if health, err := client.CheckDataSourceHealth(loki.UID); err != nil || !health.OK() {
+		t.Errorf("CheckDataSourceHealth = %+v, %v", health, err)
+	}
+	failing := gapi.DataSourceHealth{Status: gapi.HealthStatusError, Message: "Unable to connect with Loki"}
+	if err := srv.SetDataSourceHealth(loki.UID, failing); err != nil {
+		t.Fatal(err)
+	}
+	if health, err := client.CheckDataSourceHealth(loki.UID); err != nil || health.OK() || health.Message != failing.Message {
+		t.Errorf("CheckDataSourceHealth = %+v, %v", health, err)
+	}
+	if got, err := client.DataSourceIDByName("Loki"); err != nil || got != loki.Id {
+		t.Errorf("DataSourceIDByName = %d, %v, want %d", got, err, loki.Id)
+	}
+	loki.URL = "http://loki:3100"
+	if err := client.UpdateDataSourceByUID(loki); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.DataSourceByUID(loki.UID); err != nil || got.URL != "http://loki:3100" {
+		t.Errorf("DataSourceByUID = %+v, %v", got, err)
+	}
+	if err := client.DeleteDataSourceByName("Loki"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DataSourceByUID(loki.UID); !gapi.IsNotFound(err) {
+		t.Errorf("deleted datasource: got %v, want not found", err)
+	}
+
+	// Names may have slashes.
+	if _, err := client.NewDataSource(&gapi.DataSource{Name: "Team A/Logs", Type: "loki"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.DataSourceByName("Team A/Logs"); err != nil || got.Name != "Team A/Logs" {
+		t.Errorf("DataSourceByName = %+v, %v", got, err)
+	}
+	if err := client.DeleteDataSourceByName("Team A/Logs"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.DeleteDataSource(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DataSource(id); !gapi.IsNotFound(err) {
+		t.Errorf("deleted datasource: got %v, want not found", err)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	_, client := newServer(t)
+
+	if err := client.AddTeam("SRE", "sre@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddTeam("SRE", ""); !gapi.IsConflict(err) {
+		t.Errorf("duplicate team: got %v, want conflict", err)
+	}
+	if err := client.AddTeamMember(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddTeamMember(1, 1); err == nil {
+		t.Error("adding a member twice succeeded")
+	}
+	team, err := client.Team(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.MemberCount != 1 || team.Email != "sre@example.com" {
+		t.Errorf("team = %+v", team)
+	}
+	if err := client.UpdateTeamPreferences(1, "dark", 0, "utc"); err != nil {
+		t.Fatal(err)
+	}
+	prefs, err := client.TeamPreferences(1)
+	if err != nil || prefs.Theme != "dark" {
+		t.Errorf("TeamPreferences = %+v, %v", prefs, err)
+	}
+	if err := client.RemoveMemberFromTeam(1, 1); err != nil {
+		t.Fatal(err)
+	}
+	members, err := client.TeamMembers(1)
+	if err != nil || len(members) != 0 {
+		t.Errorf("TeamMembers = %v, %v", members, err)
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	_, client := newServer(t)
+
+	first, err := client.NewAnnotation(&gapi.Annotation{Time: 1000, Text: "deploy", Tags: []string{"deploy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	region, err := client.NewAnnotation(&gapi.Annotation{Time: 2000, TimeEnd: 3000, Text: "outage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.NewGraphiteAnnotation(&gapi.GraphiteAnnotation{What: "restart", When: 4, Tags: []string{"deploy"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	annotations, err := client.Annotations(url.Values{"tags": {"deploy"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 2 || annotations[0].Text != "restart" || annotations[1].ID != first {
+		t.Errorf("annotations = %+v", annotations)
+	}
+	annotations, err = client.Annotations(url.Values{"from": {"2500"}, "to": {"3500"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 1 || annotations[0].ID != region || annotations[0].RegionID != region {
+		t.Errorf("annotations in range = %+v", annotations)
+	}
+
+	if _, err := client.PatchAnnotation(first, &gapi.Annotation{Text: "deploy v2"}); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err = client.Annotations(url.Values{"to": {"1500"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 1 || annotations[0].Text != "deploy v2" || annotations[0].Time != 1000 || len(annotations[0].Tags) != 1 {
+		t.Errorf("patched annotation = %+v", annotations)
+	}
+
+	if _, err := client.DeleteAnnotationByRegionID(region); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteAnnotation(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.DeleteAnnotation(first); !gapi.IsNotFound(err) {
+		t.Errorf("deleting twice: got %v, want not found", err)
+	}
+	annotations, err = client.Annotations(nil)
+	if err != nil || len(annotations) != 1 {
+		t.Errorf("annotations left = %+v, %v", annotations, err)
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	srv, client := newServer(t)
+
+	model := map[string]interface{}{
+		"title": "Nodes",
+		"panels": []interface{}{
+			map[string]interface{}{"id": 1, "alert": map[string]interface{}{"name": "High CPU"}},
+			map[string]interface{}{"id": 2, "type": "row", "panels": []interface{}{
+				map[string]interface{}{"id": 3, "alert": map[string]interface{}{"name": "Disk full"}},
+			}},
+		},
+	}
+	saved, err := client.NewDashboard(gapi.Dashboard{Model: model})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts, err := client.Alerts(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Name != "Disk full" || alerts[0].PanelID != 3 || alerts[0].DashboardUID != saved.Uid {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	id := alerts[1].ID
+
+	if err := srv.SetAlertState(id, "alerting"); err != nil {
+		t.Fatal(err)
+	}
+	alerts, err = client.Alerts(url.Values{"state": {"alerting"}})
+	if err != nil || len(alerts) != 1 || alerts[0].ID != id {
+		t.Errorf("alerting alerts = %+v, %v", alerts, err)
+	}
+	annotations, err := client.Annotations(url.Values{"type": {"alert"}})
+	if err != nil || len(annotations) != 1 || annotations[0].NewState != "alerting" || annotations[0].AlertID != id {
+		t.Errorf("alert annotations = %+v, %v", annotations, err)
+	}
+
+	paused, err := client.PauseAlert(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if paused.State != "paused" {
+		t.Errorf("PauseAlert = %+v", paused)
+	}
+	all, err := client.PauseAllAlerts()
+	if err != nil || all.AlertsAffected != 2 {
+		t.Errorf("PauseAllAlerts = %+v, %v", all, err)
+	}
+
+	// Removing the panel removes its alert.
+	model["panels"] = model["panels"].([]interface{})[:1]
+	model["id"] = saved.Id
+	model["version"] = saved.Version
+	if _, err := client.NewDashboard(gapi.Dashboard{Model: model}); err != nil {
+		t.Fatal(err)
+	}
+	alerts, err = client.Alerts(nil)
+	if err != nil || len(alerts) != 1 || alerts[0].ID != id {
+		t.Errorf("alerts after update = %+v, %v", alerts, err)
+	}
+}
+
+func TestPlaylists(t *testing.T) {
+	_, client := newServer(t)
+
+	id, err := client.NewPlaylist(gapi.Playlist{
+		Name:     "Wall",
+		Interval: "5m",
+		Items: []gapi.PlaylistItem{
+			{Type: "dashboard_by_tag", Value: "prod", Title: "prod"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	playlist, err := client.Playlist(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playlist.Name != "Wall" || len(playlist.Items) != 1 || playlist.Items[0].Order != 1 {
+		t.Errorf("playlist = %+v", playlist)
+	}
+	playlist.Interval = "1m"
+	if err := client.UpdatePlaylist(*playlist); err != nil {
+		t.Fatal(err)
+	}
+	playlists, err := client.Playlists()
+	if err != nil || len(playlists) != 1 || playlists[0].Interval != "1m" {
+		t.Errorf("Playlists = %+v, %v", playlists, err)
+	}
+	if err := client.DeletePlaylist(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Playlist(id); !gapi.IsNotFound(err) {
+		t.Errorf("deleted playlist: got %v, want not found", err)
+	}
+}
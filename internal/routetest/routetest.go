@@ -0,0 +1,108 @@
+// Package routetest serves canned responses to the clients of the tests of
+// the packages built on gapi, and records the requests they make.
+package routetest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// Request is a request received by a Server.
+type Request struct {
+	Method, Path, Query string
+	// Auth is the Authorization header, and OrgID the X-Grafana-Org-Id one.
+	Auth, OrgID string
+	// Body is the decoded JSON body.
+	Body map[string]interface{}
+}
+
+// Server replies to requests with the canned bodies of its routes, keyed by
+// method and path, such as "GET /api/folders", and records the requests it
+// gets. Bodies in Once are replied first, a single time; routes in Fail reply
+// with their status code. Other GET requests are answered with a 404, and
+// other writes with {"id":1}.
+type Server struct {
+	Routes map[string]string
+	Once   map[string]string
+	Fail   map[string]int
+
+	mu       sync.Mutex
+	Requests []Request
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req := Request{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.RawQuery,
+		Auth:   r.Header.Get("Authorization"),
+		OrgID:  r.Header.Get("X-Grafana-Org-Id"),
+	}
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &req.Body)
+	s.Requests = append(s.Requests, req)
+
+	key := r.Method + " " + r.URL.Path
+	if status, ok := s.Fail[key]; ok {
+		w.WriteHeader(status)
+		return
+	}
+	if body, ok := s.Once[key]; ok {
+		delete(s.Once, key)
+		w.Write([]byte(body))
+		return
+	}
+	body, ok := s.Routes[key]
+	if !ok {
+		if r.Method == "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not found"}`))
+			return
+		}
+		body = `{"id":1}`
+	}
+	w.Write([]byte(body))
+}
+
+// Find returns the requests received with the method and path.
+func (s *Server) Find(method, path string) []Request {
+	var found []Request
+	for _, r := range s.Requests {
+		if r.Method == method && r.Path == path {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+// Writes returns the requests that change something, as "METHOD path".
+func (s *Server) Writes() []string {
+	var found []string
+	for _, r := range s.Requests {
+		if r.Method != "GET" {
+			found = append(found, r.Method+" "+r.Path)
+		}
+	}
+	return found
+}
+
+// NewClient starts an httptest server with the handler, and returns a client
+// of it, authenticated as admin, and a function stopping the server.
+func NewClient(t *testing.T, handler http.Handler) (*gapi.Client, func()) {
+	server := httptest.NewServer(handler)
+	client, err := gapi.New("admin:admin", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server.Close
+}
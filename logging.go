@@ -0,0 +1,136 @@
+package gapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// Logger is the interface the client logs requests and responses to.
+// Arguments are alternating keys and values, so *slog.Logger satisfies it.
+//
+// Every attempt at a request is logged with the method, path, query, org,
+// headers, status, duration and bodies. Credentials in headers and secrets in
+// bodies, such as passwords and secureJsonData, are redacted.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// stdLogger logs to the standard library's log package. It is used when no
+// Logger is configured and the GF_LOG environment variable is set.
+type stdLogger struct{}
+
+func (stdLogger) Debug(msg string, args ...interface{}) {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	log.Print(b.String())
+}
+
+// sensitiveHeaders are the request headers whose values are never logged.
+var sensitiveHeaders = []string{
+	"Authorization",
+	"Cookie",
+	AuthProxyHeader,
+}
+
+// sensitiveFields are the JSON fields, compared case-insensitively, whose
+// values are never logged.
+var sensitiveFields = map[string]bool{
+	"password":          true,
+	"basicauthpassword": true,
+	"securejsondata":    true,
+	"key":               true,
+	"token":             true,
+	"accesstoken":       true,
+	"secretkey":         true,
+	"privatekey":        true,
+}
+
+func (c *Client) logRequest(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, err error, attempt int, duration time.Duration) {
+	logger := c.logger
+	if logger == nil {
+		if os.Getenv("GF_LOG") == "" {
+			return
+		}
+		logger = stdLogger{}
+	}
+
+	args := []interface{}{
+		"method", req.Method,
+		"path", req.URL.Path,
+	}
+	if req.URL.RawQuery != "" {
+		args = append(args, "query", req.URL.RawQuery)
+	}
+	if c.orgID > 0 {
+		args = append(args, "org", c.orgID)
+	}
+	args = append(args, "headers", redactHeaders(req.Header))
+	if reqBody != nil {
+		args = append(args, "request_body", redactBody(reqBody))
+	}
+	if err != nil {
+		args = append(args, "error", err.Error())
+	} else {
+		args = append(args, "status", resp.StatusCode, "response_body", redactBody(respBody))
+	}
+	args = append(args, "duration", duration, "attempt", attempt)
+
+	logger.Debug("grafana api request", args...)
+}
+
+func redactHeaders(header http.Header) http.Header {
+	h := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+// redactBody returns a JSON body with its sensitive fields redacted.
+// Bodies which aren't JSON are summarised rather than logged.
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("[%d bytes]", len(body))
+	}
+
+	data, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return fmt.Sprintf("[%d bytes]", len(body))
+	}
+	return string(data)
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if sensitiveFields[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = redactValue(value)
+		}
+	}
+	return v
+}
@@ -0,0 +1,83 @@
+package gapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	messages []string
+	fields   []map[string]interface{}
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = args[i+1]
+	}
+	l.messages = append(l.messages, msg)
+	l.fields = append(l.fields, fields)
+}
+
+func TestLogger(t *testing.T) {
+	server, client := gapiTestTools(200, `{"id":1,"name":"prom","secureJsonData":{"password":"hunter2"}}`)
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client.logger = logger
+	client = client.WithOrgID(2)
+
+	_, err := client.NewDataSource(&DataSource{
+		Name:     "prom",
+		Password: "hunter2",
+		SecureJSONData: SecureJSONData{
+			BasicAuthPassword: "hunter2",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.fields) != 1 {
+		t.Fatalf("expected 1 log entry; got: %d", len(logger.fields))
+	}
+	fields := logger.fields[0]
+	for key, expected := range map[string]interface{}{
+		"method": "POST",
+		"path":   "/api/datasources",
+		"org":    int64(2),
+		"status": 200,
+	} {
+		if fields[key] != expected {
+			t.Errorf("expected %s: %v; got: %v", key, expected, fields[key])
+		}
+	}
+	if _, ok := fields["duration"]; !ok {
+		t.Error("expected duration to be logged")
+	}
+
+	if headers := fields["headers"].(http.Header); headers.Get("Authorization") != redacted {
+		t.Errorf("expected Authorization header to be redacted; got: %s", headers.Get("Authorization"))
+	}
+	for _, key := range []string{"request_body", "response_body"} {
+		if body := fields[key].(string); strings.Contains(body, "hunter2") || !strings.Contains(body, "prom") {
+			t.Errorf("expected %s to be redacted; got: %s", key, body)
+		}
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	for body, expected := range map[string]string{
+		`{"user":"admin","password":"admin"}`:                   `{"password":"[REDACTED]","user":"admin"}`,
+		`[{"name":"a","key":"eyJrIjoi"}]`:                       `[{"key":"[REDACTED]","name":"a"}]`,
+		`{"jsonData":{"tlsAuth":true},"BasicAuthPassword":"x"}`: `{"BasicAuthPassword":"[REDACTED]","jsonData":{"tlsAuth":true}}`,
+		`not json`: `[8 bytes]`,
+		``:         ``,
+	} {
+		if got := redactBody([]byte(body)); got != expected {
+			t.Errorf("expected: %s; got: %s", expected, got)
+		}
+	}
+}
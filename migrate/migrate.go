@@ -0,0 +1,464 @@
+// Package migrate copies the orgs, users, teams, folders, dashboards,
+// datasources and alert notification channels of a Grafana instance to
+// another one.
+//
+//	report, err := migrate.Migrate(ctx, source, target, migrate.Options{
+//		StateFile: "migration.json",
+//	})
+//	report.Print(os.Stdout)
+//	if err != nil {
+//		// Fix the cause and run again: the migration resumes where it stopped.
+//	}
+//
+// Both clients must authenticate as a server admin, with basic auth, who is
+// a member of the orgs migrated. IDs differ between instances, so references
+// to folders, teams and users are remapped to their IDs in the target.
+package migrate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// Action is what a change does to a resource of the target.
+type Action string
+
+// Change actions.
+const (
+	Create Action = "create"
+	Update Action = "update"
+)
+
+// Kind is the kind of a migrated resource.
+type Kind string
+
+// Resource kinds.
+const (
+	KindOrg                  Kind = "org"
+	KindUser                 Kind = "user"
+	KindOrgUser              Kind = "org user"
+	KindDataSource           Kind = "datasource"
+	KindAlertNotification    Kind = "alert notification"
+	KindTeam                 Kind = "team"
+	KindTeamMember           Kind = "team member"
+	KindFolder               Kind = "folder"
+	KindFolderPermissions    Kind = "folder permissions"
+	KindDashboard            Kind = "dashboard"
+	KindDashboardPermissions Kind = "dashboard permissions"
+)
+
+// Options controls how resources are migrated.
+type Options struct {
+	// Orgs limits the migration to the orgs with these names. Every org is
+	// migrated when empty.
+	Orgs []string
+	// DryRun reports the changes a migration would make without making them.
+	DryRun bool
+	// Overwrite updates the resources of the target that conflict with those
+	// of the source. Conflicting resources are left as they are otherwise.
+	// Users are never overwritten.
+	Overwrite bool
+	// StateFile records the changes made, so that a migration that failed
+	// can be run again and resume where it stopped. Resources changed by a
+	// previous run are skipped; remove the file to migrate them again.
+	StateFile string
+	// Password returns the password of a user created in the target.
+	// Grafana never returns passwords, so users get random ones when nil.
+	Password func(gapi.User) string
+	// Secrets maps the secure fields of datasources, as "<datasource>/<field>",
+	// to their values. Secure fields without a value are left unset.
+	Secrets map[string]string
+}
+
+// Resource identifies a resource of the source.
+type Resource struct {
+	// Org is the name of the org holding the resource, empty for orgs and users.
+	Org  string
+	Kind Kind
+	// Name is the UID of folders, dashboards and alert notifications, the
+	// login of users and the name of other resources. Team members are
+	// named "<team>/<login>".
+	Name string
+}
+
+func (r Resource) String() string {
+	if r.Org == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s: %s %s", r.Org, r.Kind, r.Name)
+}
+
+// Change is a change made, or to be made in a dry run, to the target.
+type Change struct {
+	Resource
+	Action Action
+}
+
+// Conflict is a resource that exists in the target but differs from the
+// source.
+type Conflict struct {
+	Resource
+	Reason string
+}
+
+// Report describes a migration. The ID maps translate the IDs of the
+// source to the IDs of the target; in a dry run, resources to be created
+// get negative IDs.
+type Report struct {
+	Changes   []Change
+	Conflicts []Conflict
+	// Warnings lists what couldn't be migrated as it is in the source.
+	Warnings []string
+
+	OrgIDs       map[int64]int64
+	UserIDs      map[int64]int64
+	TeamIDs      map[int64]int64
+	FolderIDs    map[int64]int64
+	DashboardIDs map[int64]int64
+}
+
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Print writes a summary of the report, one line per change, conflict and
+// warning.
+func (r *Report) Print(w io.Writer) error {
+	counts := map[Action]int{}
+	for _, c := range r.Changes {
+		counts[c.Action]++
+		symbol := map[Action]string{Create: "+", Update: "~"}[c.Action]
+		if _, err := fmt.Fprintf(w, "%s %s\n", symbol, c.Resource); err != nil {
+			return err
+		}
+	}
+	for _, c := range r.Conflicts {
+		if _, err := fmt.Fprintf(w, "! %s: %s\n", c.Resource, c.Reason); err != nil {
+			return err
+		}
+	}
+	for _, warning := range r.Warnings {
+		if _, err := fmt.Fprintf(w, "warning: %s\n", warning); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "Migration: %d to create, %d to update, %d conflicts.\n",
+		counts[Create], counts[Update], len(r.Conflicts))
+	return err
+}
+
+// Migrate copies the users and orgs of the source to the target, then the
+// datasources, alert notifications, teams, folders and dashboards of each
+// org, in dependency order. Resources are matched by login for users, by
+// UID for folders, dashboards and alert notifications, and by name
+// otherwise. Resources missing from the target are created; those that
+// differ are reported as conflicts.
+//
+// Migrate stops at the first error and returns the report so far.
+func Migrate(ctx context.Context, source, target *gapi.Client, opts Options) (*Report, error) {
+	m := &migrator{
+		source: source,
+		target: target,
+		opts:   opts,
+		report: &Report{
+			OrgIDs:       map[int64]int64{},
+			UserIDs:      map[int64]int64{},
+			TeamIDs:      map[int64]int64{},
+			FolderIDs:    map[int64]int64{},
+			DashboardIDs: map[int64]int64{},
+		},
+		done:         map[string]bool{},
+		userIDs:      map[string]int64{},
+		createdUsers: map[string]bool{},
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	if err := m.users(ctx); err != nil {
+		return m.report, fmt.Errorf("migrating users: %w", err)
+	}
+	if err := m.orgs(ctx); err != nil {
+		return m.report, err
+	}
+	return m.report, nil
+}
+
+type migrator struct {
+	source, target *gapi.Client
+	opts           Options
+	report         *Report
+	// done holds the resources changed by this run or previous ones.
+	done map[string]bool
+	// userIDs maps logins to user IDs in the target.
+	userIDs map[string]int64
+	// createdUsers holds the logins of the users created in the target.
+	createdUsers map[string]bool
+	lastID       int64
+}
+
+// checkpoint is the content of a state file.
+type checkpoint struct {
+	Done []string `json:"done"`
+}
+
+func (m *migrator) load() error {
+	if m.opts.StateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(m.opts.StateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c := checkpoint{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return fmt.Errorf("%s: %w", m.opts.StateFile, err)
+	}
+	for _, key := range c.Done {
+		m.done[key] = true
+	}
+	return nil
+}
+
+func (m *migrator) save() error {
+	if m.opts.StateFile == "" {
+		return nil
+	}
+	c := checkpoint{Done: make([]string, 0, len(m.done))}
+	for key := range m.done {
+		c.Done = append(c.Done, key)
+	}
+	sort.Strings(c.Done)
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash can't truncate the state.
+	tmp := m.opts.StateFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.opts.StateFile)
+}
+
+// isDone reports whether the resource was changed by this run or a previous one.
+func (m *migrator) isDone(r Resource) bool {
+	return m.done[r.String()]
+}
+
+// apply records a change and, unless in a dry run, makes it with fn and
+// saves the state.
+func (m *migrator) apply(r Resource, action Action, fn func() error) error {
+	if !m.opts.DryRun {
+		if err := fn(); err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+		m.done[r.String()] = true
+		if err := m.save(); err != nil {
+			return fmt.Errorf("saving state: %w", err)
+		}
+	}
+	m.report.Changes = append(m.report.Changes, Change{Resource: r, Action: action})
+	return nil
+}
+
+func (m *migrator) conflict(r Resource, format string, args ...interface{}) {
+	m.report.Conflicts = append(m.report.Conflicts, Conflict{Resource: r, Reason: fmt.Sprintf(format, args...)})
+}
+
+// placeholderID returns the ID standing for a resource to be created, which
+// is replaced by its actual ID unless in a dry run.
+func (m *migrator) placeholderID() int64 {
+	m.lastID--
+	return m.lastID
+}
+
+func (m *migrator) users(ctx context.Context) error {
+	users, err := m.source.UsersContext(ctx)
+	if err != nil {
+		return err
+	}
+	existing, err := m.target.UsersContext(ctx)
+	if err != nil {
+		return err
+	}
+	byLogin := map[string]gapi.User{}
+	byEmail := map[string]gapi.User{}
+	for _, u := range existing {
+		byLogin[u.Login] = u
+		if u.Email != "" {
+			byEmail[u.Email] = u
+		}
+	}
+
+	for _, u := range users {
+		r := Resource{Kind: KindUser, Name: u.Login}
+		if current, ok := byLogin[u.Login]; ok {
+			if current.Email != u.Email && !m.isDone(r) {
+				m.conflict(r, "email is %q in the target", current.Email)
+			}
+			m.userIDs[u.Login] = current.Id
+			m.report.UserIDs[u.Id] = current.Id
+			continue
+		}
+		if current, ok := byEmail[u.Email]; ok {
+			m.conflict(r, "email %q belongs to %s in the target", u.Email, current.Login)
+			continue
+		}
+
+		password, err := m.password(u)
+		if err != nil {
+			return err
+		}
+		id := m.placeholderID()
+		err = m.apply(r, Create, func() (err error) {
+			id, err = m.target.CreateUserContext(ctx, gapi.User{
+				Email:    u.Email,
+				Name:     u.Name,
+				Login:    u.Login,
+				Password: password,
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		m.userIDs[u.Login] = id
+		m.report.UserIDs[u.Id] = id
+		m.createdUsers[u.Login] = true
+	}
+	return nil
+}
+
+func (m *migrator) password(u gapi.User) (string, error) {
+	if m.opts.Password != nil {
+		return m.opts.Password(u), nil
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (m *migrator) orgs(ctx context.Context) error {
+	orgs, err := m.source.OrgsContext(ctx)
+	if err != nil {
+		return fmt.Errorf("migrating orgs: %w", err)
+	}
+	wanted := map[string]bool{}
+	for _, name := range m.opts.Orgs {
+		wanted[name] = true
+	}
+
+	for _, org := range orgs {
+		if len(wanted) > 0 && !wanted[org.Name] {
+			continue
+		}
+		if err := m.org(ctx, org); err != nil {
+			return fmt.Errorf("migrating org %q: %w", org.Name, err)
+		}
+	}
+	return nil
+}
+
+func (m *migrator) org(ctx context.Context, org gapi.Org) error {
+	current, err := m.target.OrgByNameContext(ctx, org.Name)
+	id := current.Id
+	if gapi.IsNotFound(err) {
+		id = m.placeholderID()
+		err = m.apply(Resource{Kind: KindOrg, Name: org.Name}, Create, func() (err error) {
+			id, err = m.target.NewOrgContext(ctx, org.Name)
+			return err
+		})
+	}
+	if err != nil {
+		return err
+	}
+	m.report.OrgIDs[org.Id] = id
+
+	o := &orgMigrator{
+		migrator:  m,
+		name:      org.Name,
+		sourceID:  org.Id,
+		targetID:  id,
+		source:    m.source.WithOrgID(org.Id),
+		target:    m.target.WithOrgID(id),
+		folderIDs: map[string]int64{},
+	}
+	steps := []struct {
+		name string
+		fn   func(context.Context) error
+	}{
+		{"users", o.users},
+		{"datasources", o.dataSources},
+		{"alert notifications", o.alertNotifications},
+		{"teams", o.teams},
+		{"folders", o.folders},
+		{"dashboards", o.dashboards},
+	}
+	for _, step := range steps {
+		if err := step.fn(ctx); err != nil {
+			return fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+	return nil
+}
+
+// diff returns the top-level JSON fields that differ between current and
+// desired, leaving out those ignored.
+func diff(current, desired interface{}, ignored ...string) ([]string, error) {
+	a, err := toMap(current)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toMap(desired)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range ignored {
+		delete(a, key)
+		delete(b, key)
+	}
+
+	var fields []string
+	for key, value := range b {
+		if !reflect.DeepEqual(a[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+func toMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
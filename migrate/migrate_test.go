@@ -0,0 +1,374 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	gapi "github.com/nytm/go-grafana-api"
+	"github.com/nytm/go-grafana-api/internal/routetest"
+)
+
+func sourceServer() *routetest.Server {
+	return &routetest.Server{Routes: map[string]string{
+		"GET /api/users":                            `[{"id":1,"login":"admin","email":"admin@localhost"},{"id":2,"login":"alice","email":"alice@example.com","name":"Alice"}]`,
+		"GET /api/orgs":                             `[{"id":1,"name":"Main"}]`,
+		"GET /api/orgs/1/users":                     `[{"userId":1,"login":"admin","role":"Admin"},{"userId":2,"login":"alice","role":"Editor"}]`,
+		"GET /api/datasources":                      `[{"id":4,"name":"Prometheus","type":"prometheus","url":"http://prometheus:9090","access":"proxy"}]`,
+		"GET /api/datasources/4":                    `{"id":4,"name":"Prometheus","type":"prometheus","url":"http://prometheus:9090","access":"proxy","basicAuth":true,"basicAuthUser":"grafana","secureJsonFields":{"basicAuthPassword":true}}`,
+		"GET /api/alert-notifications":              `[{"id":5,"uid":"slack","name":"Slack","type":"slack","settings":{"url":"https://hooks.slack.com/x"}}]`,
+		"GET /api/teams/search":                     `{"totalCount":1,"teams":[{"id":3,"name":"SRE","email":"sre@example.com"}]}`,
+		"GET /api/teams/3/members":                  `[{"userId":2,"login":"alice"}]`,
+		"GET /api/folders":                          `[{"id":7,"uid":"infra","title":"Infra"}]`,
+		"GET /api/folders/infra/permissions":        `[{"role":"Viewer","permission":1},{"teamId":3,"team":"SRE","permission":2},{"userId":2,"userLogin":"alice","permission":4}]`,
+		"GET /api/search":                           `[{"id":11,"uid":"nodes","title":"Nodes","type":"dash-db","folderId":7,"folderUid":"infra"}]`,
+		"GET /api/dashboards/uid/nodes":             `{"meta":{"folderId":7},"dashboard":{"id":11,"uid":"nodes","title":"Nodes","version":3}}`,
+		"GET /api/dashboards/uid/nodes/permissions": `[{"role":"Viewer","permission":1,"inherited":true},{"userId":2,"userLogin":"alice","permission":2}]`,
+	}}
+}
+
+// emptyTargetServer is a target holding nothing but its admin and main org.
+func emptyTargetServer() *routetest.Server {
+	return &routetest.Server{
+		Routes: map[string]string{
+			"GET /api/users":                            `[{"id":1,"login":"admin","email":"admin@localhost"}]`,
+			"GET /api/orgs/name/Main":                   `{"id":1,"name":"Main"}`,
+			"GET /api/orgs/1/users":                     `[{"userId":1,"login":"admin","role":"Admin"}]`,
+			"GET /api/datasources":                      `[]`,
+			"GET /api/alert-notifications":              `[]`,
+			"GET /api/teams/search":                     `{"totalCount":1,"teams":[{"id":30,"name":"SRE","email":"sre@example.com"}]}`,
+			"GET /api/teams/30/members":                 `[]`,
+			"GET /api/folders":                          `[]`,
+			"GET /api/folders/infra/permissions":        `[{"role":"Viewer","permission":1},{"role":"Editor","permission":2}]`,
+			"GET /api/search":                           `[]`,
+			"GET /api/dashboards/uid/nodes/permissions": `[]`,
+			"POST /api/admin/users":                     `{"id":20}`,
+			"POST /api/datasources":                     `{"id":40}`,
+			"POST /api/alert-notifications":             `{"id":50}`,
+			"POST /api/folders":                         `{"id":70,"uid":"infra","title":"Infra"}`,
+			"POST /api/dashboards/db":                   `{"id":110,"uid":"nodes","version":1}`,
+		},
+		// The team only exists once created.
+		Once: map[string]string{
+			"GET /api/teams/search": `{"totalCount":0,"teams":[]}`,
+		},
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	src := sourceServer()
+	source, closeSource := routetest.NewClient(t, src)
+	defer closeSource()
+	dst := emptyTargetServer()
+	target, closeTarget := routetest.NewClient(t, dst)
+	defer closeTarget()
+
+	report, err := Migrate(context.Background(), source, target, Options{
+		Password: func(u gapi.User) string { return "changeme-" + u.Login },
+		Secrets:  map[string]string{"Prometheus/basicAuthPassword": "s3cret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Resource{"", KindUser, "alice"}, Create},
+		{Resource{"Main", KindOrgUser, "alice"}, Create},
+		{Resource{"Main", KindDataSource, "Prometheus"}, Create},
+		{Resource{"Main", KindAlertNotification, "slack"}, Create},
+		{Resource{"Main", KindTeam, "SRE"}, Create},
+		{Resource{"Main", KindTeamMember, "SRE/alice"}, Create},
+		{Resource{"Main", KindFolder, "infra"}, Create},
+		{Resource{"Main", KindFolderPermissions, "infra"}, Update},
+		{Resource{"Main", KindDashboard, "nodes"}, Create},
+		{Resource{"Main", KindDashboardPermissions, "nodes"}, Update},
+	}
+	if !reflect.DeepEqual(report.Changes, want) {
+		t.Errorf("changes:\n%v\nwant:\n%v", report.Changes, want)
+	}
+	if len(report.Conflicts) != 0 || len(report.Warnings) != 0 {
+		t.Errorf("unexpected conflicts %v, warnings %v", report.Conflicts, report.Warnings)
+	}
+
+	ids := map[string]map[int64]int64{
+		"user":      report.UserIDs,
+		"team":      report.TeamIDs,
+		"folder":    report.FolderIDs,
+		"dashboard": report.DashboardIDs,
+	}
+	wantIDs := map[string]map[int64]int64{
+		"user":      {1: 1, 2: 20},
+		"team":      {3: 30},
+		"folder":    {7: 70},
+		"dashboard": {11: 110},
+	}
+	if !reflect.DeepEqual(ids, wantIDs) {
+		t.Errorf("IDs: %v, want %v", ids, wantIDs)
+	}
+
+	user := dst.Find("POST", "/api/admin/users")[0].Body
+	if user["login"] != "alice" || user["password"] != "changeme-alice" {
+		t.Errorf("user: %v", user)
+	}
+
+	ds := dst.Find("POST", "/api/datasources")
+	if len(ds) != 1 || ds[0].OrgID != "1" {
+		t.Fatalf("datasources: %v", ds)
+	}
+	secure := ds[0].Body["secureJsonData"].(map[string]interface{})
+	if secure["basicAuthPassword"] != "s3cret" || ds[0].Body["id"] != nil {
+		t.Errorf("datasource: %v", ds[0].Body)
+	}
+
+	member := dst.Find("POST", "/api/teams/30/members")
+	if len(member) != 1 || member[0].Body["userId"] != float64(20) {
+		t.Errorf("team members: %v", member)
+	}
+
+	permissions := dst.Find("POST", "/api/folders/infra/permissions")[0].Body
+	wantPermissions := map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"role": "Viewer", "permission": float64(1)},
+		map[string]interface{}{"teamId": float64(30), "permission": float64(2)},
+		map[string]interface{}{"userId": float64(20), "permission": float64(4)},
+	}}
+	if !reflect.DeepEqual(permissions, wantPermissions) {
+		t.Errorf("folder permissions: %v", permissions)
+	}
+
+	dashboard := dst.Find("POST", "/api/dashboards/db")[0].Body
+	model := dashboard["dashboard"].(map[string]interface{})
+	if dashboard["folderId"] != float64(70) || model["id"] != nil || model["version"] != nil {
+		t.Errorf("dashboard: %v", dashboard)
+	}
+
+	permissions = dst.Find("POST", "/api/dashboards/uid/nodes/permissions")[0].Body
+	wantPermissions = map[string]interface{}{"items": []interface{}{
+		map[string]interface{}{"userId": float64(20), "permission": float64(2)},
+	}}
+	if !reflect.DeepEqual(permissions, wantPermissions) {
+		t.Errorf("dashboard permissions: %v", permissions)
+	}
+}
+
+func TestMigrateDryRun(t *testing.T) {
+	source, closeSource := routetest.NewClient(t, sourceServer())
+	defer closeSource()
+	dst := emptyTargetServer()
+	target, closeTarget := routetest.NewClient(t, dst)
+	defer closeTarget()
+
+	report, err := Migrate(context.Background(), source, target, Options{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if writes := dst.Writes(); len(writes) != 0 {
+		t.Errorf("dry run changed the target: %v", writes)
+	}
+	if len(report.Changes) != 10 {
+		t.Errorf("got %d changes, want 10: %v", len(report.Changes), report.Changes)
+	}
+	if report.UserIDs[2] >= 0 || report.FolderIDs[7] >= 0 {
+		t.Errorf("expected placeholder IDs: %v %v", report.UserIDs, report.FolderIDs)
+	}
+	if want := "Main: datasource Prometheus: no secret for basicAuthPassword"; !reflect.DeepEqual(report.Warnings, []string{want}) {
+		t.Errorf("warnings: %v", report.Warnings)
+	}
+}
+
+func TestMigrateNewOrg(t *testing.T) {
+	src := sourceServer()
+	src.Routes["GET /api/orgs"] = `[{"id":1,"name":"Main"},{"id":2,"name":"Staging"}]`
+	src.Routes["GET /api/orgs/2/users"] = `[{"userId":1,"login":"admin","role":"Admin"}]`
+	source, closeSource := routetest.NewClient(t, src)
+	defer closeSource()
+	dst := emptyTargetServer()
+	dst.Routes["POST /api/orgs"] = `{"orgId":9}`
+	dst.Routes["GET /api/orgs/9/users"] = `[{"userId":1,"login":"admin","role":"Admin"}]`
+	target, closeTarget := routetest.NewClient(t, dst)
+	defer closeTarget()
+
+	report, err := Migrate(context.Background(), source, target, Options{Orgs: []string{"Staging"}, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Changes[1].Resource != (Resource{"", KindOrg, "Staging"}) {
+		t.Errorf("changes: %v", report.Changes)
+	}
+	// The target can't be read for an org that doesn't exist yet.
+	for _, r := range dst.Requests {
+		if r.OrgID != "" && r.OrgID != "0" {
+			t.Errorf("request to org %s: %s %s", r.OrgID, r.Method, r.Path)
+		}
+	}
+
+	report, err = Migrate(context.Background(), source, target, Options{Orgs: []string{"Staging"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.OrgIDs[2] != 9 {
+		t.Errorf("org IDs: %v", report.OrgIDs)
+	}
+	if ds := dst.Find("POST", "/api/datasources"); len(ds) != 1 || ds[0].OrgID != "9" {
+		t.Errorf("datasources: %v", ds)
+	}
+}
+
+func TestMigrateConflicts(t *testing.T) {
+	source, closeSource := routetest.NewClient(t, sourceServer())
+	defer closeSource()
+
+	newTarget := func() *routetest.Server {
+		dst := emptyTargetServer()
+		dst.Routes["GET /api/users"] = `[{"id":1,"login":"admin","email":"admin@localhost"},{"id":20,"login":"alice","email":"alice@old.example.com"}]`
+		dst.Routes["GET /api/orgs/1/users"] = `[{"userId":1,"login":"admin","role":"Admin"},{"userId":20,"login":"alice","role":"Viewer"}]`
+		dst.Routes["GET /api/datasources"] = `[{"id":40,"name":"Prometheus","type":"prometheus","url":"http://prometheus:9090","access":"proxy","basicAuth":true,"basicAuthUser":"grafana"}]`
+		dst.Routes["GET /api/folders"] = `[{"id":70,"uid":"infra","title":"Infrastructure"}]`
+		dst.Routes["GET /api/folders/infra"] = `{"id":70,"uid":"infra","title":"Infrastructure","version":3}`
+		dst.Routes["GET /api/folders/infra/permissions"] = `[{"role":"Viewer","permission":1},{"teamId":30,"permission":2},{"userId":20,"permission":4}]`
+		dst.Routes["GET /api/search"] = `[{"id":110,"uid":"nodes","title":"Nodes","type":"dash-db","folderId":70,"folderUid":"infra"}]`
+		dst.Routes["GET /api/dashboards/uid/nodes"] = `{"meta":{"folderId":70},"dashboard":{"id":110,"uid":"nodes","title":"Node exporter","version":1}}`
+		dst.Routes["GET /api/dashboards/uid/nodes/permissions"] = `[{"userId":20,"permission":2}]`
+		return dst
+	}
+
+	dst := newTarget()
+	target, closeTarget := routetest.NewClient(t, dst)
+	defer closeTarget()
+	report, err := Migrate(context.Background(), source, target, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Conflict{
+		{Resource{"", KindUser, "alice"}, `email is "alice@old.example.com" in the target`},
+		{Resource{"Main", KindOrgUser, "alice"}, "role is Viewer in the target"},
+		{Resource{"Main", KindFolder, "infra"}, `title is "Infrastructure" in the target`},
+		{Resource{"Main", KindDashboard, "nodes"}, "differs in the target [title]"},
+	}
+	if !reflect.DeepEqual(report.Conflicts, want) {
+		t.Errorf("conflicts:\n%v\nwant:\n%v", report.Conflicts, want)
+	}
+	wantWrites := []string{
+		"POST /api/alert-notifications",
+		"POST /api/teams",
+		"POST /api/teams/30/members",
+	}
+	if writes := dst.Writes(); !reflect.DeepEqual(writes, wantWrites) {
+		t.Errorf("writes: %v, want %v", writes, wantWrites)
+	}
+
+	dst = newTarget()
+	target, closeTarget = routetest.NewClient(t, dst)
+	defer closeTarget()
+	report, err = Migrate(context.Background(), source, target, Options{Overwrite: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantWrites = []string{
+		"PATCH /api/orgs/1/users/20",
+		"POST /api/alert-notifications",
+		"POST /api/teams",
+		"POST /api/teams/30/members",
+		"PUT /api/folders/infra",
+		"POST /api/dashboards/db",
+	}
+	if writes := dst.Writes(); !reflect.DeepEqual(writes, wantWrites) {
+		t.Errorf("writes: %v, want %v", writes, wantWrites)
+	}
+	if f := dst.Find("PUT", "/api/folders/infra")[0].Body; f["title"] != "Infra" || f["version"] != float64(3) {
+		t.Errorf("folder not updated: %v", f)
+	}
+	if d := dst.Find("POST", "/api/dashboards/db")[0].Body; d["overwrite"] != true {
+		t.Errorf("dashboard not overwritten: %v", d)
+	}
+}
+
+func TestMigrateResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, "state.json")
+
+	source, closeSource := routetest.NewClient(t, sourceServer())
+	defer closeSource()
+	dst := emptyTargetServer()
+	dst.Fail = map[string]int{"POST /api/dashboards/db": http.StatusInternalServerError}
+	target, closeTarget := routetest.NewClient(t, dst)
+	defer closeTarget()
+
+	report, err := Migrate(context.Background(), source, target, Options{StateFile: stateFile})
+	if err == nil || !strings.Contains(err.Error(), "Main: dashboard nodes") {
+		t.Fatalf("expected the dashboard to fail, got %v", err)
+	}
+	if len(report.Changes) != 8 {
+		t.Errorf("got %d changes before failing, want 8", len(report.Changes))
+	}
+	data, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"Main: folder infra"`)) || bytes.Contains(data, []byte(`"Main: dashboard nodes"`)) {
+		t.Errorf("state file:\n%s", data)
+	}
+
+	// The target now holds what the first run migrated, though its
+	// datasource and folder permissions differ from the source: they were
+	// changed by the first run, so they aren't conflicts.
+	dst = emptyTargetServer()
+	dst.Once = nil
+	dst.Routes["GET /api/users"] = `[{"id":1,"login":"admin","email":"admin@localhost"},{"id":20,"login":"alice","email":"alice@example.com"}]`
+	dst.Routes["GET /api/orgs/1/users"] = `[{"userId":1,"login":"admin","role":"Admin"},{"userId":20,"login":"alice","role":"Editor"}]`
+	dst.Routes["GET /api/datasources"] = `[{"id":40,"name":"Prometheus","type":"prometheus","url":"http://prometheus:9090"}]`
+	dst.Routes["GET /api/alert-notifications"] = `[{"id":50,"uid":"slack","name":"Slack","type":"slack","settings":{"url":"https://hooks.slack.com/x"}}]`
+	dst.Routes["GET /api/teams/30/members"] = `[{"userId":20,"login":"alice"}]`
+	dst.Routes["GET /api/folders"] = `[{"id":70,"uid":"infra","title":"Infra"}]`
+	target, closeTarget = routetest.NewClient(t, dst)
+	defer closeTarget()
+
+	report, err = Migrate(context.Background(), source, target, Options{StateFile: stateFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Resource{"Main", KindDashboard, "nodes"}, Create},
+		{Resource{"Main", KindDashboardPermissions, "nodes"}, Update},
+	}
+	if !reflect.DeepEqual(report.Changes, want) || len(report.Conflicts) != 0 {
+		t.Errorf("changes %v, conflicts %v", report.Changes, report.Conflicts)
+	}
+	if report.FolderIDs[7] != 70 || report.TeamIDs[3] != 30 {
+		t.Errorf("IDs not recovered: %v %v", report.FolderIDs, report.TeamIDs)
+	}
+}
+
+func TestReportPrint(t *testing.T) {
+	report := &Report{
+		Changes: []Change{
+			{Resource{"", KindOrg, "Staging"}, Create},
+			{Resource{"Staging", KindFolder, "infra"}, Update},
+		},
+		Conflicts: []Conflict{{Resource{"Staging", KindDashboard, "nodes"}, "differs in the target [title]"}},
+		Warnings:  []string{"Staging: datasource Loki: no secret for password"},
+	}
+	buf := &bytes.Buffer{}
+	if err := report.Print(buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `+ org Staging
+~ Staging: folder infra
+! Staging: dashboard nodes: differs in the target [title]
+warning: Staging: datasource Loki: no secret for password
+Migration: 1 to create, 1 to update, 1 conflicts.
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
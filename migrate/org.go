@@ -0,0 +1,643 @@
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// orgMigrator migrates the content of an org.
+type orgMigrator struct {
+	*migrator
+	name               string
+	sourceID, targetID int64
+	// source and target are scoped to the org.
+	source, target *gapi.Client
+	// folderIDs maps folder UIDs to their IDs in the target.
+	folderIDs map[string]int64
+}
+
+func (o *orgMigrator) resource(kind Kind, name string) Resource {
+	return Resource{Org: o.name, Kind: kind, Name: name}
+}
+
+// isNew reports whether the org is to be created in a dry run, in which
+// case the target holds nothing of it yet.
+func (o *orgMigrator) isNew() bool {
+	return o.targetID < 0
+}
+
+func (o *orgMigrator) users(ctx context.Context) error {
+	users, err := o.source.OrgUsersContext(ctx, o.sourceID)
+	if err != nil {
+		return err
+	}
+	current := map[string]gapi.OrgUser{}
+	if !o.isNew() {
+		existing, err := o.target.OrgUsersContext(ctx, o.targetID)
+		if err != nil {
+			return err
+		}
+		for _, u := range existing {
+			current[u.Login] = u
+		}
+	}
+
+	for _, u := range users {
+		u := u
+		r := o.resource(KindOrgUser, u.Login)
+		userID, ok := o.userIDs[u.Login]
+		if !ok {
+			o.report.warn("%s: user not migrated", r)
+			continue
+		}
+
+		existing, ok := current[u.Login]
+		switch {
+		case !ok:
+			err = o.apply(r, Create, func() error {
+				return o.target.AddOrgUserContext(ctx, o.targetID, u.Login, u.Role)
+			})
+		case existing.Role == u.Role || o.isDone(r):
+			continue
+		case !o.createdUsers[u.Login]:
+			// Users created by the migration get the default role of the
+			// default org, which isn't a conflict.
+			o.conflict(r, "role is %s in the target", existing.Role)
+			if !o.opts.Overwrite {
+				continue
+			}
+			fallthrough
+		default:
+			err = o.apply(r, Update, func() error {
+				return o.target.UpdateOrgUserContext(ctx, o.targetID, userID, u.Role)
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *orgMigrator) dataSources(ctx context.Context) error {
+	list, err := o.source.DataSourcesContext(ctx)
+	if err != nil {
+		return err
+	}
+	current := map[string]*gapi.DataSource{}
+	if !o.isNew() {
+		existing, err := o.target.DataSourcesContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ds := range existing {
+			current[ds.Name] = ds
+		}
+	}
+
+	for _, item := range list {
+		r := o.resource(KindDataSource, item.Name)
+		if o.isDone(r) {
+			continue
+		}
+		// Only single datasources list their secure fields.
+		ds, err := o.source.DataSourceContext(ctx, item.Id)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+
+		existing, ok := current[ds.Name]
+		if ok {
+			fields, err := diff(existing, ds, "id", "orgId", "password", "basicAuthPassword", "secureJsonData", "secureJsonFields")
+			if err != nil {
+				return fmt.Errorf("%s: %w", r, err)
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			o.conflict(r, "differs in the target %v", fields)
+			if !o.opts.Overwrite {
+				continue
+			}
+		}
+
+		if err := o.setSecrets(r, ds); err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+		ds.OrgId = 0
+		ds.SecureJSONFields = nil
+		if ok {
+			ds.Id = existing.Id
+			err = o.apply(r, Update, func() error {
+				return o.target.UpdateDataSourceContext(ctx, ds)
+			})
+		} else {
+			ds.Id = 0
+			err = o.apply(r, Create, func() error {
+				_, err := o.target.NewDataSourceContext(ctx, ds)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setSecrets sets the secure fields of a datasource to the secrets of the
+// options.
+func (o *orgMigrator) setSecrets(r Resource, ds *gapi.DataSource) error {
+	var fields []string
+	for field, set := range ds.SecureJSONFields {
+		if set {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	secrets := map[string]string{}
+	for _, field := range fields {
+		secret, ok := o.opts.Secrets[ds.Name+"/"+field]
+		if !ok {
+			o.report.warn("%s: no secret for %s", r, field)
+			continue
+		}
+		secrets[field] = secret
+	}
+
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	ds.SecureJSONData = gapi.SecureJSONData{}
+	return json.Unmarshal(data, &ds.SecureJSONData)
+}
+
+func (o *orgMigrator) alertNotifications(ctx context.Context) error {
+	notifications, err := o.source.AlertNotificationsContext(ctx)
+	if err != nil {
+		return err
+	}
+	current := map[string]gapi.AlertNotification{}
+	if !o.isNew() {
+		existing, err := o.target.AlertNotificationsContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, n := range existing {
+			current[n.Uid] = n
+		}
+	}
+
+	for _, n := range notifications {
+		n := n
+		r := o.resource(KindAlertNotification, n.Uid)
+		if o.isDone(r) {
+			continue
+		}
+
+		existing, ok := current[n.Uid]
+		if ok {
+			fields, err := diff(existing, n, "id")
+			if err != nil {
+				return fmt.Errorf("%s: %w", r, err)
+			}
+			if len(fields) == 0 {
+				continue
+			}
+			o.conflict(r, "differs in the target %v", fields)
+			if !o.opts.Overwrite {
+				continue
+			}
+			n.Id = existing.Id
+			err = o.apply(r, Update, func() error {
+				return o.target.UpdateAlertNotificationContext(ctx, &n)
+			})
+		} else {
+			n.Id = 0
+			err = o.apply(r, Create, func() error {
+				_, err := o.target.NewAlertNotificationContext(ctx, &n)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *orgMigrator) teams(ctx context.Context) error {
+	var teams []*gapi.Team
+	err := o.source.ForEachTeamContext(ctx, "", func(t *gapi.Team) error {
+		teams = append(teams, t)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	current := map[string]*gapi.Team{}
+	if !o.isNew() {
+		err := o.target.ForEachTeamContext(ctx, "", func(t *gapi.Team) error {
+			current[t.Name] = t
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range teams {
+		r := o.resource(KindTeam, t.Name)
+		existing, ok := current[t.Name]
+		var id int64
+		if ok {
+			id = existing.Id
+			if existing.Email != t.Email && !o.isDone(r) {
+				o.conflict(r, "email is %q in the target", existing.Email)
+				if o.opts.Overwrite {
+					err = o.apply(r, Update, func() error {
+						return o.target.UpdateTeamContext(ctx, id, t.Name, t.Email)
+					})
+				}
+			}
+		} else {
+			id = o.placeholderID()
+			err = o.apply(r, Create, func() (err error) {
+				if err := o.target.AddTeamContext(ctx, t.Name, t.Email); err != nil {
+					return err
+				}
+				// Creating a team doesn't return its ID, so look it up.
+				id, err = o.teamID(ctx, t.Name)
+				return err
+			})
+		}
+		if err != nil {
+			return err
+		}
+		o.report.TeamIDs[t.Id] = id
+
+		if err := o.teamMembers(ctx, t, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *orgMigrator) teamID(ctx context.Context, name string) (int64, error) {
+	var id int64
+	err := o.target.ForEachTeamContext(ctx, name, func(t *gapi.Team) error {
+		if t.Name == name {
+			id = t.Id
+			return gapi.ErrStopPaging
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("team %q not found after creation", name)
+	}
+	return id, nil
+}
+
+func (o *orgMigrator) teamMembers(ctx context.Context, t *gapi.Team, id int64) error {
+	members, err := o.source.TeamMembersContext(ctx, t.Id)
+	if err != nil {
+		return fmt.Errorf("team %q members: %w", t.Name, err)
+	}
+	isMember := map[int64]bool{}
+	if id > 0 {
+		current, err := o.target.TeamMembersContext(ctx, id)
+		if err != nil {
+			return fmt.Errorf("team %q members: %w", t.Name, err)
+		}
+		for _, m := range current {
+			isMember[m.UserId] = true
+		}
+	}
+
+	for _, m := range members {
+		r := o.resource(KindTeamMember, t.Name+"/"+m.Login)
+		userID, ok := o.userIDs[m.Login]
+		if !ok {
+			o.report.warn("%s: user not migrated", r)
+			continue
+		}
+		if isMember[userID] {
+			continue
+		}
+		err := o.apply(r, Create, func() error {
+			return o.target.AddTeamMemberContext(ctx, id, userID)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *orgMigrator) folders(ctx context.Context) error {
+	folders, err := o.source.FoldersContext(ctx)
+	if err != nil {
+		return err
+	}
+	current := map[string]gapi.Folder{}
+	if !o.isNew() {
+		existing, err := o.target.FoldersContext(ctx)
+		if err != nil {
+			return err
+		}
+		for _, f := range existing {
+			current[f.Uid] = f
+		}
+	}
+
+	for _, f := range folders {
+		f := f
+		r := o.resource(KindFolder, f.Uid)
+		existing, ok := current[f.Uid]
+		// Permissions of folders the migration created or overwrote are
+		// replaced without a conflict.
+		owned := !ok || o.isDone(r)
+
+		id := existing.Id
+		if !ok {
+			id = o.placeholderID()
+			err = o.apply(r, Create, func() error {
+				folder, err := o.target.NewFolderWithUIDContext(ctx, f.Title, f.Uid)
+				id = folder.Id
+				return err
+			})
+		} else if existing.Title != f.Title && !o.isDone(r) {
+			o.conflict(r, "title is %q in the target", existing.Title)
+			if o.opts.Overwrite {
+				owned = true
+				err = o.apply(r, Update, func() error {
+					return o.target.UpdateFolderContext(ctx, f.Uid, f.Title)
+				})
+			}
+		}
+		if err != nil {
+			return err
+		}
+		o.folderIDs[f.Uid] = id
+		o.report.FolderIDs[f.Id] = id
+
+		if err := o.folderPermissions(ctx, f, id, owned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *orgMigrator) folderPermissions(ctx context.Context, f gapi.Folder, id int64, owned bool) error {
+	r := o.resource(KindFolderPermissions, f.Uid)
+	if o.isDone(r) {
+		return nil
+	}
+	permissions, err := o.source.FolderPermissionsContext(ctx, f.Uid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r, err)
+	}
+	source := make([]grant, 0, len(permissions))
+	for _, p := range permissions {
+		source = append(source, grant{p.Role, p.TeamId, p.Team, p.UserId, p.UserLogin, p.Permission})
+	}
+
+	var current []grant
+	if id > 0 {
+		permissions, err := o.target.FolderPermissionsContext(ctx, f.Uid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+		for _, p := range permissions {
+			current = append(current, grant{p.Role, p.TeamId, p.Team, p.UserId, p.UserLogin, p.Permission})
+		}
+	}
+
+	return o.permissions(r, source, current, owned, func(items *gapi.PermissionItems) error {
+		return o.target.UpdateFolderPermissionsContext(ctx, f.Uid, items)
+	})
+}
+
+func (o *orgMigrator) dashboards(ctx context.Context) error {
+	results, err := o.source.SearchDashboardsContext(ctx, gapi.SearchQuery{Type: gapi.SearchTypeDashboard})
+	if err != nil {
+		return err
+	}
+	current := map[string]gapi.DashboardSearchResponse{}
+	if !o.isNew() {
+		existing, err := o.target.SearchDashboardsContext(ctx, gapi.SearchQuery{Type: gapi.SearchTypeDashboard})
+		if err != nil {
+			return err
+		}
+		for _, d := range existing {
+			current[d.Uid] = d
+		}
+	}
+
+	for _, res := range results {
+		r := o.resource(KindDashboard, res.Uid)
+		folderID, ok := o.folderIDs[res.FolderUid]
+		if !ok && res.FolderUid != "" {
+			return fmt.Errorf("%s: folder %q not migrated", r, res.FolderUid)
+		}
+
+		existing, ok := current[res.Uid]
+		owned := !ok || o.isDone(r)
+		id := int64(existing.Id)
+		if !o.isDone(r) {
+			dashboard, err := o.source.DashboardByUidContext(ctx, res.Uid)
+			if err != nil {
+				return fmt.Errorf("%s: %w", r, err)
+			}
+
+			action := Create
+			if ok {
+				fields, err := o.dashboardChanges(ctx, existing, dashboard.Model, folderID)
+				if err != nil {
+					return fmt.Errorf("%s: %w", r, err)
+				}
+				action = ""
+				if len(fields) > 0 {
+					o.conflict(r, "differs in the target %v", fields)
+					if o.opts.Overwrite {
+						action = Update
+					}
+				}
+			}
+
+			if action != "" {
+				owned = true
+				if !ok {
+					id = o.placeholderID()
+				}
+				err = o.apply(r, action, func() error {
+					resp, err := o.saveDashboard(ctx, dashboard.Model, folderID, ok)
+					if resp != nil {
+						id = resp.Id
+					}
+					return err
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		o.report.DashboardIDs[int64(res.Id)] = id
+
+		if err := o.dashboardPermissions(ctx, res.Uid, id, owned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dashboardChanges returns the top-level fields of a dashboard of the
+// target that differ from the model, and "folder" when it's in another
+// folder.
+func (o *orgMigrator) dashboardChanges(ctx context.Context, existing gapi.DashboardSearchResponse, model map[string]interface{}, folderID int64) ([]string, error) {
+	dashboard, err := o.target.DashboardByUidContext(ctx, existing.Uid)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := diff(dashboard.Model, model, "id", "version")
+	if err != nil {
+		return nil, err
+	}
+	if int64(existing.FolderId) != folderID {
+		fields = append(fields, "folder")
+	}
+	return fields, nil
+}
+
+func (o *orgMigrator) saveDashboard(ctx context.Context, model map[string]interface{}, folderID int64, overwrite bool) (*gapi.DashboardSaveResponse, error) {
+	m := make(map[string]interface{}, len(model))
+	for key, value := range model {
+		m[key] = value
+	}
+	m["id"] = nil
+	delete(m, "version")
+
+	return o.target.NewDashboardContext(ctx, gapi.Dashboard{
+		Model:     m,
+		Folder:    folderID,
+		Overwrite: overwrite,
+	})
+}
+
+func (o *orgMigrator) dashboardPermissions(ctx context.Context, uid string, id int64, owned bool) error {
+	r := o.resource(KindDashboardPermissions, uid)
+	if o.isDone(r) {
+		return nil
+	}
+	// Inherited permissions are migrated with the folder.
+	grants := func(permissions []*gapi.DashboardPermission) []grant {
+		var grants []grant
+		for _, p := range permissions {
+			if !p.Inherited {
+				grants = append(grants, grant{p.Role, p.TeamId, p.Team, p.UserId, p.UserLogin, p.Permission})
+			}
+		}
+		return grants
+	}
+
+	permissions, err := o.source.DashboardPermissionsByUidContext(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r, err)
+	}
+	source := grants(permissions)
+
+	var current []grant
+	if id > 0 {
+		permissions, err := o.target.DashboardPermissionsByUidContext(ctx, uid)
+		if err != nil {
+			return fmt.Errorf("%s: %w", r, err)
+		}
+		current = grants(permissions)
+	}
+
+	return o.permissions(r, source, current, owned, func(items *gapi.PermissionItems) error {
+		return o.target.UpdateDashboardPermissionsByUidContext(ctx, uid, items)
+	})
+}
+
+// grant is a permission of a folder or dashboard.
+type grant struct {
+	role       string
+	teamID     int64
+	team       string
+	userID     int64
+	login      string
+	permission gapi.PermissionLevel
+}
+
+func (g grant) item() *gapi.PermissionItem {
+	return &gapi.PermissionItem{Role: g.role, TeamId: g.teamID, UserId: g.userID, Permission: g.permission}
+}
+
+// permissions replaces the permissions of a folder or dashboard of the
+// target with those of the source, remapping team and user IDs, when they
+// differ. Differences are conflicts unless the migration owns the resource.
+func (o *orgMigrator) permissions(r Resource, source, current []grant, owned bool, update func(*gapi.PermissionItems) error) error {
+	desired := make([]*gapi.PermissionItem, 0, len(source))
+	for _, g := range source {
+		item := g.item()
+		switch {
+		case g.teamID != 0:
+			id, ok := o.report.TeamIDs[g.teamID]
+			if !ok {
+				o.report.warn("%s: team %q not migrated", r, g.team)
+				continue
+			}
+			item.TeamId = id
+		case g.userID != 0:
+			id, ok := o.userIDs[g.login]
+			if !ok {
+				o.report.warn("%s: user %q not migrated", r, g.login)
+				continue
+			}
+			item.UserId = id
+		}
+		desired = append(desired, item)
+	}
+
+	existing := make([]*gapi.PermissionItem, 0, len(current))
+	for _, g := range current {
+		existing = append(existing, g.item())
+	}
+	if sameItems(existing, desired) {
+		return nil
+	}
+	if !owned {
+		o.conflict(r, "differ in the target")
+		if !o.opts.Overwrite {
+			return nil
+		}
+	}
+	return o.apply(r, Update, func() error {
+		return update(&gapi.PermissionItems{Items: desired})
+	})
+}
+
+// sameItems reports whether two lists hold the same permission items, in
+// any order.
+func sameItems(a, b []*gapi.PermissionItem) bool {
+	key := func(items []*gapi.PermissionItem) []string {
+		keys := make([]string, 0, len(items))
+		for _, item := range items {
+			keys = append(keys, fmt.Sprintf("%s/%d/%d/%d", item.Role, item.TeamId, item.UserId, item.Permission))
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	return reflect.DeepEqual(key(a), key(b))
+}
@@ -33,13 +33,12 @@ func gapiTestTools(code int, body string) (*mockServer, *Client) {
 		},
 	}
 
-	httpClient := &http.Client{Transport: tr}
-
-	url := url.URL{
-		Scheme: "http",
-		Host:   "my-grafana.com",
+	client, err := NewWithOptions("http://my-grafana.com",
+		WithAPIKey("my-key"),
+		WithTransport(tr),
+	)
+	if err != nil {
+		panic(err)
 	}
-
-	client := &Client{"my-key", url, httpClient}
 	return mock, client
 }
@@ -0,0 +1,247 @@
+package gapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// Option configures a Client created with NewWithOptions.
+type Option func(*clientOptions) error
+
+type clientOptions struct {
+	httpClient *http.Client
+	transport  http.RoundTripper
+	tlsConfig  *tls.Config
+	timeout    time.Duration
+	headers    http.Header
+	auth       Authenticator
+	orgID      int64
+	logger     Logger
+	retry      *RetryPolicy
+	pageSize   int
+}
+
+// NewWithOptions creates a new grafana client for the Grafana instance at baseURL.
+func NewWithOptions(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &clientOptions{
+		headers: http.Header{},
+	}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	httpClient, err := o.buildHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if b, ok := o.auth.(clientBinder); ok {
+		if err := b.bind(*u, httpClient, o.headers); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Client{
+		auth:     o.auth,
+		baseURL:  *u,
+		Client:   httpClient,
+		Retry:    o.retry,
+		headers:  o.headers,
+		orgID:    o.orgID,
+		logger:   o.logger,
+		pageSize: o.pageSize,
+	}, nil
+}
+
+func (o *clientOptions) buildHTTPClient() (*http.Client, error) {
+	var httpClient *http.Client
+	if o.httpClient != nil {
+		// Copy the caller's client so the options below don't modify it.
+		c := *o.httpClient
+		httpClient = &c
+	} else {
+		httpClient = cleanhttp.DefaultClient()
+	}
+
+	if o.transport != nil {
+		httpClient.Transport = o.transport
+	}
+
+	if o.tlsConfig != nil {
+		var transport *http.Transport
+		switch t := httpClient.Transport.(type) {
+		case nil:
+			transport = cleanhttp.DefaultTransport()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			return nil, fmt.Errorf("TLS options require an *http.Transport, got %T", t)
+		}
+		transport.TLSClientConfig = o.tlsConfig
+		httpClient.Transport = transport
+	}
+
+	if o.timeout != 0 {
+		httpClient.Timeout = o.timeout
+	}
+
+	return httpClient, nil
+}
+
+func (o *clientOptions) tls() *tls.Config {
+	if o.tlsConfig == nil {
+		o.tlsConfig = &tls.Config{}
+	}
+	return o.tlsConfig
+}
+
+// WithHTTPClient sets the HTTP client used to make requests.
+// The client is copied, so other options never modify it.
+func WithHTTPClient(client *http.Client) Option {
+	return func(o *clientOptions) error {
+		o.httpClient = client
+		return nil
+	}
+}
+
+// WithTransport sets the RoundTripper used to make requests.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(o *clientOptions) error {
+		o.transport = transport
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to Grafana.
+// It replaces any TLS settings made by options applied before it.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(o *clientOptions) error {
+		o.tlsConfig = config.Clone()
+		return nil
+	}
+}
+
+// WithCACert adds the PEM encoded certificates to the pool used to verify
+// the Grafana server certificate.
+func WithCACert(pem []byte) Option {
+	return func(o *clientOptions) error {
+		config := o.tls()
+		if config.RootCAs == nil {
+			config.RootCAs = x509.NewCertPool()
+		}
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no valid CA certificates found")
+		}
+		return nil
+	}
+}
+
+// WithClientCert sets the PEM encoded certificate and key presented to Grafana.
+func WithClientCert(certPEM, keyPEM []byte) Option {
+	return func(o *clientOptions) error {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return err
+		}
+		config := o.tls()
+		config.Certificates = append(config.Certificates, cert)
+		return nil
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the Grafana server certificate.
+func WithInsecureSkipVerify() Option {
+	return func(o *clientOptions) error {
+		o.tls().InsecureSkipVerify = true
+		return nil
+	}
+}
+
+// WithTimeout sets the time limit for requests made by the client.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *clientOptions) error {
+		o.timeout = timeout
+		return nil
+	}
+}
+
+// WithHeader adds a header sent with every request.
+func WithHeader(key, value string) Option {
+	return func(o *clientOptions) error {
+		o.headers.Add(key, value)
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) Option {
+	return func(o *clientOptions) error {
+		o.headers.Set("User-Agent", userAgent)
+		return nil
+	}
+}
+
+// WithAuth sets the Authenticator used to add credentials to requests.
+func WithAuth(auth Authenticator) Option {
+	return func(o *clientOptions) error {
+		o.auth = auth
+		return nil
+	}
+}
+
+// WithBasicAuth authenticates requests with a username and password.
+func WithBasicAuth(username, password string) Option {
+	return WithAuth(BasicAuth(username, password))
+}
+
+// WithAPIKey authenticates requests with an API key.
+func WithAPIKey(key string) Option {
+	return WithAuth(TokenAuth(key))
+}
+
+// WithOrgID scopes requests to the org whose ID it's passed.
+func WithOrgID(id int64) Option {
+	return func(o *clientOptions) error {
+		o.orgID = id
+		return nil
+	}
+}
+
+// WithLogger sets the logger requests and responses are logged to.
+func WithLogger(logger Logger) Option {
+	return func(o *clientOptions) error {
+		o.logger = logger
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests.
+func WithRetryPolicy(policy *RetryPolicy) Option {
+	return func(o *clientOptions) error {
+		o.retry = policy
+		return nil
+	}
+}
+
+// WithPageSize sets the number of items requested per page when walking
+// paginated endpoints. It defaults to 1000.
+func WithPageSize(size int) Option {
+	return func(o *clientOptions) error {
+		o.pageSize = size
+		return nil
+	}
+}
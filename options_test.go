@@ -0,0 +1,118 @@
+package gapi
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions_headers(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client, err := NewWithOptions(server.URL,
+		WithAPIKey("abc:def"),
+		WithHeader("X-Custom", "value"),
+		WithUserAgent("gapi-test"),
+		WithOrgID(3),
+		WithTimeout(time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Orgs(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]string{
+		"Authorization":    "Bearer abc:def",
+		"X-Custom":         "value",
+		"User-Agent":       "gapi-test",
+		"X-Grafana-Org-Id": "3",
+	} {
+		if got.Get(key) != expected {
+			t.Errorf("expected %s header: %s; got: %s", key, expected, got.Get(key))
+		}
+	}
+
+	if client.Timeout != time.Second {
+		t.Errorf("expected timeout: %v; got: %v", time.Second, client.Timeout)
+	}
+}
+
+func TestNewWithOptions_tls(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	client, err := NewWithOptions(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Orgs(); err == nil {
+		t.Error("expected unknown certificate authority to be rejected")
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	client, err = NewWithOptions(server.URL, WithCACert(caPEM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Orgs(); err != nil {
+		t.Error(err)
+	}
+
+	client, err = NewWithOptions(server.URL, WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Orgs(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewWithOptions_httpClient(t *testing.T) {
+	httpClient := &http.Client{}
+	client, err := NewWithOptions("http://my-grafana.com",
+		WithHTTPClient(httpClient),
+		WithTimeout(time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if client.Client == httpClient || httpClient.Timeout != 0 {
+		t.Error("expected the HTTP client to be copied")
+	}
+	if client.Timeout != time.Minute {
+		t.Errorf("expected timeout: %v; got: %v", time.Minute, client.Timeout)
+	}
+}
+
+func TestNewWithOptions_errors(t *testing.T) {
+	if _, err := NewWithOptions("http://my-grafana.com", WithCACert([]byte("nope"))); err == nil {
+		t.Error("expected invalid CA certificate to be rejected")
+	}
+
+	_, err := NewWithOptions("http://my-grafana.com",
+		WithTransport(roundTripperFunc(http.DefaultTransport.RoundTrip)),
+		WithInsecureSkipVerify(),
+	)
+	if err == nil {
+		t.Error("expected TLS options to require an *http.Transport")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -17,8 +18,30 @@ type OrgUser struct {
 
 // OrgUsers fetches and returns the users for the org whose ID it's passed.
 func (c *Client) OrgUsers(orgID int64) ([]OrgUser, error) {
+	return c.OrgUsersContext(context.Background(), orgID)
+}
+
+// OrgUsersContext is like OrgUsers but takes a context.
+func (c *Client) OrgUsersContext(ctx context.Context, orgID int64) ([]OrgUser, error) {
+	users := make([]OrgUser, 0)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/orgs/%d/users", orgID), nil, nil, &users)
+	if err != nil {
+		return users, err
+	}
+
+	return users, err
+}
+
+// OrgUsersCurrent fetches and returns the users of the org the client is
+// scoped to, or of the current org of the authenticated user.
+func (c *Client) OrgUsersCurrent() ([]OrgUser, error) {
+	return c.OrgUsersCurrentContext(context.Background())
+}
+
+// OrgUsersCurrentContext is like OrgUsersCurrent but takes a context.
+func (c *Client) OrgUsersCurrentContext(ctx context.Context) ([]OrgUser, error) {
 	users := make([]OrgUser, 0)
-	err := c.request("GET", fmt.Sprintf("/api/orgs/%d/users", orgID), nil, nil, &users)
+	err := c.request(ctx, "GET", "/api/org/users", nil, nil, &users)
 	if err != nil {
 		return users, err
 	}
@@ -28,6 +51,11 @@ func (c *Client) OrgUsers(orgID int64) ([]OrgUser, error) {
 
 // AddOrgUser adds a user to an org with the specified role.
 func (c *Client) AddOrgUser(orgID int64, user, role string) error {
+	return c.AddOrgUserContext(context.Background(), orgID, user, role)
+}
+
+// AddOrgUserContext is like AddOrgUser but takes a context.
+func (c *Client) AddOrgUserContext(ctx context.Context, orgID int64, user, role string) error {
 	dataMap := map[string]string{
 		"loginOrEmail": user,
 		"role":         role,
@@ -37,11 +65,16 @@ func (c *Client) AddOrgUser(orgID int64, user, role string) error {
 		return err
 	}
 
-	return c.request("POST", fmt.Sprintf("/api/orgs/%d/users", orgID), nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "POST", fmt.Sprintf("/api/orgs/%d/users", orgID), nil, bytes.NewBuffer(data), nil)
 }
 
 // UpdateOrgUser updates and org user.
 func (c *Client) UpdateOrgUser(orgID, userID int64, role string) error {
+	return c.UpdateOrgUserContext(context.Background(), orgID, userID, role)
+}
+
+// UpdateOrgUserContext is like UpdateOrgUser but takes a context.
+func (c *Client) UpdateOrgUserContext(ctx context.Context, orgID, userID int64, role string) error {
 	dataMap := map[string]string{
 		"role": role,
 	}
@@ -50,10 +83,15 @@ func (c *Client) UpdateOrgUser(orgID, userID int64, role string) error {
 		return err
 	}
 
-	return c.request("PATCH", fmt.Sprintf("/api/orgs/%d/users/%d", orgID, userID), nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PATCH", fmt.Sprintf("/api/orgs/%d/users/%d", orgID, userID), nil, bytes.NewBuffer(data), nil)
 }
 
 // RemoveOrgUser removes a user from an org.
 func (c *Client) RemoveOrgUser(orgID, userID int64) error {
-	return c.request("DELETE", fmt.Sprintf("/api/orgs/%d/users/%d", orgID, userID), nil, nil, nil)
+	return c.RemoveOrgUserContext(context.Background(), orgID, userID)
+}
+
+// RemoveOrgUserContext is like RemoveOrgUser but takes a context.
+func (c *Client) RemoveOrgUserContext(ctx context.Context, orgID, userID int64) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("/api/orgs/%d/users/%d", orgID, userID), nil, nil, nil)
 }
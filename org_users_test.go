@@ -38,6 +38,22 @@ func TestOrgUsers(t *testing.T) {
 	}
 }
 
+func TestOrgUsersCurrent(t *testing.T) {
+	server, client := gapiTestTools(200, getOrgUsersJSON)
+	defer server.Close()
+
+	resp, err := client.OrgUsersCurrent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(resp))
+
+	if len(resp) != 1 || resp[0].Login != "admin" {
+		t.Error("Not correctly parsing returned organization users.")
+	}
+}
+
 func TestAddOrgUser(t *testing.T) {
 	server, client := gapiTestTools(200, addOrgUserJSON)
 	defer server.Close()
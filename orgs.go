@@ -2,8 +2,11 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 )
 
 // Org represents a Grafana org.
@@ -14,8 +17,16 @@ type Org struct {
 
 // Orgs fetches and returns the Grafana orgs.
 func (c *Client) Orgs() ([]Org, error) {
+	return c.OrgsContext(context.Background())
+}
+
+// OrgsContext is like Orgs but takes a context.
+func (c *Client) OrgsContext(ctx context.Context) ([]Org, error) {
 	orgs := make([]Org, 0)
-	err := c.request("GET", "/api/orgs/", nil, nil, &orgs)
+	err := c.ForEachOrgContext(ctx, "", func(org Org) error {
+		orgs = append(orgs, org)
+		return nil
+	})
 	if err != nil {
 		return orgs, err
 	}
@@ -23,10 +34,50 @@ func (c *Client) Orgs() ([]Org, error) {
 	return orgs, err
 }
 
+// ForEachOrg calls fn for each Grafana org matching the query, walking all
+// pages of results. An empty query matches every org.
+// Return ErrStopPaging from fn to stop early.
+func (c *Client) ForEachOrg(query string, fn func(Org) error) error {
+	return c.ForEachOrgContext(context.Background(), query, fn)
+}
+
+// ForEachOrgContext is like ForEachOrg but takes a context.
+func (c *Client) ForEachOrgContext(ctx context.Context, query string, fn func(Org) error) error {
+	perPage := c.perPage()
+
+	return paginate(ctx, func(page int) (bool, error) {
+		queryValues := url.Values{}
+		queryValues.Set("perpage", strconv.Itoa(perPage))
+		queryValues.Set("page", strconv.Itoa(page))
+		if query != "" {
+			queryValues.Set("query", query)
+		}
+
+		result := make([]Org, 0)
+		err := c.request(ctx, "GET", "/api/orgs/", queryValues, nil, &result)
+		if err != nil {
+			return false, err
+		}
+
+		for _, org := range result {
+			if err := fn(org); err != nil {
+				return false, err
+			}
+		}
+
+		return morePages(page, perPage, len(result), 0), nil
+	})
+}
+
 // OrgByName fetches and returns the org whose name it's passed.
 func (c *Client) OrgByName(name string) (Org, error) {
+	return c.OrgByNameContext(context.Background(), name)
+}
+
+// OrgByNameContext is like OrgByName but takes a context.
+func (c *Client) OrgByNameContext(ctx context.Context, name string) (Org, error) {
 	org := Org{}
-	err := c.request("GET", fmt.Sprintf("/api/orgs/name/%s", name), nil, nil, &org)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/orgs/name/%s", name), nil, nil, &org)
 	if err != nil {
 		return org, err
 	}
@@ -36,8 +87,13 @@ func (c *Client) OrgByName(name string) (Org, error) {
 
 // Org fetches and returns the org whose ID it's passed.
 func (c *Client) Org(id int64) (Org, error) {
+	return c.OrgContext(context.Background(), id)
+}
+
+// OrgContext is like Org but takes a context.
+func (c *Client) OrgContext(ctx context.Context, id int64) (Org, error) {
 	org := Org{}
-	err := c.request("GET", fmt.Sprintf("/api/orgs/%d", id), nil, nil, &org)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/orgs/%d", id), nil, nil, &org)
 	if err != nil {
 		return org, err
 	}
@@ -47,6 +103,11 @@ func (c *Client) Org(id int64) (Org, error) {
 
 // NewOrg creates a new Grafana org.
 func (c *Client) NewOrg(name string) (int64, error) {
+	return c.NewOrgContext(context.Background(), name)
+}
+
+// NewOrgContext is like NewOrg but takes a context.
+func (c *Client) NewOrgContext(ctx context.Context, name string) (int64, error) {
 	id := int64(0)
 
 	dataMap := map[string]string{
@@ -60,7 +121,7 @@ func (c *Client) NewOrg(name string) (int64, error) {
 		Id int64 `json:"orgId"`
 	}{}
 
-	err = c.request("POST", "/api/orgs", nil, bytes.NewBuffer(data), &tmp)
+	err = c.request(ctx, "POST", "/api/orgs", nil, bytes.NewBuffer(data), &tmp)
 	if err != nil {
 		return id, err
 	}
@@ -70,6 +131,11 @@ func (c *Client) NewOrg(name string) (int64, error) {
 
 // UpdateOrg updates a Grafana org.
 func (c *Client) UpdateOrg(id int64, name string) error {
+	return c.UpdateOrgContext(context.Background(), id, name)
+}
+
+// UpdateOrgContext is like UpdateOrg but takes a context.
+func (c *Client) UpdateOrgContext(ctx context.Context, id int64, name string) error {
 	dataMap := map[string]string{
 		"name": name,
 	}
@@ -78,10 +144,48 @@ func (c *Client) UpdateOrg(id int64, name string) error {
 		return err
 	}
 
-	return c.request("PUT", fmt.Sprintf("/api/orgs/%d", id), nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PUT", fmt.Sprintf("/api/orgs/%d", id), nil, bytes.NewBuffer(data), nil)
 }
 
 // DeleteOrg deletes the Grafana org whose ID it's passed.
 func (c *Client) DeleteOrg(id int64) error {
-	return c.request("DELETE", fmt.Sprintf("/api/orgs/%d", id), nil, nil, nil)
+	return c.DeleteOrgContext(context.Background(), id)
+}
+
+// DeleteOrgContext is like DeleteOrg but takes a context.
+func (c *Client) DeleteOrgContext(ctx context.Context, id int64) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("/api/orgs/%d", id), nil, nil, nil)
+}
+
+// OrgPreferences fetches and returns the preferences of the org the client
+// is scoped to, or of the current org of the authenticated user.
+func (c *Client) OrgPreferences() (*Preferences, error) {
+	return c.OrgPreferencesContext(context.Background())
+}
+
+// OrgPreferencesContext is like OrgPreferences but takes a context.
+func (c *Client) OrgPreferencesContext(ctx context.Context) (*Preferences, error) {
+	preferences := &Preferences{}
+	err := c.request(ctx, "GET", "/api/org/preferences", nil, nil, preferences)
+	if err != nil {
+		return nil, err
+	}
+
+	return preferences, nil
+}
+
+// UpdateOrgPreferences updates the preferences of the org the client is
+// scoped to, or of the current org of the authenticated user.
+func (c *Client) UpdateOrgPreferences(preferences Preferences) error {
+	return c.UpdateOrgPreferencesContext(context.Background(), preferences)
+}
+
+// UpdateOrgPreferencesContext is like UpdateOrgPreferences but takes a context.
+func (c *Client) UpdateOrgPreferencesContext(ctx context.Context, preferences Preferences) error {
+	data, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+
+	return c.request(ctx, "PUT", "/api/org/preferences", nil, bytes.NewBuffer(data), nil)
 }
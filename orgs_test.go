@@ -102,3 +102,26 @@ func TestDeleteOrg(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestOrgPreferences(t *testing.T) {
+	server, client := gapiTestTools(200, `{"theme":"dark","homeDashboardId":12,"timezone":"utc"}`)
+	defer server.Close()
+
+	preferences, err := client.OrgPreferences()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(pretty.PrettyFormat(preferences))
+
+	if preferences.Theme != "dark" || preferences.HomeDashboardId != 12 || preferences.Timezone != "utc" {
+		t.Error("Not correctly parsing returned preferences.")
+	}
+
+	for _, code := range []int{400, 401, 403, 500} {
+		server.code = code
+		if err := client.UpdateOrgPreferences(*preferences); err == nil {
+			t.Errorf("%d not detected", code)
+		}
+	}
+}
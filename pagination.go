@@ -0,0 +1,54 @@
+package gapi
+
+import (
+	"context"
+	"errors"
+)
+
+// defaultPageSize is the number of items requested per page from paginated
+// endpoints. It matches Grafana's default for most of them.
+const defaultPageSize = 1000
+
+// ErrStopPaging can be returned by the callback passed to the ForEach methods
+// to stop walking the pages early. The ForEach method then returns nil.
+var ErrStopPaging = errors.New("stop paging")
+
+// paginate calls fetch with successive page numbers, starting from 1, until
+// fetch reports that there are no more pages or returns an error.
+func paginate(ctx context.Context, fetch func(page int) (more bool, err error)) error {
+	for page := 1; ; page++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		more, err := fetch(page)
+		if errors.Is(err, ErrStopPaging) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+// morePages reports whether a page is followed by another one, given how many
+// items it held and, when the endpoint reports it, the total count.
+func morePages(page, perPage, count int, total int64) bool {
+	if count < perPage {
+		return false
+	}
+	if total > 0 && int64(page*perPage) >= total {
+		return false
+	}
+	return true
+}
+
+func (c *Client) perPage() int {
+	if c.pageSize > 0 {
+		return c.pageSize
+	}
+	return defaultPageSize
+}
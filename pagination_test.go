@@ -0,0 +1,205 @@
+package gapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// pagingTestTools serves five items from each paginated endpoint.
+func pagingTestTools(t *testing.T) (*httptest.Server, *Client, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		perPage, _ := strconv.Atoi(q.Get("perPage") + q.Get("perpage") + q.Get("limit"))
+
+		var ids []int64
+		for id := int64((page-1)*perPage + 1); id <= 5 && len(ids) < perPage; id++ {
+			ids = append(ids, id)
+		}
+
+		var body interface{}
+		switch r.URL.Path {
+		case "/api/teams/search":
+			teams := []*Team{}
+			for _, id := range ids {
+				teams = append(teams, &Team{Id: id})
+			}
+			body = SearchTeam{TotalCount: 5, Teams: teams}
+		case "/api/users/search":
+			users := []User{}
+			for _, id := range ids {
+				users = append(users, User{Id: id})
+			}
+			body = map[string]interface{}{"totalCount": 5, "users": users}
+		case "/api/orgs":
+			orgs := []Org{}
+			for _, id := range ids {
+				orgs = append(orgs, Org{Id: id})
+			}
+			body = orgs
+		case "/api/search":
+			dashboards := []DashboardSearchResponse{}
+			for _, id := range ids {
+				dashboards = append(dashboards, DashboardSearchResponse{Id: uint(id)})
+			}
+			body = dashboards
+		case "/api/annotations":
+			// Annotations 5 to 1, at times 50 to 10, with 3 and 2 sharing a time.
+			to, err := strconv.ParseInt(q.Get("to"), 10, 64)
+			if err != nil {
+				to = 100
+			}
+			annotations := []Annotation{}
+			for id := int64(5); id >= 1 && len(annotations) < perPage; id-- {
+				a := Annotation{ID: id, Time: id * 10}
+				if id == 2 {
+					a.Time = 30
+				}
+				if a.Time <= to {
+					annotations = append(annotations, a)
+				}
+			}
+			body = annotations
+		default:
+			t.Fatalf("unexpected path: %s", r.URL.Path)
+		}
+		json.NewEncoder(w).Encode(body)
+	}))
+
+	client, err := NewWithOptions(server.URL, WithPageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client, &requests
+}
+
+func TestPagination(t *testing.T) {
+	server, client, _ := pagingTestTools(t)
+	defer server.Close()
+
+	for name, walk := range map[string]func(fn func(int64)) error{
+		"teams": func(fn func(int64)) error {
+			return client.ForEachTeam("", func(team *Team) error { fn(team.Id); return nil })
+		},
+		"users": func(fn func(int64)) error {
+			return client.ForEachUser("", func(user User) error { fn(user.Id); return nil })
+		},
+		"orgs": func(fn func(int64)) error {
+			return client.ForEachOrg("", func(org Org) error { fn(org.Id); return nil })
+		},
+		"dashboards": func(fn func(int64)) error {
+			return client.ForEachDashboard(url.Values{}, func(d DashboardSearchResponse) error { fn(int64(d.Id)); return nil })
+		},
+		"annotations": func(fn func(int64)) error {
+			return client.ForEachAnnotation(url.Values{}, func(a Annotation) error { fn(6 - a.ID); return nil })
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var ids []int64
+			err := walk(func(id int64) { ids = append(ids, id) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ids) != 5 {
+				t.Fatalf("expected 5 items; got: %v", ids)
+			}
+			for i, id := range ids {
+				if id != int64(i+1) {
+					t.Errorf("expected item %d; got: %d", i+1, id)
+				}
+			}
+		})
+	}
+}
+
+func TestForEachAnnotation_sameTime(t *testing.T) {
+	// Annotations 6 to 2 share a time, more than fit in a page.
+	all := []Annotation{{ID: 6, Time: 20}, {ID: 5, Time: 20}, {ID: 4, Time: 20}, {ID: 3, Time: 20}, {ID: 2, Time: 20}, {ID: 1, Time: 10}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		to, err := strconv.ParseInt(q.Get("to"), 10, 64)
+		if err != nil {
+			to = 100
+		}
+		annotations := []Annotation{}
+		for _, a := range all {
+			if a.Time <= to && len(annotations) < limit {
+				annotations = append(annotations, a)
+			}
+		}
+		json.NewEncoder(w).Encode(annotations)
+	}))
+	defer server.Close()
+	client, err := NewWithOptions(server.URL, WithPageSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int64
+	err = client.ForEachAnnotation(url.Values{}, func(a Annotation) error {
+		ids = append(ids, a.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 6 || ids[5] != 1 {
+		t.Errorf("expected 6 annotations; got: %v", ids)
+	}
+}
+
+func TestPagination_stop(t *testing.T) {
+	server, client, requests := pagingTestTools(t)
+	defer server.Close()
+
+	count := 0
+	err := client.ForEachTeam("", func(team *Team) error {
+		count++
+		if count == 3 {
+			return ErrStopPaging
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || *requests != 2 {
+		t.Errorf("expected 3 teams from 2 requests; got: %d from %d", count, *requests)
+	}
+}
+
+func TestPagination_aggregates(t *testing.T) {
+	server, client, _ := pagingTestTools(t)
+	defer server.Close()
+
+	teams, err := client.SearchTeam("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if teams.TotalCount != 5 || len(teams.Teams) != 5 {
+		t.Errorf("expected 5 teams; got: %d", len(teams.Teams))
+	}
+
+	orgs, err := client.Orgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orgs) != 5 {
+		t.Errorf("expected 5 orgs; got: %d", len(orgs))
+	}
+
+	dashboards, err := client.Dashboards()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dashboards) != 5 {
+		t.Errorf("expected 5 dashboards; got: %d", len(dashboards))
+	}
+}
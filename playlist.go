@@ -2,6 +2,7 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 )
@@ -22,11 +23,33 @@ type Playlist struct {
 	Items    []PlaylistItem `json:"items"`
 }
 
+// Playlists fetches and returns the Grafana playlists of the org.
+// Their items aren't listed; fetch them with Playlist.
+func (c *Client) Playlists() ([]Playlist, error) {
+	return c.PlaylistsContext(context.Background())
+}
+
+// PlaylistsContext is like Playlists but takes a context.
+func (c *Client) PlaylistsContext(ctx context.Context) ([]Playlist, error) {
+	playlists := make([]Playlist, 0)
+	err := c.request(ctx, "GET", "/api/playlists", nil, nil, &playlists)
+	if err != nil {
+		return nil, err
+	}
+
+	return playlists, nil
+}
+
 // Playlist fetches and returns a Grafana playlist.
 func (c *Client) Playlist(id int) (*Playlist, error) {
+	return c.PlaylistContext(context.Background(), id)
+}
+
+// PlaylistContext is like Playlist but takes a context.
+func (c *Client) PlaylistContext(ctx context.Context, id int) (*Playlist, error) {
 	path := fmt.Sprintf("/api/playlists/%d", id)
 	playlist := &Playlist{}
-	err := c.request("GET", path, nil, nil, playlist)
+	err := c.request(ctx, "GET", path, nil, nil, playlist)
 	if err != nil {
 		return nil, err
 	}
@@ -36,6 +59,11 @@ func (c *Client) Playlist(id int) (*Playlist, error) {
 
 // NewPlaylist creates a new Grafana playlist.
 func (c *Client) NewPlaylist(playlist Playlist) (int, error) {
+	return c.NewPlaylistContext(context.Background(), playlist)
+}
+
+// NewPlaylistContext is like NewPlaylist but takes a context.
+func (c *Client) NewPlaylistContext(ctx context.Context, playlist Playlist) (int, error) {
 	data, err := json.Marshal(playlist)
 	if err != nil {
 		return 0, err
@@ -45,7 +73,7 @@ func (c *Client) NewPlaylist(playlist Playlist) (int, error) {
 		Id int
 	}{}
 
-	err = c.request("POST", "/api/playlists", nil, bytes.NewBuffer(data), &result)
+	err = c.request(ctx, "POST", "/api/playlists", nil, bytes.NewBuffer(data), &result)
 	if err != nil {
 		return 0, err
 	}
@@ -55,18 +83,28 @@ func (c *Client) NewPlaylist(playlist Playlist) (int, error) {
 
 // UpdatePlaylist updates a Grafana playlist.
 func (c *Client) UpdatePlaylist(playlist Playlist) error {
+	return c.UpdatePlaylistContext(context.Background(), playlist)
+}
+
+// UpdatePlaylistContext is like UpdatePlaylist but takes a context.
+func (c *Client) UpdatePlaylistContext(ctx context.Context, playlist Playlist) error {
 	path := fmt.Sprintf("/api/playlists/%d", playlist.Id)
 	data, err := json.Marshal(playlist)
 	if err != nil {
 		return err
 	}
 
-	return c.request("PUT", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), nil)
 }
 
 // DeletePlaylist deletes the Grafana playlist whose ID it's passed.
 func (c *Client) DeletePlaylist(id int) error {
+	return c.DeletePlaylistContext(context.Background(), id)
+}
+
+// DeletePlaylistContext is like DeletePlaylist but takes a context.
+func (c *Client) DeletePlaylistContext(ctx context.Context, id int) error {
 	path := fmt.Sprintf("/api/playlists/%d", id)
 
-	return c.request("DELETE", path, nil, nil, nil)
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
 }
@@ -91,6 +91,20 @@ func TestGetPlaylist(t *testing.T) {
 	}
 }
 
+func TestPlaylists(t *testing.T) {
+	server, client := gapiTestTools(200, "["+createAndUpdatePlaylistResponse+"]")
+	defer server.Close()
+
+	playlists, err := client.Playlists()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(playlists) != 1 || playlists[0].Id != 1 || playlists[0].Name != "my playlist" {
+		t.Errorf("Not correctly parsing returned playlists: %#v", playlists)
+	}
+}
+
 func TestDeletePlaylist(t *testing.T) {
 	server, client := gapiTestTools(200, "")
 	defer server.Close()
@@ -0,0 +1,457 @@
+// Package reconcile converges the folders, datasources and dashboards of a
+// Grafana org on a desired state, typically kept in git.
+//
+//	desired, err := reconcile.Load("grafana/")
+//	...
+//	plan, err := reconcile.NewPlan(ctx, client, desired, reconcile.Options{Prune: true})
+//	...
+//	plan.Print(os.Stdout)
+//	err = reconcile.Apply(ctx, client, plan)
+//
+// Plans only hold the changes needed, so reconciling an org already in the
+// desired state changes nothing.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// Action is what a change does to a resource.
+type Action string
+
+// Change actions.
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Kind is the kind of resource a change applies to.
+type Kind string
+
+// Resource kinds.
+const (
+	KindFolder     Kind = "folder"
+	KindDataSource Kind = "datasource"
+	KindDashboard  Kind = "dashboard"
+)
+
+// Options controls how the desired state is reached.
+type Options struct {
+	// Prune deletes the resources of managed kinds missing from the desired
+	// state. Folders holding dashboards that aren't deleted with them, as
+	// those of states without dashboards, make NewPlan fail instead.
+	Prune bool
+	// DryRun makes Reconcile print the plan without applying it.
+	DryRun bool
+}
+
+// Change is a change to a single resource.
+type Change struct {
+	Action Action
+	Kind   Kind
+	// Name identifies the resource: its UID for folders and dashboards,
+	// its name for datasources.
+	Name  string
+	Title string
+	// Fields lists the top-level fields changed by an update.
+	Fields []string
+
+	folder     gapi.Folder
+	dataSource gapi.DataSource
+	dashboard  Dashboard
+}
+
+// Plan is an ordered list of changes: folders are created before the
+// datasources and dashboards, and deleted after them.
+type Plan struct {
+	Changes []Change
+
+	// folderIDs maps the UIDs of existing folders to their IDs.
+	folderIDs map[string]int64
+}
+
+// Empty reports whether the plan changes nothing.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Print writes a summary of the plan, one line per change.
+func (p *Plan) Print(w io.Writer) error {
+	if p.Empty() {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	counts := map[Action]int{}
+	for _, c := range p.Changes {
+		counts[c.Action]++
+
+		symbol := map[Action]string{Create: "+", Update: "~", Delete: "-"}[c.Action]
+		line := fmt.Sprintf("%s %s %s", symbol, c.Kind, c.Name)
+		if c.Title != "" && c.Title != c.Name {
+			line += fmt.Sprintf(" %q", c.Title)
+		}
+		if len(c.Fields) > 0 {
+			line += fmt.Sprintf(" %v", c.Fields)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "Plan: %d to create, %d to update, %d to delete.\n",
+		counts[Create], counts[Update], counts[Delete])
+	return err
+}
+
+// NewPlan reads the current state of the org through the client and
+// returns the changes needed to reach the desired state.
+//
+// Secure datasource fields can't be read back, so they're sent when a
+// datasource is created or updated, but never cause an update themselves.
+func NewPlan(ctx context.Context, client *gapi.Client, desired *State, opts Options) (*Plan, error) {
+	if err := desired.Validate(); err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{folderIDs: map[string]int64{}}
+	var deletes []Change
+
+	folders, err := client.FoldersContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currentFolders := map[string]gapi.Folder{}
+	for _, f := range folders {
+		currentFolders[f.Uid] = f
+		plan.folderIDs[f.Uid] = f.Id
+	}
+	desiredFolders := map[string]bool{}
+	for _, f := range desired.Folders {
+		desiredFolders[f.Uid] = true
+		current, ok := currentFolders[f.Uid]
+		switch {
+		case !ok:
+			plan.add(Change{Action: Create, Kind: KindFolder, Name: f.Uid, Title: f.Title, folder: f})
+		case current.Title != f.Title:
+			plan.add(Change{Action: Update, Kind: KindFolder, Name: f.Uid, Title: f.Title, Fields: []string{"title"}, folder: f})
+		}
+	}
+	var prunedFolders []gapi.Folder
+	if opts.Prune && desired.Folders != nil {
+		for _, f := range folders {
+			if !desiredFolders[f.Uid] {
+				prunedFolders = append(prunedFolders, f)
+			}
+		}
+	}
+
+	dataSources, err := client.DataSourcesContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	currentDataSources := map[string]*gapi.DataSource{}
+	for _, ds := range dataSources {
+		currentDataSources[ds.Name] = ds
+	}
+	desiredDataSources := map[string]bool{}
+	for _, ds := range desired.DataSources {
+		desiredDataSources[ds.Name] = true
+		listed, ok := currentDataSources[ds.Name]
+		if !ok {
+			plan.add(Change{Action: Create, Kind: KindDataSource, Name: ds.Name, dataSource: ds})
+			continue
+		}
+		// The list leaves out fields, such as basicAuthUser, that are
+		// compared.
+		current, err := client.DataSourceContext(ctx, listed.Id)
+		if err != nil {
+			return nil, fmt.Errorf("datasource %q: %w", ds.Name, err)
+		}
+		if ds.UID == "" {
+			// Grafana generates UIDs, which states need not pin.
+			ds.UID = current.UID
+		}
+		fields, err := dataSourceChanges(*current, ds)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			ds.Id = current.Id
+			plan.add(Change{Action: Update, Kind: KindDataSource, Name: ds.Name, Fields: fields, dataSource: ds})
+		}
+	}
+	if opts.Prune && desired.DataSources != nil {
+		for _, ds := range dataSources {
+			if !desiredDataSources[ds.Name] {
+				deletes = append(deletes, Change{Action: Delete, Kind: KindDataSource, Name: ds.Name, dataSource: *ds})
+			}
+		}
+	}
+
+	dashboards, err := client.SearchDashboardsContext(ctx, gapi.SearchQuery{Type: gapi.SearchTypeDashboard})
+	if err != nil {
+		return nil, err
+	}
+	currentDashboards := map[string]gapi.DashboardSearchResponse{}
+	for _, d := range dashboards {
+		currentDashboards[d.Uid] = d
+	}
+	desiredDashboards := map[string]bool{}
+	for _, d := range desired.Dashboards {
+		uid := d.Uid()
+		desiredDashboards[uid] = true
+		if d.FolderUid != "" && !desiredFolders[d.FolderUid] {
+			if _, ok := currentFolders[d.FolderUid]; !ok {
+				return nil, fmt.Errorf("dashboard %q: folder %q not found", uid, d.FolderUid)
+			}
+		}
+
+		summary, ok := currentDashboards[uid]
+		if !ok {
+			plan.add(Change{Action: Create, Kind: KindDashboard, Name: uid, Title: d.Title(), dashboard: d})
+			continue
+		}
+		current, err := client.DashboardByUidContext(ctx, uid)
+		if err != nil {
+			return nil, err
+		}
+		fields, err := dashboardChanges(current.Model, d.Model)
+		if err != nil {
+			return nil, err
+		}
+		if summary.FolderUid != d.FolderUid {
+			fields = append(fields, "folder")
+		}
+		if len(fields) > 0 {
+			plan.add(Change{Action: Update, Kind: KindDashboard, Name: uid, Title: d.Title(), Fields: fields, dashboard: d})
+		}
+	}
+	prunedDashboards := map[string]bool{}
+	if opts.Prune && desired.Dashboards != nil {
+		for _, d := range dashboards {
+			if !desiredDashboards[d.Uid] {
+				prunedDashboards[d.Uid] = true
+				deletes = append(deletes, Change{Action: Delete, Kind: KindDashboard, Name: d.Uid, Title: d.Title})
+			}
+		}
+	}
+
+	// Deleting a folder deletes its dashboards, so only folders whose
+	// dashboards are all deleted or moved out are pruned.
+	for _, f := range prunedFolders {
+		for _, d := range dashboards {
+			if d.FolderUid == f.Uid && !prunedDashboards[d.Uid] && !desiredDashboards[d.Uid] {
+				return nil, fmt.Errorf("folder %q: can't prune it, as it holds unmanaged dashboard %q", f.Uid, d.Uid)
+			}
+		}
+		for _, d := range desired.Dashboards {
+			if d.FolderUid == f.Uid {
+				return nil, fmt.Errorf("folder %q: can't prune it, as dashboard %q is saved in it", f.Uid, d.Uid())
+			}
+		}
+		deletes = append(deletes, Change{Action: Delete, Kind: KindFolder, Name: f.Uid, Title: f.Title, folder: f})
+	}
+
+	plan.Changes = append(plan.Changes, deletes...)
+	plan.sort()
+	return plan, nil
+}
+
+func (p *Plan) add(c Change) {
+	p.Changes = append(p.Changes, c)
+}
+
+// sort orders the changes by name within a kind, creating and updating
+// folders first and deleting them last.
+func (p *Plan) sort() {
+	order := func(c Change) int {
+		kinds := map[Kind]int{KindFolder: 0, KindDataSource: 1, KindDashboard: 2}
+		if c.Action == Delete {
+			return 10 - kinds[c.Kind]
+		}
+		return kinds[c.Kind]
+	}
+	sort.SliceStable(p.Changes, func(i, j int) bool {
+		a, b := p.Changes[i], p.Changes[j]
+		if order(a) != order(b) {
+			return order(a) < order(b)
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Apply makes the changes of the plan, in order. It stops at the first
+// change that fails; planning again and applying the new plan resumes.
+func Apply(ctx context.Context, client *gapi.Client, plan *Plan) error {
+	folderIDs := map[string]int64{}
+	for uid, id := range plan.folderIDs {
+		folderIDs[uid] = id
+	}
+
+	for _, c := range plan.Changes {
+		if err := apply(ctx, client, c, folderIDs); err != nil {
+			return fmt.Errorf("%s %s %q: %w", c.Action, c.Kind, c.Name, err)
+		}
+	}
+	return nil
+}
+
+func apply(ctx context.Context, client *gapi.Client, c Change, folderIDs map[string]int64) error {
+	switch c.Kind {
+	case KindFolder:
+		switch c.Action {
+		case Create:
+			folder, err := client.NewFolderWithUIDContext(ctx, c.folder.Title, c.folder.Uid)
+			if err != nil {
+				return err
+			}
+			folderIDs[folder.Uid] = folder.Id
+			return nil
+		case Update:
+			return client.UpdateFolderContext(ctx, c.folder.Uid, c.folder.Title)
+		case Delete:
+			return client.DeleteFolderContext(ctx, c.folder.Uid)
+		}
+
+	case KindDataSource:
+		ds := c.dataSource
+		switch c.Action {
+		case Create:
+			_, err := client.NewDataSourceContext(ctx, &ds)
+			return err
+		case Update:
+			return client.UpdateDataSourceContext(ctx, &ds)
+		case Delete:
+			return client.DeleteDataSourceContext(ctx, ds.Id)
+		}
+
+	case KindDashboard:
+		switch c.Action {
+		case Create, Update:
+			folderID, ok := folderIDs[c.dashboard.FolderUid]
+			if !ok && c.dashboard.FolderUid != "" {
+				return fmt.Errorf("folder %q not found", c.dashboard.FolderUid)
+			}
+			model := make(map[string]interface{}, len(c.dashboard.Model))
+			for key, value := range c.dashboard.Model {
+				model[key] = value
+			}
+			// Dashboards are matched by UID; IDs and versions differ across instances.
+			model["id"] = nil
+			delete(model, "version")
+			_, err := client.NewDashboardContext(ctx, gapi.Dashboard{
+				Model:     model,
+				Folder:    folderID,
+				Overwrite: true,
+			})
+			return err
+		case Delete:
+			return client.DeleteDashboardByUidContext(ctx, c.Name)
+		}
+	}
+	return fmt.Errorf("unsupported change")
+}
+
+// Reconcile plans the changes needed to reach the desired state, prints the
+// plan to w, and applies it unless opts.DryRun is set.
+func Reconcile(ctx context.Context, client *gapi.Client, desired *State, opts Options, w io.Writer) (*Plan, error) {
+	plan, err := NewPlan(ctx, client, desired, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.Print(w); err != nil {
+		return plan, err
+	}
+	if opts.DryRun {
+		return plan, nil
+	}
+	return plan, Apply(ctx, client, plan)
+}
+
+// dataSourceChanges returns the fields of the desired datasource that
+// differ from the current one, leaving out the fields Grafana sets and the
+// secure fields it never returns.
+func dataSourceChanges(current, desired gapi.DataSource) ([]string, error) {
+	comparable := func(ds gapi.DataSource) (map[string]interface{}, error) {
+		ds.Id = 0
+		ds.OrgId = 0
+		ds.Password = ""
+		ds.BasicAuthPassword = ""
+		ds.SecureJSONData = gapi.SecureJSONData{}
+		if ds.Access == "" {
+			// Grafana stores datasources without an access mode as proxy.
+			ds.Access = "proxy"
+		}
+		v, err := normalize(ds)
+		if err != nil {
+			return nil, err
+		}
+		m := v.(map[string]interface{})
+		delete(m, "secureJsonData")
+		delete(m, "secureJsonFields")
+		return m, nil
+	}
+
+	a, err := comparable(current)
+	if err != nil {
+		return nil, err
+	}
+	b, err := comparable(desired)
+	if err != nil {
+		return nil, err
+	}
+	return changedFields(a, b), nil
+}
+
+// dashboardChanges returns the fields of the desired dashboard model that
+// differ from the current one, leaving out IDs and versions.
+func dashboardChanges(current, desired map[string]interface{}) ([]string, error) {
+	comparable := func(model map[string]interface{}) (map[string]interface{}, error) {
+		v, err := normalize(model)
+		if err != nil {
+			return nil, err
+		}
+		m, _ := v.(map[string]interface{})
+		if m == nil {
+			m = map[string]interface{}{}
+		}
+		delete(m, "id")
+		delete(m, "version")
+		return m, nil
+	}
+
+	a, err := comparable(current)
+	if err != nil {
+		return nil, err
+	}
+	b, err := comparable(desired)
+	if err != nil {
+		return nil, err
+	}
+	return changedFields(a, b), nil
+}
+
+// changedFields returns the sorted keys whose values differ between a and b.
+func changedFields(a, b map[string]interface{}) []string {
+	var fields []string
+	for key, value := range b {
+		if !reflect.DeepEqual(a[key], value) {
+			fields = append(fields, key)
+		}
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,356 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	gapi "github.com/nytm/go-grafana-api"
+)
+
+// fakeGrafana serves the folder, datasource and dashboard endpoints used by
+// reconcile, keeping what's written in memory.
+type fakeGrafana struct {
+	mu          sync.Mutex
+	nextID      int64
+	folders     []gapi.Folder
+	dataSources []gapi.DataSource
+	dashboards  map[string]gapi.Dashboard
+	writes      int
+}
+
+func newFakeGrafana() *fakeGrafana {
+	return &fakeGrafana{nextID: 1, dashboards: map[string]gapi.Dashboard{}}
+}
+
+func (f *fakeGrafana) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method != "GET" {
+		f.writes++
+	}
+	reply := func(v interface{}) { json.NewEncoder(w).Encode(v) }
+	decode := func(v interface{}) { json.NewDecoder(r.Body).Decode(v) }
+	id := func() int64 { f.nextID++; return f.nextID }
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case r.Method == "GET" && path == "/api/folders":
+		reply(f.folders)
+	case r.Method == "POST" && path == "/api/folders":
+		folder := gapi.Folder{}
+		decode(&folder)
+		folder.Id = id()
+		f.folders = append(f.folders, folder)
+		reply(folder)
+	case strings.HasPrefix(path, "/api/folders/"):
+		uid := strings.TrimPrefix(path, "/api/folders/")
+		for i, folder := range f.folders {
+			if folder.Uid != uid {
+				continue
+			}
+			switch r.Method {
+			case "GET":
+				reply(folder)
+				return
+			case "DELETE":
+				f.folders = append(f.folders[:i], f.folders[i+1:]...)
+				for key, d := range f.dashboards {
+					if d.Folder == folder.Id {
+						delete(f.dashboards, key)
+					}
+				}
+			default:
+				decode(&f.folders[i])
+				f.folders[i].Version++
+			}
+			reply(map[string]string{"message": "ok"})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	case r.Method == "GET" && path == "/api/datasources":
+		// Like Grafana, the list leaves out some fields of datasources.
+		list := []gapi.DataSource{}
+		for _, ds := range f.dataSources {
+			ds.BasicAuthUser = ""
+			ds.JSONData = gapi.JSONData{}
+			ds.SecureJSONFields = nil
+			list = append(list, ds)
+		}
+		reply(list)
+	case r.Method == "POST" && path == "/api/datasources":
+		ds := gapi.DataSource{}
+		decode(&ds)
+		ds.Id = id()
+		asStored(&ds)
+		f.dataSources = append(f.dataSources, ds)
+		reply(map[string]int64{"id": ds.Id})
+	case strings.HasPrefix(path, "/api/datasources/"):
+		dsID, _ := strconv.ParseInt(strings.TrimPrefix(path, "/api/datasources/"), 10, 64)
+		for i, ds := range f.dataSources {
+			if ds.Id != dsID {
+				continue
+			}
+			switch r.Method {
+			case "GET":
+				reply(ds)
+				return
+			case "DELETE":
+				f.dataSources = append(f.dataSources[:i], f.dataSources[i+1:]...)
+			default:
+				f.dataSources[i] = gapi.DataSource{}
+				decode(&f.dataSources[i])
+				asStored(&f.dataSources[i])
+			}
+			reply(map[string]string{"message": "ok"})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+
+	case r.Method == "GET" && path == "/api/search":
+		results := []gapi.DashboardSearchResponse{}
+		for uid, d := range f.dashboards {
+			result := gapi.DashboardSearchResponse{Uid: uid, Title: d.Model["title"].(string), Type: "dash-db"}
+			for _, folder := range f.folders {
+				if folder.Id == d.Folder {
+					result.FolderUid = folder.Uid
+				}
+			}
+			results = append(results, result)
+		}
+		reply(results)
+	case r.Method == "POST" && path == "/api/dashboards/db":
+		d := gapi.Dashboard{}
+		decode(&d)
+		uid := d.Model["uid"].(string)
+		d.Model["id"] = id()
+		d.Model["version"] = 1
+		f.dashboards[uid] = d
+		reply(gapi.DashboardSaveResponse{Uid: uid, Status: "success"})
+	case strings.HasPrefix(path, "/api/dashboards/uid/"):
+		uid := strings.TrimPrefix(path, "/api/dashboards/uid/")
+		d, ok := f.dashboards[uid]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "DELETE" {
+			delete(f.dashboards, uid)
+		}
+		reply(d)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// asStored changes a datasource as Grafana does when storing it: its secure
+// fields are replaced with the list of those that are set, and the access
+// mode defaults to proxy.
+func asStored(ds *gapi.DataSource) {
+	if ds.Access == "" {
+		ds.Access = "proxy"
+	}
+	data, _ := json.Marshal(ds.SecureJSONData)
+	fields := map[string]interface{}{}
+	json.Unmarshal(data, &fields)
+	ds.SecureJSONData = gapi.SecureJSONData{}
+	ds.SecureJSONFields = nil
+	for field := range fields {
+		if ds.SecureJSONFields == nil {
+			ds.SecureJSONFields = map[string]bool{}
+		}
+		ds.SecureJSONFields[field] = true
+	}
+}
+
+func TestLoad(t *testing.T) {
+	state, err := Load("testdata/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Folders) != 1 || state.Folders[0].Uid != "team-a" || state.Folders[0].Title != "Team A" {
+		t.Errorf("Unexpected folders: %#v", state.Folders)
+	}
+	if len(state.DataSources) != 2 || state.DataSources[0].Name != "Prometheus" || state.DataSources[0].JSONData.HttpMethod != "POST" {
+		t.Errorf("Unexpected datasources: %#v", state.DataSources)
+	}
+	if len(state.Dashboards) != 2 {
+		t.Fatalf("Unexpected dashboards: %#v", state.Dashboards)
+	}
+	if d := state.Dashboards[0]; d.Uid() != "home" || d.FolderUid != "" {
+		t.Errorf("Unexpected dashboard: %#v", d)
+	}
+	if d := state.Dashboards[1]; d.Uid() != "payments" || d.FolderUid != "team-a" {
+		t.Errorf("Unexpected dashboard: %#v", d)
+	}
+
+	if state, err := Load("testdata/missing"); err != nil || state.Folders != nil {
+		t.Errorf("Missing directory should leave kinds unmanaged: %#v, %v", state, err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	fake := newFakeGrafana()
+	fake.dataSources = []gapi.DataSource{
+		{Id: 100, UID: "P1809F7CD0C75ACF3", Name: "Prometheus", Type: "prometheus", URL: "http://old:9090", Access: "proxy"},
+		{Id: 101, Name: "Graphite", Type: "graphite", URL: "http://graphite", Access: "proxy"},
+	}
+	fake.dashboards["stale"] = gapi.Dashboard{Model: map[string]interface{}{"uid": "stale", "title": "Stale"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := gapi.New("admin:admin", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desired, err := Load("testdata/state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	out := &bytes.Buffer{}
+	plan, err := Reconcile(ctx, client, desired, Options{Prune: true, DryRun: true}, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(out.String())
+
+	expected := `+ folder team-a "Team A"
++ datasource Loki
+~ datasource Prometheus [isDefault jsonData url]
++ dashboard home "Home"
++ dashboard payments "Payments"
+- dashboard stale "Stale"
+- datasource Graphite
+Plan: 4 to create, 1 to update, 2 to delete.
+`
+	if out.String() != expected {
+		t.Errorf("Expected plan:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if fake.writes != 0 {
+		t.Errorf("Dry run made %d writes", fake.writes)
+	}
+
+	if err := Apply(ctx, client, plan); err != nil {
+		t.Fatal(err)
+	}
+	if fake.writes != len(plan.Changes) {
+		t.Errorf("Expected %d writes, got %d", len(plan.Changes), fake.writes)
+	}
+	if d := fake.dashboards["payments"]; d.Folder != fake.folders[0].Id {
+		t.Errorf("Dashboard saved in folder %d, expected %d", d.Folder, fake.folders[0].Id)
+	}
+
+	// Reconciling again changes nothing.
+	plan, err = NewPlan(ctx, client, desired, Options{Prune: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		out.Reset()
+		plan.Print(out)
+		t.Errorf("Expected an empty plan, got:\n%s", out.String())
+	}
+
+	// Moving a dashboard and renaming a folder are updates.
+	desired.Folders[0].Title = "Team A (payments)"
+	desired.Dashboards[1].FolderUid = ""
+	plan, err = NewPlan(ctx, client, desired, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	plan.Print(out)
+	expected = `~ folder team-a "Team A (payments)" [title]
+~ dashboard payments "Payments" [folder]
+Plan: 0 to create, 2 to update, 0 to delete.
+`
+	if out.String() != expected {
+		t.Errorf("Expected plan:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestNewPlan_unknownFolder(t *testing.T) {
+	server := httptest.NewServer(newFakeGrafana())
+	defer server.Close()
+
+	client, err := gapi.New("admin:admin", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	desired := &State{Dashboards: []Dashboard{
+		{FolderUid: "missing", Model: map[string]interface{}{"uid": "d", "title": "D"}},
+	}}
+	_, err = NewPlan(context.Background(), client, desired, Options{})
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("%q", "missing")) {
+		t.Errorf("Unknown folder not detected: %v", err)
+	}
+}
+
+func TestNewPlan_pruneFolder(t *testing.T) {
+	fake := newFakeGrafana()
+	fake.folders = []gapi.Folder{{Id: 5, Uid: "old", Title: "Old"}}
+	fake.dashboards["kept"] = gapi.Dashboard{Folder: 5, Model: map[string]interface{}{"uid": "kept", "title": "Kept"}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := gapi.New("admin:admin", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Dashboards aren't managed, so deleting the folder would delete one
+	// the state leaves alone.
+	desired := &State{Folders: []gapi.Folder{}}
+	if _, err := NewPlan(ctx, client, desired, Options{Prune: true}); err == nil || !strings.Contains(err.Error(), `"kept"`) {
+		t.Errorf("Pruning a folder with unmanaged dashboards: got %v", err)
+	}
+
+	// Nor can a folder holding a dashboard of the state be pruned.
+	desired.Dashboards = []Dashboard{{FolderUid: "old", Model: map[string]interface{}{"uid": "kept", "title": "Kept"}}}
+	if _, err := NewPlan(ctx, client, desired, Options{Prune: true}); err == nil || !strings.Contains(err.Error(), `"kept"`) {
+		t.Errorf("Pruning the folder of a dashboard: got %v", err)
+	}
+
+	// Once its dashboards are pruned or moved out, so is the folder.
+	for _, dashboards := range [][]Dashboard{{}, {{Model: map[string]interface{}{"uid": "kept", "title": "Kept"}}}} {
+		desired.Dashboards = dashboards
+		plan, err := NewPlan(ctx, client, desired, Options{Prune: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		last := plan.Changes[len(plan.Changes)-1]
+		if last.Action != Delete || last.Kind != KindFolder || last.Name != "old" {
+			t.Errorf("Expected the folder to be deleted last, got %+v", plan.Changes)
+		}
+	}
+}
+
+func TestState_Validate(t *testing.T) {
+	states := map[string]*State{
+		"folder without uid":      {Folders: []gapi.Folder{{Title: "t"}}},
+		"duplicate folder":        {Folders: []gapi.Folder{{Uid: "a", Title: "a"}, {Uid: "a", Title: "b"}}},
+		"datasource without type": {DataSources: []gapi.DataSource{{Name: "p"}}},
+		"duplicate datasource":    {DataSources: []gapi.DataSource{{Name: "p", Type: "t"}, {Name: "p", Type: "t"}}},
+		"dashboard without uid":   {Dashboards: []Dashboard{{Model: map[string]interface{}{"title": "t"}}}},
+	}
+	for name, state := range states {
+		if err := state.Validate(); err == nil {
+			t.Errorf("%s not detected", name)
+		}
+	}
+}
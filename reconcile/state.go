@@ -0,0 +1,259 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	gapi "github.com/nytm/go-grafana-api"
+	"gopkg.in/yaml.v3"
+)
+
+// Directories of a desired state directory, one per kind of resource.
+const (
+	FoldersDir     = "folders"
+	DataSourcesDir = "datasources"
+	DashboardsDir  = "dashboards"
+)
+
+// State is the desired state of a Grafana org.
+//
+// A nil list leaves the resources of its kind unmanaged: they're never
+// pruned. An empty list manages them, so pruning deletes them all.
+type State struct {
+	Folders     []gapi.Folder
+	DataSources []gapi.DataSource
+	Dashboards  []Dashboard
+}
+
+// Dashboard is a dashboard of the desired state.
+type Dashboard struct {
+	// FolderUid is the UID of the folder holding the dashboard.
+	// It's empty for the General folder.
+	FolderUid string
+	// Model is the dashboard JSON model. Its uid is required.
+	Model map[string]interface{}
+}
+
+// Uid returns the UID of the dashboard.
+func (d Dashboard) Uid() string {
+	uid, _ := d.Model["uid"].(string)
+	return uid
+}
+
+// Title returns the title of the dashboard.
+func (d Dashboard) Title() string {
+	title, _ := d.Model["title"].(string)
+	return title
+}
+
+// Load reads the desired state from a directory of JSON and YAML files:
+//
+//	folders/*.json          folders, with their uid and title
+//	datasources/*.yaml      datasources, as sent to the datasources API
+//	dashboards/*.json       dashboard models, in the General folder
+//	dashboards/<uid>/*.json dashboard models, in the folder whose UID is <uid>
+//
+// Folder and datasource files hold either one resource or a list of them.
+// Kinds whose directory is missing are left unmanaged.
+func Load(dir string) (*State, error) {
+	state := &State{}
+
+	err := walkKind(dir, FoldersDir, func(path, _ string) error {
+		return decodeList(path, func(data []byte) error {
+			folder := gapi.Folder{}
+			if err := json.Unmarshal(data, &folder); err != nil {
+				return err
+			}
+			state.Folders = append(state.Folders, folder)
+			return nil
+		})
+	}, func() { state.Folders = []gapi.Folder{} })
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkKind(dir, DataSourcesDir, func(path, _ string) error {
+		return decodeList(path, func(data []byte) error {
+			ds := gapi.DataSource{}
+			if err := json.Unmarshal(data, &ds); err != nil {
+				return err
+			}
+			state.DataSources = append(state.DataSources, ds)
+			return nil
+		})
+	}, func() { state.DataSources = []gapi.DataSource{} })
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkKind(dir, DashboardsDir, func(path, folderUid string) error {
+		v, err := decodeFile(path)
+		if err != nil {
+			return err
+		}
+		model, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected a dashboard model", path)
+		}
+		state.Dashboards = append(state.Dashboards, Dashboard{FolderUid: folderUid, Model: model})
+		return nil
+	}, func() { state.Dashboards = []Dashboard{} })
+	if err != nil {
+		return nil, err
+	}
+
+	return state, state.Validate()
+}
+
+// Validate checks that the resources of the state are uniquely identified.
+func (s *State) Validate() error {
+	folders := map[string]bool{}
+	for _, f := range s.Folders {
+		if f.Uid == "" || f.Title == "" {
+			return fmt.Errorf("folder %q: uid and title are required", f.Uid+f.Title)
+		}
+		if folders[f.Uid] {
+			return fmt.Errorf("folder %q: duplicate uid", f.Uid)
+		}
+		folders[f.Uid] = true
+	}
+
+	dataSources := map[string]bool{}
+	for _, ds := range s.DataSources {
+		if ds.Name == "" || ds.Type == "" {
+			return fmt.Errorf("datasource %q: name and type are required", ds.Name)
+		}
+		if dataSources[ds.Name] {
+			return fmt.Errorf("datasource %q: duplicate name", ds.Name)
+		}
+		dataSources[ds.Name] = true
+	}
+
+	dashboards := map[string]bool{}
+	for _, d := range s.Dashboards {
+		uid := d.Uid()
+		if uid == "" || d.Title() == "" {
+			return fmt.Errorf("dashboard %q: uid and title are required", d.Title())
+		}
+		if dashboards[uid] {
+			return fmt.Errorf("dashboard %q: duplicate uid", uid)
+		}
+		dashboards[uid] = true
+	}
+	return nil
+}
+
+// walkKind calls fn with every state file under dir/kind and the name of
+// the subdirectory holding it, if any. found is called when dir/kind exists.
+func walkKind(dir, kind string, fn func(path, subdir string) error, found func()) error {
+	root := filepath.Join(dir, kind)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	found()
+
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isStateFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		subdir := ""
+		if parts := strings.Split(filepath.ToSlash(rel), "/"); len(parts) > 1 {
+			subdir = parts[0]
+		}
+		if err := fn(path, subdir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isStateFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// decodeFile decodes a JSON or YAML file into generic JSON values.
+func decodeFile(path string) (interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, &v)
+	} else {
+		err = yaml.Unmarshal(data, &v)
+		if err == nil {
+			// YAML allows keys JSON doesn't, so round-trip through JSON
+			// to check the document and normalize its values.
+			v, err = normalize(v)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return v, nil
+}
+
+// decodeList calls fn with the JSON encoding of the resource, or of each
+// resource of the list, held by a file.
+func decodeList(path string, fn func(data []byte) error) error {
+	v, err := decodeFile(path)
+	if err != nil {
+		return err
+	}
+
+	items, ok := v.([]interface{})
+	if !ok {
+		items = []interface{}{v}
+	}
+	for _, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := fn(data); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// normalize returns v as decoded from its JSON encoding, so that values
+// of different origins compare equal.
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
@@ -0,0 +1,331 @@
+package gapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-cleanhttp"
+)
+
+// Interaction is a request and its response, as saved in fixture files by
+// a Recorder and replayed by a Replayer.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded request. Credentials in its headers and
+// secrets in its query and body are redacted, as in logs.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	// Body is set for JSON bodies, and BodyText for other ones.
+	Body     json.RawMessage `json:"body,omitempty"`
+	BodyText string          `json:"bodyText,omitempty"`
+}
+
+// RecordedResponse is a recorded response, with its secrets redacted.
+type RecordedResponse struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	// Body is set for JSON bodies, and BodyText for other ones.
+	Body     json.RawMessage `json:"body,omitempty"`
+	BodyText string          `json:"bodyText,omitempty"`
+}
+
+func (r *RecordedRequest) String() string {
+	s := r.Method + " " + r.Path
+	if r.Query != "" {
+		s += "?" + r.Query
+	}
+	return s
+}
+
+type fixture struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records the requests it makes and
+// their responses, to replay them later with a Replayer:
+//
+//	recorder := gapi.NewRecorder("testdata/folders.json", nil)
+//	client, err := gapi.NewWithOptions(url, gapi.WithAPIKey(key), gapi.WithTransport(recorder))
+//	...
+//	err = recorder.Save()
+type Recorder struct {
+	path string
+	next http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder returns a Recorder saving to the fixture file at path, which
+// makes requests with next, or a default transport if next is nil.
+func NewRecorder(path string, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = cleanhttp.DefaultTransport()
+	}
+	return &Recorder{path: path, next: next}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	recorded := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  redactQuery(req.URL.Query()),
+			Header: redactHeaders(req.Header),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     redactHeaders(resp.Header),
+		},
+	}
+	recorded.Request.Body, recorded.Request.BodyText = recordBody(reqBody)
+	recorded.Response.Body, recorded.Response.BodyText = recordBody(respBody)
+	recorded.Response.Header.Del("Set-Cookie")
+	recorded.Response.Header.Del("Date")
+	// The body is re-encoded.
+	recorded.Response.Header.Del("Content-Length")
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, recorded)
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Interactions returns the interactions recorded so far.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the interactions recorded so far to the fixture file,
+// creating its directory if needed.
+func (r *Recorder) Save() error {
+	data, err := json.MarshalIndent(fixture{Interactions: r.Interactions()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, append(data, '\n'), 0644)
+}
+
+// Replayer is an http.RoundTripper that answers requests with the
+// responses saved in a fixture file by a Recorder, without making any.
+//
+// Requests are matched to the recorded ones on their method, path, query
+// and body; JSON bodies match when they hold the same values. Each
+// recorded interaction is replayed once, in the order they were recorded,
+// so that the same request can get different responses over time.
+// Requests matching none of the remaining interactions fail.
+type Replayer struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer returns a Replayer of the fixture file at path.
+func NewReplayer(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := fixture{}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Replayer{
+		path:         path,
+		interactions: f.Interactions,
+		used:         make([]bool, len(f.Interactions)),
+	}, nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	query := redactQuery(req.URL.Query())
+	body, bodyText := recordBody(reqBody)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	candidates := 0
+	for i, recorded := range r.interactions {
+		if r.used[i] || recorded.Request.Method != req.Method || recorded.Request.Path != req.URL.Path {
+			continue
+		}
+		candidates++
+		if recordedQuery(recorded.Request.Query) != query ||
+			!sameJSON(recorded.Request.Body, body) ||
+			recorded.Request.BodyText != bodyText {
+			continue
+		}
+
+		r.used[i] = true
+		return recorded.Response.response(req), nil
+	}
+
+	unmatched := RecordedRequest{Method: req.Method, Path: req.URL.Path, Query: query}
+	msg := fmt.Sprintf("gapi: no recorded response to %s in %s", unmatched.String(), r.path)
+	if candidates > 0 {
+		msg += fmt.Sprintf(": %d remaining %s %s requests have another query or body", candidates, req.Method, req.URL.Path)
+	}
+	if len(body) > 0 {
+		msg += fmt.Sprintf(" (body %s)", body)
+	}
+	return nil, errors.New(msg)
+}
+
+// Unused returns the recorded interactions that haven't been replayed.
+// Tests can check it's empty to make sure the code made all the requests
+// expected of it.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, recorded := range r.interactions {
+		if !r.used[i] {
+			unused = append(unused, recorded)
+		}
+	}
+	return unused
+}
+
+func (r RecordedResponse) response(req *http.Request) *http.Response {
+	body := []byte(r.BodyText)
+	if len(r.Body) > 0 {
+		body = r.Body
+	}
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func readBody(body io.ReadCloser) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// recordBody returns the body redacted, as JSON if it is, else as text.
+func recordBody(body []byte) (json.RawMessage, string) {
+	if len(body) == 0 {
+		return nil, ""
+	}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil || d.More() {
+		return nil, redactText(body)
+	}
+	data, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return nil, redactText(body)
+	}
+	return data, ""
+}
+
+// redactText returns a body which isn't JSON, with its sensitive fields
+// redacted when it's a form, such as "user=admin&password=secret".
+func redactText(body []byte) string {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return string(body)
+	}
+	for key := range form {
+		if sensitiveFields[strings.ToLower(key)] {
+			return redactQuery(form)
+		}
+	}
+	return string(body)
+}
+
+// sameJSON reports whether a and b are encodings of the same values.
+func sameJSON(a, b json.RawMessage) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	ca, _ := recordBody(a)
+	cb, _ := recordBody(b)
+	return bytes.Equal(ca, cb)
+}
+
+// recordedQuery returns the query of a recorded request in the encoding of
+// redactQuery, with its parameters sorted.
+func recordedQuery(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	return values.Encode()
+}
+
+// redactQuery returns the encoded query with its sensitive parameters
+// redacted.
+func redactQuery(query url.Values) string {
+	for key := range query {
+		if sensitiveFields[strings.ToLower(key)] {
+			query[key] = []string{redacted}
+		}
+	}
+	return query.Encode()
+}
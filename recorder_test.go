@@ -0,0 +1,179 @@
+package gapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	folders := `[]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/folders":
+			folders = `[{"id":1,"uid":"nErXDvCkzz","title":"Ops"}]`
+			w.Write([]byte(`{"id":1,"uid":"nErXDvCkzz","title":"Ops"}`))
+		case "GET /api/folders":
+			w.Write([]byte(folders))
+		case "POST /api/admin/users":
+			w.Write([]byte(`{"id":2,"message":"User created"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"Not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "testdata", "folders.json")
+	recorder := NewRecorder(path, nil)
+	client, err := NewWithOptions(server.URL, WithAPIKey("my-key"), WithTransport(recorder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	use := func(client *Client) {
+		t.Helper()
+		if got, err := client.Folders(); err != nil || len(got) != 0 {
+			t.Fatalf("Folders = %v, %v", got, err)
+		}
+		if _, err := client.NewFolder("Ops"); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := client.Folders(); err != nil || len(got) != 1 || got[0].Uid != "nErXDvCkzz" {
+			t.Fatalf("Folders = %v, %v", got, err)
+		}
+		if _, err := client.CreateUser(User{Login: "jane", Password: "hunter2"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := client.Folder(9); !IsNotFound(err) {
+			t.Fatalf("Folder(9): got %v, want not found", err)
+		}
+	}
+	use(client)
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"my-key", "hunter2"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("fixture contains %q:\n%s", secret, data)
+		}
+	}
+
+	// Replaying needs no server and gives the same results.
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err = NewWithOptions("http://grafana.invalid", WithAPIKey("other-key"), WithTransport(replayer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	use(client)
+	if unused := replayer.Unused(); len(unused) != 0 {
+		t.Errorf("unused interactions: %v", unused)
+	}
+
+	_, err = client.NewFolder("Dev")
+	if err == nil || !strings.Contains(err.Error(), "no recorded response to POST /api/folders") ||
+		!strings.Contains(err.Error(), `"title":"Dev"`) {
+		t.Errorf("unmatched request: got %v", err)
+	}
+}
+
+func TestRecorderFormBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "login.json")
+	recorder := NewRecorder(path, nil)
+	login := func(transport http.RoundTripper) error {
+		req, _ := http.NewRequest("POST", server.URL+"/login", strings.NewReader("user=admin&password=hunter2"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		resp, err := (&http.Client{Transport: transport}).Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	if err := login(recorder); err != nil {
+		t.Fatal(err)
+	}
+	if body := recorder.Interactions()[0].Request.BodyText; strings.Contains(body, "hunter2") || !strings.Contains(body, "user=admin") {
+		t.Errorf("recorded body = %q", body)
+	}
+
+	// Redacted forms are matched on replay.
+	if err := recorder.Save(); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := login(replayer); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayerMatching(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.json")
+	fixture := `{
+  "interactions": [
+    {
+      "request": {"method": "GET", "path": "/api/search", "query": "type=dash-db&query=nodes&page=1&limit=1000"},
+      "response": {"status": 200, "body": [{"id": 1}]}
+    },
+    {
+      "request": {"method": "POST", "path": "/api/dashboards/calculate-diff", "body": {"new": {"version": 2, "dashboardId": 3}, "diffType": "json", "base": {"dashboardId": 3, "version": 1}}},
+      "response": {"status": 200, "bodyText": "<p>diff</p>"}
+    }
+  ]
+}`
+	if err := ioutil.WriteFile(path, []byte(fixture), 0644); err != nil {
+		t.Fatal(err)
+	}
+	replayer, err := NewReplayer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewWithOptions("http://grafana.invalid", WithTransport(replayer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.SearchDashboards(SearchQuery{Query: "nodes"}); err == nil ||
+		!strings.Contains(err.Error(), "1 remaining GET /api/search requests have another query or body") {
+		t.Errorf("query mismatch: got %v", err)
+	}
+	// Query parameters and JSON fields match in any order.
+	results, err := client.SearchDashboards(SearchQuery{Type: SearchTypeDashboard, Query: "nodes"})
+	if err != nil || len(results) != 1 {
+		t.Errorf("SearchDashboards = %v, %v", results, err)
+	}
+	diff, err := client.DiffDashboardVersions(
+		DashboardDiffTarget{DashboardId: 3, Version: 1},
+		DashboardDiffTarget{DashboardId: 3, Version: 2},
+		DiffTypeJSON,
+	)
+	if err != nil || diff != "<p>diff</p>" {
+		t.Errorf("DiffDashboardVersions = %q, %v", diff, err)
+	}
+
+	// Interactions are replayed once.
+	if _, err := client.SearchDashboards(SearchQuery{Type: SearchTypeDashboard, Query: "nodes"}); err == nil {
+		t.Error("replayed an interaction twice")
+	}
+	if len(replayer.Unused()) != 0 {
+		t.Errorf("unused = %v", replayer.Unused())
+	}
+}
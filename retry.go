@@ -0,0 +1,150 @@
+package gapi
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryMaxAttempts = 4
+	defaultRetryMinBackoff  = 250 * time.Millisecond
+	defaultRetryMaxBackoff  = 10 * time.Second
+)
+
+// defaultRetryStatusCodes are the response codes retried when a RetryPolicy
+// doesn't list its own.
+var defaultRetryStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy configures how the client retries requests that fail with a
+// connection error or a retryable status code.
+// Zero values fall back to sensible defaults.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. It doubles on each
+	// subsequent retry, up to MaxBackoff, with random jitter applied.
+	MinBackoff time.Duration
+	// MaxBackoff also bounds the wait asked for by Retry-After headers:
+	// responses asking for a longer one aren't retried.
+	MaxBackoff time.Duration
+	// StatusCodes lists the response codes that are retried.
+	// It defaults to 429, 502, 503 and 504.
+	StatusCodes []int
+	// RetryNonIdempotent allows POST and PATCH requests to be retried.
+	// By default only GET, HEAD, OPTIONS, PUT and DELETE requests are.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy returns a RetryPolicy with the default settings.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: defaultRetryMaxAttempts,
+		MinBackoff:  defaultRetryMinBackoff,
+		MaxBackoff:  defaultRetryMaxBackoff,
+		StatusCodes: defaultRetryStatusCodes,
+	}
+}
+
+// next reports whether the given attempt should be retried and how long to
+// wait before doing so. A nil policy never retries.
+func (p *RetryPolicy) next(method string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if p == nil {
+		return 0, false
+	}
+
+	maxAttempts := p.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultRetryMaxAttempts
+	}
+	if attempt >= maxAttempts || !p.retryableMethod(method) {
+		return 0, false
+	}
+
+	if err == nil && !p.retryableStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return wait, wait <= p.maxBackoff()
+		}
+	}
+
+	return p.backoff(attempt), true
+}
+
+func (p *RetryPolicy) retryableMethod(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	case "POST", "PATCH":
+		return p.RetryNonIdempotent
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	codes := p.StatusCodes
+	if len(codes) == 0 {
+		codes = defaultRetryStatusCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the exponential delay for the given attempt with "equal
+// jitter": half of the delay is fixed and the other half is random.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	min, max := p.MinBackoff, p.maxBackoff()
+	if min <= 0 {
+		min = defaultRetryMinBackoff
+	}
+
+	wait := min
+	for i := 1; i < attempt && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff <= 0 {
+		return defaultRetryMaxBackoff
+	}
+	return p.MaxBackoff
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		wait := time.Until(t)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
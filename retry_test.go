@@ -0,0 +1,146 @@
+package gapi
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func retryTestTools(t *testing.T, codes ...int) (*httptest.Server, *Client, *[]string) {
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		code := codes[0]
+		if len(codes) > 1 {
+			codes = codes[1:]
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(`{"id":1}`))
+	}))
+
+	client, err := New("my-key", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Retry = &RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	}
+	return server, client, &bodies
+}
+
+func TestRetry_recovers(t *testing.T) {
+	server, client, bodies := retryTestTools(t, 503, 502, 200)
+	defer server.Close()
+
+	err := client.request(context.Background(), "PUT", "/foo", nil, bytes.NewBufferString(`{"a":1}`), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*bodies) != 3 {
+		t.Fatalf("expected 3 attempts; got: %d", len(*bodies))
+	}
+	for _, b := range *bodies {
+		if b != `{"a":1}` {
+			t.Errorf("expected body to be replayed; got: %q", b)
+		}
+	}
+}
+
+func TestRetry_givesUp(t *testing.T) {
+	server, client, bodies := retryTestTools(t, 503)
+	defer server.Close()
+
+	err := client.request(context.Background(), "GET", "/foo", nil, nil, nil)
+	if !IsServerError(err) {
+		t.Errorf("expected server error; got: %v", err)
+	}
+	if len(*bodies) != 3 {
+		t.Errorf("expected 3 attempts; got: %d", len(*bodies))
+	}
+}
+
+func TestRetry_nonIdempotent(t *testing.T) {
+	server, client, bodies := retryTestTools(t, 503, 200)
+	defer server.Close()
+
+	err := client.request(context.Background(), "POST", "/foo", nil, nil, nil)
+	if err == nil {
+		t.Error("expected POST not to be retried")
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("expected 1 attempt; got: %d", len(*bodies))
+	}
+
+	client.Retry.RetryNonIdempotent = true
+	err = client.request(context.Background(), "POST", "/foo", nil, nil, nil)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetry_notRetryable(t *testing.T) {
+	server, client, bodies := retryTestTools(t, 404, 200)
+	defer server.Close()
+
+	err := client.request(context.Background(), "GET", "/foo", nil, nil, nil)
+	if !IsNotFound(err) {
+		t.Errorf("expected not found; got: %v", err)
+	}
+	if len(*bodies) != 1 {
+		t.Errorf("expected 1 attempt; got: %d", len(*bodies))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	wait, ok := retryAfter("3")
+	if !ok || wait != 3*time.Second {
+		t.Errorf("expected 3s; got: %v", wait)
+	}
+
+	wait, ok = retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	if !ok || wait < 59*time.Minute {
+		t.Errorf("expected about an hour; got: %v", wait)
+	}
+
+	if _, ok = retryAfter("soon"); ok {
+		t.Error("expected invalid value to be ignored")
+	}
+}
+
+func TestRetryPolicy_retryAfter(t *testing.T) {
+	p := &RetryPolicy{MaxBackoff: 5 * time.Second}
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+
+	resp.Header.Set("Retry-After", "2")
+	if wait, ok := p.next("GET", 1, resp, nil); !ok || wait != 2*time.Second {
+		t.Errorf("expected a retry in 2s; got: %v, %v", wait, ok)
+	}
+
+	// Waits longer than MaxBackoff are left to the caller.
+	resp.Header.Set("Retry-After", "3600")
+	if wait, ok := p.next("GET", 1, resp, nil); ok {
+		t.Errorf("expected no retry; got one in %v", wait)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for attempt, max := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		3: 400 * time.Millisecond,
+		6: time.Second,
+	} {
+		wait := p.backoff(attempt)
+		if wait < max/2 || wait > max {
+			t.Errorf("attempt %d: expected backoff between %v and %v; got: %v", attempt, max/2, max, wait)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package gapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
 )
 
 // SearchTeam represents a search for a Grafana team.
@@ -46,18 +48,24 @@ type Preferences struct {
 }
 
 // SearchTeam searches Grafana teams and returns the results.
+// All pages of results are fetched.
 func (c *Client) SearchTeam(query string) (*SearchTeam, error) {
-	var result SearchTeam
+	return c.SearchTeamContext(context.Background(), query)
+}
 
-	page := "1"
-	perPage := "1000"
-	path := "/api/teams/search"
-	queryValues := url.Values{}
-	queryValues.Set("page", page)
-	queryValues.Set("perPage", perPage)
-	queryValues.Set("query", query)
+// SearchTeamContext is like SearchTeam but takes a context.
+func (c *Client) SearchTeamContext(ctx context.Context, query string) (*SearchTeam, error) {
+	result := SearchTeam{
+		Teams:   make([]*Team, 0),
+		Page:    1,
+		PerPage: int64(c.perPage()),
+	}
 
-	err := c.request("GET", path, queryValues, nil, &result)
+	err := c.forEachTeamPage(ctx, query, func(page *SearchTeam) error {
+		result.TotalCount = page.TotalCount
+		result.Teams = append(result.Teams, page.Teams...)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -65,10 +73,56 @@ func (c *Client) SearchTeam(query string) (*SearchTeam, error) {
 	return &result, nil
 }
 
+// ForEachTeam calls fn for each Grafana team matching the query, walking all
+// pages of results. Return ErrStopPaging from fn to stop early.
+func (c *Client) ForEachTeam(query string, fn func(*Team) error) error {
+	return c.ForEachTeamContext(context.Background(), query, fn)
+}
+
+// ForEachTeamContext is like ForEachTeam but takes a context.
+func (c *Client) ForEachTeamContext(ctx context.Context, query string, fn func(*Team) error) error {
+	return c.forEachTeamPage(ctx, query, func(page *SearchTeam) error {
+		for _, team := range page.Teams {
+			if err := fn(team); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (c *Client) forEachTeamPage(ctx context.Context, query string, fn func(*SearchTeam) error) error {
+	perPage := c.perPage()
+	path := "/api/teams/search"
+
+	return paginate(ctx, func(page int) (bool, error) {
+		queryValues := url.Values{}
+		queryValues.Set("page", strconv.Itoa(page))
+		queryValues.Set("perPage", strconv.Itoa(perPage))
+		queryValues.Set("query", query)
+
+		var result SearchTeam
+		err := c.request(ctx, "GET", path, queryValues, nil, &result)
+		if err != nil {
+			return false, err
+		}
+		if err := fn(&result); err != nil {
+			return false, err
+		}
+
+		return morePages(page, perPage, len(result.Teams), result.TotalCount), nil
+	})
+}
+
 // Team fetches and returns the Grafana team whose ID it's passed.
 func (c *Client) Team(id int64) (*Team, error) {
+	return c.TeamContext(context.Background(), id)
+}
+
+// TeamContext is like Team but takes a context.
+func (c *Client) TeamContext(ctx context.Context, id int64) (*Team, error) {
 	team := &Team{}
-	err := c.request("GET", fmt.Sprintf("/api/teams/%d", id), nil, nil, team)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/teams/%d", id), nil, nil, team)
 	if err != nil {
 		return nil, err
 	}
@@ -80,6 +134,11 @@ func (c *Client) Team(id int64) (*Team, error) {
 // email arg is an optional value.
 // If you don't want to set email, please set "" (empty string).
 func (c *Client) AddTeam(name string, email string) error {
+	return c.AddTeamContext(context.Background(), name, email)
+}
+
+// AddTeamContext is like AddTeam but takes a context.
+func (c *Client) AddTeamContext(ctx context.Context, name string, email string) error {
 	path := fmt.Sprintf("/api/teams")
 	team := Team{
 		Name:  name,
@@ -90,11 +149,16 @@ func (c *Client) AddTeam(name string, email string) error {
 		return err
 	}
 
-	return c.request("POST", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "POST", path, nil, bytes.NewBuffer(data), nil)
 }
 
 // UpdateTeam updates a Grafana team.
 func (c *Client) UpdateTeam(id int64, name string, email string) error {
+	return c.UpdateTeamContext(context.Background(), id, name, email)
+}
+
+// UpdateTeamContext is like UpdateTeam but takes a context.
+func (c *Client) UpdateTeamContext(ctx context.Context, id int64, name string, email string) error {
 	path := fmt.Sprintf("/api/teams/%d", id)
 	team := Team{
 		Name: name,
@@ -108,18 +172,28 @@ func (c *Client) UpdateTeam(id int64, name string, email string) error {
 		return err
 	}
 
-	return c.request("PUT", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), nil)
 }
 
 // DeleteTeam deletes the Grafana team whose ID it's passed.
 func (c *Client) DeleteTeam(id int64) error {
-	return c.request("DELETE", fmt.Sprintf("/api/teams/%d", id), nil, nil, nil)
+	return c.DeleteTeamContext(context.Background(), id)
+}
+
+// DeleteTeamContext is like DeleteTeam but takes a context.
+func (c *Client) DeleteTeamContext(ctx context.Context, id int64) error {
+	return c.request(ctx, "DELETE", fmt.Sprintf("/api/teams/%d", id), nil, nil, nil)
 }
 
 // TeamMembers fetches and returns the team members for the Grafana team whose ID it's passed.
 func (c *Client) TeamMembers(id int64) ([]*TeamMember, error) {
+	return c.TeamMembersContext(context.Background(), id)
+}
+
+// TeamMembersContext is like TeamMembers but takes a context.
+func (c *Client) TeamMembersContext(ctx context.Context, id int64) ([]*TeamMember, error) {
 	members := make([]*TeamMember, 0)
-	err := c.request("GET", fmt.Sprintf("/api/teams/%d/members", id), nil, nil, &members)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/teams/%d/members", id), nil, nil, &members)
 	if err != nil {
 		return members, err
 	}
@@ -129,6 +203,11 @@ func (c *Client) TeamMembers(id int64) ([]*TeamMember, error) {
 
 // AddTeamMember adds a user to the Grafana team whose ID it's passed.
 func (c *Client) AddTeamMember(id int64, userID int64) error {
+	return c.AddTeamMemberContext(context.Background(), id, userID)
+}
+
+// AddTeamMemberContext is like AddTeamMember but takes a context.
+func (c *Client) AddTeamMemberContext(ctx context.Context, id int64, userID int64) error {
 	path := fmt.Sprintf("/api/teams/%d/members", id)
 	member := TeamMember{UserId: userID}
 	data, err := json.Marshal(member)
@@ -136,20 +215,30 @@ func (c *Client) AddTeamMember(id int64, userID int64) error {
 		return err
 	}
 
-	return c.request("POST", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "POST", path, nil, bytes.NewBuffer(data), nil)
 }
 
 // RemoveMemberFromTeam removes a user from the Grafana team whose ID it's passed.
 func (c *Client) RemoveMemberFromTeam(id int64, userID int64) error {
+	return c.RemoveMemberFromTeamContext(context.Background(), id, userID)
+}
+
+// RemoveMemberFromTeamContext is like RemoveMemberFromTeam but takes a context.
+func (c *Client) RemoveMemberFromTeamContext(ctx context.Context, id int64, userID int64) error {
 	path := fmt.Sprintf("/api/teams/%d/members/%d", id, userID)
 
-	return c.request("DELETE", path, nil, nil, nil)
+	return c.request(ctx, "DELETE", path, nil, nil, nil)
 }
 
 // TeamPreferences fetches and returns preferences for the Grafana team whose ID it's passed.
 func (c *Client) TeamPreferences(id int64) (*Preferences, error) {
+	return c.TeamPreferencesContext(context.Background(), id)
+}
+
+// TeamPreferencesContext is like TeamPreferences but takes a context.
+func (c *Client) TeamPreferencesContext(ctx context.Context, id int64) (*Preferences, error) {
 	preferences := &Preferences{}
-	err := c.request("GET", fmt.Sprintf("/api/teams/%d/preferences", id), nil, nil, preferences)
+	err := c.request(ctx, "GET", fmt.Sprintf("/api/teams/%d/preferences", id), nil, nil, preferences)
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +248,12 @@ func (c *Client) TeamPreferences(id int64) (*Preferences, error) {
 
 // UpdateTeamPreferences updates team preferences for the Grafana team whose ID it's passed.
 func (c *Client) UpdateTeamPreferences(id int64, theme string, homeDashboardID int64, timezone string) error {
-	path := fmt.Sprintf("/api/teams/%d", id)
+	return c.UpdateTeamPreferencesContext(context.Background(), id, theme, homeDashboardID, timezone)
+}
+
+// UpdateTeamPreferencesContext is like UpdateTeamPreferences but takes a context.
+func (c *Client) UpdateTeamPreferencesContext(ctx context.Context, id int64, theme string, homeDashboardID int64, timezone string) error {
+	path := fmt.Sprintf("/api/teams/%d/preferences", id)
 	preferences := Preferences{
 		Theme:           theme,
 		HomeDashboardId: homeDashboardID,
@@ -170,5 +264,5 @@ func (c *Client) UpdateTeamPreferences(id int64, theme string, homeDashboardID i
 		return err
 	}
 
-	return c.request("PUT", path, nil, bytes.NewBuffer(data), nil)
+	return c.request(ctx, "PUT", path, nil, bytes.NewBuffer(data), nil)
 }
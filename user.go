@@ -1,7 +1,9 @@
 package gapi
 
 import (
+	"context"
 	"net/url"
+	"strconv"
 )
 
 // User represents a Grafana user.
@@ -16,8 +18,28 @@ type User struct {
 
 // Users fetches and returns Grafana users.
 func (c *Client) Users() ([]User, error) {
+	return c.UsersContext(context.Background())
+}
+
+// UsersContext is like Users but takes a context.
+func (c *Client) UsersContext(ctx context.Context) ([]User, error) {
 	users := make([]User, 0)
-	err := c.request("GET", "/api/users", nil, nil, &users)
+	perPage := c.perPage()
+
+	err := paginate(ctx, func(page int) (bool, error) {
+		query := url.Values{}
+		query.Set("perpage", strconv.Itoa(perPage))
+		query.Set("page", strconv.Itoa(page))
+
+		result := make([]User, 0)
+		err := c.request(ctx, "GET", "/api/users", query, nil, &result)
+		if err != nil {
+			return false, err
+		}
+		users = append(users, result...)
+
+		return morePages(page, perPage, len(result), 0), nil
+	})
 	if err != nil {
 		return users, err
 	}
@@ -25,8 +47,48 @@ func (c *Client) Users() ([]User, error) {
 	return users, err
 }
 
+// ForEachUser calls fn for each Grafana user matching the query, walking all
+// pages of results. Return ErrStopPaging from fn to stop early.
+func (c *Client) ForEachUser(query string, fn func(User) error) error {
+	return c.ForEachUserContext(context.Background(), query, fn)
+}
+
+// ForEachUserContext is like ForEachUser but takes a context.
+func (c *Client) ForEachUserContext(ctx context.Context, query string, fn func(User) error) error {
+	perPage := c.perPage()
+
+	return paginate(ctx, func(page int) (bool, error) {
+		queryValues := url.Values{}
+		queryValues.Set("perpage", strconv.Itoa(perPage))
+		queryValues.Set("page", strconv.Itoa(page))
+		queryValues.Set("query", query)
+
+		result := struct {
+			TotalCount int64  `json:"totalCount"`
+			Users      []User `json:"users"`
+		}{}
+		err := c.request(ctx, "GET", "/api/users/search", queryValues, nil, &result)
+		if err != nil {
+			return false, err
+		}
+
+		for _, user := range result.Users {
+			if err := fn(user); err != nil {
+				return false, err
+			}
+		}
+
+		return morePages(page, perPage, len(result.Users), result.TotalCount), nil
+	})
+}
+
 // UserByEmail fetches and returns the user whose email matches that passed.
 func (c *Client) UserByEmail(email string) (User, error) {
+	return c.UserByEmailContext(context.Background(), email)
+}
+
+// UserByEmailContext is like UserByEmail but takes a context.
+func (c *Client) UserByEmailContext(ctx context.Context, email string) (User, error) {
 	user := User{}
 	query := url.Values{}
 	query.Add("loginOrEmail", email)
@@ -39,7 +101,7 @@ func (c *Client) UserByEmail(email string) (User, error) {
 		IsAdmin  bool   `json:"isGrafanaAdmin,omitempty"`
 	}{}
 
-	err := c.request("GET", "/api/users/lookup", query, nil, &tmp)
+	err := c.request(ctx, "GET", "/api/users/lookup", query, nil, &tmp)
 	if err != nil {
 		return user, err
 	}